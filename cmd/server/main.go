@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/news-reader/internal/handlers"
@@ -23,9 +24,17 @@ var (
 func main() {
 	// Command line flags
 	var (
-		port      = flag.Int("port", 8082, "Server port")
-		prefsFile = flag.String("prefs", "preferences.json", "Path to preferences file")
-		debug     = flag.Bool("debug", true, "Enable debug mode")
+		port             = flag.Int("port", 8082, "Server port")
+		prefsFile        = flag.String("prefs", "preferences.json", "Path to preferences file")
+		debug            = flag.Bool("debug", true, "Enable debug mode")
+		trendingInterval = flag.Duration("trending-interval", 15*time.Minute, "How often to record trending history (0 disables)")
+		refreshInterval  = flag.Duration("refresh-interval", 5*time.Minute, "How often to fetch news for stream clients (0 disables)")
+		pushSubject      = flag.String("push-subject", "", "Contact URL (mailto: or https:) given to Web Push services")
+		digestInterval   = flag.Duration("digest-interval", time.Minute, "How often to check for digests that are due (0 disables)")
+		smtpAddr         = flag.String("smtp-addr", "", "SMTP server (host:port) digests are mailed through")
+		smtpFrom         = flag.String("smtp-from", "news-reader@localhost", "Sender address of mailed digests")
+		smtpUser         = flag.String("smtp-user", "", "SMTP username; the password is read from SMTP_PASSWORD")
+		digestDir        = flag.String("digest-dir", "digests", "Directory digest files are written under")
 	)
 	flag.Parse()
 
@@ -46,6 +55,38 @@ func main() {
 		log.Fatalf("Failed to initialize news service: %v", err)
 	}
 
+	if *pushSubject != "" {
+		if err := newsService.SetPushSubject(*pushSubject); err != nil {
+			log.Fatalf("Invalid push subject: %v", err)
+		}
+	}
+
+	newsService.SetMailer(services.Mailer{
+		Addr:     *smtpAddr,
+		From:     *smtpFrom,
+		Username: *smtpUser,
+		Password: os.Getenv("SMTP_PASSWORD"),
+	})
+	newsService.SetDigestDir(*digestDir)
+
+	// Record trending history in the background
+	if *trendingInterval > 0 {
+		stop := newsService.StartTrendingSnapshots(*trendingInterval)
+		defer stop()
+	}
+
+	// Fetch news in the background so /api/stream has something to push
+	if *refreshInterval > 0 {
+		stop := newsService.StartRefresh(*refreshInterval)
+		defer stop()
+	}
+
+	// Send scheduled digests in the background
+	if *digestInterval > 0 {
+		stop := newsService.StartDigests(*digestInterval)
+		defer stop()
+	}
+
 	// Initialize handlers
 	newsHandler := handlers.NewNewsHandler(newsService)
 
@@ -69,12 +110,61 @@ func setupRoutes(r *gin.Engine, newsHandler *handlers.NewsHandler) {
 	{
 		api.GET("/news", newsHandler.GetNews)
 		api.GET("/news/trending", newsHandler.GetTrendingTopicsHandler)
+		api.GET("/news/geo", newsHandler.GetNewsGeo)
+		api.GET("/trending/history", newsHandler.GetTrendingHistory)
+		api.GET("/trending/peaks", newsHandler.GetTrendingPeaks)
+		api.GET("/breaking", newsHandler.GetBreaking)
+		api.GET("/stream", newsHandler.Stream)
+		api.GET("/sources/health", newsHandler.GetSourceHealth)
+		api.GET("/sources/discover", newsHandler.DiscoverFeeds)
+		api.POST("/sources/test", newsHandler.TestSource)
+		api.GET("/entities", newsHandler.GetEntities)
+		api.GET("/follows", newsHandler.GetFollows)
+		api.POST("/follows", newsHandler.CreateFollow)
+		api.DELETE("/follows/:id", newsHandler.DeleteFollow)
+		api.GET("/follows/:id/timeline", newsHandler.GetFollowTimeline)
+		api.POST("/follows/:id/seen", newsHandler.MarkFollowSeen)
+		api.GET("/alerts", newsHandler.GetAlertRules)
+		api.POST("/alerts", newsHandler.CreateAlertRule)
+		api.GET("/alerts/deliveries", newsHandler.GetDeliveries)
+		api.PUT("/alerts/:id", newsHandler.UpdateAlertRule)
+		api.DELETE("/alerts/:id", newsHandler.DeleteAlertRule)
+		api.POST("/alerts/:id/test", newsHandler.TestAlertRule)
+		api.GET("/push/key", newsHandler.GetPushKey)
+		api.GET("/push/subscriptions", newsHandler.GetPushSubscriptions)
+		api.POST("/push/subscriptions", newsHandler.CreatePushSubscription)
+		api.DELETE("/push/subscriptions/:id", newsHandler.DeletePushSubscription)
+		api.POST("/push/subscriptions/:id/test", newsHandler.TestPushSubscription)
+		api.GET("/digests", newsHandler.GetDigests)
+		api.POST("/digests", newsHandler.CreateDigest)
+		api.PUT("/digests/:id", newsHandler.UpdateDigest)
+		api.DELETE("/digests/:id", newsHandler.DeleteDigest)
+		api.GET("/digests/:id/preview", newsHandler.PreviewDigest)
+		api.POST("/digests/:id/send", newsHandler.SendDigest)
 		api.GET("/version", newsHandler.GetVersionHandler)
 		api.GET("/tags", newsHandler.GetTags)
 		api.POST("/tags", newsHandler.CreateTag)
+		api.PUT("/tags/:id", newsHandler.UpdateTag)
+		api.POST("/tags/preview", newsHandler.PreviewTag)
+		api.GET("/tags/suggestions", newsHandler.GetSuggestions)
+		api.POST("/tags/suggestions/accept", newsHandler.AcceptSuggestion)
+		api.POST("/tags/suggestions/reject", newsHandler.RejectSuggestion)
+		api.POST("/tags/retrain", newsHandler.RetrainClassifier)
 		api.PUT("/preferences", newsHandler.UpdatePreferences)
 		api.GET("/preferences", newsHandler.GetPreferences)
 		api.POST("/news/:id/tags", newsHandler.UpdateNewsTags)
+		api.POST("/news/state/:state", newsHandler.SetItemState)
+		api.POST("/news/read-all", newsHandler.MarkAllRead)
+	}
+
+	// Output feeds of filtered views, for other readers to subscribe to
+	feeds := r.Group("/feeds")
+	{
+		feeds.GET("/tag/:file", newsHandler.GetTagFeed)
+		feeds.GET("/category/:file", newsHandler.GetCategoryFeed)
+		feeds.GET("/search.rss", newsHandler.GetSearchFeed)
+		feeds.GET("/search.atom", newsHandler.GetSearchFeed)
+		feeds.GET("/search.json", newsHandler.GetSearchFeed)
 	}
 
 	// Serve static files
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/services"
+)
+
+// GetAlertRules lists the alert rules.
+func (h *NewsHandler) GetAlertRules(c *gin.Context) {
+	rules := h.newsService.AlertRules()
+	c.JSON(http.StatusOK, gin.H{
+		"alerts": rules,
+		"count":  len(rules),
+	})
+}
+
+// CreateAlertRule adds an alert rule posting the fetched items matching its
+// expression to its webhook.
+func (h *NewsHandler) CreateAlertRule(c *gin.Context) {
+	var rule models.AlertRule
+	if err := c.BindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.newsService.CreateAlertRule(rule)
+	if err != nil {
+		c.JSON(alertErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// UpdateAlertRule replaces the alert rule named by the :id path parameter.
+func (h *NewsHandler) UpdateAlertRule(c *gin.Context) {
+	var rule models.AlertRule
+	if err := c.BindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.newsService.UpdateAlertRule(c.Param("id"), rule)
+	if err != nil {
+		c.JSON(alertErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteAlertRule removes the alert rule named by the :id path parameter.
+func (h *NewsHandler) DeleteAlertRule(c *gin.Context) {
+	if err := h.newsService.DeleteAlertRule(c.Param("id")); err != nil {
+		c.JSON(alertErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": c.Param("id")})
+}
+
+// TestAlertRule sends the rule's webhook the latest matching items once and
+// returns how the delivery went.
+func (h *NewsHandler) TestAlertRule(c *gin.Context) {
+	delivery, err := h.newsService.TestAlertRule(c.Param("id"))
+	if err != nil {
+		c.JSON(alertErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// GetDeliveries returns the webhook delivery log, newest first, optionally
+// limited to the rule given by the rule parameter.
+func (h *NewsHandler) GetDeliveries(c *gin.Context) {
+	deliveries := h.newsService.Deliveries(c.Query("rule"))
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	})
+}
+
+func alertErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrAlertRuleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidAlertRule):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
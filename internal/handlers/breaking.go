@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBreaking returns the stories many sources started covering at once,
+// most severe first. Events whose surge is over are included when all=1.
+func (h *NewsHandler) GetBreaking(c *gin.Context) {
+	// Fetching runs detection over the latest items
+	h.newsService.FetchNews()
+
+	events := h.newsService.BreakingEvents(c.Query("all") == "1")
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"count":  len(events),
+	})
+}
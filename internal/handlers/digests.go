@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/services"
+)
+
+// GetDigests lists the scheduled digests.
+func (h *NewsHandler) GetDigests(c *gin.Context) {
+	digests := h.newsService.Digests()
+	c.JSON(http.StatusOK, gin.H{
+		"digests": digests,
+		"count":   len(digests),
+	})
+}
+
+// CreateDigest schedules a daily or weekly digest mailed to the recipients
+// in to, written to directory under the server's digest directory, or both.
+func (h *NewsHandler) CreateDigest(c *gin.Context) {
+	var digest models.Digest
+	if err := c.BindJSON(&digest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	digest, err := h.newsService.CreateDigest(digest)
+	if err != nil {
+		c.JSON(digestErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, digest)
+}
+
+// UpdateDigest replaces the digest named by the :id path parameter.
+func (h *NewsHandler) UpdateDigest(c *gin.Context) {
+	var digest models.Digest
+	if err := c.BindJSON(&digest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	digest, err := h.newsService.UpdateDigest(c.Param("id"), digest)
+	if err != nil {
+		c.JSON(digestErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, digest)
+}
+
+// DeleteDigest removes the digest named by the :id path parameter.
+func (h *NewsHandler) DeleteDigest(c *gin.Context) {
+	if err := h.newsService.DeleteDigest(c.Param("id")); err != nil {
+		c.JSON(digestErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": c.Param("id")})
+}
+
+// PreviewDigest shows what the digest would contain if sent now, rendered
+// as html or markdown, or as json when format asks for it.
+func (h *NewsHandler) PreviewDigest(c *gin.Context) {
+	content, err := h.newsService.BuildDigest(c.Param("id"), h.newsService.FetchNews(), time.Now())
+	if err != nil {
+		c.JSON(digestErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	format := c.DefaultQuery("format", content.Digest.Format)
+	if format == "json" {
+		c.JSON(http.StatusOK, content)
+		return
+	}
+	data, err := services.RenderDigest(content, format)
+	if err != nil {
+		c.JSON(digestErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	contentType := "text/html; charset=utf-8"
+	if format == models.DigestMarkdown {
+		contentType = "text/markdown; charset=utf-8"
+	}
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// SendDigest delivers the digest now, whatever its schedule.
+func (h *NewsHandler) SendDigest(c *gin.Context) {
+	digest, err := h.newsService.SendDigest(c.Param("id"), h.newsService.FetchNews())
+	if err != nil {
+		c.JSON(digestErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, digest)
+}
+
+func digestErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrDigestNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidDigest):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrMailNotConfigured), errors.Is(err, services.ErrDigestDirNotConfigured):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
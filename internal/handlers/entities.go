@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/news-reader/internal/models"
+)
+
+// GetEntities returns the people, organizations, places and tickers named in
+// the filtered news, each with its counts, sources and items, most covered
+// first. It accepts the filters of GetNews, plus type to keep one kind of
+// entity, id to return a single entity and limit to cap the list.
+func (h *NewsHandler) GetEntities(c *gin.Context) {
+	entityType := c.Query("type")
+	switch entityType {
+	case "", models.EntityPerson, models.EntityOrg, models.EntityPlace, models.EntityTicker:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be person, org, place or ticker"})
+		return
+	}
+
+	limit := 50
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+			return
+		}
+		limit = n
+	}
+
+	items, _, err := h.filteredNews(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entities := h.newsService.EntitySummaries(items, entityType)
+	if id := c.Query("id"); id != "" {
+		for _, entity := range entities {
+			if entity.ID == id {
+				c.JSON(http.StatusOK, entity)
+				return
+			}
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "entity not found"})
+		return
+	}
+
+	total := len(entities)
+	if len(entities) > limit {
+		entities = entities[:limit]
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"entities": entities,
+		"count":    len(entities),
+		"total":    total,
+	})
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/services"
+)
+
+const (
+	defaultFeedItems = 50
+	maxFeedItems     = 200
+)
+
+// GetTagFeed publishes the items tagged with the tag named by the :file path
+// parameter, whose extension (.rss, .atom or .json) picks the format.
+func (h *NewsHandler) GetTagFeed(c *gin.Context) {
+	id, format, ok := feedFile(c)
+	if !ok {
+		return
+	}
+	tag, found := h.newsService.FindTag(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("tag not found: %s", id)})
+		return
+	}
+
+	items := services.NewsWithTag(h.followedNews(), tag.ID)
+	h.writeFeed(c, services.OutputFeed{
+		Title:       "News tagged " + tag.Name,
+		Description: fmt.Sprintf("Items tagged %s", tag.Name),
+	}, items, format)
+}
+
+// GetCategoryFeed publishes the items in the category named by the :file path
+// parameter, whose extension picks the format.
+func (h *NewsHandler) GetCategoryFeed(c *gin.Context) {
+	category, format, ok := feedFile(c)
+	if !ok {
+		return
+	}
+
+	items := []models.NewsItem{}
+	for _, item := range h.followedNews() {
+		if item.HasCategory(category) {
+			items = append(items, item)
+		}
+	}
+	h.writeFeed(c, services.OutputFeed{
+		Title:       "News in " + category,
+		Description: fmt.Sprintf("Items in the %s category", category),
+	}, items, format)
+}
+
+// GetSearchFeed publishes the items matching the q query parameter, in the
+// format given by the extension of the path.
+func (h *NewsHandler) GetSearchFeed(c *gin.Context) {
+	format, ok := feedFormat(path.Ext(c.Request.URL.Path))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feeds are served as .rss, .atom or .json"})
+		return
+	}
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+
+	items := h.newsService.SearchNews(h.followedNews(), query)
+	h.writeFeed(c, services.OutputFeed{
+		Title:       fmt.Sprintf("News matching %q", query),
+		Description: fmt.Sprintf("Items matching the search %q", query),
+	}, items, format)
+}
+
+// writeFeed renders the newest items, limit of them (50 by default, at most
+// 200), with an ETag of the body, so readers polling the feed get 304 Not
+// Modified while nothing changes.
+func (h *NewsHandler) writeFeed(c *gin.Context, feed services.OutputFeed, items []models.NewsItem, format string) {
+	limit := defaultFeedItems
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxFeedItems {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be a number from 1 to %d", maxFeedItems)})
+			return
+		}
+		limit = n
+	}
+
+	base := requestScheme(c) + "://" + c.Request.Host
+	feed.SelfURL = base + c.Request.URL.RequestURI()
+	feed.HomeURL = base + "/"
+
+	body, err := services.RenderFeed(feed, services.NewestItems(items, limit), format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Header("ETag", etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, services.FeedContentTypes[format], body)
+}
+
+// feedFile splits the :file path parameter into the name and the format its
+// extension gives, answering 404 if the extension is not a feed format.
+func feedFile(c *gin.Context) (string, string, bool) {
+	file := c.Param("file")
+	ext := path.Ext(file)
+	format, ok := feedFormat(ext)
+	name := strings.TrimSuffix(file, ext)
+	if !ok || name == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "feeds are served as .rss, .atom or .json"})
+		return "", "", false
+	}
+	return name, format, true
+}
+
+func feedFormat(ext string) (string, bool) {
+	switch strings.ToLower(ext) {
+	case ".rss", ".xml":
+		return services.FeedRSS, true
+	case ".atom":
+		return services.FeedAtom, true
+	case ".json":
+		return services.FeedJSON, true
+	default:
+		return "", false
+	}
+}
+
+// requestScheme returns the scheme the client used, trusting the
+// X-Forwarded-Proto header of a proxy in front of the server.
+func requestScheme(c *gin.Context) string {
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		return proto
+	}
+	if c.Request.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/services"
+)
+
+// GetFollows lists the followed clusters, entities, searches and tags with
+// how many current items each matches and how many of those are new.
+func (h *NewsHandler) GetFollows(c *gin.Context) {
+	follows := h.newsService.FollowSummaries(h.followedNews())
+	c.JSON(http.StatusOK, gin.H{
+		"follows": follows,
+		"count":   len(follows),
+	})
+}
+
+// CreateFollow starts following the cluster, entity, search or tag given as
+// type and target in the body.
+func (h *NewsHandler) CreateFollow(c *gin.Context) {
+	var follow models.Follow
+	if err := c.BindJSON(&follow); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	follow, err := h.newsService.CreateFollow(follow, h.followedNews())
+	if err != nil {
+		c.JSON(followErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, follow)
+}
+
+// DeleteFollow stops following the follow named by the :id path parameter.
+func (h *NewsHandler) DeleteFollow(c *gin.Context) {
+	if err := h.newsService.DeleteFollow(c.Param("id")); err != nil {
+		c.JSON(followErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": c.Param("id")})
+}
+
+// GetFollowTimeline returns every current item the follow matches across all
+// sources, oldest first, with the items published since it was last marked
+// seen flagged as new.
+func (h *NewsHandler) GetFollowTimeline(c *gin.Context) {
+	timeline, err := h.newsService.FollowTimeline(c.Param("id"), h.followedNews())
+	if err != nil {
+		c.JSON(followErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, timeline)
+}
+
+// MarkFollowSeen records that the follow's timeline has been looked at, so
+// only later items count as new.
+func (h *NewsHandler) MarkFollowSeen(c *gin.Context) {
+	follow, err := h.newsService.MarkFollowSeen(c.Param("id"), h.followedNews())
+	if err != nil {
+		c.JSON(followErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, follow)
+}
+
+// followedNews returns the items follows are matched against: every source,
+// whatever the category and interest filters, without hidden items.
+func (h *NewsHandler) followedNews() []models.NewsItem {
+	items := h.newsService.ApplyItemState(h.newsService.FetchNews())
+	visible, _ := h.newsService.FilterByState(items, "")
+	return visible
+}
+
+func followErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrFollowNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidFollow):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -19,14 +21,86 @@ func NewNewsHandler(newsService *services.NewsService) *NewsHandler {
 	}
 }
 
+// GetNews returns the filtered news items together with unread counts per
+// source and category. The optional state query parameter restricts the
+// items to unread, read, starred or hidden ones. Items are ranked by their
+// personalised score unless sort=recent is given; debug=1 adds the score
+// breakdown to every item. With view=clusters the items are grouped into
+// story clusters across sources, each marked with the severity of any
+// breaking event it is part of. The q parameter keeps only items containing
+// every word of the query, matched in each item's language.
 func (h *NewsHandler) GetNews(c *gin.Context) {
+	items, unread, err := h.filteredNews(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch c.DefaultQuery("sort", "score") {
+	case "score":
+		items = h.newsService.RankNews(items, c.Query("debug") == "1")
+	case "recent":
+		items = h.newsService.SortByRecency(items)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be score or recent"})
+		return
+	}
+
+	switch c.DefaultQuery("view", "items") {
+	case "items":
+	case "clusters":
+		clusters := h.newsService.ClusterNews(items)
+		h.newsService.MarkBreaking(clusters)
+		c.JSON(http.StatusOK, gin.H{
+			"clusters": clusters,
+			"count":    len(clusters),
+			"unread":   unread,
+		})
+		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "view must be items or clusters"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":  items,
+		"count":  len(items),
+		"unread": unread,
+	})
+}
+
+// GetNewsGeo returns the places mentioned in the filtered news as a GeoJSON
+// FeatureCollection with one point per place. It accepts the same filters as
+// GetNews.
+func (h *NewsHandler) GetNewsGeo(c *gin.Context) {
+	items, _, err := h.filteredNews(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "application/geo+json")
+	c.JSON(http.StatusOK, h.newsService.GeoFeatures(items))
+}
+
+// filteredNews fetches the news and applies the user's preferences, item
+// state and the query's filters. The unread counts cover every item that
+// passes the preferences, whatever the state filter.
+func (h *NewsHandler) filteredNews(c *gin.Context) ([]models.NewsItem, services.UnreadCounts, error) {
 	news := h.newsService.FetchNews()
 	filteredNews := h.newsService.FilterNews(news)
-	c.JSON(http.StatusOK, filteredNews)
+	filteredNews = h.newsService.ApplyItemState(filteredNews)
+	unread := h.newsService.CountUnread(filteredNews)
+	if query := c.Query("q"); query != "" {
+		filteredNews = h.newsService.SearchNews(filteredNews, query)
+	}
+
+	items, err := h.newsService.FilterByState(filteredNews, c.Query("state"))
+	return items, unread, err
 }
 
 func (h *NewsHandler) GetPreferences(c *gin.Context) {
-	prefs := h.newsService.GetPreferences()
+	prefs := h.newsService.ClientPreferences()
 	c.JSON(http.StatusOK, prefs)
 }
 
@@ -38,11 +112,11 @@ func (h *NewsHandler) UpdatePreferences(c *gin.Context) {
 	}
 
 	if err := h.newsService.UpdatePreferences(newPrefs); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(sourceErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, newPrefs)
+	c.JSON(http.StatusOK, h.newsService.ClientPreferences())
 }
 
 func (h *NewsHandler) GetTags(c *gin.Context) {
@@ -62,7 +136,7 @@ func (h *NewsHandler) CreateTag(c *gin.Context) {
 
 	tag, err := h.newsService.CreateTag(newTag)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(tagErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -85,22 +159,97 @@ func (h *NewsHandler) UpdateNewsTags(c *gin.Context) {
 	c.JSON(http.StatusOK, tags)
 }
 
-// GetTrendingTopicsHandler returns the current trending topics based on recent news
+// GetTrendingTopicsHandler returns the topics rising fastest in recent news.
+// window (1h, 6h or 24h) sets the period that counts as recent and baseline
+// (a duration such as 72h) the period before it that sets the expected
+// frequency. category and language restrict the news, rank=acceleration
+// orders by ratio instead of z-score, and by=category or by=language returns
+// trending topics for every category or language. Results are cached for a
+// minute and carry an ETag, so polling clients get 304 Not Modified while
+// nothing changes.
 func (h *NewsHandler) GetTrendingTopicsHandler(c *gin.Context) {
-	// Get recent news items
-	news := h.newsService.GetAllNews()
+	opts := services.TrendingOptions{
+		Category: c.Query("category"),
+		Language: c.Query("language"),
+		Rank:     c.DefaultQuery("rank", services.TrendingByScore),
+		Now:      time.Now(),
+	}
+
+	window, ok := services.TrendingWindows[c.DefaultQuery("window", "6h")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "window must be 1h, 6h or 24h"})
+		return
+	}
+	opts.Window = window
+
+	if baseline := c.Query("baseline"); baseline != "" {
+		d, err := time.ParseDuration(baseline)
+		if err != nil || d < opts.Window {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "baseline must be a duration no shorter than the window"})
+			return
+		}
+		opts.Baseline = d
+	}
+
+	if opts.Rank != services.TrendingByScore && opts.Rank != services.TrendingByAcceleration {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rank must be score or acceleration"})
+		return
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+			return
+		}
+		opts.Limit = n
+	}
+
+	by := c.Query("by")
+	if by != "" && by != "category" && by != "language" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "by must be category or language"})
+		return
+	}
+
+	// Get trending topics from the index of cached news
+	result := h.newsService.TrendingTopics(opts, by)
 
-	// Get trending topics
-	topics := h.newsService.GetTrendingTopics(news)
+	c.Header("Cache-Control", "private, max-age=60")
+	c.Header("ETag", result.ETag)
+	if etagMatches(c.GetHeader("If-None-Match"), result.ETag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
 
 	// Return trending topics
+	if by != "" {
+		c.JSON(http.StatusOK, gin.H{
+			"groups": result.Groups,
+			"count":  len(result.Groups),
+			"window": c.DefaultQuery("window", "6h"),
+			"time":   result.Time,
+		})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"topics": topics,
-		"count":  len(topics),
-		"time":   time.Now().UTC(),
+		"topics": result.Topics,
+		"count":  len(result.Topics),
+		"window": c.DefaultQuery("window", "6h"),
+		"time":   result.Time,
 	})
 }
 
+// etagMatches reports whether an If-None-Match header lists etag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
 // GetVersionHandler returns the current version information
 func (h *NewsHandler) GetVersionHandler(c *gin.Context) {
 	// Get version information from main package
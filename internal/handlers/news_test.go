@@ -1,10 +1,20 @@
 package handlers
 
 import (
+	"bufio"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/xml"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/news-reader/internal/models"
@@ -66,4 +76,1119 @@ func TestGetTrendingTopicsHandler(t *testing.T) {
 	if response.Time == "" {
 		t.Error("Expected non-empty timestamp")
 	}
+
+	etag := w.Header().Get("ETag")
+	if etag == "" || w.Header().Get("Cache-Control") == "" {
+		t.Errorf("Expected ETag and Cache-Control headers, got %v", w.Header())
+	}
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expected status code %d with no body for a matching ETag, got %d", http.StatusNotModified, w.Code)
+	}
+
+	for _, query := range []string{"?window=2h", "?baseline=30m&window=1h", "?rank=bogus", "?by=source", "?limit=0"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/news/trending"+query, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET /api/news/trending%s: expected status %d, got %d", query, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/news/trending?window=24h&by=category", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"groups"`) {
+		t.Errorf("Expected grouped trending topics, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+const testFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+<channel>
+  <title>Test Feed</title>
+  <link>https://example.com/</link>
+  <description>Test feed</description>
+  <item>
+    <title>Storting passes new budget</title>
+    <link>https://example.com/news/1</link>
+    <description>The government budget was approved.</description>
+    <pubDate>Mon, 02 Jan 2006 15:04:05 GMT</pubDate>
+  </item>
+  <item>
+    <title>Local team wins championship</title>
+    <link>https://example.com/news/2</link>
+    <description>A tournament ended with a local win.</description>
+    <pubDate>Mon, 02 Jan 2006 16:04:05 GMT</pubDate>
+  </item>
+</channel>
+</rss>`
+
+// newTestService creates a news service whose only source is a local feed
+// serving body.
+func newTestService(t *testing.T, body string) *services.NewsService {
+	t.Helper()
+	return newTestServiceFor(t, func() string { return body })
+}
+
+// newTestServiceFor creates a news service whose only source is a local feed
+// serving what body returns at the time of each fetch.
+func newTestServiceFor(t *testing.T, body func() string) *services.NewsService {
+	t.Helper()
+
+	feedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(body()))
+	}))
+	t.Cleanup(feedServer.Close)
+
+	service, err := services.NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+
+	prefs := *service.GetPreferences()
+	prefs.Sources = []models.NewsSource{
+		{Name: "Test", URL: feedServer.URL, Category: "General", ContentType: models.TypeRSS, Enabled: true},
+	}
+	prefs.Categories = nil
+	prefs.ContentTypes = nil
+	if err := service.UpdatePreferences(prefs); err != nil {
+		t.Fatalf("Failed to update preferences: %v", err)
+	}
+	return service
+}
+
+type newsResponse struct {
+	Items  []models.NewsItem     `json:"items"`
+	Count  int                   `json:"count"`
+	Unread services.UnreadCounts `json:"unread"`
+}
+
+func getNews(t *testing.T, r *gin.Engine, query string) newsResponse {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/news"+query, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /api/news%s: expected status %d, got %d: %s", query, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response newsResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return response
+}
+
+func TestItemStateHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	handler := NewNewsHandler(newTestService(t, testFeed))
+	r.GET("/api/news", handler.GetNews)
+	r.POST("/api/news/state/:state", handler.SetItemState)
+	r.POST("/api/news/read-all", handler.MarkAllRead)
+
+	all := getNews(t, r, "")
+	if all.Count != 2 || all.Unread.Total != 2 || all.Unread.BySource["Test"] != 2 {
+		t.Fatalf("Unexpected initial response: %+v", all)
+	}
+
+	body := `{"ids": ["` + all.Items[0].ID + `"]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/news/state/starred", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	starred := getNews(t, r, "?state=starred")
+	if starred.Count != 1 || !starred.Items[0].Starred {
+		t.Errorf("Expected one starred item, got %+v", starred.Items)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/news/read-all", strings.NewReader(`{"category": "General"}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	unread := getNews(t, r, "?state=unread")
+	if unread.Count != 0 || unread.Unread.Total != 0 {
+		t.Errorf("Expected no unread items, got %+v", unread)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/news/state/bogus", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for unknown state, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestGetNewsSearch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	handler := NewNewsHandler(newTestService(t, testFeed))
+	r.GET("/api/news", handler.GetNews)
+
+	found := getNews(t, r, "?q=budgets+approved")
+	if found.Count != 1 || found.Items[0].Title != "Storting passes new budget" {
+		t.Errorf("Expected the budget item, got %+v", found.Items)
+	}
+	if found.Unread.Total != 2 {
+		t.Errorf("Expected unread counts to ignore the search, got %d", found.Unread.Total)
+	}
+
+	if none := getNews(t, r, "?q=budget+championship"); none.Count != 0 {
+		t.Errorf("Expected no item with both words, got %+v", none.Items)
+	}
+}
+
+func TestGetNewsGeo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	feed := strings.Replace(testFeed, "Storting passes new budget", "Storting in Oslo passes new budget", 1)
+	handler := NewNewsHandler(newTestService(t, feed))
+	r.GET("/api/news/geo", handler.GetNewsGeo)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/news/geo", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/geo+json" {
+		t.Errorf("Expected GeoJSON content type, got %q", ct)
+	}
+
+	var collection models.FeatureCollection
+	if err := json.NewDecoder(w.Body).Decode(&collection); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 2 {
+		t.Fatalf("Expected features for Oslo and Norway, got %+v", collection)
+	}
+	for _, feature := range collection.Features {
+		if len(feature.Properties.Items) != 1 || feature.Properties.Items[0].Title != "Storting in Oslo passes new budget" {
+			t.Errorf("Unexpected items for %s: %+v", feature.Properties.ID, feature.Properties.Items)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/news/geo?state=bogus", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for unknown state, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestTagRuleHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	handler := NewNewsHandler(newTestService(t, testFeed))
+	r.GET("/api/news", handler.GetNews)
+	r.PUT("/api/tags/:id", handler.UpdateTag)
+	r.POST("/api/tags/preview", handler.PreviewTag)
+
+	// Fill the cache
+	getNews(t, r, "")
+
+	body := `{"name": "Budget", "rules": [{"keywords": ["budget"]}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/tags/preview", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var preview newsResponse
+	if err := json.NewDecoder(w.Body).Decode(&preview); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if preview.Count != 1 || preview.Items[0].Title != "Storting passes new budget" {
+		t.Errorf("Expected the budget item only, got %+v", preview.Items)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{name: "Bad pattern", method: http.MethodPost, path: "/api/tags/preview", body: `{"rules": [{"patterns": ["("]}]}`, status: http.StatusBadRequest},
+		{name: "Unknown tag", method: http.MethodPut, path: "/api/tags/missing", body: `{"rules": []}`, status: http.StatusNotFound},
+		{name: "System tag", method: http.MethodPut, path: "/api/tags/sports", body: `{"rules": [{"keywords": ["fotball"]}]}`, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("Expected status code %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSuggestionHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	service := newTestService(t, testFeed)
+	handler := NewNewsHandler(service)
+	r.GET("/api/news", handler.GetNews)
+	r.GET("/api/tags/suggestions", handler.GetSuggestions)
+	r.POST("/api/tags/suggestions/accept", handler.AcceptSuggestion)
+	r.POST("/api/tags/suggestions/reject", handler.RejectSuggestion)
+	r.POST("/api/tags/retrain", handler.RetrainClassifier)
+
+	all := getNews(t, r, "")
+	tag, err := service.CreateTag(models.Tag{Name: "Budgets"})
+	if err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{name: "List", method: http.MethodGet, path: "/api/tags/suggestions", status: http.StatusOK},
+		{name: "Accept", method: http.MethodPost, path: "/api/tags/suggestions/accept", body: `{"newsId": "` + all.Items[0].ID + `", "tagId": "` + tag.ID + `"}`, status: http.StatusOK},
+		{name: "Reject", method: http.MethodPost, path: "/api/tags/suggestions/reject", body: `{"newsId": "` + all.Items[1].ID + `", "tagId": "` + tag.ID + `"}`, status: http.StatusOK},
+		{name: "Unknown item", method: http.MethodPost, path: "/api/tags/suggestions/accept", body: `{"newsId": "missing", "tagId": "` + tag.ID + `"}`, status: http.StatusNotFound},
+		{name: "Unknown tag", method: http.MethodPost, path: "/api/tags/suggestions/reject", body: `{"newsId": "` + all.Items[0].ID + `", "tagId": "missing"}`, status: http.StatusNotFound},
+		{name: "Retrain", method: http.MethodPost, path: "/api/tags/retrain", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("Expected status code %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	var tagged bool
+	for _, nt := range service.GetPreferences().NewsTags {
+		if nt.NewsID == all.Items[0].ID && nt.TagID == tag.ID {
+			tagged = true
+		}
+	}
+	if !tagged {
+		t.Error("Expected the accepted suggestion to be saved as a manual tag")
+	}
+}
+
+func TestTrendingHistoryHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	service := newTestService(t, testFeed)
+	now := time.Now()
+	items := []models.NewsItem{
+		{ID: "1", Title: "Budget talks resume", Language: "english", Published: now.Add(-time.Hour)},
+		{ID: "2", Title: "Budget talks stall", Language: "english", Published: now.Add(-time.Hour)},
+	}
+	if err := service.RecordTrendingHistory(items, now); err != nil {
+		t.Fatalf("Failed to record trending history: %v", err)
+	}
+
+	handler := NewNewsHandler(service)
+	r.GET("/api/trending/history", handler.GetTrendingHistory)
+	r.GET("/api/trending/peaks", handler.GetTrendingPeaks)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/trending/history?term=budget&interval=day", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var series services.TrendSeries
+	if err := json.NewDecoder(w.Body).Decode(&series); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if series.Total != 4 || len(series.Points) < 7 {
+		t.Errorf("Expected a week of daily points totalling 4, got %+v", series)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/trending/peaks", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var peaks struct {
+		Peaks []services.TrendPeak `json:"peaks"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&peaks); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(peaks.Peaks) != 1 || peaks.Peaks[0].Topic != "budget talks" {
+		t.Errorf("Expected budget talks to peak, got %+v", peaks.Peaks)
+	}
+
+	for _, path := range []string{
+		"/api/trending/history",
+		"/api/trending/history?term=budget&interval=week",
+		"/api/trending/history?term=budget&from=2026-01-02&to=2026-01-01",
+		"/api/trending/peaks?from=yesterday",
+		"/api/trending/peaks?from=2020-01-01&to=2026-01-01",
+		"/api/trending/peaks?limit=-1",
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: expected status %d, got %d", path, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	// Periods older than the history is kept are rejected, not returned empty
+	from := now.Add(-services.TrendRetention - 24*time.Hour)
+	req = httptest.NewRequest(http.MethodGet, "/api/trending/history?term=budget&interval=day&from="+from.Format("2006-01-02")+"&to="+from.AddDate(0, 0, 7).Format("2006-01-02"), nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "90 days ago") {
+		t.Errorf("Expected status %d for a period past retention, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+}
+
+func TestGetEntities(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	feed := strings.Replace(testFeed, "The government budget was approved.", "Prime Minister Jonas Gahr Støre said Equinor will pay more tax.", 1)
+	feed = strings.Replace(feed, "A tournament ended with a local win.", "Shares in Equinor fell.", 1)
+	handler := NewNewsHandler(newTestService(t, feed))
+	r.GET("/api/entities", handler.GetEntities)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/entities?type=org", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var response struct {
+		Entities []services.EntitySummary `json:"entities"`
+		Count    int                      `json:"count"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Count != 2 || response.Entities[0].ID != "org:equinor" || len(response.Entities[0].Items) != 2 {
+		t.Errorf("Expected Equinor in both items, then Stortinget, got %+v", response.Entities)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/entities?id=person:jonas-gahr-støre", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var person services.EntitySummary
+	if err := json.NewDecoder(w.Body).Decode(&person); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusOK || person.Name != "Jonas Gahr Støre" || person.Count != 1 {
+		t.Errorf("Expected the person, got %d: %+v", w.Code, person)
+	}
+
+	for path, status := range map[string]int{
+		"/api/entities?id=person:nobody": http.StatusNotFound,
+		"/api/entities?type=animal":      http.StatusBadRequest,
+		"/api/entities?limit=zero":       http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("GET %s: expected status %d, got %d", path, status, w.Code)
+		}
+	}
+}
+
+func TestFollowHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	handler := NewNewsHandler(newTestService(t, testFeed))
+	r.GET("/api/follows", handler.GetFollows)
+	r.POST("/api/follows", handler.CreateFollow)
+	r.DELETE("/api/follows/:id", handler.DeleteFollow)
+	r.GET("/api/follows/:id/timeline", handler.GetFollowTimeline)
+	r.POST("/api/follows/:id/seen", handler.MarkFollowSeen)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/follows", `{"type": "search", "target": "budget"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var follow models.Follow
+	if err := json.NewDecoder(w.Body).Decode(&follow); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	w = do(http.MethodGet, "/api/follows/"+follow.ID+"/timeline", "")
+	var timeline services.FollowTimeline
+	if err := json.NewDecoder(w.Body).Decode(&timeline); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusOK || len(timeline.Items) != 1 || timeline.Items[0].Title != "Storting passes new budget" {
+		t.Errorf("Expected the budget item on the timeline, got %d: %+v", w.Code, timeline.Items)
+	}
+	if timeline.New != 0 {
+		t.Errorf("Expected no new items on a new follow, got %d", timeline.New)
+	}
+
+	if w := do(http.MethodPost, "/api/follows/"+follow.ID+"/seen", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d marking seen, got %d", http.StatusOK, w.Code)
+	}
+
+	w = do(http.MethodGet, "/api/follows", "")
+	var list struct {
+		Follows []services.FollowSummary `json:"follows"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(list.Follows) != 1 || list.Follows[0].Items != 1 {
+		t.Errorf("Expected one follow matching one item, got %+v", list.Follows)
+	}
+
+	if w := do(http.MethodDelete, "/api/follows/"+follow.ID, ""); w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d deleting, got %d", http.StatusOK, w.Code)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodGet, "/api/follows/" + follow.ID + "/timeline", "", http.StatusNotFound},
+		{http.MethodPost, "/api/follows/" + follow.ID + "/seen", "", http.StatusNotFound},
+		{http.MethodDelete, "/api/follows/" + follow.ID, "", http.StatusNotFound},
+		{http.MethodPost, "/api/follows", `{"type": "entity", "target": "person:nobody"}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/follows", `{"type": "planet", "target": "mars"}`, http.StatusBadRequest},
+	} {
+		if w := do(tc.method, tc.path, tc.body); w.Code != tc.status {
+			t.Errorf("%s %s: expected status %d, got %d", tc.method, tc.path, tc.status, w.Code)
+		}
+	}
+}
+
+func TestAlertHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	received := make(chan string, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		received <- string(body)
+	}))
+	defer hook.Close()
+
+	handler := NewNewsHandler(newTestService(t, testFeed))
+	handler.newsService.FetchNews()
+	r.GET("/api/alerts", handler.GetAlertRules)
+	r.POST("/api/alerts", handler.CreateAlertRule)
+	r.GET("/api/alerts/deliveries", handler.GetDeliveries)
+	r.PUT("/api/alerts/:id", handler.UpdateAlertRule)
+	r.DELETE("/api/alerts/:id", handler.DeleteAlertRule)
+	r.POST("/api/alerts/:id/test", handler.TestAlertRule)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/alerts", `{"expression": "title contains budget", "url": "`+hook.URL+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var rule models.AlertRule
+	if err := json.NewDecoder(w.Body).Decode(&rule); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	w = do(http.MethodPost, "/api/alerts/"+rule.ID+"/test", "")
+	var delivery services.Delivery
+	if err := json.NewDecoder(w.Body).Decode(&delivery); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusOK || delivery.Status != services.DeliveryDelivered {
+		t.Errorf("Expected a delivered test, got %d: %+v", w.Code, delivery)
+	}
+	if body := <-received; !strings.Contains(body, "Storting passes new budget") {
+		t.Errorf("Expected the budget item in the webhook body, got %s", body)
+	}
+
+	w = do(http.MethodGet, "/api/alerts/deliveries?rule="+rule.ID, "")
+	var log struct {
+		Deliveries []services.Delivery `json:"deliveries"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&log); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(log.Deliveries) != 1 || log.Deliveries[0].ID != delivery.ID {
+		t.Errorf("Expected the test delivery in the log, got %+v", log.Deliveries)
+	}
+
+	if w := do(http.MethodPut, "/api/alerts/"+rule.ID, `{"expression": "tag=economy", "url": "`+hook.URL+`", "format": "slack"}`); w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d updating, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if w := do(http.MethodDelete, "/api/alerts/"+rule.ID, ""); w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d deleting, got %d", http.StatusOK, w.Code)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodPost, "/api/alerts/" + rule.ID + "/test", "", http.StatusNotFound},
+		{http.MethodDelete, "/api/alerts/" + rule.ID, "", http.StatusNotFound},
+		{http.MethodPut, "/api/alerts/" + rule.ID, `{"expression": "tag=economy", "url": "https://example.com"}`, http.StatusNotFound},
+		{http.MethodPost, "/api/alerts", `{"expression": "title contains", "url": "https://example.com"}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/alerts", `{"expression": "tag=economy", "url": "example.com"}`, http.StatusBadRequest},
+	} {
+		if w := do(tc.method, tc.path, tc.body); w.Code != tc.status {
+			t.Errorf("%s %s: expected status %d, got %d", tc.method, tc.path, tc.status, w.Code)
+		}
+	}
+}
+
+func TestPushHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	pushed := make(chan http.Header, 1)
+	pushService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		pushed <- req.Header
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer pushService.Close()
+
+	handler := NewNewsHandler(newTestService(t, testFeed))
+	r.GET("/api/push/key", handler.GetPushKey)
+	r.GET("/api/push/subscriptions", handler.GetPushSubscriptions)
+	r.POST("/api/push/subscriptions", handler.CreatePushSubscription)
+	r.DELETE("/api/push/subscriptions/:id", handler.DeletePushSubscription)
+	r.POST("/api/push/subscriptions/:id/test", handler.TestPushSubscription)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, "/api/push/key", "")
+	var key struct {
+		PublicKey string `json:"publicKey"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&key); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusOK || key.PublicKey == "" {
+		t.Errorf("Expected the VAPID public key, got %d: %+v", w.Code, key)
+	}
+
+	browserKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	p256dh := base64.RawURLEncoding.EncodeToString(browserKey.PublicKey().Bytes())
+	subscription := `{"endpoint": "` + pushService.URL + `/send/1", "expirationTime": null, "keys": {"p256dh": "` + p256dh + `", "auth": "BTBZMqHH6r4Tts7J_aSIgg"}, "breaking": true}`
+	w = do(http.MethodPost, "/api/push/subscriptions", subscription)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var sub models.PushSubscription
+	if err := json.NewDecoder(w.Body).Decode(&sub); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	w = do(http.MethodPost, "/api/push/subscriptions/"+sub.ID+"/test", "")
+	var result services.PushResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusOK || result.StatusCode != http.StatusCreated || result.Error != "" {
+		t.Errorf("Expected the push service to accept the test, got %d: %+v", w.Code, result)
+	}
+	if header := <-pushed; !strings.HasPrefix(header.Get("Authorization"), "vapid t=") || !strings.Contains(header.Get("Authorization"), "k="+key.PublicKey) {
+		t.Errorf("Expected a VAPID Authorization header, got %q", header.Get("Authorization"))
+	}
+
+	w = do(http.MethodGet, "/api/push/subscriptions", "")
+	var list struct {
+		Subscriptions []models.PushSubscription `json:"subscriptions"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(list.Subscriptions) != 1 || !list.Subscriptions[0].Breaking || list.Subscriptions[0].LastSent.IsZero() {
+		t.Errorf("Expected the subscription with its last send, got %+v", list.Subscriptions)
+	}
+
+	if w := do(http.MethodDelete, "/api/push/subscriptions/"+sub.ID, ""); w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d deleting, got %d", http.StatusOK, w.Code)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodPost, "/api/push/subscriptions/" + sub.ID + "/test", "", http.StatusNotFound},
+		{http.MethodDelete, "/api/push/subscriptions/" + sub.ID, "", http.StatusNotFound},
+		{http.MethodPost, "/api/push/subscriptions", `{"endpoint": "` + pushService.URL + `", "keys": {"p256dh": "BAAA", "auth": "BTBZMqHH6r4Tts7J_aSIgg"}}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/push/subscriptions", `{"keys": {}}`, http.StatusBadRequest},
+	} {
+		if w := do(tc.method, tc.path, tc.body); w.Code != tc.status {
+			t.Errorf("%s %s: expected status %d, got %d", tc.method, tc.path, tc.status, w.Code)
+		}
+	}
+}
+
+func TestDigestHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	service := newTestService(t, testFeed)
+	service.SetDigestDir(t.TempDir())
+	handler := NewNewsHandler(service)
+	r.GET("/api/digests", handler.GetDigests)
+	r.POST("/api/digests", handler.CreateDigest)
+	r.PUT("/api/digests/:id", handler.UpdateDigest)
+	r.DELETE("/api/digests/:id", handler.DeleteDigest)
+	r.GET("/api/digests/:id/preview", handler.PreviewDigest)
+	r.POST("/api/digests/:id/send", handler.SendDigest)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/digests", `{"name": "Weekly roundup", "schedule": "weekly", "weekday": "Sunday", "directory": "weekly"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var digest models.Digest
+	if err := json.NewDecoder(w.Body).Decode(&digest); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if digest.Weekday != "sunday" || digest.Time != "07:00" || digest.Format != models.DigestHTML {
+		t.Errorf("Expected the defaults filled in, got %+v", digest)
+	}
+
+	if w := do(http.MethodPost, "/api/digests", `{"schedule": "daily", "directory": "../outside"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for a directory outside the digest directory, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = do(http.MethodGet, "/api/digests/"+digest.ID+"/preview?format=markdown", "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/markdown") || !strings.HasPrefix(w.Body.String(), "# Weekly roundup") {
+		t.Errorf("Expected a Markdown preview, got %d %q: %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	w = do(http.MethodGet, "/api/digests/"+digest.ID+"/preview", "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(w.Body.String(), "<h1") {
+		t.Errorf("Expected an HTML preview, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	w = do(http.MethodGet, "/api/digests/"+digest.ID+"/preview?format=json", "")
+	var content services.DigestContent
+	if err := json.NewDecoder(w.Body).Decode(&content); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if content.To.Sub(content.From) != 7*24*time.Hour {
+		t.Errorf("Expected a week long digest, got %v to %v", content.From, content.To)
+	}
+
+	w = do(http.MethodPost, "/api/digests/"+digest.ID+"/send", "")
+	if err := json.NewDecoder(w.Body).Decode(&digest); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusOK || digest.LastSent.IsZero() {
+		t.Errorf("Expected the digest sent, got %d: %+v", w.Code, digest)
+	}
+
+	if w := do(http.MethodPut, "/api/digests/"+digest.ID, `{"schedule": "daily", "to": ["reader@example.com"]}`); w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d updating, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if w := do(http.MethodDelete, "/api/digests/"+digest.ID, ""); w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d deleting, got %d", http.StatusOK, w.Code)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodGet, "/api/digests/" + digest.ID + "/preview", "", http.StatusNotFound},
+		{http.MethodPost, "/api/digests/" + digest.ID + "/send", "", http.StatusNotFound},
+		{http.MethodDelete, "/api/digests/" + digest.ID, "", http.StatusNotFound},
+		{http.MethodPut, "/api/digests/" + digest.ID, `{"schedule": "daily", "directory": "out"}`, http.StatusNotFound},
+		{http.MethodPost, "/api/digests", `{"schedule": "monthly", "directory": "out"}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/digests", `{"schedule": "daily"}`, http.StatusBadRequest},
+	} {
+		if w := do(tc.method, tc.path, tc.body); w.Code != tc.status {
+			t.Errorf("%s %s: expected status %d, got %d", tc.method, tc.path, tc.status, w.Code)
+		}
+	}
+}
+
+func TestFeedHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	handler := NewNewsHandler(newTestService(t, testFeed))
+	r.GET("/feeds/tag/:file", handler.GetTagFeed)
+	r.GET("/feeds/category/:file", handler.GetCategoryFeed)
+	r.GET("/feeds/search.rss", handler.GetSearchFeed)
+	r.GET("/feeds/search.atom", handler.GetSearchFeed)
+	r.GET("/feeds/search.json", handler.GetSearchFeed)
+
+	do := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/feeds/tag/politics.atom", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != services.FeedContentTypes[services.FeedAtom] {
+		t.Fatalf("Expected an Atom feed, got %d %q: %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	var atom struct {
+		Title   string `xml:"title"`
+		Entries []struct {
+			Title string `xml:"title"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &atom); err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+	if len(atom.Entries) != 1 || atom.Entries[0].Title != "Storting passes new budget" {
+		t.Errorf("Expected the item tagged politics, got %+v", atom.Entries)
+	}
+
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag")
+	}
+	if w := do("/feeds/tag/politics.atom", etag); w.Code != http.StatusNotModified {
+		t.Errorf("Expected status code %d for a matching ETag, got %d", http.StatusNotModified, w.Code)
+	}
+
+	w = do("/feeds/category/General.rss?limit=1", "")
+	var rss struct {
+		Items []struct {
+			Title string `xml:"title"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &rss); err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+	if len(rss.Items) != 1 || rss.Items[0].Title != "Local team wins championship" {
+		t.Errorf("Expected the newest item only, got %+v", rss.Items)
+	}
+
+	w = do("/feeds/search.json?q=championship", "")
+	var feed struct {
+		Version string `json:"version"`
+		FeedURL string `json:"feed_url"`
+		Items   []struct {
+			Title string `json:"title"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&feed); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if feed.Version != "https://jsonfeed.org/version/1.1" || len(feed.Items) != 1 || feed.FeedURL != "http://example.com/feeds/search.json?q=championship" {
+		t.Errorf("Expected a JSON Feed of the match, got %+v", feed)
+	}
+
+	for _, tc := range []struct {
+		path   string
+		status int
+	}{
+		{"/feeds/tag/unknown.rss", http.StatusNotFound},
+		{"/feeds/tag/politics.html", http.StatusNotFound},
+		{"/feeds/category/General", http.StatusNotFound},
+		{"/feeds/search.rss", http.StatusBadRequest},
+		{"/feeds/category/General.rss?limit=0", http.StatusBadRequest},
+	} {
+		if w := do(tc.path, ""); w.Code != tc.status {
+			t.Errorf("GET %s: expected status %d, got %d", tc.path, tc.status, w.Code)
+		}
+	}
+}
+
+func TestDiscoverFeeds(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><link rel="alternate" type="application/feed+json" href="/feed.json"></head></html>`))
+	}))
+	defer page.Close()
+
+	handler := NewNewsHandler(newTestService(t, testFeed))
+	r.GET("/api/sources/discover", handler.DiscoverFeeds)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/sources/discover?url="+page.URL, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var response struct {
+		Feeds []services.DiscoveredFeed `json:"feeds"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Feeds) != 1 || response.Feeds[0].URL != page.URL+"/feed.json" || response.Feeds[0].ContentType != models.TypeJSONFeed {
+		t.Errorf("Expected the JSON Feed, got %+v", response.Feeds)
+	}
+
+	for _, tc := range []struct {
+		query  string
+		status int
+	}{
+		{"", http.StatusBadRequest},
+		{"?url=example.com", http.StatusBadRequest},
+		{"?url=" + page.URL + "/missing", http.StatusBadGateway},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/sources/discover"+tc.query, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Errorf("GET /api/sources/discover%s: expected status %d, got %d", tc.query, tc.status, w.Code)
+		}
+	}
+}
+
+func TestTestSource(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<ul><li><a href="/a">Ferja er innstilt</a></li><li><a href="/b">Brua stengt</a></li></ul>`))
+	}))
+	defer page.Close()
+
+	handler := NewNewsHandler(newTestService(t, testFeed))
+	r.POST("/api/sources/test", handler.TestSource)
+
+	do := func(query, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/sources/test"+query, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	source := `{"name": "Lokalavisa", "url": "` + page.URL + `", "contentType": "scrape", "scrape": {"item": "li", "title": "a"}}`
+	w := do("?preview=true", source)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var result services.SourceTest
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !result.OK || result.Count != 2 || len(result.Items) != 2 || result.Items[1].Link != page.URL+"/b" {
+		t.Errorf("Expected both items previewed, got %+v", result)
+	}
+
+	for _, tc := range []struct {
+		body   string
+		status int
+	}{
+		{`{"url": "` + page.URL + `", "contentType": "scrape"}`, http.StatusBadRequest},
+		{`{"url": "` + page.URL + `", "contentType": "scrape", "scrape": {"item": "li[", "title": "a"}}`, http.StatusBadRequest},
+		{`{"url": "not a url", "contentType": "rss"}`, http.StatusBadRequest},
+		{`{`, http.StatusBadRequest},
+	} {
+		if w := do("", tc.body); w.Code != tc.status {
+			t.Errorf("POST /api/sources/test %s: expected status %d, got %d", tc.body, tc.status, w.Code)
+		}
+	}
+}
+
+func TestGetBreaking(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	handler := NewNewsHandler(newTestService(t, testFeed))
+	r.GET("/api/breaking", handler.GetBreaking)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/breaking?all=1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	var response struct {
+		Events []services.BreakingEvent `json:"events"`
+		Count  int                      `json:"count"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	// One source with two old items is never breaking
+	if response.Events == nil || response.Count != 0 {
+		t.Errorf("Expected an empty list of events, got %+v", response)
+	}
+}
+
+type streamEvent struct {
+	id, event, data string
+}
+
+// readStream parses the Server-Sent Events of a stream response.
+func readStream(resp *http.Response) <-chan streamEvent {
+	events := make(chan streamEvent)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(nil, 1<<20)
+		var event streamEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id:"):
+				event.id = line[3:]
+			case strings.HasPrefix(line, "event:"):
+				event.event = line[6:]
+			case strings.HasPrefix(line, "data:"):
+				event.data += line[5:]
+			case line == "" && event.event != "":
+				events <- event
+				event = streamEvent{}
+			}
+		}
+	}()
+	return events
+}
+
+// nextItem returns the title of the next item event on the stream.
+func nextItem(t *testing.T, events <-chan streamEvent) (streamEvent, string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("Stream ended before an item arrived")
+			}
+			if event.event != services.StreamItem {
+				continue
+			}
+			var item models.NewsItem
+			if err := json.Unmarshal([]byte(event.data), &item); err != nil {
+				t.Fatalf("Failed to decode item: %v", err)
+			}
+			return event, item.Title
+		case <-timeout:
+			t.Fatalf("Timed out waiting for an item")
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	var mu sync.Mutex
+	feed := testFeed
+	service := newTestServiceFor(t, func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return feed
+	})
+	handler := NewNewsHandler(service)
+	r.GET("/api/stream", handler.Stream)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	open := func(lastEventID string) <-chan streamEvent {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/stream?q=ferry", nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to open stream: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("Expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		return readStream(resp)
+	}
+
+	// Items present at the first fetch are not announced
+	service.FetchNews()
+	events := open("")
+
+	mu.Lock()
+	feed = strings.Replace(feed, "<item>", `<item>
+    <title>Storm closes roads</title>
+    <link>https://example.com/news/3</link>
+    <pubDate>Mon, 02 Jan 2006 17:04:05 GMT</pubDate>
+  </item>
+  <item>
+    <title>Ferry strike ends</title>
+    <link>https://example.com/news/4</link>
+    <pubDate>Mon, 02 Jan 2006 18:04:05 GMT</pubDate>
+  </item>
+  <item>`, 1)
+	mu.Unlock()
+	service.FetchNews()
+
+	// Only the item matching q is pushed
+	event, title := nextItem(t, events)
+	if title != "Ferry strike ends" || event.id == "" {
+		t.Errorf("Expected the ferry item with an ID, got %q (id %q)", title, event.id)
+	}
+
+	// A client from before a restart resumes from the buffer
+	_, title = nextItem(t, open("earlier-1"))
+	if title != "Ferry strike ends" {
+		t.Errorf("Expected the ferry item on resume, got %q", title)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stream?state=unknown", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for an unknown state, got %d", http.StatusBadRequest, w.Code)
+	}
 }
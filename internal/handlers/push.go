@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/services"
+)
+
+// GetPushKey returns the VAPID public key browsers pass as
+// applicationServerKey when subscribing.
+func (h *NewsHandler) GetPushKey(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"publicKey": h.newsService.VAPIDPublicKey()})
+}
+
+// GetPushSubscriptions lists the browsers subscribed to push notifications.
+func (h *NewsHandler) GetPushSubscriptions(c *gin.Context) {
+	subs := h.newsService.PushSubscriptions()
+	c.JSON(http.StatusOK, gin.H{
+		"subscriptions": subs,
+		"count":         len(subs),
+	})
+}
+
+// CreatePushSubscription saves a browser's push subscription, the JSON of
+// its PushSubscription plus the alert rules to follow and whether to get
+// breaking events.
+func (h *NewsHandler) CreatePushSubscription(c *gin.Context) {
+	var sub models.PushSubscription
+	if err := c.BindJSON(&sub); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub, err := h.newsService.AddPushSubscription(sub)
+	if err != nil {
+		c.JSON(pushErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sub)
+}
+
+// DeletePushSubscription removes the push subscription named by the :id path
+// parameter.
+func (h *NewsHandler) DeletePushSubscription(c *gin.Context) {
+	if err := h.newsService.DeletePushSubscription(c.Param("id")); err != nil {
+		c.JSON(pushErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": c.Param("id")})
+}
+
+// TestPushSubscription sends the subscription a test notification and
+// returns what the push service answered.
+func (h *NewsHandler) TestPushSubscription(c *gin.Context) {
+	result, err := h.newsService.TestPushSubscription(c.Param("id"))
+	if err != nil {
+		c.JSON(pushErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func pushErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrPushSubscriptionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidPushSubscription):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
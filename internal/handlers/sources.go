@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/services"
+)
+
+// DiscoverFeeds lists the RSS, Atom and JSON Feed feeds the page given as the
+// url query parameter announces, with the source type to add each as.
+func (h *NewsHandler) DiscoverFeeds(c *gin.Context) {
+	pageURL := c.Query("url")
+	if pageURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url is required"})
+		return
+	}
+
+	feeds, err := h.newsService.DiscoverFeeds(pageURL)
+	if err != nil {
+		c.JSON(sourceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"feeds": feeds,
+		"count": len(feeds),
+	})
+}
+
+// TestSource fetches the source given in the body once without saving it,
+// reporting whether it worked and how many items it gave. With preview=true
+// the first items are returned too, as they would be listed, so the
+// selectors of a scrape source can be tried out.
+func (h *NewsHandler) TestSource(c *gin.Context) {
+	var src models.NewsSource
+	if err := c.BindJSON(&src); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.newsService.TestSource(src, c.Query("preview") == "true")
+	if err != nil {
+		c.JSON(sourceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func sourceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidSource):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrInvalidDiscoverURL):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrDiscoveryFailed):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/services"
+)
+
+type itemStateRequest struct {
+	IDs   []string `json:"ids"`
+	Value *bool    `json:"value"`
+}
+
+// SetItemState sets the read, starred or hidden flag named by the :state
+// path parameter on a batch of items. The value defaults to true.
+func (h *NewsHandler) SetItemState(c *gin.Context) {
+	var req itemStateRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.IDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ids must not be empty"})
+		return
+	}
+
+	value := true
+	if req.Value != nil {
+		value = *req.Value
+	}
+
+	field := models.StateField(c.Param("state"))
+	if err := h.newsService.SetItemState(req.IDs, field, value); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"state":   field,
+		"value":   value,
+		"updated": len(req.IDs),
+	})
+}
+
+// MarkAllRead marks every cached item matching the optional source, category
+// and before filters as read.
+func (h *NewsHandler) MarkAllRead(c *gin.Context) {
+	var opts services.MarkReadOptions
+	if err := c.BindJSON(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	marked, err := h.newsService.MarkAllRead(opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"marked": marked})
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/services"
+)
+
+// streamKeepAlive is how often an idle stream gets a comment, so proxies do
+// not close it.
+const streamKeepAlive = 30 * time.Second
+
+// Stream pushes new items, source health changes, trending updates and
+// breaking alerts as Server-Sent Events, named item, source, trending and
+// breaking. Items are filtered like GetNews, by the preferences and the q
+// and state parameters. A client reconnecting with Last-Event-ID, or the
+// lastEventId parameter, first gets the events it missed.
+func (h *NewsHandler) Stream(c *gin.Context) {
+	query, state := c.Query("q"), c.Query("state")
+	if _, err := h.newsService.FilterByState(nil, state); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("lastEventId")
+	}
+	backlog, events, cancel := h.newsService.Subscribe(lastEventID)
+	defer cancel()
+
+	send := func(event services.StreamEvent) {
+		data := event.Data
+		if item, ok := data.(models.NewsItem); ok {
+			if data, ok = h.newsService.StreamItemMatches(item, query, state); !ok {
+				return
+			}
+		}
+		c.Render(-1, sse.Event{Id: event.ID, Event: event.Type, Data: data})
+	}
+
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	for _, event := range backlog {
+		send(event)
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			send(event)
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+		case <-c.Request.Context().Done():
+			return false
+		}
+		return true
+	})
+}
+
+// GetSourceHealth reports how the latest fetch of every feed went.
+func (h *NewsHandler) GetSourceHealth(c *gin.Context) {
+	health := h.newsService.SourceHealth()
+	c.JSON(http.StatusOK, gin.H{
+		"sources": health,
+		"count":   len(health),
+	})
+}
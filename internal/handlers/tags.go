@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/services"
+)
+
+// UpdateTag changes the tag named by the :id path parameter. System tags
+// only take new rules; sending none restores their built-in rules.
+func (h *NewsHandler) UpdateTag(c *gin.Context) {
+	var update models.Tag
+	if err := c.BindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag, err := h.newsService.UpdateTag(c.Param("id"), update)
+	if err != nil {
+		c.JSON(tagErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// PreviewTag lists the recent items a tag's rules would match, so rules can
+// be tried out before they are saved.
+func (h *NewsHandler) PreviewTag(c *gin.Context) {
+	var tag models.Tag
+	if err := c.BindJSON(&tag); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	items, err := h.newsService.PreviewTag(tag)
+	if err != nil {
+		c.JSON(tagErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": items,
+		"count": len(items),
+	})
+}
+
+type suggestionRequest struct {
+	NewsID string `json:"newsId"`
+	TagID  string `json:"tagId"`
+}
+
+// GetSuggestions lists the cached items with tags suggested by the classifier
+// learned from manual tagging.
+func (h *NewsHandler) GetSuggestions(c *gin.Context) {
+	suggestions := h.newsService.Suggestions()
+	c.JSON(http.StatusOK, gin.H{
+		"items": suggestions,
+		"count": len(suggestions),
+	})
+}
+
+// AcceptSuggestion adds a suggested tag to an item, the same as tagging it by
+// hand.
+func (h *NewsHandler) AcceptSuggestion(c *gin.Context) {
+	var req suggestionRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.newsService.AcceptSuggestion(req.NewsID, req.TagID); err != nil {
+		c.JSON(tagErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, req)
+}
+
+// RejectSuggestion records that a suggested tag does not fit an item.
+func (h *NewsHandler) RejectSuggestion(c *gin.Context) {
+	var req suggestionRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.newsService.RejectSuggestion(req.NewsID, req.TagID); err != nil {
+		c.JSON(tagErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, req)
+}
+
+// RetrainClassifier rebuilds the learned tag models and reports how many
+// examples each tag had.
+func (h *NewsHandler) RetrainClassifier(c *gin.Context) {
+	stats, err := h.newsService.RetrainClassifier()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tags": stats})
+}
+
+func tagErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrTagNotFound), errors.Is(err, services.ErrItemNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidTagRule):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
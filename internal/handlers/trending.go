@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/news-reader/internal/services"
+)
+
+// GetTrendingHistory returns how often a term was mentioned per hour or day.
+// term is required; interval is hour (the default) or day, and from and to
+// (RFC 3339 times or dates) default to the last week. Periods reaching back
+// further than the history is kept are rejected. language stems the
+// term in that language instead of looking it up in every language.
+func (h *NewsHandler) GetTrendingHistory(c *gin.Context) {
+	term := c.Query("term")
+	if term == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "term is required"})
+		return
+	}
+
+	interval, from, to, err := trendPeriod(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	series := h.newsService.TrendingHistory(term, c.Query("language"), interval, from, to)
+	c.JSON(http.StatusOK, series)
+}
+
+// GetTrendingPeaks lists the topics whose busiest hour or day fell in the
+// period, busiest first. It takes the interval, from and to parameters of
+// GetTrendingHistory and an optional limit.
+func (h *NewsHandler) GetTrendingPeaks(c *gin.Context) {
+	interval, from, to, err := trendPeriod(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+			return
+		}
+	}
+
+	peaks := h.newsService.TrendingPeaks(interval, from, to, limit)
+	c.JSON(http.StatusOK, gin.H{
+		"peaks":    peaks,
+		"count":    len(peaks),
+		"interval": interval,
+		"from":     from,
+		"to":       to,
+	})
+}
+
+// trendPeriod reads the interval, from and to query parameters.
+func trendPeriod(c *gin.Context) (string, time.Time, time.Time, error) {
+	interval := c.DefaultQuery("interval", services.TrendHourly)
+	if interval != services.TrendHourly && interval != services.TrendDaily {
+		return "", time.Time{}, time.Time{}, errors.New("interval must be hour or day")
+	}
+
+	to := time.Now().UTC()
+	if value := c.Query("to"); value != "" {
+		t, err := parseTrendTime(value)
+		if err != nil {
+			return "", time.Time{}, time.Time{}, fmt.Errorf("invalid to: %v", err)
+		}
+		to = t
+	}
+	from := to.Add(-7 * 24 * time.Hour)
+	if value := c.Query("from"); value != "" {
+		t, err := parseTrendTime(value)
+		if err != nil {
+			return "", time.Time{}, time.Time{}, fmt.Errorf("invalid from: %v", err)
+		}
+		from = t
+	}
+
+	if !from.Before(to) {
+		return "", time.Time{}, time.Time{}, errors.New("from must be before to")
+	}
+	days := int(services.TrendRetention.Hours() / 24)
+	if to.Sub(from) > services.TrendRetention {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("the period can be at most %d days", days)
+	}
+	if from.Before(time.Now().Add(-services.TrendRetention)) {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("from can be at most %d days ago, as older counts are not kept", days)
+	}
+	return interval, from, to, nil
+}
+
+// parseTrendTime accepts an RFC 3339 time or a date, taken as midnight UTC.
+func parseTrendTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse("2006-01-02", value)
+}
@@ -0,0 +1,112 @@
+// Package matcher finds many fixed strings in a text in a single pass using
+// the Aho-Corasick algorithm.
+package matcher
+
+// Match is one occurrence of a pattern: text[Start:End] == patterns[Pattern].
+type Match struct {
+	Pattern int
+	Start   int
+	End     int
+}
+
+// Matcher is an Aho-Corasick automaton compiled into a byte-level DFA. It is
+// safe for concurrent use once built.
+type Matcher struct {
+	// next[state*256+b] is the state after reading byte b in state.
+	next []int32
+	// outputs[state] lists the patterns ending at state, including those
+	// reached through failure links.
+	outputs [][]int32
+	lengths []int
+}
+
+// New compiles patterns into a Matcher. Empty patterns never match.
+func New(patterns []string) *Matcher {
+	m := &Matcher{lengths: make([]int, len(patterns))}
+
+	// Build the trie; goto edges are -1 until set
+	addState := func() int32 {
+		for b := 0; b < 256; b++ {
+			m.next = append(m.next, -1)
+		}
+		m.outputs = append(m.outputs, nil)
+		return int32(len(m.outputs) - 1)
+	}
+	addState()
+	for i, pattern := range patterns {
+		m.lengths[i] = len(pattern)
+		if pattern == "" {
+			continue
+		}
+		state := int32(0)
+		for j := 0; j < len(pattern); j++ {
+			edge := int(state)*256 + int(pattern[j])
+			if m.next[edge] < 0 {
+				child := addState()
+				m.next[edge] = child
+			}
+			state = m.next[edge]
+		}
+		m.outputs[state] = append(m.outputs[state], int32(i))
+	}
+
+	// Breadth-first, fill in failure transitions so every edge is defined
+	fail := make([]int32, len(m.outputs))
+	queue := make([]int32, 0, len(m.outputs))
+	for b := 0; b < 256; b++ {
+		if child := m.next[b]; child > 0 {
+			fail[child] = 0
+			queue = append(queue, child)
+		} else {
+			m.next[b] = 0
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		if f := fail[state]; len(m.outputs[f]) > 0 {
+			m.outputs[state] = append(m.outputs[state], m.outputs[f]...)
+		}
+		for b := 0; b < 256; b++ {
+			edge := int(state)*256 + b
+			fallback := m.next[int(fail[state])*256+b]
+			if child := m.next[edge]; child >= 0 {
+				fail[child] = fallback
+				queue = append(queue, child)
+			} else {
+				m.next[edge] = fallback
+			}
+		}
+	}
+	return m
+}
+
+// FindAll returns every occurrence of every pattern in text, overlapping ones
+// included, ordered by where they end.
+func (m *Matcher) FindAll(text string) []Match {
+	var matches []Match
+	m.scan(text, func(pattern, end int) {
+		matches = append(matches, Match{Pattern: pattern, Start: end - m.lengths[pattern], End: end})
+	})
+	return matches
+}
+
+// Contains reports which patterns occur in text; the result is indexed like
+// the patterns passed to New.
+func (m *Matcher) Contains(text string) []bool {
+	found := make([]bool, len(m.lengths))
+	m.scan(text, func(pattern, _ int) {
+		found[pattern] = true
+	})
+	return found
+}
+
+func (m *Matcher) scan(text string, emit func(pattern, end int)) {
+	state := int32(0)
+	for i := 0; i < len(text); i++ {
+		state = m.next[int(state)*256+int(text[i])]
+		for _, pattern := range m.outputs[state] {
+			emit(int(pattern), i+1)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package matcher
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		text     string
+		expected []Match
+	}{
+		{
+			name:     "Classic example",
+			patterns: []string{"he", "she", "his", "hers"},
+			text:     "ushers",
+			expected: []Match{{Pattern: 1, Start: 1, End: 4}, {Pattern: 0, Start: 2, End: 4}, {Pattern: 3, Start: 2, End: 6}},
+		},
+		{
+			name:     "Repeated matches",
+			patterns: []string{"aa"},
+			text:     "aaaa",
+			expected: []Match{{Pattern: 0, Start: 0, End: 2}, {Pattern: 0, Start: 1, End: 3}, {Pattern: 0, Start: 2, End: 4}},
+		},
+		{
+			name:     "Word boundaries by padding",
+			patterns: []string{" ai ", " art "},
+			text:     " said again ai start art ",
+			expected: []Match{{Pattern: 0, Start: 11, End: 15}, {Pattern: 1, Start: 20, End: 25}},
+		},
+		{
+			name:     "Multibyte text",
+			patterns: []string{"tromsø", "ø"},
+			text:     "i tromsø",
+			expected: []Match{{Pattern: 0, Start: 2, End: 9}, {Pattern: 1, Start: 7, End: 9}},
+		},
+		{
+			name:     "No patterns",
+			patterns: nil,
+			text:     "anything",
+			expected: nil,
+		},
+		{
+			name:     "Empty pattern",
+			patterns: []string{""},
+			text:     "anything",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := New(tt.patterns).FindAll(tt.text)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("FindAll() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestContainsAgreesWithStrings(t *testing.T) {
+	patterns := []string{" climate ", " oil price ", " ai ", "budget", " nrk ", "ing "}
+	texts := []string{
+		" the oil price fell as climate talks stalled ",
+		" nrk reports on the budget ",
+		" said again ",
+		"",
+	}
+
+	m := New(patterns)
+	for _, text := range texts {
+		found := m.Contains(text)
+		for i, pattern := range patterns {
+			if found[i] != strings.Contains(text, pattern) {
+				t.Errorf("Contains(%q)[%q] = %v, want %v", text, pattern, found[i], !found[i])
+			}
+		}
+	}
+}
+
+var benchPatterns = func() []string {
+	words := strings.Fields(`politics government election president minister economy market stock
+		trade financial inflation technology software digital cyber science research study health
+		medical disease treatment covid hospital sports game tournament championship player
+		entertainment movie film music celebrity environment climate pollution sustainable emissions
+		norway sweden denmark finland germany france spain italy china japan india brazil canada`)
+	patterns := make([]string, len(words))
+	for i, word := range words {
+		patterns[i] = " " + word + " "
+	}
+	return patterns
+}()
+
+var benchText = " " + strings.Repeat("the government said the market reacted to new climate research from norway and the hospital reported ", 4) + " "
+
+func BenchmarkMatcher(b *testing.B) {
+	m := New(benchPatterns)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Contains(benchText)
+	}
+}
+
+func BenchmarkStringsContains(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		found := make([]bool, len(benchPatterns))
+		for j, pattern := range benchPatterns {
+			found[j] = strings.Contains(benchText, pattern)
+		}
+	}
+}
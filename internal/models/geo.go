@@ -0,0 +1,39 @@
+package models
+
+// FeatureCollection is a GeoJSON (RFC 7946) feature collection.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON point feature for one place mentioned in the news.
+type Feature struct {
+	Type       string          `json:"type"`
+	Geometry   PointGeometry   `json:"geometry"`
+	Properties PlaceProperties `json:"properties"`
+}
+
+// PointGeometry holds a position as [longitude, latitude].
+type PointGeometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// PlaceProperties describes a place and the items that mention it. Count is
+// the total number of mentions across all items.
+type PlaceProperties struct {
+	ID      string      `json:"id"`
+	Name    string      `json:"name"`
+	Kind    string      `json:"kind"`
+	Country string      `json:"country,omitempty"`
+	Region  string      `json:"region"`
+	Count   int         `json:"count"`
+	Items   []PlaceItem `json:"items"`
+}
+
+// PlaceItem is an item mentioning a place, with how often it does so.
+type PlaceItem struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Count int    `json:"count"`
+}
@@ -1,14 +1,19 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type ContentType string
 
 const (
-	TypeRSS     ContentType = "rss"
-	TypeVideo   ContentType = "video"
-	TypePodcast ContentType = "podcast"
-	TypeAPI     ContentType = "api"
+	TypeRSS      ContentType = "rss"
+	TypeVideo    ContentType = "video"
+	TypePodcast  ContentType = "podcast"
+	TypeAPI      ContentType = "api"
+	TypeJSONFeed ContentType = "jsonfeed"
+	TypeScrape   ContentType = "scrape"
 )
 
 type NewsSource struct {
@@ -17,24 +22,191 @@ type NewsSource struct {
 	Category    string      `json:"category"`
 	ContentType ContentType `json:"contentType"`
 	Enabled     bool        `json:"enabled"`
+	// Language overrides the language the feed declares, e.g. "nb" or "nn".
+	Language string `json:"language,omitempty"`
+	// Scrape says where the page of a scrape source, at URL, lists its
+	// items.
+	Scrape *ScrapeConfig `json:"scrape,omitempty"`
+}
+
+// ScrapeConfig describes the items of a page without a feed by CSS
+// selectors. Selectors for values may end in @attr to read an attribute
+// instead of the text; links default to href, images to src and dates to the
+// datetime attribute where there is one.
+type ScrapeConfig struct {
+	// Item matches each item's container; the others are matched within it.
+	Item  string `json:"item"`
+	Title string `json:"title"`
+	// Link defaults to the title's link, or the container's first link.
+	Link        string `json:"link,omitempty"`
+	Description string `json:"description,omitempty"`
+	Date        string `json:"date,omitempty"`
+	// DateFormat is a Go time layout such as "02.01.2006 15:04", read in
+	// Timezone (an IANA name, UTC by default). RFC 3339 is always tried.
+	DateFormat string `json:"dateFormat,omitempty"`
+	Timezone   string `json:"timezone,omitempty"`
+	Image      string `json:"image,omitempty"`
+	// NextPage matches the link to the next page, followed up to MaxPages
+	// pages in all.
+	NextPage string `json:"nextPage,omitempty"`
+	MaxPages int    `json:"maxPages,omitempty"`
 }
 
 type NewsItem struct {
-	ID          string      `json:"id"`
-	Title       string      `json:"title"`
-	Link        string      `json:"link"`
-	Description string      `json:"description"`
-	Published   time.Time   `json:"published"`
-	Source      string      `json:"source"`
-	Category    string      `json:"category"`
-	ContentType ContentType `json:"contentType"`
-	Thumbnail   string      `json:"thumbnail,omitempty"`
-	Duration    string      `json:"duration,omitempty"`
-	AudioURL    string      `json:"audioUrl,omitempty"`
-	VideoURL    string      `json:"videoUrl,omitempty"`
-	Tags        []Tag       `json:"tags"`
-	Region      string      `json:"region,omitempty"`
-	Language    string      `json:"language,omitempty"`
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	Link         string    `json:"link"`
+	OriginalLink string    `json:"originalLink,omitempty"`
+	Description  string    `json:"description"`
+	Published    time.Time `json:"published"`
+	Source       string    `json:"source"`
+	// SourceNames lists the names of every source entry sharing the item's
+	// feed, Source first, when there is more than one.
+	SourceNames []string `json:"sourceNames,omitempty"`
+	// Author is the byline the feed gives, if any.
+	Author     string   `json:"author,omitempty"`
+	Category   string   `json:"category"`
+	Categories []string `json:"categories,omitempty"`
+	// Keywords are the feed's own tags for the item.
+	Keywords           []string        `json:"keywords,omitempty"`
+	ContentType        ContentType     `json:"contentType"`
+	Thumbnail          string          `json:"thumbnail,omitempty"`
+	Duration           string          `json:"duration,omitempty"`
+	AudioURL           string          `json:"audioUrl,omitempty"`
+	VideoURL           string          `json:"videoUrl,omitempty"`
+	Tags               []Tag           `json:"tags"`
+	Region             string          `json:"region,omitempty"`
+	Regions            []RegionMention `json:"regions,omitempty"`
+	Places             []PlaceMention  `json:"places,omitempty"`
+	Entities           []Entity        `json:"entities,omitempty"`
+	Language           string          `json:"language,omitempty"`
+	LanguageConfidence float64         `json:"languageConfidence,omitempty"`
+	Read               bool            `json:"read"`
+	Starred            bool            `json:"starred"`
+	Hidden             bool            `json:"hidden,omitempty"`
+	Score              *ScoreBreakdown `json:"score,omitempty"`
+	ClusterID          string          `json:"clusterId,omitempty"`
+	Suggestions        []TagSuggestion `json:"suggestions,omitempty"`
+}
+
+// TagSuggestion is a user tag the classifier thinks fits an item, with the
+// estimated probability that it does.
+type TagSuggestion struct {
+	TagID      string  `json:"tagId"`
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+}
+
+// RegionMention counts how often places in a region were mentioned.
+type RegionMention struct {
+	Region string `json:"region"`
+	Count  int    `json:"count"`
+}
+
+// PlaceMention is a country, city or union of countries found in an item.
+// A country's count includes mentions of its cities.
+type PlaceMention struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Country string `json:"country,omitempty"`
+	Region  string `json:"region"`
+	Count   int    `json:"count"`
+}
+
+// Entity types.
+const (
+	EntityPerson = "person"
+	EntityOrg    = "org"
+	EntityPlace  = "place"
+	EntityTicker = "ticker"
+)
+
+// Entity is a person, organization, place or stock ticker named in an item.
+// The ID is the type and a normalised name, e.g. "org:equinor", and is the
+// same across items and sources.
+type Entity struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+}
+
+// StoryCluster groups items from different sources that cover the same story.
+type StoryCluster struct {
+	ID             string          `json:"id"`
+	Representative NewsItem        `json:"representative"`
+	Coverage       []ClusterMember `json:"coverage"`
+	SourceCount    int             `json:"sourceCount"`
+	FirstPublished time.Time       `json:"firstPublished"`
+	LastPublished  time.Time       `json:"lastPublished"`
+	// Breaking is the severity of the breaking event the story is part of.
+	Breaking string `json:"breaking,omitempty"`
+}
+
+// ClusterMember is one item's entry in a story cluster's coverage list.
+type ClusterMember struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Link      string    `json:"link"`
+	Source    string    `json:"source"`
+	Published time.Time `json:"published"`
+}
+
+// ScoreBreakdown shows how the ranking arrived at an item's score. Each
+// component has already been multiplied by its weight.
+type ScoreBreakdown struct {
+	Recency    float64 `json:"recency"`
+	Interest   float64 `json:"interest"`
+	Tags       float64 `json:"tags"`
+	Source     float64 `json:"source"`
+	Engagement float64 `json:"engagement"`
+	Total      float64 `json:"total"`
+}
+
+// HasCategory reports whether the item belongs to category, either as its
+// primary category or through another source entry sharing its feed.
+func (item NewsItem) HasCategory(category string) bool {
+	if item.Category == category {
+		return true
+	}
+	for _, c := range item.Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// HasSource reports whether the item comes from the source named name,
+// ignoring case, either as its primary source or as another source entry
+// sharing its feed.
+func (item NewsItem) HasSource(name string) bool {
+	if strings.EqualFold(item.Source, name) {
+		return true
+	}
+	for _, source := range item.SourceNames {
+		if strings.EqualFold(source, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllSources returns the name of every source entry the item comes from.
+func (item NewsItem) AllSources() []string {
+	if len(item.SourceNames) == 0 {
+		return []string{item.Source}
+	}
+	return item.SourceNames
+}
+
+// AllCategories returns every category the item belongs to.
+func (item NewsItem) AllCategories() []string {
+	if len(item.Categories) == 0 {
+		return []string{item.Category}
+	}
+	return item.Categories
 }
 
 type Tag struct {
@@ -42,6 +214,28 @@ type Tag struct {
 	Name     string `json:"name"`
 	Color    string `json:"color"`
 	Category string `json:"category"` // system or user
+	// Rules decide which items get the tag; any matching rule applies it.
+	// A user tag without rules matches its name as a whole word.
+	Rules []TagRule `json:"rules,omitempty"`
+}
+
+// TagRule matches items that contain any of its keywords or patterns and
+// meet every constraint that is set. A rule with constraints but no keywords
+// or patterns matches every item meeting the constraints; an empty rule
+// matches nothing.
+type TagRule struct {
+	// Keywords are words or phrases matched case-insensitively on word
+	// boundaries.
+	Keywords []string `json:"keywords,omitempty"`
+	// Patterns are regular expressions matched against the title and
+	// description. Use (?i) for case-insensitive matching.
+	Patterns []string `json:"patterns,omitempty"`
+	// Sources, Categories and Languages restrict the rule to items from one
+	// of the listed sources, in one of the categories or in one of the
+	// languages (tag IDs such as "english" or codes such as "en").
+	Sources    []string `json:"sources,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	Languages  []string `json:"languages,omitempty"`
 }
 
 type NewsTag struct {
@@ -49,14 +243,226 @@ type NewsTag struct {
 	TagID  string `json:"tagId"`
 }
 
+// StateField names one of the per-item flags tracked in ItemState.
+type StateField string
+
+const (
+	StateRead    StateField = "read"
+	StateStarred StateField = "starred"
+	StateHidden  StateField = "hidden"
+)
+
+// ItemState holds the user's read, starred and hidden flags for a news item.
+// Source and category are kept so the state stays meaningful after the item
+// has left the cache.
+type ItemState struct {
+	Read      bool      `json:"read,omitempty"`
+	Starred   bool      `json:"starred,omitempty"`
+	Hidden    bool      `json:"hidden,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	Category  string    `json:"category,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
 type UserPreferences struct {
-	Sources      []NewsSource    `json:"sources"`
-	Interests    []string        `json:"interests"`
-	Categories   []string        `json:"categories"`
-	ContentTypes []string        `json:"contentTypes"`
-	APIKeys      map[string]string `json:"apiKeys"`
-	Tags         []Tag          `json:"tags"`
-	NewsTags     []NewsTag      `json:"newsTags"`
+	Sources      []NewsSource         `json:"sources"`
+	Interests    []string             `json:"interests"`
+	Categories   []string             `json:"categories"`
+	ContentTypes []string             `json:"contentTypes"`
+	APIKeys      map[string]string    `json:"apiKeys"`
+	Tags         []Tag                `json:"tags"`
+	NewsTags     []NewsTag            `json:"newsTags"`
+	ItemStates   map[string]ItemState `json:"itemStates"`
+	Ranking      RankingSettings      `json:"ranking"`
+	// SystemTagRules replaces the rules of built-in tags, keyed by tag ID.
+	SystemTagRules map[string][]TagRule `json:"systemTagRules,omitempty"`
+	Classifier     ClassifierSettings   `json:"classifier"`
+	Follows        []Follow             `json:"follows,omitempty"`
+	Breaking       BreakingSettings     `json:"breaking"`
+	Alerts         []AlertRule          `json:"alerts,omitempty"`
+	Digests        []Digest             `json:"digests,omitempty"`
+}
+
+// Webhook payload formats.
+const (
+	WebhookJSON    = "json"
+	WebhookSlack   = "slack"
+	WebhookDiscord = "discord"
+)
+
+// AlertRule posts newly fetched items matching an expression to a webhook
+// and to the push subscriptions following the rule.
+type AlertRule struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Expression selects the items, e.g. "tag=economy AND title contains
+	// 'Equinor'".
+	Expression string `json:"expression"`
+	// URL is the webhook. Rules without one only notify push subscribers.
+	URL string `json:"url,omitempty"`
+	// Format is WebhookJSON (the default), WebhookSlack or WebhookDiscord.
+	Format string `json:"format,omitempty"`
+	// Secret, when set, is used to sign every payload with HMAC-SHA256. It
+	// is write-only: responses leave it out and set HasSecret instead, and
+	// updates with HasSecret set and no Secret keep the one stored.
+	Secret    string `json:"secret,omitempty"`
+	HasSecret bool   `json:"hasSecret,omitempty"`
+	Disabled  bool   `json:"disabled,omitempty"`
+	// QuietHours suppresses deliveries during part of the day.
+	QuietHours *QuietHours `json:"quietHours,omitempty"`
+	// MaxPerHour caps the deliveries in any hour. Zero uses the default in
+	// the services package.
+	MaxPerHour int `json:"maxPerHour,omitempty"`
+}
+
+// QuietHours is a daily period given as "15:04" times, which may span
+// midnight.
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Timezone is an IANA name such as "Europe/Oslo"; the server's local
+	// time is used when it is empty.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// Digest schedules.
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// Digest formats.
+const (
+	DigestHTML     = "html"
+	DigestMarkdown = "markdown"
+)
+
+// Digest is a scheduled summary of the top stories, trending topics,
+// starred items and new items per followed tag, mailed or written to a
+// directory.
+type Digest struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Schedule is DigestDaily or DigestWeekly. Digests go out at Time, a
+	// "15:04" time in Timezone, and weekly ones on Weekday.
+	Schedule string `json:"schedule"`
+	Time     string `json:"time,omitempty"`
+	Weekday  string `json:"weekday,omitempty"`
+	// Timezone is an IANA name; the server's local time is used when it is
+	// empty.
+	Timezone string `json:"timezone,omitempty"`
+	// Format is DigestHTML (the default) or DigestMarkdown, for the files
+	// written to Directory. Mail carries both.
+	Format string   `json:"format,omitempty"`
+	To     []string `json:"to,omitempty"`
+	// Directory is where the files go, relative to the digest directory the
+	// server is configured with.
+	Directory string `json:"directory,omitempty"`
+	// MaxStories caps the top stories. Zero uses the default in the
+	// services package.
+	MaxStories int       `json:"maxStories,omitempty"`
+	Disabled   bool      `json:"disabled,omitempty"`
+	Created    time.Time `json:"created"`
+	LastSent   time.Time `json:"lastSent,omitempty"`
+	LastError  string    `json:"lastError,omitempty"`
+}
+
+// PushSubscription is a browser's Web Push subscription, as returned by
+// PushSubscription.toJSON() in the browser, with what it is notified of.
+type PushSubscription struct {
+	ID       string   `json:"id"`
+	Endpoint string   `json:"endpoint"`
+	Keys     PushKeys `json:"keys"`
+	// Rules lists the alert rules whose matches are pushed; empty means all.
+	Rules []string `json:"rules,omitempty"`
+	// Breaking pushes breaking events when they are raised or escalate.
+	Breaking  bool      `json:"breaking,omitempty"`
+	Created   time.Time `json:"created"`
+	LastSent  time.Time `json:"lastSent,omitempty"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+// PushKeys are a subscription's base64url encoded P-256 public key and
+// authentication secret.
+type PushKeys struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
+
+// BreakingSettings controls breaking-news detection. Zero values fall back to
+// the defaults in the services package.
+type BreakingSettings struct {
+	// WindowMinutes is how recent items must be to count towards a surge.
+	WindowMinutes int `json:"windowMinutes,omitempty"`
+	// MinSources is how many different sources must cover a story or term
+	// within the window for it to be breaking.
+	MinSources int `json:"minSources,omitempty"`
+	// Disabled turns detection off.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// Follow types.
+const (
+	FollowCluster = "cluster"
+	FollowEntity  = "entity"
+	FollowSearch  = "search"
+	FollowTag     = "tag"
+)
+
+// Follow is a story cluster, entity, search or tag the user keeps up with.
+type Follow struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// Target is the cluster ID, entity ID, search query or tag ID followed.
+	Target string `json:"target"`
+	Name   string `json:"name"`
+	// Members are the items known to cover a followed cluster, so the story
+	// is still found once its first item has left the cache.
+	Members   []string  `json:"members,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	// LastSeen is when the timeline was last looked at; items published
+	// after it are new.
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// ClassifierSettings controls the tags learned from manual tagging. Zero
+// values fall back to the defaults in the services package.
+type ClassifierSettings struct {
+	// SuggestThreshold is the confidence from which a tag is suggested.
+	SuggestThreshold float64 `json:"suggestThreshold,omitempty"`
+	// AutoApplyThreshold is the confidence from which a tag is applied
+	// without asking.
+	AutoApplyThreshold float64 `json:"autoApplyThreshold,omitempty"`
+	// MinExamples is how many tagged and how many untagged examples a tag
+	// needs before it is learned.
+	MinExamples int `json:"minExamples,omitempty"`
+	// Disabled turns suggestions and auto-applied tags off.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// RankingSettings tunes the personalised ordering of /api/news. Zero values
+// fall back to the defaults in the services package, except for the weights,
+// which fall back when unset so that a weight of 0 turns its signal off.
+type RankingSettings struct {
+	HalfLifeHours    float64  `json:"halfLifeHours,omitempty"`
+	RecencyWeight    *float64 `json:"recencyWeight,omitempty"`
+	InterestWeight   *float64 `json:"interestWeight,omitempty"`
+	TagWeight        *float64 `json:"tagWeight,omitempty"`
+	SourceWeight     *float64 `json:"sourceWeight,omitempty"`
+	EngagementWeight *float64 `json:"engagementWeight,omitempty"`
+
+	// InterestWeights maps a term to a weight; negative weights push
+	// matching items down. Plain Interests count with weight 1.
+	InterestWeights map[string]float64 `json:"interestWeights,omitempty"`
+	// TagWeights maps a tag ID to a weight.
+	TagWeights map[string]float64 `json:"tagWeights,omitempty"`
+	// SourcePriority maps a source name to a weight.
+	SourcePriority map[string]float64 `json:"sourcePriority,omitempty"`
+
+	// DiversityCap limits how many items one source may place among the
+	// first DiversityWindow results.
+	DiversityCap    int `json:"diversityCap,omitempty"`
+	DiversityWindow int `json:"diversityWindow,omitempty"`
 }
 
 type Preferences struct {
@@ -75,6 +481,7 @@ func NewDefaultPreferences() *UserPreferences {
 		APIKeys:      make(map[string]string),
 		Tags:         []Tag{},
 		NewsTags:     []NewsTag{},
+		ItemStates:   make(map[string]ItemState),
 	}
 }
 
@@ -125,14 +532,38 @@ var DefaultTags = []Tag{
 	{ID: "spanish", Name: "Spanish", Color: "#E67E22", Category: "language"},
 	{ID: "french", Name: "French", Color: "#F1C40F", Category: "language"},
 	{ID: "german", Name: "German", Color: "#2ECC71", Category: "language"},
+	{ID: "norwegian-bokmal", Name: "Norwegian Bokmål", Color: "#C0392B", Category: "language"},
+	{ID: "norwegian-nynorsk", Name: "Norwegian Nynorsk", Color: "#1F618D", Category: "language"},
+	{ID: "swedish", Name: "Swedish", Color: "#F4D03F", Category: "language"},
+	{ID: "danish", Name: "Danish", Color: "#E6B0AA", Category: "language"},
+	{ID: "finnish", Name: "Finnish", Color: "#5DADE2", Category: "language"},
+	{ID: "dutch", Name: "Dutch", Color: "#EB984E", Category: "language"},
+	{ID: "italian", Name: "Italian", Color: "#58D68D", Category: "language"},
+	{ID: "portuguese", Name: "Portuguese", Color: "#A569BD", Category: "language"},
 
 	// Topics
-	{ID: "politics", Name: "Politics", Color: "#E74C3C", Category: "topic"},
-	{ID: "economy", Name: "Economy", Color: "#27AE60", Category: "topic"},
-	{ID: "technology", Name: "Technology", Color: "#3498DB", Category: "topic"},
-	{ID: "science", Name: "Science", Color: "#8E44AD", Category: "topic"},
-	{ID: "health", Name: "Health", Color: "#2C3E50", Category: "topic"},
-	{ID: "sports", Name: "Sports", Color: "#F39C12", Category: "topic"},
-	{ID: "entertainment", Name: "Entertainment", Color: "#D35400", Category: "topic"},
-	{ID: "environment", Name: "Environment", Color: "#16A085", Category: "topic"},
+	{ID: "politics", Name: "Politics", Color: "#E74C3C", Category: "topic", Rules: []TagRule{
+		{Keywords: []string{"politics", "political", "government", "election", "elections", "president", "minister", "ministers"}},
+	}},
+	{ID: "economy", Name: "Economy", Color: "#27AE60", Category: "topic", Rules: []TagRule{
+		{Keywords: []string{"economy", "economic", "market", "markets", "stock", "stocks", "trade", "financial", "inflation"}},
+	}},
+	{ID: "technology", Name: "Technology", Color: "#3498DB", Category: "topic", Rules: []TagRule{
+		{Keywords: []string{"technology", "tech", "software", "digital", "cyber", "cybersecurity", "AI", "artificial intelligence"}},
+	}},
+	{ID: "science", Name: "Science", Color: "#8E44AD", Category: "topic", Rules: []TagRule{
+		{Keywords: []string{"science", "scientists", "research", "researchers", "study", "studies", "discovery"}},
+	}},
+	{ID: "health", Name: "Health", Color: "#2C3E50", Category: "topic", Rules: []TagRule{
+		{Keywords: []string{"health", "medical", "disease", "diseases", "treatment", "covid", "hospital"}},
+	}},
+	{ID: "sports", Name: "Sports", Color: "#F39C12", Category: "topic", Rules: []TagRule{
+		{Keywords: []string{"sports", "sport", "game", "games", "tournament", "championship", "player", "players"}},
+	}},
+	{ID: "entertainment", Name: "Entertainment", Color: "#D35400", Category: "topic", Rules: []TagRule{
+		{Keywords: []string{"entertainment", "movie", "movies", "film", "music", "celebrity", "art", "arts"}},
+	}},
+	{ID: "environment", Name: "Environment", Color: "#16A085", Category: "topic", Rules: []TagRule{
+		{Keywords: []string{"environment", "environmental", "climate", "pollution", "sustainable", "emissions"}},
+	}},
 }
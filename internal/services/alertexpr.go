@@ -0,0 +1,296 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/news-reader/internal/models"
+)
+
+// An alert expression combines conditions with AND, OR, NOT and
+// parentheses, AND binding tighter than OR:
+//
+//	tag=economy AND title contains 'Equinor'
+//	(source="NRK" OR source=VG) AND NOT category=Sports
+//
+// A condition is a field, an operator and a value. The operators are = and
+// != (equal ignoring case), contains (substring ignoring case) and matches
+// (regular expression). Values are bare words or quoted with ' or ".
+// Fields with several values, such as tag, match when any value does, and
+// != when none is equal.
+var alertFields = map[string]func(item *models.NewsItem) []string{
+	"title":       func(item *models.NewsItem) []string { return []string{item.Title} },
+	"description": func(item *models.NewsItem) []string { return []string{item.Description} },
+	"text":        func(item *models.NewsItem) []string { return []string{itemText(item)} },
+	"link":        func(item *models.NewsItem) []string { return []string{item.Link} },
+	"source":      func(item *models.NewsItem) []string { return []string{item.Source} },
+	"language":    func(item *models.NewsItem) []string { return []string{item.Language} },
+	"category": func(item *models.NewsItem) []string {
+		return append([]string{item.Category}, item.Categories...)
+	},
+	"tag": func(item *models.NewsItem) []string {
+		var values []string
+		for _, tag := range item.Tags {
+			values = append(values, tag.ID, tag.Name)
+		}
+		return values
+	},
+	"region": func(item *models.NewsItem) []string {
+		values := []string{item.Region}
+		for _, region := range item.Regions {
+			values = append(values, region.Region)
+		}
+		return values
+	},
+	"place": func(item *models.NewsItem) []string {
+		var values []string
+		for _, place := range item.Places {
+			values = append(values, place.ID, place.Name)
+		}
+		return values
+	},
+	"entity": func(item *models.NewsItem) []string {
+		var values []string
+		for _, entity := range item.Entities {
+			values = append(values, entity.ID, entity.Name)
+		}
+		return values
+	},
+}
+
+// alertExpr is a compiled alert expression.
+type alertExpr interface {
+	match(item *models.NewsItem) bool
+}
+
+type alertAnd struct{ left, right alertExpr }
+
+func (e alertAnd) match(item *models.NewsItem) bool { return e.left.match(item) && e.right.match(item) }
+
+type alertOr struct{ left, right alertExpr }
+
+func (e alertOr) match(item *models.NewsItem) bool { return e.left.match(item) || e.right.match(item) }
+
+type alertNot struct{ expr alertExpr }
+
+func (e alertNot) match(item *models.NewsItem) bool { return !e.expr.match(item) }
+
+type alertCondition struct {
+	values  func(item *models.NewsItem) []string
+	op      string
+	value   string
+	pattern *regexp.Regexp
+}
+
+func (c alertCondition) match(item *models.NewsItem) bool {
+	if c.op == "!=" {
+		for _, v := range c.values(item) {
+			if strings.EqualFold(v, c.value) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, v := range c.values(item) {
+		switch c.op {
+		case "=":
+			if strings.EqualFold(v, c.value) {
+				return true
+			}
+		case "contains":
+			if strings.Contains(strings.ToLower(v), c.value) {
+				return true
+			}
+		case "matches":
+			if c.pattern.MatchString(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// alertToken is a word, a quoted string or one of = != ( ).
+type alertToken struct {
+	text   string
+	quoted bool
+	pos    int
+}
+
+func tokenizeAlertExpr(expr string) ([]alertToken, error) {
+	var tokens []alertToken
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == '=':
+			tokens = append(tokens, alertToken{text: string(r), pos: i})
+			i++
+		case r == '!':
+			if i+1 >= len(runes) || runes[i+1] != '=' {
+				return nil, fmt.Errorf("%w: unexpected ! at %d", ErrInvalidAlertRule, i)
+			}
+			tokens = append(tokens, alertToken{text: "!=", pos: i})
+			i += 2
+		case r == '\'' || r == '"':
+			var value strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				// A backslash only escapes the quote, so patterns keep theirs
+				if runes[j] == '\\' && j+1 < len(runes) && runes[j+1] == r {
+					j++
+				}
+				value.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("%w: unterminated string at %d", ErrInvalidAlertRule, i)
+			}
+			tokens = append(tokens, alertToken{text: value.String(), quoted: true, pos: i})
+			i = j + 1
+		default:
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune("()=!'\"", runes[j]) {
+				j++
+			}
+			tokens = append(tokens, alertToken{text: string(runes[i:j]), pos: i})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// alertParser is a recursive descent parser over alert tokens.
+type alertParser struct {
+	tokens []alertToken
+	pos    int
+}
+
+// compileAlertExpr parses an alert expression.
+func compileAlertExpr(expr string) (alertExpr, error) {
+	tokens, err := tokenizeAlertExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: empty expression", ErrInvalidAlertRule)
+	}
+
+	p := &alertParser{tokens: tokens}
+	compiled, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q at %d", ErrInvalidAlertRule, p.tokens[p.pos].text, p.tokens[p.pos].pos)
+	}
+	return compiled, nil
+}
+
+// keyword reports whether the next token is the unquoted keyword, and
+// consumes it if so.
+func (p *alertParser) keyword(word string) bool {
+	if p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *alertParser) or() (alertExpr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = alertOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *alertParser) and() (alertExpr, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = alertAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *alertParser) not() (alertExpr, error) {
+	if p.keyword("NOT") {
+		expr, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return alertNot{expr}, nil
+	}
+	if p.keyword("(") {
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword(")") {
+			return nil, fmt.Errorf("%w: missing )", ErrInvalidAlertRule)
+		}
+		return expr, nil
+	}
+	return p.condition()
+}
+
+func (p *alertParser) condition() (alertExpr, error) {
+	if p.pos+3 > len(p.tokens) {
+		return nil, fmt.Errorf("%w: incomplete condition at the end", ErrInvalidAlertRule)
+	}
+	field, op, value := p.tokens[p.pos], p.tokens[p.pos+1], p.tokens[p.pos+2]
+	p.pos += 3
+
+	values, ok := alertFields[strings.ToLower(field.text)]
+	if field.quoted || !ok {
+		return nil, fmt.Errorf("%w: unknown field %q at %d", ErrInvalidAlertRule, field.text, field.pos)
+	}
+	if !value.quoted {
+		switch value.text {
+		case "(", ")", "=", "!=":
+			return nil, fmt.Errorf("%w: missing value at %d", ErrInvalidAlertRule, value.pos)
+		}
+	}
+
+	cond := alertCondition{values: values, op: strings.ToLower(op.text), value: value.text}
+	if op.quoted {
+		cond.op = ""
+	}
+	switch cond.op {
+	case "=", "!=":
+		if strings.EqualFold(field.text, "language") {
+			if language := languageFromCode(value.text); language != "" {
+				cond.value = language
+			}
+		}
+	case "contains":
+		cond.value = strings.ToLower(value.text)
+	case "matches":
+		pattern, err := regexp.Compile(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidAlertRule, err)
+		}
+		cond.pattern = pattern
+	default:
+		return nil, fmt.Errorf("%w: unknown operator %q at %d", ErrInvalidAlertRule, op.text, op.pos)
+	}
+	return cond, nil
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/news-reader/internal/models"
+)
+
+func TestCompileAlertExpr(t *testing.T) {
+	item := models.NewsItem{
+		Title:    "Equinor raises dividend after record quarter",
+		Source:   "E24",
+		Language: "norwegian-bokmal",
+		Category: "Business",
+		Tags:     []models.Tag{{ID: "economy", Name: "Economy"}},
+		Entities: []models.Entity{{ID: "org:equinor", Name: "Equinor", Type: models.EntityOrg}},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"tag=economy AND title contains 'Equinor'", true},
+		{"tag=Economy and title contains \"equinor\"", true},
+		{"tag=sports OR source=e24", true},
+		{"tag=economy AND NOT category=Business", false},
+		{"(source=NRK OR source=VG) AND tag=economy", false},
+		{"tag!=sports", true},
+		{"tag!=economy", false},
+		{"language=no", true},
+		{"title matches '^Equinor\\s+raises'", true},
+		{"entity='org:equinor'", true},
+		{"NOT NOT title contains dividend", true},
+	}
+	for _, tt := range tests {
+		expr, err := compileAlertExpr(tt.expr)
+		if err != nil {
+			t.Errorf("compileAlertExpr(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if got := expr.match(&item); got != tt.want {
+			t.Errorf("compileAlertExpr(%q).match() = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{
+		"",
+		"tag",
+		"tag=",
+		"colour=red",
+		"title like 'x'",
+		"title contains 'unterminated",
+		"(tag=economy",
+		"tag=economy AND",
+		"title matches '('",
+		"tag=economy source=VG",
+	} {
+		if _, err := compileAlertExpr(expr); !errors.Is(err, ErrInvalidAlertRule) {
+			t.Errorf("compileAlertExpr(%q) error = %v, want ErrInvalidAlertRule", expr, err)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+var (
+	// ErrAlertRuleNotFound is returned when an ID matches no alert rule.
+	ErrAlertRuleNotFound = errors.New("alert rule not found")
+	// ErrInvalidAlertRule is wrapped by errors describing an alert rule
+	// that cannot be used, such as one whose expression does not parse.
+	ErrInvalidAlertRule = errors.New("invalid alert rule")
+)
+
+// defaultMaxPerHour is the delivery limit of rules that do not set one.
+const defaultMaxPerHour = 10
+
+// AlertRules returns the user's alert rules, without their secrets.
+func (s *NewsService) AlertRules() []models.AlertRule {
+	return redactAlertRules(s.alertRules())
+}
+
+// alertRules returns the user's alert rules with their secrets, for
+// delivering.
+func (s *NewsService) alertRules() []models.AlertRule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]models.AlertRule{}, s.preferences.Alerts...)
+}
+
+// redactAlertRules returns copies of rules with each secret replaced by
+// HasSecret.
+func redactAlertRules(rules []models.AlertRule) []models.AlertRule {
+	if rules == nil {
+		return nil
+	}
+	redacted := make([]models.AlertRule, len(rules))
+	for i, rule := range rules {
+		redacted[i] = redactAlertRule(rule)
+	}
+	return redacted
+}
+
+func redactAlertRule(rule models.AlertRule) models.AlertRule {
+	rule.HasSecret = rule.Secret != ""
+	rule.Secret = ""
+	return rule
+}
+
+// keepAlertSecret gives update the secret of stored when the client sent
+// back a rule it read, which has HasSecret set but no secret.
+func keepAlertSecret(update *models.AlertRule, stored models.AlertRule) {
+	if update.Secret == "" && update.HasSecret {
+		update.Secret = stored.Secret
+	}
+	update.HasSecret = false
+}
+
+// CreateAlertRule validates and saves a new alert rule.
+func (s *NewsService) CreateAlertRule(rule models.AlertRule) (models.AlertRule, error) {
+	if err := validateAlertRule(&rule); err != nil {
+		return models.AlertRule{}, err
+	}
+
+	hash := sha256.Sum256([]byte(rule.Name + rule.Expression + time.Now().String()))
+	rule.ID = hex.EncodeToString(hash[:])[:8]
+	rule.HasSecret = false
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.preferences.Alerts = append(s.preferences.Alerts, rule)
+	if err := s.savePreferences(); err != nil {
+		return models.AlertRule{}, err
+	}
+	return redactAlertRule(rule), nil
+}
+
+// UpdateAlertRule replaces the alert rule id with update.
+func (s *NewsService) UpdateAlertRule(id string, update models.AlertRule) (models.AlertRule, error) {
+	if err := validateAlertRule(&update); err != nil {
+		return models.AlertRule{}, err
+	}
+	update.ID = id
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, rule := range s.preferences.Alerts {
+		if rule.ID == id {
+			keepAlertSecret(&update, rule)
+			s.preferences.Alerts[i] = update
+			if err := s.savePreferences(); err != nil {
+				return models.AlertRule{}, err
+			}
+			return redactAlertRule(update), nil
+		}
+	}
+	return models.AlertRule{}, ErrAlertRuleNotFound
+}
+
+// DeleteAlertRule removes the alert rule id.
+func (s *NewsService) DeleteAlertRule(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, rule := range s.preferences.Alerts {
+		if rule.ID == id {
+			s.preferences.Alerts = append(s.preferences.Alerts[:i], s.preferences.Alerts[i+1:]...)
+			return s.savePreferences()
+		}
+	}
+	return ErrAlertRuleNotFound
+}
+
+// TestAlertRule delivers the most recent cached items matching rule id, or
+// a sample item if none does, once and regardless of quiet hours and rate
+// limits.
+func (s *NewsService) TestAlertRule(id string) (Delivery, error) {
+	rule, ok := s.alertRule(id)
+	if !ok {
+		return Delivery{}, ErrAlertRuleNotFound
+	}
+	if rule.URL == "" {
+		return Delivery{}, fmt.Errorf("%w: the rule has no webhook", ErrInvalidAlertRule)
+	}
+	expr, err := compileAlertExpr(rule.Expression)
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	var matched []models.NewsItem
+	for _, item := range s.SortByRecency(s.GetAllNews()) {
+		if expr.match(&item) {
+			matched = append(matched, item)
+			if len(matched) == 3 {
+				break
+			}
+		}
+	}
+	if len(matched) == 0 {
+		matched = []models.NewsItem{{
+			ID:          "test",
+			Title:       "Test alert for " + rule.Name,
+			Description: "No cached item matches " + rule.Expression,
+			Source:      "News Reader",
+			Published:   time.Now(),
+		}}
+	}
+
+	return s.alerts.deliver(rule, matched, 1), nil
+}
+
+func (s *NewsService) alertRule(id string) (models.AlertRule, bool) {
+	for _, rule := range s.alertRules() {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+	return models.AlertRule{}, false
+}
+
+// runAlerts posts the items of a newly fetched batch to the webhook and the
+// push subscribers of every enabled rule they match. Rules in their quiet
+// hours or over their hourly limit log the batch as suppressed instead.
+func (s *NewsService) runAlerts(items []models.NewsItem) {
+	if s.alerts == nil || len(items) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for _, rule := range s.alertRules() {
+		if rule.Disabled {
+			continue
+		}
+		expr, err := compileAlertExpr(rule.Expression)
+		if err != nil {
+			continue
+		}
+
+		var matched []models.NewsItem
+		for i := range items {
+			if expr.match(&items[i]) {
+				matched = append(matched, items[i])
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		if inQuietHours(rule.QuietHours, now) {
+			s.alerts.suppress(rule, matched, DeliveryQuiet, now)
+			continue
+		}
+		if !s.alerts.allow(rule, now) {
+			s.alerts.suppress(rule, matched, DeliveryRateLimited, now)
+			continue
+		}
+		if rule.URL != "" {
+			go s.alerts.deliver(rule, matched, webhookMaxAttempts)
+		}
+		go s.pushAlert(rule, matched)
+	}
+}
+
+// newItems returns the items of batch whose IDs are not in previous.
+func newItems(previous, batch []models.NewsItem) []models.NewsItem {
+	known := make(map[string]bool, len(previous))
+	for _, item := range previous {
+		known[item.ID] = true
+	}
+	var added []models.NewsItem
+	for _, item := range batch {
+		if !known[item.ID] {
+			added = append(added, item)
+		}
+	}
+	return added
+}
+
+// validateAlertRule checks a rule and fills in its defaults.
+func validateAlertRule(rule *models.AlertRule) error {
+	if _, err := compileAlertExpr(rule.Expression); err != nil {
+		return err
+	}
+
+	if rule.URL != "" {
+		u, err := url.Parse(rule.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: url must be an http or https URL", ErrInvalidAlertRule)
+		}
+	}
+
+	switch rule.Format {
+	case "":
+		rule.Format = models.WebhookJSON
+	case models.WebhookJSON, models.WebhookSlack, models.WebhookDiscord:
+	default:
+		return fmt.Errorf("%w: format must be json, slack or discord", ErrInvalidAlertRule)
+	}
+
+	if rule.MaxPerHour < 0 {
+		return fmt.Errorf("%w: maxPerHour must not be negative", ErrInvalidAlertRule)
+	}
+
+	if q := rule.QuietHours; q != nil {
+		if _, err := time.Parse("15:04", q.Start); err != nil {
+			return fmt.Errorf("%w: quiet hours start must be a time such as 22:00", ErrInvalidAlertRule)
+		}
+		if _, err := time.Parse("15:04", q.End); err != nil {
+			return fmt.Errorf("%w: quiet hours end must be a time such as 07:00", ErrInvalidAlertRule)
+		}
+		if _, err := quietLocation(q); err != nil {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidAlertRule, q.Timezone)
+		}
+	}
+
+	if strings.TrimSpace(rule.Name) == "" {
+		rule.Name = rule.Expression
+	}
+	return nil
+}
+
+// inQuietHours reports whether now falls within the quiet hours.
+func inQuietHours(q *models.QuietHours, now time.Time) bool {
+	if q == nil {
+		return false
+	}
+	start, err1 := time.Parse("15:04", q.Start)
+	end, err2 := time.Parse("15:04", q.End)
+	loc, err3 := quietLocation(q)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return false
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+	if from <= to {
+		return minute >= from && minute < to
+	}
+	// The quiet hours span midnight
+	return minute >= from || minute < to
+}
+
+func quietLocation(q *models.QuietHours) (*time.Location, error) {
+	if q.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(q.Timezone)
+}
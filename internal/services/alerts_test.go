@@ -0,0 +1,270 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+func TestAlertRules(t *testing.T) {
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+
+	invalid := []models.AlertRule{
+		{Expression: "tag=", URL: "https://example.com/hook"},
+		{Expression: "tag=economy", URL: "ftp://example.com/hook"},
+		{Expression: "tag=economy", URL: "https://example.com/hook", Format: "teams"},
+		{Expression: "tag=economy", URL: "https://example.com/hook", QuietHours: &models.QuietHours{Start: "22", End: "07:00"}},
+		{Expression: "tag=economy", URL: "https://example.com/hook", QuietHours: &models.QuietHours{Start: "22:00", End: "07:00", Timezone: "Mars/Olympus"}},
+	}
+	for _, rule := range invalid {
+		if _, err := service.CreateAlertRule(rule); !errors.Is(err, ErrInvalidAlertRule) {
+			t.Errorf("CreateAlertRule(%+v) error = %v, want ErrInvalidAlertRule", rule, err)
+		}
+	}
+
+	rule, err := service.CreateAlertRule(models.AlertRule{Expression: "tag=economy", URL: "https://example.com/hook"})
+	if err != nil {
+		t.Fatalf("Failed to create alert rule: %v", err)
+	}
+	if rule.ID == "" || rule.Format != models.WebhookJSON || rule.Name != "tag=economy" {
+		t.Errorf("CreateAlertRule() = %+v, want an ID and the default format and name", rule)
+	}
+
+	rule.Format = models.WebhookSlack
+	if updated, err := service.UpdateAlertRule(rule.ID, rule); err != nil || updated.Format != models.WebhookSlack {
+		t.Errorf("UpdateAlertRule() = %+v, %v, want the slack format", updated, err)
+	}
+
+	// Secrets are write-only, and kept when a rule is sent back as read
+	rule.Secret = "s3cret"
+	if updated, err := service.UpdateAlertRule(rule.ID, rule); err != nil || updated.Secret != "" || !updated.HasSecret {
+		t.Errorf("UpdateAlertRule() = %+v, %v, want the secret left out", updated, err)
+	}
+	read := service.AlertRules()[0]
+	if read.Secret != "" || !read.HasSecret {
+		t.Errorf("AlertRules() = %+v, want the secret left out", read)
+	}
+	if _, err := service.UpdateAlertRule(rule.ID, read); err != nil {
+		t.Fatalf("Failed to update alert rule: %v", err)
+	}
+	clientPrefs := service.ClientPreferences()
+	if clientPrefs.Alerts[0].Secret != "" {
+		t.Errorf("ClientPreferences() = %+v, want the secret left out", clientPrefs.Alerts)
+	}
+	if err := service.UpdatePreferences(clientPrefs); err != nil {
+		t.Fatalf("Failed to update preferences: %v", err)
+	}
+	if stored, _ := service.alertRule(rule.ID); stored.Secret != "s3cret" {
+		t.Errorf("Stored secret = %q, want it kept", stored.Secret)
+	}
+	rule.Secret = ""
+	if _, err := service.UpdateAlertRule("missing", rule); !errors.Is(err, ErrAlertRuleNotFound) {
+		t.Errorf("UpdateAlertRule(missing) error = %v, want ErrAlertRuleNotFound", err)
+	}
+
+	// Alert rules survive preference updates that leave them out
+	prefs := *service.GetPreferences()
+	prefs.Alerts = nil
+	if err := service.UpdatePreferences(prefs); err != nil {
+		t.Fatalf("Failed to update preferences: %v", err)
+	}
+	if rules := service.AlertRules(); len(rules) != 1 {
+		t.Errorf("AlertRules() after UpdatePreferences = %+v, want the rule kept", rules)
+	}
+
+	if err := service.DeleteAlertRule(rule.ID); err != nil {
+		t.Errorf("DeleteAlertRule() error = %v", err)
+	}
+	if err := service.DeleteAlertRule(rule.ID); !errors.Is(err, ErrAlertRuleNotFound) {
+		t.Errorf("DeleteAlertRule() twice error = %v, want ErrAlertRuleNotFound", err)
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	backoff := webhookBackoff
+	webhookBackoff = []time.Duration{time.Millisecond}
+	defer func() { webhookBackoff = backoff }()
+
+	var mu sync.Mutex
+	var bodies []string
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		want := "sha256=" + signWebhook("s3cret", r.Header.Get("X-Webhook-Timestamp"), body)
+		if got := r.Header.Get("X-Webhook-Signature"); got != want {
+			t.Errorf("X-Webhook-Signature = %q, want %q", got, want)
+		}
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	items := []models.NewsItem{{
+		ID:     "a",
+		Title:  "Equinor <raises> dividend & more",
+		Link:   "https://example.com/a",
+		Source: "E24",
+		Tags:   []models.Tag{{ID: "economy"}},
+	}}
+	rule := models.AlertRule{ID: "r1", Name: "Equinor", URL: server.URL, Format: models.WebhookJSON, Secret: "s3cret"}
+
+	d := newAlertDispatcher()
+	delivery := d.deliver(rule, items, webhookMaxAttempts)
+	if delivery.Status != DeliveryDelivered || delivery.Attempts != 2 || delivery.StatusCode != http.StatusOK {
+		t.Errorf("deliver() = %+v, want delivered on the second attempt", delivery)
+	}
+	var payload struct {
+		Rule  struct{ ID string }
+		Items []alertItem
+	}
+	if err := json.Unmarshal([]byte(bodies[0]), &payload); err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+	if payload.Rule.ID != "r1" || len(payload.Items) != 1 || payload.Items[0].Tags[0] != "economy" {
+		t.Errorf("JSON payload = %s, want the rule and the item", bodies[0])
+	}
+
+	rule.Format = models.WebhookSlack
+	d.deliver(rule, items, 1)
+	if !strings.Contains(bodies[1], `<https://example.com/a|Equinor &lt;raises&gt; dividend &amp; more>`) {
+		t.Errorf("Slack payload = %s, want an escaped link", bodies[1])
+	}
+	tricky := []models.NewsItem{{Title: "a|b", Link: "https://example.com/?q=a|b&x=<y>"}}
+	payload2, err := webhookPayload(rule, tricky, "d1", time.Now())
+	if err != nil {
+		t.Fatalf("Failed to render payload: %v", err)
+	}
+	if !strings.Contains(string(payload2), `<https://example.com/?q=a%7Cb&amp;x=%3Cy%3E|a|b>`) {
+		t.Errorf("Slack payload = %s, want the link's |, & and <> escaped", payload2)
+	}
+
+	rule.Format = models.WebhookDiscord
+	many := make([]models.NewsItem, 12)
+	for i := range many {
+		many[i] = items[0]
+	}
+	d.deliver(rule, many, 1)
+	var discord struct {
+		Content string
+		Embeds  []json.RawMessage
+	}
+	if err := json.Unmarshal([]byte(bodies[2]), &discord); err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+	if len(discord.Embeds) != maxDiscordEmbeds || !strings.Contains(discord.Content, "12 new") {
+		t.Errorf("Discord payload = %s, want %d embeds for 12 items", bodies[2], maxDiscordEmbeds)
+	}
+
+	rule.URL = server.URL + "/gone"
+	server.Config.Handler = http.NotFoundHandler()
+	if delivery := d.deliver(rule, items, webhookMaxAttempts); delivery.Status != DeliveryFailed || delivery.Attempts != 1 {
+		t.Errorf("deliver() to a 404 = %+v, want failed without retries", delivery)
+	}
+
+	service := &NewsService{alerts: d}
+	if deliveries := service.Deliveries("r1"); len(deliveries) != 4 || deliveries[0].StatusCode != http.StatusNotFound {
+		t.Errorf("Deliveries(r1) = %+v, want 4, newest first", deliveries)
+	}
+}
+
+func TestAlertLimits(t *testing.T) {
+	d := newAlertDispatcher()
+	rule := models.AlertRule{ID: "r1", MaxPerHour: 2}
+	now := time.Now()
+
+	if !d.allow(rule, now) || !d.allow(rule, now.Add(time.Minute)) {
+		t.Errorf("allow() refused a delivery under the limit")
+	}
+	if d.allow(rule, now.Add(2*time.Minute)) {
+		t.Errorf("allow() accepted a third delivery within the hour")
+	}
+	if !d.allow(rule, now.Add(61*time.Minute)) {
+		t.Errorf("allow() refused a delivery after the first left the hour")
+	}
+
+	utc := time.Date(2024, 3, 1, 23, 30, 0, 0, time.UTC)
+	tests := []struct {
+		quiet *models.QuietHours
+		now   time.Time
+		want  bool
+	}{
+		{nil, utc, false},
+		{&models.QuietHours{Start: "22:00", End: "07:00", Timezone: "UTC"}, utc, true},
+		{&models.QuietHours{Start: "22:00", End: "07:00", Timezone: "UTC"}, utc.Add(8 * time.Hour), false},
+		{&models.QuietHours{Start: "09:00", End: "17:00", Timezone: "UTC"}, utc, false},
+		// 23:30 UTC is 00:30 in Oslo
+		{&models.QuietHours{Start: "00:00", End: "01:00", Timezone: "Europe/Oslo"}, utc, true},
+	}
+	for _, tt := range tests {
+		if got := inQuietHours(tt.quiet, tt.now); got != tt.want {
+			t.Errorf("inQuietHours(%+v, %v) = %v, want %v", tt.quiet, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestRunAlerts(t *testing.T) {
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+	}))
+	defer server.Close()
+
+	for _, rule := range []models.AlertRule{
+		{Expression: "title contains storm", URL: server.URL},
+		{Expression: "title contains storm", URL: server.URL, QuietHours: &models.QuietHours{Start: "00:00", End: "23:59"}},
+		{Expression: "title contains budget", URL: server.URL},
+		{Expression: "title contains storm", URL: server.URL, Disabled: true},
+	} {
+		if _, err := service.CreateAlertRule(rule); err != nil {
+			t.Fatalf("Failed to create alert rule: %v", err)
+		}
+	}
+
+	previous := []models.NewsItem{{ID: "a", Title: "Storm warning for the west coast"}}
+	batch := append(previous, models.NewsItem{ID: "b", Title: "Storm closes roads"})
+	service.runAlerts(newItems(previous, batch))
+
+	select {
+	case body := <-received:
+		if !strings.Contains(body, "Storm closes roads") || strings.Contains(body, "west coast") {
+			t.Errorf("Webhook received %s, want only the new storm item", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("No webhook delivery")
+	}
+	select {
+	case body := <-received:
+		t.Errorf("Webhook received a second delivery %s", body)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	statuses := map[string]int{}
+	for _, delivery := range service.Deliveries("") {
+		statuses[delivery.Status]++
+	}
+	if statuses[DeliveryQuiet] != 1 {
+		t.Errorf("Delivery statuses = %v, want one batch held for quiet hours", statuses)
+	}
+}
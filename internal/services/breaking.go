@@ -0,0 +1,474 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+// Defaults for models.BreakingSettings.
+const (
+	defaultBreakingWindow     = 20 * time.Minute
+	defaultBreakingMinSources = 5
+)
+
+const (
+	// breakingMinAcceleration is how many times more often than the trending
+	// baseline predicts a term must be mentioned to count as a surge, so
+	// words every source uses all the time do not.
+	breakingMinAcceleration = 3
+	// breakingRetention is how long an event is kept after it last surged.
+	breakingRetention = 24 * time.Hour
+)
+
+// Severity levels of breaking events. An event is low at the minimum number
+// of sources, medium at twice and high at three times as many.
+const (
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+// Kinds of breaking events.
+const (
+	BreakingCluster = "cluster"
+	BreakingTerm    = "term"
+)
+
+// BreakingEvent is a story that many sources started covering at once.
+type BreakingEvent struct {
+	// ID stays the same while the event lasts, even if the cluster it is
+	// attached to is renamed.
+	ID        string `json:"id"`
+	ClusterID string `json:"clusterId"`
+	Title     string `json:"title"`
+	// Kind is BreakingCluster when the story's own coverage surged and
+	// BreakingTerm when only terms it shares with other coverage did.
+	Kind string `json:"kind"`
+	// Terms are the surging words and word pairs found in the story.
+	Terms    []string `json:"terms,omitempty"`
+	Severity string   `json:"severity"`
+	// Sources and Items are the coverage published within the window.
+	Sources []string               `json:"sources"`
+	Items   []models.ClusterMember `json:"items"`
+	Started time.Time              `json:"started"`
+	Updated time.Time              `json:"updated"`
+	// Active is false once the surge is over.
+	Active bool `json:"active"`
+}
+
+// breakingDetector holds the events raised so far.
+type breakingDetector struct {
+	mu     sync.Mutex
+	events map[string]*BreakingEvent
+	// version and minute are the trending index version and the minute of
+	// the last run, so unchanged items are not checked twice a minute.
+	version uint64
+	minute  time.Time
+}
+
+func newBreakingDetector() *breakingDetector {
+	return &breakingDetector{events: make(map[string]*BreakingEvent)}
+}
+
+func (s *NewsService) breakingSettings() models.BreakingSettings {
+	settings := s.preferences.Breaking
+	if settings.WindowMinutes <= 0 {
+		settings.WindowMinutes = int(defaultBreakingWindow / time.Minute)
+	}
+	if settings.MinSources <= 0 {
+		settings.MinSources = defaultBreakingMinSources
+	}
+	return settings
+}
+
+// updateBreaking runs detection over the cached items when they changed or
+// a minute passed since the last run, returning the events raised.
+func (s *NewsService) updateBreaking(items []models.NewsItem) []BreakingEvent {
+	if s.breaking == nil || s.trendIndex == nil {
+		return nil
+	}
+	s.trendIndex.mu.Lock()
+	version := s.trendIndex.version
+	s.trendIndex.mu.Unlock()
+
+	now := time.Now()
+	minute := now.Truncate(time.Minute)
+	s.breaking.mu.Lock()
+	unchanged := s.breaking.version == version && s.breaking.minute.Equal(minute)
+	s.breaking.version, s.breaking.minute = version, minute
+	s.breaking.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+	return s.DetectBreaking(items, now)
+}
+
+// DetectBreaking looks for stories that at least the minimum number of
+// sources published about within the window before now, either as one
+// cluster or by using the same unusual term, and raises an event attached to
+// the story's cluster. It returns the events that were raised or grew more
+// severe; events that stopped surging are kept but marked inactive.
+func (s *NewsService) DetectBreaking(items []models.NewsItem, now time.Time) []BreakingEvent {
+	if s.breaking == nil {
+		return nil
+	}
+	s.mu.RLock()
+	settings := s.breakingSettings()
+	s.mu.RUnlock()
+	if settings.Disabled {
+		return nil
+	}
+
+	window := time.Duration(settings.WindowMinutes) * time.Minute
+	surges := s.breakingSurges(items, now, window, settings.MinSources)
+	return s.breaking.update(surges, now, settings.MinSources)
+}
+
+// breakingSurge is a cluster whose coverage, or whose terms, surged.
+type breakingSurge struct {
+	cluster models.StoryCluster
+	kind    string
+	terms   map[string]int
+	sources map[string]bool
+	items   map[string]models.NewsItem
+}
+
+func (b *breakingSurge) add(item models.NewsItem) {
+	b.sources[item.Source] = true
+	b.items[item.ID] = item
+}
+
+func (s *NewsService) breakingSurges(items []models.NewsItem, now time.Time, window time.Duration, minSources int) []*breakingSurge {
+	windowStart := now.Add(-window)
+	baselineStart := windowStart.Add(-defaultTrendingBaseline)
+	inWindow := func(item models.NewsItem) bool {
+		return !item.Published.Before(windowStart) && !item.Published.After(now)
+	}
+
+	// All items are clustered, as view=clusters does, so a story's cluster
+	// is named after its earliest item even when that is older than the
+	// window and events carry the cluster IDs clients see there
+	clustered := append([]models.NewsItem(nil), items...)
+	clusters := s.ClusterNews(clustered)
+	clusterIndex := make(map[string]int, len(clusters))
+	for i, cluster := range clusters {
+		clusterIndex[cluster.ID] = i
+	}
+	clusterOf := make(map[string]int, len(clustered))
+	for _, item := range clustered {
+		clusterOf[item.ID] = clusterIndex[item.ClusterID]
+	}
+
+	surges := make(map[int]*breakingSurge)
+	surgeFor := func(i int, kind string) *breakingSurge {
+		surge := surges[i]
+		if surge == nil {
+			surge = &breakingSurge{
+				cluster: clusters[i],
+				kind:    kind,
+				terms:   make(map[string]int),
+				sources: make(map[string]bool),
+				items:   make(map[string]models.NewsItem),
+			}
+			surges[i] = surge
+		}
+		return surge
+	}
+
+	// Clusters covered by enough sources within the window
+	byCluster := make(map[int][]models.NewsItem)
+	for _, item := range clustered {
+		if !inWindow(item) {
+			continue
+		}
+		i := clusterOf[item.ID]
+		byCluster[i] = append(byCluster[i], item)
+	}
+	for i, members := range byCluster {
+		if countSources(members) < minSources {
+			continue
+		}
+		surge := surgeFor(i, BreakingCluster)
+		for _, item := range members {
+			surge.add(item)
+		}
+	}
+
+	// Terms used by enough sources within the window and rare before it
+	type termCount struct {
+		items    []models.NewsItem
+		forms    map[string]int
+		baseline int
+	}
+	terms := make(map[string]*termCount)
+	for _, item := range items {
+		if item.Published.Before(baselineStart) || item.Published.After(now) {
+			continue
+		}
+		current := inWindow(item)
+		for key, term := range s.indexedTrendingDoc(item).terms {
+			tc := terms[key]
+			if tc == nil {
+				tc = &termCount{forms: make(map[string]int)}
+				terms[key] = tc
+			}
+			if !current {
+				tc.baseline++
+				continue
+			}
+			tc.items = append(tc.items, item)
+			tc.forms[term.form]++
+		}
+	}
+	scale := window.Hours() / defaultTrendingBaseline.Hours()
+	for _, tc := range terms {
+		sources := countSources(tc.items)
+		expected := float64(tc.baseline) * scale
+		if sources < minSources || (float64(len(tc.items))+1)/(expected+1) < breakingMinAcceleration {
+			continue
+		}
+
+		// Attach the term to the cluster most of its items belong to
+		perCluster := make(map[int]int)
+		best := -1
+		for _, item := range tc.items {
+			i := clusterOf[item.ID]
+			perCluster[i]++
+			if best < 0 || perCluster[i] > perCluster[best] || (perCluster[i] == perCluster[best] && i < best) {
+				best = i
+			}
+		}
+		surge := surgeFor(best, BreakingTerm)
+		surge.terms[mostCommonForm(tc.forms)] = sources
+		for _, item := range tc.items {
+			surge.add(item)
+		}
+	}
+
+	result := make([]*breakingSurge, 0, len(surges))
+	for _, surge := range surges {
+		result = append(result, surge)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].cluster.ID < result[j].cluster.ID
+	})
+	return result
+}
+
+// update turns this run's surges into events, reusing the event of an
+// earlier run that shares items with a surge.
+func (d *breakingDetector) update(surges []*breakingSurge, now time.Time, minSources int) []BreakingEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var changed []BreakingEvent
+	surging := make(map[string]bool)
+	for _, surge := range surges {
+		event := d.eventFor(surge)
+		if event == nil {
+			event = &BreakingEvent{ID: surge.cluster.ID, Started: now}
+		}
+		previous := *event
+
+		event.ClusterID = surge.cluster.ID
+		event.Title = surge.cluster.Representative.Title
+		event.Kind = surge.kind
+		event.Terms = sortedTerms(surge.terms)
+		event.Sources = make([]string, 0, len(surge.sources))
+		for source := range surge.sources {
+			event.Sources = append(event.Sources, source)
+		}
+		sort.Strings(event.Sources)
+		event.Items = breakingItems(surge.items)
+		event.Severity = breakingSeverity(len(event.Sources), minSources)
+		event.Updated = now
+		event.Active = true
+
+		d.events[event.ID] = event
+		surging[event.ID] = true
+		if !previous.Active || severityRank(event.Severity) > severityRank(previous.Severity) {
+			changed = append(changed, *event)
+		}
+	}
+
+	for id, event := range d.events {
+		if surging[id] {
+			continue
+		}
+		event.Active = false
+		if now.Sub(event.Updated) > breakingRetention {
+			delete(d.events, id)
+		}
+	}
+	return changed
+}
+
+// eventFor returns the known event covering any of the surge's items.
+// Callers must hold d.mu.
+func (d *breakingDetector) eventFor(surge *breakingSurge) *BreakingEvent {
+	if event, ok := d.events[surge.cluster.ID]; ok {
+		return event
+	}
+	for _, event := range d.events {
+		for _, item := range event.Items {
+			if _, ok := surge.items[item.ID]; ok {
+				return event
+			}
+		}
+	}
+	return nil
+}
+
+// BreakingEvents returns the breaking events, active ones first, then by
+// severity and most recent surge. Ended events are left out unless
+// includeEnded is set.
+func (s *NewsService) BreakingEvents(includeEnded bool) []BreakingEvent {
+	events := []BreakingEvent{}
+	if s.breaking == nil {
+		return events
+	}
+	s.breaking.mu.Lock()
+	for _, event := range s.breaking.events {
+		if event.Active || includeEnded {
+			events = append(events, *event)
+		}
+	}
+	s.breaking.mu.Unlock()
+
+	sort.Slice(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if a.Active != b.Active {
+			return a.Active
+		}
+		if severityRank(a.Severity) != severityRank(b.Severity) {
+			return severityRank(a.Severity) > severityRank(b.Severity)
+		}
+		if !a.Updated.Equal(b.Updated) {
+			return a.Updated.After(b.Updated)
+		}
+		return a.ID < b.ID
+	})
+	return events
+}
+
+// MarkBreaking sets the Breaking severity of every cluster that shares an
+// item with an active event.
+func (s *NewsService) MarkBreaking(clusters []models.StoryCluster) {
+	events := s.BreakingEvents(false)
+	if len(events) == 0 {
+		return
+	}
+	severity := make(map[string]string)
+	for i := len(events) - 1; i >= 0; i-- {
+		for _, item := range events[i].Items {
+			severity[item.ID] = events[i].Severity
+		}
+	}
+	for i := range clusters {
+		for _, member := range clusters[i].Coverage {
+			if level, ok := severity[member.ID]; ok && severityRank(level) > severityRank(clusters[i].Breaking) {
+				clusters[i].Breaking = level
+			}
+		}
+	}
+}
+
+// indexedTrendingDoc returns the item's trending analysis from the trending
+// index, analysing it if the index does not have it.
+func (s *NewsService) indexedTrendingDoc(item models.NewsItem) *trendingDoc {
+	if idx := s.trendIndex; idx != nil {
+		idx.mu.Lock()
+		doc, ok := idx.docs[item.ID]
+		idx.mu.Unlock()
+		if ok {
+			return doc
+		}
+	}
+	return s.trendingDoc(item)
+}
+
+func breakingSeverity(sources, minSources int) string {
+	switch {
+	case sources >= 3*minSources:
+		return SeverityHigh
+	case sources >= 2*minSources:
+		return SeverityMedium
+	default:
+		return SeverityLow
+	}
+}
+
+func severityRank(severity string) int {
+	switch severity {
+	case SeverityLow:
+		return 1
+	case SeverityMedium:
+		return 2
+	case SeverityHigh:
+		return 3
+	default:
+		return 0
+	}
+}
+
+func countSources(items []models.NewsItem) int {
+	sources := make(map[string]bool)
+	for _, item := range items {
+		sources[item.Source] = true
+	}
+	return len(sources)
+}
+
+// sortedTerms orders terms by how many sources used them, then by name. A
+// single word is left out when a pair containing it was used as widely.
+func sortedTerms(terms map[string]int) []string {
+	sorted := make([]string, 0, len(terms))
+	for term, sources := range terms {
+		if !strings.Contains(term, " ") && coveredTerm(term, sources, terms) {
+			continue
+		}
+		sorted = append(sorted, term)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if terms[sorted[i]] != terms[sorted[j]] {
+			return terms[sorted[i]] > terms[sorted[j]]
+		}
+		return sorted[i] < sorted[j]
+	})
+	return sorted
+}
+
+func coveredTerm(word string, sources int, terms map[string]int) bool {
+	for term, n := range terms {
+		first, second, ok := strings.Cut(term, " ")
+		if ok && (first == word || second == word) && n >= sources {
+			return true
+		}
+	}
+	return false
+}
+
+// breakingItems lists items oldest first.
+func breakingItems(items map[string]models.NewsItem) []models.ClusterMember {
+	members := make([]models.ClusterMember, 0, len(items))
+	for _, item := range items {
+		members = append(members, models.ClusterMember{
+			ID:        item.ID,
+			Title:     item.Title,
+			Link:      item.Link,
+			Source:    item.Source,
+			Published: item.Published,
+		})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if !members[i].Published.Equal(members[j].Published) {
+			return members[i].Published.Before(members[j].Published)
+		}
+		return members[i].ID < members[j].ID
+	})
+	return members
+}
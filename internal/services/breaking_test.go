@@ -0,0 +1,135 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+func breakingItem(id, source, title string, published time.Time) models.NewsItem {
+	return models.NewsItem{ID: id, Source: source, Title: title, Published: published, Language: "english"}
+}
+
+func TestDetectBreaking(t *testing.T) {
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var items []models.NewsItem
+	// Words used all week long do not break, however many sources use them
+	for i := 0; i < 600; i++ {
+		items = append(items, breakingItem(fmt.Sprintf("old%d", i), fmt.Sprintf("Source %d", i%7),
+			fmt.Sprintf("Government report number %d", i), now.Add(-time.Duration(i+30)*time.Minute)))
+	}
+	for i, title := range []string{
+		"Government cuts school budgets",
+		"Hospitals wait for the government",
+		"New road plans from the government",
+		"Farmers protest against government",
+		"Government names new ambassador",
+	} {
+		items = append(items, breakingItem(fmt.Sprintf("gov%d", i), fmt.Sprintf("Source %d", i), title, now.Add(-time.Minute)))
+	}
+	// One story from six sources within the window
+	for i := 0; i < 6; i++ {
+		items = append(items, breakingItem(fmt.Sprintf("quake%d", i), fmt.Sprintf("Source %d", i),
+			"Strong earthquake hits Bergen, buildings evacuated", now.Add(-time.Duration(i)*time.Minute)))
+	}
+	// The story was first reported before the window
+	items = append(items, breakingItem("quake-early", "Source 0", "Strong earthquake hits Bergen, buildings evacuated", now.Add(-2*time.Hour)))
+	// Differently worded coverage from five sources sharing a term
+	for i, title := range []string{
+		"Tsunami warning issued for the coast",
+		"Residents flee after tsunami warning",
+		"Ferries cancelled as tsunami warning spreads",
+		"Schools closed under tsunami warning",
+		"What the tsunami warning means for you",
+	} {
+		items = append(items, breakingItem(fmt.Sprintf("wave%d", i), fmt.Sprintf("Outlet %d", i), title, now.Add(-5*time.Minute)))
+	}
+
+	raised := service.DetectBreaking(items, now)
+	if len(raised) != 2 {
+		t.Fatalf("DetectBreaking() raised %d events, want 2: %+v", len(raised), raised)
+	}
+	events := service.BreakingEvents(false)
+	var quake, wave *BreakingEvent
+	for i := range events {
+		switch events[i].Items[0].ID[:4] {
+		case "quak":
+			quake = &events[i]
+		case "wave":
+			wave = &events[i]
+		}
+	}
+	if quake == nil || quake.Kind != BreakingCluster || quake.Severity != SeverityLow || len(quake.Sources) != 6 || len(quake.Items) != 6 {
+		t.Errorf("Earthquake event = %+v, want a low cluster event from 6 sources", quake)
+	}
+	// The event carries the ID the story's cluster has in the clustered news
+	for _, cluster := range service.ClusterNews(append([]models.NewsItem(nil), items...)) {
+		if cluster.Coverage[0].ID == "quake-early" && (quake == nil || quake.ClusterID != cluster.ID) {
+			t.Errorf("Earthquake event = %+v, want cluster %s", quake, cluster.ID)
+		}
+	}
+	if wave == nil || wave.Kind != BreakingTerm || len(wave.Sources) != 5 || len(wave.Terms) != 1 || wave.Terms[0] != "tsunami warning" {
+		t.Errorf("Tsunami event = %+v, want a term event for \"tsunami warning\" from 5 sources", wave)
+	}
+
+	// Coverage from ten sources makes the story more severe
+	for i := 6; i < 10; i++ {
+		items = append(items, breakingItem(fmt.Sprintf("quake%d", i), fmt.Sprintf("Source %d", i),
+			"Strong earthquake hits Bergen, buildings evacuated", now.Add(time.Minute)))
+	}
+	later := now.Add(2 * time.Minute)
+	raised = service.DetectBreaking(items, later)
+	if len(raised) != 1 || raised[0].ID != quake.ID || raised[0].Severity != SeverityMedium || !raised[0].Started.Equal(now) {
+		t.Errorf("DetectBreaking() after more coverage = %+v, want the earthquake event escalated to medium", raised)
+	}
+
+	clusters := service.ClusterNews(append([]models.NewsItem{}, items...))
+	service.MarkBreaking(clusters)
+	marked := 0
+	for _, cluster := range clusters {
+		if cluster.Breaking != "" {
+			marked++
+		}
+	}
+	if marked < 2 {
+		t.Errorf("MarkBreaking() marked %d clusters, want the earthquake and tsunami clusters", marked)
+	}
+
+	// Once the window has passed the events end but are kept
+	if raised := service.DetectBreaking(items, now.Add(time.Hour)); len(raised) != 0 {
+		t.Errorf("DetectBreaking() an hour later raised %+v, want nothing", raised)
+	}
+	if active := service.BreakingEvents(false); len(active) != 0 {
+		t.Errorf("BreakingEvents(false) = %+v, want no active events", active)
+	}
+	if all := service.BreakingEvents(true); len(all) != 2 || all[0].Active {
+		t.Errorf("BreakingEvents(true) = %+v, want both ended events", all)
+	}
+	if service.DetectBreaking(items, now.Add(2*breakingRetention)); len(service.BreakingEvents(true)) != 0 {
+		t.Errorf("BreakingEvents(true) after retention = %+v, want none", service.BreakingEvents(true))
+	}
+}
+
+func TestBreakingSeverity(t *testing.T) {
+	tests := []struct {
+		sources  int
+		expected string
+	}{
+		{5, SeverityLow},
+		{9, SeverityLow},
+		{10, SeverityMedium},
+		{15, SeverityHigh},
+	}
+	for _, tt := range tests {
+		if got := breakingSeverity(tt.sources, 5); got != tt.expected {
+			t.Errorf("breakingSeverity(%d, 5) = %q, want %q", tt.sources, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package services
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/news-reader/internal/models"
+)
+
+// Query parameters that only exist to track clicks and never change the page.
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "gclsrc": true, "msclkid": true,
+	"yclid": true, "igshid": true, "mc_cid": true, "mc_eid": true, "_hsenc": true,
+	"_hsmi": true, "mkt_tok": true, "ref_src": true, "ref_url": true, "cmpid": true,
+	"ncid": true, "ocid": true, "sr_share": true, "at_medium": true, "at_campaign": true,
+	"s_cid": true, "wt.mc_id": true, "__twitter_impression": true,
+}
+
+// Hosts whose links only redirect to the real article.
+var defaultRedirectHosts = []string{
+	"feedproxy.google.com", "feeds.feedburner.com", "t.co", "bit.ly", "ow.ly",
+	"buff.ly", "trib.al", "dlvr.it", "lnkd.in", "tinyurl.com", "goo.gl", "rebrand.ly",
+}
+
+const (
+	resolveTimeout   = 5 * time.Second
+	maxResolvedLinks = 10000
+	maxCanonicalBody = 512 * 1024
+	// resolveWorkers bounds how many links of a fetch are followed at once.
+	resolveWorkers = 8
+	// resolveBudget is how long a fetch waits for its links to resolve.
+	resolveBudget = 10 * time.Second
+)
+
+// urlResolver follows redirector and AMP links to the article they stand for
+// and remembers the answers.
+type urlResolver struct {
+	client        *http.Client
+	redirectHosts map[string]bool
+	budget        time.Duration
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+func newURLResolver() *urlResolver {
+	hosts := make(map[string]bool)
+	for _, host := range defaultRedirectHosts {
+		hosts[host] = true
+	}
+	return &urlResolver{
+		client:        &http.Client{Timeout: resolveTimeout},
+		redirectHosts: hosts,
+		budget:        resolveBudget,
+		cache:         make(map[string]string),
+	}
+}
+
+// canonicalizeLinks sets the canonical link and original link of every item.
+// Links that have to be fetched are resolved together by a bounded pool of
+// workers. Those still unresolved when the budget runs out keep their
+// cleaned link; they are resolved in the background and cached, so a later
+// fetch picks up their canonical form.
+func (s *NewsService) canonicalizeLinks(items []models.NewsItem) {
+	var pending []string
+	seen := make(map[string]bool)
+	for i := range items {
+		cleaned := cleanURL(items[i].Link)
+		if s.urls != nil && s.urls.needsResolving(cleaned) && !seen[cleaned] {
+			seen[cleaned] = true
+			pending = append(pending, cleaned)
+		}
+	}
+
+	var resolved map[string]string
+	if len(pending) > 0 {
+		resolved = s.urls.resolveAll(pending, s.urls.budget)
+	}
+
+	for i := range items {
+		link := items[i].Link
+		canonical := cleanURL(link)
+		if target, ok := resolved[canonical]; ok {
+			canonical = target
+		}
+		if canonical == link {
+			items[i].OriginalLink = ""
+			continue
+		}
+		items[i].Link, items[i].OriginalLink = canonical, link
+	}
+}
+
+// needsResolving reports whether link has to be fetched to find the article
+// it points at.
+func (r *urlResolver) needsResolving(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	return r.redirectHosts[strings.ToLower(u.Host)] || isAMPURL(u)
+}
+
+// resolve follows link and returns the page's rel=canonical URL, or the
+// final redirect target when the page has none. Failures fall back to link.
+func (r *urlResolver) resolve(link string) string {
+	r.mu.Lock()
+	if resolved, ok := r.cache[link]; ok {
+		r.mu.Unlock()
+		return resolved
+	}
+	r.mu.Unlock()
+
+	resolved := link
+	if target, err := r.fetchCanonical(link); err == nil && target != "" {
+		resolved = cleanURL(target)
+	}
+	if u, err := url.Parse(resolved); err == nil && isAMPURL(u) {
+		resolved = cleanURL(stripAMP(u).String())
+	}
+
+	r.mu.Lock()
+	if len(r.cache) >= maxResolvedLinks {
+		r.cache = make(map[string]string)
+	}
+	r.cache[link] = resolved
+	r.mu.Unlock()
+
+	return resolved
+}
+
+// resolveAll resolves links with at most resolveWorkers fetches at a time
+// and returns the answers that arrived within budget. Workers still busy
+// when it returns finish in the background and fill the cache.
+func (r *urlResolver) resolveAll(links []string, budget time.Duration) map[string]string {
+	queue := make(chan string, len(links))
+	for _, link := range links {
+		queue <- link
+	}
+	close(queue)
+
+	var mu sync.Mutex
+	results := make(map[string]string, len(links))
+	var wg sync.WaitGroup
+	for i := 0; i < min(resolveWorkers, len(links)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for link := range queue {
+				resolved := r.resolve(link)
+				mu.Lock()
+				results[link] = resolved
+				mu.Unlock()
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(budget)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	answered := make(map[string]string, len(results))
+	for link, resolved := range results {
+		answered[link] = resolved
+	}
+	return answered
+}
+
+func (r *urlResolver) fetchCanonical(link string) (string, error) {
+	req, err := http.NewRequest("GET", link, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; NewsReader/1.0)")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	final := resp.Request.URL
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		return final.String(), nil
+	}
+
+	doc, err := goquery.NewDocumentFromReader(io.LimitReader(resp.Body, maxCanonicalBody))
+	if err != nil {
+		return final.String(), nil
+	}
+
+	href, ok := doc.Find(`link[rel="canonical"]`).First().Attr("href")
+	if !ok || strings.TrimSpace(href) == "" {
+		return final.String(), nil
+	}
+	canonical, err := final.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return final.String(), nil
+	}
+	return canonical.String(), nil
+}
+
+// cleanURL removes tracking parameters and fragments from link. Links that
+// do not parse are returned unchanged.
+func cleanURL(link string) string {
+	link = strings.TrimSpace(link)
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return link
+	}
+
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.RawQuery != "" {
+		query := u.Query()
+		removed := false
+		for key := range query {
+			lower := strings.ToLower(key)
+			if strings.HasPrefix(lower, "utm_") || trackingParams[lower] {
+				query.Del(key)
+				removed = true
+			}
+		}
+		if removed {
+			u.RawQuery = query.Encode()
+		}
+	}
+	return u.String()
+}
+
+// isAMPURL reports whether u looks like the AMP variant of an article.
+func isAMPURL(u *url.URL) bool {
+	host := strings.ToLower(u.Host)
+	path := strings.ToLower(u.Path)
+	query := u.Query()
+	return strings.HasPrefix(host, "amp.") ||
+		strings.HasSuffix(host, ".cdn.ampproject.org") ||
+		strings.HasSuffix(path, "/amp") || strings.HasSuffix(path, "/amp/") ||
+		strings.HasSuffix(path, ".amp") || strings.HasSuffix(path, ".amp.html") ||
+		strings.HasPrefix(path, "/amp/") ||
+		query.Has("amp") || strings.EqualFold(query.Get("outputType"), "amp")
+}
+
+// stripAMP guesses the non-AMP URL for u without fetching anything.
+func stripAMP(u *url.URL) *url.URL {
+	clean := *u
+	clean.RawPath = ""
+	host := strings.ToLower(clean.Host)
+
+	// Google's AMP cache: https://example-com.cdn.ampproject.org/c/s/example.com/path
+	if strings.HasSuffix(host, ".cdn.ampproject.org") {
+		parts := strings.SplitN(strings.TrimPrefix(clean.Path, "/"), "/", 4)
+		if len(parts) == 4 && parts[0] == "c" && parts[1] == "s" {
+			clean.Host = parts[2]
+			clean.Path = "/" + parts[3]
+			clean.Scheme = "https"
+		}
+	}
+
+	clean.Host = strings.TrimPrefix(clean.Host, "amp.")
+	for _, suffix := range []string{"/amp/", "/amp", ".amp.html", ".amp"} {
+		if strings.HasSuffix(strings.ToLower(clean.Path), suffix) {
+			clean.Path = clean.Path[:len(clean.Path)-len(suffix)]
+			if suffix == ".amp.html" {
+				clean.Path += ".html"
+			}
+			break
+		}
+	}
+	clean.Path = strings.Replace(clean.Path, "/amp/", "/", 1)
+
+	query := clean.Query()
+	query.Del("amp")
+	if strings.EqualFold(query.Get("outputType"), "amp") {
+		query.Del("outputType")
+	}
+	clean.RawQuery = query.Encode()
+	return &clean
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+func TestCleanURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		link     string
+		expected string
+	}{
+		{
+			name:     "UTM parameters",
+			link:     "https://example.com/story?utm_source=rss&utm_medium=feed&id=7",
+			expected: "https://example.com/story?id=7",
+		},
+		{
+			name:     "Click identifiers and fragment",
+			link:     "https://example.com/story?fbclid=abc&gclid=def#top",
+			expected: "https://example.com/story",
+		},
+		{
+			name:     "Untouched query keeps its order",
+			link:     "https://example.com/search?q=news&a=1",
+			expected: "https://example.com/search?q=news&a=1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanURL(tt.link); got != tt.expected {
+				t.Errorf("cleanURL() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStripAMP(t *testing.T) {
+	tests := []struct {
+		link     string
+		expected string
+	}{
+		{"https://www.example.com/news/story/amp", "https://www.example.com/news/story"},
+		{"https://amp.example.com/news/story", "https://example.com/news/story"},
+		{"https://example.com/news/story.amp.html", "https://example.com/news/story.html"},
+		{"https://example.com/news/story?outputType=amp", "https://example.com/news/story"},
+		{"https://example-com.cdn.ampproject.org/c/s/example.com/news/story", "https://example.com/news/story"},
+	}
+
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.link)
+		if !isAMPURL(u) {
+			t.Errorf("isAMPURL(%q) = false", tt.link)
+		}
+		if got := stripAMP(u).String(); got != tt.expected {
+			t.Errorf("stripAMP(%q) = %v, want %v", tt.link, got, tt.expected)
+		}
+	}
+}
+
+func TestCanonicalizeLink(t *testing.T) {
+	var hits int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/short", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		http.Redirect(w, r, "/article?utm_campaign=x", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><link rel="canonical" href="/canonical/article"></head></html>`))
+	})
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	host, _ := url.Parse(server.URL)
+	service := &NewsService{urls: newURLResolver()}
+	service.urls.redirectHosts[host.Host] = true
+	canonicalize := func(link string) (string, string) {
+		items := []models.NewsItem{{Link: link}}
+		service.canonicalizeLinks(items)
+		return items[0].Link, items[0].OriginalLink
+	}
+
+	link, original := canonicalize(server.URL + "/short")
+	if link != server.URL+"/canonical/article" {
+		t.Errorf("Expected rel=canonical target, got %s", link)
+	}
+	if original != server.URL+"/short" {
+		t.Errorf("Expected original link to be kept, got %s", original)
+	}
+
+	canonicalize(server.URL + "/short")
+	if hits != 1 {
+		t.Errorf("Expected resolved link to be cached, got %d fetches", hits)
+	}
+
+	if link, _ := canonicalize(server.URL + "/plain?utm_source=x"); link != server.URL+"/plain" {
+		t.Errorf("Expected final redirect target, got %s", link)
+	}
+
+	link, original = canonicalize("https://other.example/story")
+	if link != "https://other.example/story" || original != "" {
+		t.Errorf("Expected clean link to pass through, got %s (%s)", link, original)
+	}
+}
+
+func TestCanonicalizeLinksBudget(t *testing.T) {
+	var inFlight, most int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/article/") {
+			return
+		}
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&most)
+			if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+				break
+			}
+		}
+		<-release
+		http.Redirect(w, r, "/article"+r.URL.Path, http.StatusFound)
+	}))
+	defer server.Close()
+
+	host, _ := url.Parse(server.URL)
+	service := &NewsService{urls: newURLResolver()}
+	service.urls.redirectHosts[host.Host] = true
+	service.urls.budget = 50 * time.Millisecond
+
+	var items []models.NewsItem
+	for i := 0; i < 2*resolveWorkers; i++ {
+		items = append(items, models.NewsItem{Link: server.URL + "/" + string(rune('a'+i)) + "?utm_source=x"})
+	}
+	start := time.Now()
+	service.canonicalizeLinks(items)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("canonicalizeLinks() took %v, want it bounded by the budget", elapsed)
+	}
+	if items[0].Link != server.URL+"/a" || items[0].OriginalLink != server.URL+"/a?utm_source=x" {
+		t.Errorf("Expected the cleaned link while unresolved, got %+v", items[0])
+	}
+	if most := atomic.LoadInt32(&most); most > resolveWorkers {
+		t.Errorf("Expected at most %d fetches at once, got %d", resolveWorkers, most)
+	}
+
+	// The links keep resolving in the background and are cached
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		service.urls.mu.Lock()
+		cached := len(service.urls.cache)
+		service.urls.mu.Unlock()
+		if cached == len(items) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	service.urls.budget = time.Second
+	again := []models.NewsItem{{Link: server.URL + "/a"}}
+	service.canonicalizeLinks(again)
+	if again[0].Link != server.URL+"/article/a" {
+		t.Errorf("Expected the resolved link from the cache, got %s", again[0].Link)
+	}
+}
+
+func TestNewsIDStableAcrossResolution(t *testing.T) {
+	release := make(chan struct{})
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>Test</title>
+<item><title>Slow redirect</title><link>` + server.URL + `/short?utm_source=rss</link></item>
+</channel></rss>`))
+	})
+	mux.HandleFunc("/short", func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		http.Redirect(w, r, "/article", http.StatusFound)
+	})
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {})
+	server = httptest.NewServer(mux)
+	defer server.Close()
+	defer func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	}()
+
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+	host, _ := url.Parse(server.URL)
+	service.urls.redirectHosts[host.Host] = true
+	service.urls.budget = 50 * time.Millisecond
+	src := models.NewsSource{Name: "Test", URL: server.URL + "/feed.xml", ContentType: models.TypeRSS}
+
+	before, err := service.fetchNewsFromSource(src)
+	if err != nil || len(before) != 1 {
+		t.Fatalf("fetchNewsFromSource() = %+v, %v", before, err)
+	}
+	if before[0].Link != server.URL+"/short" {
+		t.Fatalf("Expected the cleaned link while unresolved, got %s", before[0].Link)
+	}
+
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		service.urls.mu.Lock()
+		cached := len(service.urls.cache)
+		service.urls.mu.Unlock()
+		if cached == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	after, err := service.fetchNewsFromSource(src)
+	if err != nil || len(after) != 1 {
+		t.Fatalf("fetchNewsFromSource() = %+v, %v", after, err)
+	}
+	if after[0].Link != server.URL+"/article" {
+		t.Errorf("Expected the resolved link, got %s", after[0].Link)
+	}
+	if after[0].ID != before[0].ID {
+		t.Errorf("Item ID changed from %s to %s once the link resolved", before[0].ID, after[0].ID)
+	}
+}
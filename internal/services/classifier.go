@@ -0,0 +1,576 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/textanalysis"
+)
+
+// Defaults for models.ClassifierSettings.
+const (
+	defaultSuggestThreshold   = 0.6
+	defaultAutoApplyThreshold = 0.9
+	defaultMinExamples        = 3
+)
+
+// ErrItemNotFound is returned when an item is neither cached nor known from
+// earlier tagging.
+var ErrItemNotFound = errors.New("news item not found")
+
+// TrainingExample is the text of an item the user tagged or judged by hand.
+// It is kept so the classifier can be retrained after the item has left the
+// cache.
+type TrainingExample struct {
+	NewsID    string    `json:"newsId"`
+	Text      string    `json:"text"`
+	Language  string    `json:"language,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	Rejected  []string  `json:"rejected,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TagModelStats describes what one tag's classifier was trained on.
+type TagModelStats struct {
+	TagID     string `json:"tagId"`
+	Name      string `json:"name"`
+	Positives int    `json:"positives"`
+	Negatives int    `json:"negatives"`
+	Trained   bool   `json:"trained"`
+}
+
+// ItemSuggestions lists the suggested tags for one item.
+type ItemSuggestions struct {
+	NewsID      string                 `json:"newsId"`
+	Title       string                 `json:"title"`
+	Suggestions []models.TagSuggestion `json:"suggestions"`
+}
+
+// tagModel is a two-class multinomial Naive Bayes model over the words of an
+// item: items with the tag against items without it.
+type tagModel struct {
+	tag      models.Tag
+	logPrior float64 // log P(tag) - log P(not tag)
+	logRatio map[string]float64
+}
+
+// tagClassifier keeps the training examples on disk and the models built
+// from them in memory. Models are rebuilt lazily after the examples change.
+type tagClassifier struct {
+	mu       sync.Mutex
+	file     string
+	examples map[string]*TrainingExample
+	models   []*tagModel
+	stats    []TagModelStats
+	stale    bool
+}
+
+// trainingFile returns where the training examples for prefsFile live.
+func trainingFile(prefsFile string) string {
+	return strings.TrimSuffix(prefsFile, filepath.Ext(prefsFile)) + ".training.json"
+}
+
+func newTagClassifier(file string) (*tagClassifier, error) {
+	c := &tagClassifier{
+		file:     file,
+		examples: make(map[string]*TrainingExample),
+		stale:    true,
+	}
+
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var examples []*TrainingExample
+	if err := json.Unmarshal(data, &examples); err != nil {
+		return nil, err
+	}
+	for _, example := range examples {
+		c.examples[example.NewsID] = example
+	}
+	return c, nil
+}
+
+// save writes the examples to disk. Callers must hold c.mu.
+func (c *tagClassifier) save() error {
+	examples := make([]*TrainingExample, 0, len(c.examples))
+	for _, example := range c.examples {
+		examples = append(examples, example)
+	}
+	sort.Slice(examples, func(i, j int) bool {
+		return examples[i].NewsID < examples[j].NewsID
+	})
+
+	data, err := json.MarshalIndent(examples, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.file, data, 0644)
+}
+
+// example returns the example for item, creating it if needed. Callers must
+// hold c.mu.
+func (c *tagClassifier) example(item models.NewsItem) *TrainingExample {
+	example, ok := c.examples[item.ID]
+	if !ok {
+		example = &TrainingExample{NewsID: item.ID}
+		c.examples[item.ID] = example
+	}
+	if item.Title != "" || item.Description != "" {
+		example.Text = item.Title + " " + item.Description
+		example.Language = item.Language
+	}
+	example.UpdatedAt = time.Now()
+	c.stale = true
+	return example
+}
+
+// tagPreferences is what learned tagging reads from the preferences, copied
+// under s.mu so that fetch goroutines can tag items without holding it.
+type tagPreferences struct {
+	settings models.ClassifierSettings
+	tags     []models.Tag
+	// manual maps a news ID to the IDs of the tags the user gave it.
+	manual map[string][]string
+}
+
+// tagPreferences takes a snapshot of the user tags, manual tags and
+// classifier settings.
+func (s *NewsService) tagPreferences() tagPreferences {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefs := tagPreferences{
+		settings: s.classifierSettings(),
+		tags:     append([]models.Tag(nil), s.preferences.Tags...),
+		manual:   make(map[string][]string),
+	}
+	for _, nt := range s.preferences.NewsTags {
+		prefs.manual[nt.NewsID] = append(prefs.manual[nt.NewsID], nt.TagID)
+	}
+	return prefs
+}
+
+// tag returns the user tag id.
+func (p tagPreferences) tag(id string) (models.Tag, bool) {
+	for _, tag := range p.tags {
+		if tag.ID == id {
+			return tag, true
+		}
+	}
+	return models.Tag{}, false
+}
+
+func (s *NewsService) classifierSettings() models.ClassifierSettings {
+	settings := s.preferences.Classifier
+	if settings.SuggestThreshold <= 0 {
+		settings.SuggestThreshold = defaultSuggestThreshold
+	}
+	if settings.AutoApplyThreshold <= 0 {
+		settings.AutoApplyThreshold = defaultAutoApplyThreshold
+	}
+	if settings.MinExamples <= 0 {
+		settings.MinExamples = defaultMinExamples
+	}
+	return settings
+}
+
+// recordManualTags stores the item's text with the tags the user gave it.
+func (s *NewsService) recordManualTags(newsID string, tags []models.Tag) error {
+	if s.classifier == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	item, ok := s.cachedItemsByID()[newsID]
+	s.mu.RUnlock()
+	if !ok {
+		item = models.NewsItem{ID: newsID}
+	}
+
+	s.classifier.mu.Lock()
+	defer s.classifier.mu.Unlock()
+
+	example := s.classifier.example(item)
+	example.Tags = nil
+	for _, tag := range tags {
+		example.Tags = append(example.Tags, tag.ID)
+		example.Rejected = removeString(example.Rejected, tag.ID)
+	}
+	return s.classifier.save()
+}
+
+// AcceptSuggestion adds a suggested tag to the item's manual tags, which also
+// makes the item a positive training example.
+func (s *NewsService) AcceptSuggestion(newsID, tagID string) error {
+	tag, ok := s.userTag(tagID)
+	if !ok {
+		return ErrTagNotFound
+	}
+	if _, ok := s.knownItem(newsID); !ok {
+		return ErrItemNotFound
+	}
+
+	var tags []models.Tag
+	for _, nt := range s.preferences.NewsTags {
+		if nt.NewsID == newsID && nt.TagID != tagID {
+			tags = append(tags, models.Tag{ID: nt.TagID})
+		}
+	}
+	return s.UpdateNewsTags(newsID, append(tags, tag))
+}
+
+// RejectSuggestion records that tagID does not fit the item, removing it from
+// the item's manual tags if it was there. The item becomes a negative
+// training example for the tag and is not suggested the tag again.
+func (s *NewsService) RejectSuggestion(newsID, tagID string) error {
+	if _, ok := s.userTag(tagID); !ok {
+		return ErrTagNotFound
+	}
+
+	item, ok := s.knownItem(newsID)
+	if !ok {
+		return ErrItemNotFound
+	}
+
+	var tags []models.Tag
+	tagged := false
+	for _, nt := range s.preferences.NewsTags {
+		if nt.NewsID != newsID {
+			continue
+		}
+		if nt.TagID == tagID {
+			tagged = true
+		} else {
+			tags = append(tags, models.Tag{ID: nt.TagID})
+		}
+	}
+	if tagged {
+		if err := s.UpdateNewsTags(newsID, tags); err != nil {
+			return err
+		}
+	}
+
+	s.classifier.mu.Lock()
+	defer s.classifier.mu.Unlock()
+
+	example := s.classifier.example(item)
+	example.Tags = removeString(example.Tags, tagID)
+	if !containsString(example.Rejected, tagID) {
+		example.Rejected = append(example.Rejected, tagID)
+	}
+	return s.classifier.save()
+}
+
+// RetrainClassifier rebuilds every tag model from the stored examples and
+// the manual tags of cached items, and reports what each was trained on.
+func (s *NewsService) RetrainClassifier() ([]TagModelStats, error) {
+	if s.classifier == nil {
+		return []TagModelStats{}, nil
+	}
+
+	// Items tagged before examples were kept can still be learned from while
+	// they are cached
+	s.mu.RLock()
+	cached := s.cachedItemsByID()
+	s.mu.RUnlock()
+	prefs := s.tagPreferences()
+
+	s.classifier.mu.Lock()
+	defer s.classifier.mu.Unlock()
+
+	backfilled := false
+	for newsID, tagIDs := range prefs.manual {
+		if _, ok := s.classifier.examples[newsID]; ok {
+			continue
+		}
+		if item, ok := cached[newsID]; ok {
+			s.classifier.example(item).Tags = tagIDs
+			backfilled = true
+		}
+	}
+	if backfilled {
+		if err := s.classifier.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.trainModels(prefs)
+	return s.classifier.stats, nil
+}
+
+// invalidate makes the next use retrain the models, for instance after the
+// user's tags or settings changed.
+func (c *tagClassifier) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.stale = true
+	c.mu.Unlock()
+}
+
+// tagModels returns the current models, training them first if the examples
+// changed.
+func (s *NewsService) tagModels(prefs tagPreferences) []*tagModel {
+	s.classifier.mu.Lock()
+	defer s.classifier.mu.Unlock()
+
+	if s.classifier.stale {
+		s.trainModels(prefs)
+	}
+	return s.classifier.models
+}
+
+// trainModels trains one model per user tag. An example is positive for a
+// tag it carries and negative for a tag it was judged without: one rejected
+// or left off when the user tagged the item. Callers must hold
+// s.classifier.mu.
+func (s *NewsService) trainModels(prefs tagPreferences) {
+	minExamples := prefs.settings.MinExamples
+
+	docs := make(map[string][]string, len(s.classifier.examples))
+	for id, example := range s.classifier.examples {
+		docs[id] = classifierWords(example.Language, example.Text)
+	}
+
+	s.classifier.models = nil
+	s.classifier.stats = []TagModelStats{}
+	for _, tag := range prefs.tags {
+		var positives, negatives [][]string
+		for id, example := range s.classifier.examples {
+			if len(docs[id]) == 0 {
+				continue
+			}
+			switch {
+			case containsString(example.Tags, tag.ID):
+				positives = append(positives, docs[id])
+			case containsString(example.Rejected, tag.ID) || len(example.Tags) > 0:
+				negatives = append(negatives, docs[id])
+			}
+		}
+
+		stats := TagModelStats{TagID: tag.ID, Name: tag.Name, Positives: len(positives), Negatives: len(negatives)}
+		if len(positives) >= minExamples && len(negatives) >= minExamples {
+			s.classifier.models = append(s.classifier.models, trainTagModel(tag, positives, negatives))
+			stats.Trained = true
+		}
+		s.classifier.stats = append(s.classifier.stats, stats)
+	}
+	s.classifier.stale = false
+}
+
+func trainTagModel(tag models.Tag, positives, negatives [][]string) *tagModel {
+	count := func(docs [][]string) (map[string]int, int) {
+		counts := make(map[string]int)
+		total := 0
+		for _, words := range docs {
+			for _, word := range words {
+				counts[word]++
+				total++
+			}
+		}
+		return counts, total
+	}
+	posCounts, posTotal := count(positives)
+	negCounts, negTotal := count(negatives)
+
+	vocabulary := make(map[string]bool)
+	for word := range posCounts {
+		vocabulary[word] = true
+	}
+	for word := range negCounts {
+		vocabulary[word] = true
+	}
+
+	// Laplace smoothing over the shared vocabulary
+	v := float64(len(vocabulary))
+	model := &tagModel{
+		tag:      models.Tag{ID: tag.ID, Name: tag.Name, Color: tag.Color, Category: tag.Category},
+		logPrior: math.Log(float64(len(positives))) - math.Log(float64(len(negatives))),
+		logRatio: make(map[string]float64, len(vocabulary)),
+	}
+	for word := range vocabulary {
+		pos := (float64(posCounts[word]) + 1) / (float64(posTotal) + v)
+		neg := (float64(negCounts[word]) + 1) / (float64(negTotal) + v)
+		model.logRatio[word] = math.Log(pos) - math.Log(neg)
+	}
+	return model
+}
+
+// probability returns P(tag | words). Words never seen in training carry no
+// evidence either way.
+func (m *tagModel) probability(words []string) float64 {
+	logOdds := m.logPrior
+	for _, word := range words {
+		logOdds += m.logRatio[word]
+	}
+	return 1 / (1 + math.Exp(-logOdds))
+}
+
+// classifierWords returns the stems of the words in text worth learning
+// from: no stop words and nothing shorter than two letters.
+func classifierWords(language, text string) []string {
+	var words []string
+	for _, term := range textanalysis.For(language).Terms(text) {
+		if textanalysis.Length(term) >= 2 {
+			words = append(words, term)
+		}
+	}
+	return words
+}
+
+// suggestTags scores item against every trained tag model. Tags the item
+// already carries, was tagged with by hand or that were rejected for it are
+// skipped.
+func (s *NewsService) suggestTags(item *models.NewsItem, prefs tagPreferences) []models.TagSuggestion {
+	if s.classifier == nil {
+		return nil
+	}
+	settings := prefs.settings
+	if settings.Disabled {
+		return nil
+	}
+
+	trained := s.tagModels(prefs)
+	if len(trained) == 0 {
+		return nil
+	}
+
+	s.classifier.mu.Lock()
+	var rejected []string
+	if example, ok := s.classifier.examples[item.ID]; ok {
+		rejected = example.Rejected
+	}
+	s.classifier.mu.Unlock()
+
+	manual := make(map[string]bool)
+	for _, tagID := range prefs.manual[item.ID] {
+		manual[tagID] = true
+	}
+
+	words := classifierWords(item.Language, item.Title+" "+item.Description)
+	var suggestions []models.TagSuggestion
+	for _, model := range trained {
+		if hasTag(item, model.tag.ID) || manual[model.tag.ID] || containsString(rejected, model.tag.ID) {
+			continue
+		}
+		if p := model.probability(words); p >= settings.SuggestThreshold {
+			suggestions = append(suggestions, models.TagSuggestion{
+				TagID:      model.tag.ID,
+				Name:       model.tag.Name,
+				Confidence: p,
+			})
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		return suggestions[i].Confidence > suggestions[j].Confidence
+	})
+	return suggestions
+}
+
+// applyLearnedTags adds the item's manual tags, applies learned tags above
+// the auto-apply threshold and keeps the rest as suggestions.
+func (s *NewsService) applyLearnedTags(item *models.NewsItem, prefs tagPreferences) {
+	for _, tagID := range prefs.manual[item.ID] {
+		if hasTag(item, tagID) {
+			continue
+		}
+		if tag, ok := prefs.tag(tagID); ok {
+			tag.Rules = nil
+			item.Tags = append(item.Tags, tag)
+		}
+	}
+
+	threshold := prefs.settings.AutoApplyThreshold
+	item.Suggestions = nil
+	for _, suggestion := range s.suggestTags(item, prefs) {
+		if suggestion.Confidence < threshold {
+			item.Suggestions = append(item.Suggestions, suggestion)
+			continue
+		}
+		if tag, ok := prefs.tag(suggestion.TagID); ok {
+			tag.Rules = nil
+			item.Tags = append(item.Tags, tag)
+		}
+	}
+}
+
+// Suggestions returns the tag suggestions for every cached item that has
+// any, most confident first.
+func (s *NewsService) Suggestions() []ItemSuggestions {
+	result := []ItemSuggestions{}
+	prefs := s.tagPreferences()
+	for _, item := range s.SortByRecency(s.GetAllNews()) {
+		if suggestions := s.suggestTags(&item, prefs); len(suggestions) > 0 {
+			result = append(result, ItemSuggestions{NewsID: item.ID, Title: item.Title, Suggestions: suggestions})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Suggestions[0].Confidence > result[j].Suggestions[0].Confidence
+	})
+	return result
+}
+
+// knownItem returns the cached item with the given ID, or a bare item when it
+// is only known from earlier tagging.
+func (s *NewsService) knownItem(newsID string) (models.NewsItem, bool) {
+	if s.classifier == nil {
+		return models.NewsItem{}, false
+	}
+
+	s.mu.RLock()
+	item, ok := s.cachedItemsByID()[newsID]
+	s.mu.RUnlock()
+	if ok {
+		return item, true
+	}
+
+	s.classifier.mu.Lock()
+	defer s.classifier.mu.Unlock()
+	_, ok = s.classifier.examples[newsID]
+	return models.NewsItem{ID: newsID}, ok
+}
+
+func (s *NewsService) userTag(id string) (models.Tag, bool) {
+	for _, tag := range s.preferences.Tags {
+		if tag.ID == id {
+			return tag, true
+		}
+	}
+	return models.Tag{}, false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(values []string, value string) []string {
+	kept := values[:0]
+	for _, v := range values {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return kept
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/news-reader/internal/models"
+)
+
+// newClassifierService returns a service with a user tag and a cache of
+// items, half about robots and half about football.
+func newClassifierService(t *testing.T) (*NewsService, models.Tag, []models.NewsItem) {
+	t.Helper()
+
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+	tag, err := service.CreateTag(models.Tag{Name: "Gadgets"})
+	if err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+
+	var items []models.NewsItem
+	for i := 0; i < 4; i++ {
+		items = append(items,
+			models.NewsItem{ID: fmt.Sprintf("robot-%d", i), Title: fmt.Sprintf("New robot vacuum model %d ships with smarter sensors", i)},
+			models.NewsItem{ID: fmt.Sprintf("football-%d", i), Title: fmt.Sprintf("Football club wins league match %d after late goal", i)},
+		)
+	}
+	service.newsCache["test"] = items
+	return service, tag, items
+}
+
+func TestClassifierSuggestions(t *testing.T) {
+	service, tag, _ := newClassifierService(t)
+	other, err := service.CreateTag(models.Tag{Name: "Leisure"})
+	if err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+
+	// Tag three of each kind by hand, the football items with another tag
+	for i := 0; i < 3; i++ {
+		if err := service.UpdateNewsTags(fmt.Sprintf("robot-%d", i), []models.Tag{tag}); err != nil {
+			t.Fatalf("Failed to tag item: %v", err)
+		}
+		if err := service.UpdateNewsTags(fmt.Sprintf("football-%d", i), []models.Tag{other}); err != nil {
+			t.Fatalf("Failed to tag item: %v", err)
+		}
+	}
+
+	stats, err := service.RetrainClassifier()
+	if err != nil {
+		t.Fatalf("Failed to retrain: %v", err)
+	}
+	if len(stats) != 2 || !stats[0].Trained || stats[0].Positives != 3 || stats[0].Negatives != 3 {
+		t.Errorf("RetrainClassifier() = %+v, want Gadgets trained on 3 and 3 examples", stats)
+	}
+
+	robot := models.NewsItem{ID: "robot-3", Title: "New robot vacuum model 3 ships with smarter sensors"}
+	suggestions := service.suggestTags(&robot, service.tagPreferences())
+	if len(suggestions) != 1 || suggestions[0].TagID != tag.ID || suggestions[0].Confidence < 0.9 {
+		t.Errorf("suggestTags(robot) = %+v, want Gadgets with high confidence", suggestions)
+	}
+
+	football := models.NewsItem{ID: "football-3", Title: "Football club wins league match 3 after late goal"}
+	for _, suggestion := range service.suggestTags(&football, service.tagPreferences()) {
+		if suggestion.TagID == tag.ID {
+			t.Errorf("suggestTags(football) = %+v, want no Gadgets suggestion", suggestion)
+		}
+	}
+
+	// High confidence applies the tag, lower confidence only suggests it
+	service.autoTagNews(&robot, service.tagPreferences())
+	if !hasTag(&robot, tag.ID) {
+		t.Errorf("Expected Gadgets to be applied automatically, got %+v", robot.Tags)
+	}
+	service.preferences.Classifier.AutoApplyThreshold = 1.1
+	robot.Tags = nil
+	service.autoTagNews(&robot, service.tagPreferences())
+	if hasTag(&robot, tag.ID) || len(robot.Suggestions) != 1 {
+		t.Errorf("Expected Gadgets as a suggestion only, got tags %+v and suggestions %+v", robot.Tags, robot.Suggestions)
+	}
+
+	// Items tagged by hand get no suggestions
+	listed := service.Suggestions()
+	if len(listed) != 2 {
+		t.Fatalf("Suggestions() = %+v, want the two untagged items", listed)
+	}
+	for _, entry := range listed {
+		if entry.NewsID != "robot-3" && entry.NewsID != "football-3" {
+			t.Errorf("Suggestions() lists %s, which was tagged by hand", entry.NewsID)
+		}
+	}
+
+	// Rejection stops the suggestion for that item
+	if err := service.RejectSuggestion("robot-3", tag.ID); err != nil {
+		t.Fatalf("Failed to reject suggestion: %v", err)
+	}
+	if suggestions := service.suggestTags(&robot, service.tagPreferences()); len(suggestions) != 0 {
+		t.Errorf("suggestTags() after rejection = %+v, want none", suggestions)
+	}
+}
+
+func TestClassifierNeedsExamples(t *testing.T) {
+	service, tag, _ := newClassifierService(t)
+
+	if err := service.UpdateNewsTags("robot-0", []models.Tag{tag}); err != nil {
+		t.Fatalf("Failed to tag item: %v", err)
+	}
+	stats, err := service.RetrainClassifier()
+	if err != nil {
+		t.Fatalf("Failed to retrain: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Trained {
+		t.Errorf("RetrainClassifier() = %+v, want untrained Gadgets", stats)
+	}
+	robot := models.NewsItem{ID: "robot-1", Title: "New robot vacuum model"}
+	if suggestions := service.suggestTags(&robot, service.tagPreferences()); len(suggestions) != 0 {
+		t.Errorf("suggestTags() = %+v, want none without a trained model", suggestions)
+	}
+}
+
+func TestAcceptSuggestion(t *testing.T) {
+	service, tag, _ := newClassifierService(t)
+
+	if err := service.AcceptSuggestion("robot-0", tag.ID); err != nil {
+		t.Fatalf("Failed to accept suggestion: %v", err)
+	}
+	if len(service.preferences.NewsTags) != 1 || service.preferences.NewsTags[0].TagID != tag.ID {
+		t.Errorf("NewsTags = %+v, want the accepted tag", service.preferences.NewsTags)
+	}
+
+	item := models.NewsItem{ID: "robot-0", Title: "Unrelated title"}
+	service.autoTagNews(&item, service.tagPreferences())
+	if !hasTag(&item, tag.ID) {
+		t.Errorf("Expected the manual tag on the item, got %+v", item.Tags)
+	}
+
+	if err := service.AcceptSuggestion("missing", tag.ID); !errors.Is(err, ErrItemNotFound) {
+		t.Errorf("AcceptSuggestion(missing item) error = %v, want ErrItemNotFound", err)
+	}
+	if err := service.AcceptSuggestion("robot-0", "missing"); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("AcceptSuggestion(missing tag) error = %v, want ErrTagNotFound", err)
+	}
+
+	// Examples outlive the cache
+	reloaded, err := NewNewsService(service.prefsFile)
+	if err != nil {
+		t.Fatalf("Failed to reload news service: %v", err)
+	}
+	example, ok := reloaded.classifier.examples["robot-0"]
+	if !ok || example.Text == "" || example.Tags[0] != tag.ID {
+		t.Errorf("Reloaded example = %+v, want robot-0 with its text and tag", example)
+	}
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/textanalysis"
+)
+
+// Near-duplicate detection uses MinHash signatures over word shingles with
+// locality-sensitive hashing to find candidate pairs, which are then checked
+// against the exact Jaccard similarity.
+const (
+	minHashBands        = 32
+	minHashRows         = 2
+	minHashSize         = minHashBands * minHashRows
+	clusterSimilarity   = 0.4
+	maxDescriptionWords = 40
+)
+
+// minHashSeeds holds one seed per hash function in the signature.
+var minHashSeeds = func() [minHashSize]uint64 {
+	var seeds [minHashSize]uint64
+	x := uint64(0x9E3779B97F4A7C15)
+	for i := range seeds {
+		x = splitMix64(x)
+		seeds[i] = x
+	}
+	return seeds
+}()
+
+// ClusterNews groups items covering the same story. Clusters are returned in
+// the order of their first member in items, and that member becomes the
+// cluster's representative, so ranking the items first yields ranked
+// clusters. Every item's ClusterID is set as a side effect.
+func (s *NewsService) ClusterNews(items []models.NewsItem) []models.StoryCluster {
+	groups := clusterGroups(items)
+
+	clusters := make([]models.StoryCluster, 0, len(groups))
+	for _, members := range groups {
+		cluster := buildCluster(items, members)
+		for _, i := range members {
+			items[i].ClusterID = cluster.ID
+		}
+		cluster.Representative.ClusterID = cluster.ID
+		clusters = append(clusters, cluster)
+	}
+	return clusters
+}
+
+// clusterGroups returns the indexes of items that belong together, each group
+// sorted and the groups ordered by their first index.
+func clusterGroups(items []models.NewsItem) [][]int {
+	parent := make([]int, len(items))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(a, b int) {
+		ra, rb := find(a), find(b)
+		if ra < rb {
+			parent[rb] = ra
+		} else if rb < ra {
+			parent[ra] = rb
+		}
+	}
+
+	// Exact duplicates share a canonical URL
+	byURL := make(map[string]int)
+	for i, item := range items {
+		key := canonicalURLKey(item.Link)
+		if key == "" {
+			continue
+		}
+		if j, ok := byURL[key]; ok {
+			union(i, j)
+		} else {
+			byURL[key] = i
+		}
+	}
+
+	// Near duplicates share a band of their MinHash signatures
+	shingles := make([]map[string]bool, len(items))
+	buckets := make(map[uint64][]int)
+	for i, item := range items {
+		shingles[i] = itemShingles(item)
+		if len(shingles[i]) == 0 {
+			continue
+		}
+		sig := minHashSignature(shingles[i])
+		for band := 0; band < minHashBands; band++ {
+			h := fnv.New64a()
+			var buf [8]byte
+			for row := 0; row < minHashRows; row++ {
+				v := sig[band*minHashRows+row]
+				for k := 0; k < 8; k++ {
+					buf[k] = byte(v >> (8 * k))
+				}
+				h.Write(buf[:])
+			}
+			key := h.Sum64() ^ uint64(band)
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	checked := make(map[[2]int]bool)
+	for _, bucket := range buckets {
+		for x := 0; x < len(bucket); x++ {
+			for y := x + 1; y < len(bucket); y++ {
+				pair := [2]int{bucket[x], bucket[y]}
+				if checked[pair] {
+					continue
+				}
+				checked[pair] = true
+				if jaccard(shingles[pair[0]], shingles[pair[1]]) >= clusterSimilarity {
+					union(pair[0], pair[1])
+				}
+			}
+		}
+	}
+
+	byRoot := make(map[int][]int)
+	var roots []int
+	for i := range items {
+		root := find(i)
+		if _, ok := byRoot[root]; !ok {
+			roots = append(roots, root)
+		}
+		byRoot[root] = append(byRoot[root], i)
+	}
+
+	groups := make([][]int, 0, len(roots))
+	for _, root := range roots {
+		groups = append(groups, byRoot[root])
+	}
+	return groups
+}
+
+func buildCluster(items []models.NewsItem, members []int) models.StoryCluster {
+	cluster := models.StoryCluster{
+		Representative: items[members[0]],
+		Coverage:       make([]models.ClusterMember, 0, len(members)),
+	}
+
+	sources := make(map[string]bool)
+	earliest := members[0]
+	for _, i := range members {
+		item := items[i]
+		sources[item.Source] = true
+		cluster.Coverage = append(cluster.Coverage, models.ClusterMember{
+			ID:        item.ID,
+			Title:     item.Title,
+			Link:      item.Link,
+			Source:    item.Source,
+			Published: item.Published,
+		})
+
+		if cluster.FirstPublished.IsZero() || item.Published.Before(cluster.FirstPublished) {
+			cluster.FirstPublished = item.Published
+		}
+		if item.Published.After(cluster.LastPublished) {
+			cluster.LastPublished = item.Published
+		}
+		if item.Published.Before(items[earliest].Published) ||
+			(item.Published.Equal(items[earliest].Published) && item.ID < items[earliest].ID) {
+			earliest = i
+		}
+	}
+	cluster.SourceCount = len(sources)
+
+	sort.SliceStable(cluster.Coverage, func(i, j int) bool {
+		return cluster.Coverage[i].Published.Before(cluster.Coverage[j].Published)
+	})
+
+	// Name the cluster after its earliest item so the ID survives new coverage
+	hash := sha256.Sum256([]byte(items[earliest].ID))
+	cluster.ID = hex.EncodeToString(hash[:])[:16]
+	return cluster
+}
+
+// canonicalURLKey reduces a link to a comparable key: no scheme, no "www."
+// prefix, no fragment, no trailing slash, no tracking parameters and the
+// rest in sorted order.
+func canonicalURLKey(link string) string {
+	u, err := url.Parse(cleanURL(link))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	key := strings.TrimPrefix(strings.ToLower(u.Host), "www.") + strings.TrimSuffix(u.EscapedPath(), "/")
+	if encoded := u.Query().Encode(); encoded != "" {
+		key += "?" + encoded
+	}
+	return key
+}
+
+// itemShingles returns the set of word unigrams and bigrams from the title
+// and the start of the description. Words are stemmed in the item's
+// language and stop words left out, so inflections and filler do not hide
+// shared wording.
+func itemShingles(item models.NewsItem) map[string]bool {
+	analyzer := textanalysis.For(item.Language)
+	words := shingleWords(analyzer, item.Title)
+	desc := shingleWords(analyzer, item.Description)
+	if len(desc) > maxDescriptionWords {
+		desc = desc[:maxDescriptionWords]
+	}
+
+	shingles := make(map[string]bool)
+	for _, part := range [][]string{words, desc} {
+		for i, word := range part {
+			shingles[word] = true
+			if i > 0 {
+				shingles[part[i-1]+" "+word] = true
+			}
+		}
+	}
+	return shingles
+}
+
+func shingleWords(analyzer *textanalysis.Analyzer, text string) []string {
+	var words []string
+	for _, token := range analyzer.Analyze(text) {
+		if !token.Stop && textanalysis.Length(token.Lower) >= 3 {
+			words = append(words, token.Stem)
+		}
+	}
+	return words
+}
+
+func minHashSignature(shingles map[string]bool) [minHashSize]uint64 {
+	var sig [minHashSize]uint64
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for shingle := range shingles {
+		h := fnv.New64a()
+		h.Write([]byte(shingle))
+		base := h.Sum64()
+		for i, seed := range minHashSeeds {
+			if v := splitMix64(base ^ seed); v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+	return sig
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	shared := 0
+	for shingle := range a {
+		if b[shingle] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+func splitMix64(x uint64) uint64 {
+	x += 0x9E3779B97F4A7C15
+	x = (x ^ (x >> 30)) * 0xBF58476D1CE4E5B9
+	x = (x ^ (x >> 27)) * 0x94D049BB133111EB
+	return x ^ (x >> 31)
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+func TestClusterNews(t *testing.T) {
+	service := &NewsService{}
+	now := time.Now()
+
+	items := []models.NewsItem{
+		{ID: "guardian", Source: "Guardian", Link: "https://www.theguardian.com/world/2024/quake",
+			Title:       "Powerful earthquake strikes central Japan, tsunami warning issued",
+			Description: "A powerful earthquake struck central Japan on Monday, prompting a tsunami warning for the coast.",
+			Published:   now.Add(-time.Hour)},
+		{ID: "reddit", Source: "Reddit World News", Link: "https://reddit.com/r/worldnews/quake",
+			Title:       "Powerful earthquake strikes central Japan; tsunami warning issued",
+			Description: "A powerful earthquake struck central Japan on Monday, prompting a tsunami warning.",
+			Published:   now.Add(-2 * time.Hour)},
+		{ID: "nrk-culture", Source: "NRK Culture", Link: "https://www.nrk.no/kultur/konsert-1.123?utm_source=rss",
+			Title: "Stor konsert i Bergen", Published: now},
+		{ID: "nrk-entertainment", Source: "NRK Entertainment", Link: "https://nrk.no/kultur/konsert-1.123",
+			Title: "Stor konsert i Bergen", Published: now},
+		{ID: "unrelated", Source: "TechCrunch", Link: "https://techcrunch.com/startup",
+			Title:       "Startup raises funding for battery recycling",
+			Description: "The company plans to expand its recycling plants.",
+			Published:   now},
+	}
+
+	clusters := service.ClusterNews(items)
+	if len(clusters) != 3 {
+		t.Fatalf("Expected 3 clusters, got %d", len(clusters))
+	}
+
+	quake := clusters[0]
+	if quake.Representative.ID != "guardian" {
+		t.Errorf("Expected first item as representative, got %s", quake.Representative.ID)
+	}
+	if len(quake.Coverage) != 2 || quake.SourceCount != 2 {
+		t.Errorf("Expected coverage from 2 sources, got %+v", quake.Coverage)
+	}
+	if quake.Coverage[0].ID != "reddit" {
+		t.Errorf("Expected coverage in publication order, got %s first", quake.Coverage[0].ID)
+	}
+	if items[0].ClusterID != quake.ID || items[1].ClusterID != quake.ID {
+		t.Error("Expected cluster ID to be set on members")
+	}
+
+	if len(clusters[1].Coverage) != 2 {
+		t.Errorf("Expected exact URL duplicates to be clustered, got %+v", clusters[1].Coverage)
+	}
+	if len(clusters[2].Coverage) != 1 {
+		t.Errorf("Expected unrelated item on its own, got %+v", clusters[2].Coverage)
+	}
+
+	// The cluster ID follows the earliest item, not the input order
+	reordered := []models.NewsItem{items[1], items[0]}
+	if again := service.ClusterNews(reordered); again[0].ID != quake.ID {
+		t.Errorf("Expected stable cluster ID, got %s and %s", again[0].ID, quake.ID)
+	}
+}
+
+func TestCanonicalURLKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"https://www.example.com/story/", "http://example.com/story"},
+		{"https://example.com/story?utm_source=rss&id=1", "https://example.com/story?id=1"},
+		{"https://example.com/story#comments", "https://example.com/story"},
+		{"https://example.com/story?b=2&a=1", "https://example.com/story?a=1&b=2"},
+	}
+
+	for _, tt := range tests {
+		if canonicalURLKey(tt.a) != canonicalURLKey(tt.b) {
+			t.Errorf("canonicalURLKey(%q) = %q, canonicalURLKey(%q) = %q", tt.a, canonicalURLKey(tt.a), tt.b, canonicalURLKey(tt.b))
+		}
+	}
+}
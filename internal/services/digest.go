@@ -0,0 +1,674 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+var (
+	// ErrDigestNotFound is returned when an ID matches no digest.
+	ErrDigestNotFound = errors.New("digest not found")
+	// ErrInvalidDigest is wrapped by errors describing a digest that cannot
+	// be used.
+	ErrInvalidDigest = errors.New("invalid digest")
+	// ErrMailNotConfigured is returned when a digest is mailed without an
+	// SMTP server set.
+	ErrMailNotConfigured = errors.New("no SMTP server is configured")
+	// ErrDigestDirNotConfigured is returned when a digest is written to a
+	// directory without a digest directory set.
+	ErrDigestDirNotConfigured = errors.New("no digest directory is configured")
+)
+
+const (
+	defaultDigestTime     = "07:00"
+	defaultDigestWeekday  = "monday"
+	defaultDigestStories  = 10
+	digestTrendingLimit   = 10
+	maxDigestStarredItems = 20
+	maxDigestTagItems     = 5
+)
+
+//go:embed templates/digest.html
+var digestHTML string
+
+//go:embed templates/digest.md
+var digestMarkdown string
+
+var digestWeekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Mailer is the SMTP server digests are mailed through. Username and
+// Password are optional.
+type Mailer struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+// DigestContent is what a digest covers: the top stories of the period,
+// its trending topics, the items starred during it, and the new items of
+// every followed tag. An item appears in at most one section.
+type DigestContent struct {
+	Digest   models.Digest         `json:"digest"`
+	From     time.Time             `json:"from"`
+	To       time.Time             `json:"to"`
+	Stories  []models.StoryCluster `json:"stories"`
+	Trending []TrendingTopic       `json:"trending"`
+	Starred  []models.NewsItem     `json:"starred"`
+	Tags     []DigestTag           `json:"tags"`
+}
+
+// DigestTag is the new items of a followed tag.
+type DigestTag struct {
+	Tag   string            `json:"tag"`
+	Name  string            `json:"name"`
+	Items []models.NewsItem `json:"items"`
+}
+
+// Empty reports whether the digest has nothing to show.
+func (d DigestContent) Empty() bool {
+	return len(d.Stories) == 0 && len(d.Starred) == 0 && len(d.Tags) == 0
+}
+
+// digestRunner serializes sending, so a scheduled run and a manual send do
+// not deliver the same digest twice.
+type digestRunner struct {
+	mu     sync.Mutex
+	mailer Mailer
+	// dir is the directory digest files are written under.
+	dir string
+}
+
+// SetMailer sets the SMTP server digests are mailed through.
+func (s *NewsService) SetMailer(mailer Mailer) {
+	s.digests.mu.Lock()
+	defer s.digests.mu.Unlock()
+	s.digests.mailer = mailer
+}
+
+// SetDigestDir sets the directory digests are written under. Each digest's
+// Directory is a path relative to it.
+func (s *NewsService) SetDigestDir(dir string) {
+	s.digests.mu.Lock()
+	defer s.digests.mu.Unlock()
+	s.digests.dir = dir
+}
+
+// Digests returns the configured digests.
+func (s *NewsService) Digests() []models.Digest {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]models.Digest{}, s.preferences.Digests...)
+}
+
+// CreateDigest validates and saves a new digest.
+func (s *NewsService) CreateDigest(digest models.Digest) (models.Digest, error) {
+	if err := validateDigest(&digest); err != nil {
+		return models.Digest{}, err
+	}
+
+	digest.Created = time.Now()
+	hash := sha256.Sum256([]byte(digest.Name + digest.Created.String()))
+	digest.ID = hex.EncodeToString(hash[:])[:8]
+	digest.LastSent, digest.LastError = time.Time{}, ""
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.preferences.Digests = append(s.preferences.Digests, digest)
+	if err := s.savePreferences(); err != nil {
+		return models.Digest{}, err
+	}
+	return digest, nil
+}
+
+// UpdateDigest replaces the digest id with update, keeping when it was
+// created and last sent.
+func (s *NewsService) UpdateDigest(id string, update models.Digest) (models.Digest, error) {
+	if err := validateDigest(&update); err != nil {
+		return models.Digest{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, digest := range s.preferences.Digests {
+		if digest.ID == id {
+			update.ID, update.Created = id, digest.Created
+			update.LastSent, update.LastError = digest.LastSent, digest.LastError
+			s.preferences.Digests[i] = update
+			if err := s.savePreferences(); err != nil {
+				return models.Digest{}, err
+			}
+			return update, nil
+		}
+	}
+	return models.Digest{}, ErrDigestNotFound
+}
+
+// DeleteDigest removes the digest id.
+func (s *NewsService) DeleteDigest(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, digest := range s.preferences.Digests {
+		if digest.ID == id {
+			s.preferences.Digests = append(s.preferences.Digests[:i], s.preferences.Digests[i+1:]...)
+			return s.savePreferences()
+		}
+	}
+	return ErrDigestNotFound
+}
+
+func (s *NewsService) findDigest(id string) (models.Digest, bool) {
+	for _, digest := range s.Digests() {
+		if digest.ID == id {
+			return digest, true
+		}
+	}
+	return models.Digest{}, false
+}
+
+// BuildDigest collects what the digest id covers from items, for the day
+// or week up to now.
+func (s *NewsService) BuildDigest(id string, items []models.NewsItem, now time.Time) (DigestContent, error) {
+	digest, ok := s.findDigest(id)
+	if !ok {
+		return DigestContent{}, ErrDigestNotFound
+	}
+	return s.buildDigest(digest, items, now), nil
+}
+
+func (s *NewsService) buildDigest(digest models.Digest, items []models.NewsItem, now time.Time) DigestContent {
+	loc := digestLocation(digest)
+	period := digestPeriod(digest)
+	content := DigestContent{
+		Digest: digest,
+		From:   now.Add(-period).In(loc),
+		To:     now.In(loc),
+	}
+
+	// The items of the period the user would see, best first
+	visible, _ := s.FilterByState(s.ApplyItemState(s.FilterNews(items)), "")
+	var recent []models.NewsItem
+	for _, item := range visible {
+		if item.Published.After(content.From) && !item.Published.After(now) {
+			recent = append(recent, item)
+		}
+	}
+	recent = s.RankNews(recent, false)
+
+	seen := make(map[string]bool)
+	maxStories := digest.MaxStories
+	if maxStories <= 0 {
+		maxStories = defaultDigestStories
+	}
+	clusters := s.ClusterNews(append([]models.NewsItem{}, recent...))
+	s.MarkBreaking(clusters)
+	for _, cluster := range clusters {
+		if len(content.Stories) == maxStories {
+			break
+		}
+		content.Stories = append(content.Stories, cluster)
+		for _, member := range cluster.Coverage {
+			seen[member.ID] = true
+		}
+	}
+
+	content.Trending = s.TrendingTopics(TrendingOptions{
+		Window:   period,
+		Baseline: 4 * period,
+		Limit:    digestTrendingLimit,
+		Now:      now,
+	}, "").Topics
+
+	// Items starred during the period, whenever they were published
+	s.mu.RLock()
+	states := s.preferences.ItemStates
+	for _, item := range visible {
+		state := states[item.ID]
+		if item.Starred && state.UpdatedAt.After(content.From) && !seen[item.ID] && len(content.Starred) < maxDigestStarredItems {
+			content.Starred = append(content.Starred, item)
+			seen[item.ID] = true
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, follow := range s.Follows() {
+		if follow.Type != models.FollowTag {
+			continue
+		}
+		tag := DigestTag{Tag: follow.Target, Name: follow.Name}
+		for _, item := range s.followedItems(follow, recent) {
+			if len(tag.Items) == maxDigestTagItems {
+				break
+			}
+			if !seen[item.ID] {
+				tag.Items = append(tag.Items, item)
+				seen[item.ID] = true
+			}
+		}
+		if len(tag.Items) > 0 {
+			content.Tags = append(content.Tags, tag)
+		}
+	}
+	return content
+}
+
+// RenderDigest renders content as DigestHTML or DigestMarkdown.
+func RenderDigest(content DigestContent, format string) ([]byte, error) {
+	loc := content.To.Location()
+	funcs := map[string]interface{}{
+		"date": func(t time.Time) string { return t.In(loc).Format("Monday 2 January 2006") },
+		"time": func(t time.Time) string { return t.In(loc).Format("2 Jan 15:04") },
+		"inc":  func(i int) int { return i + 1 },
+		"more": func(sources int) int { return sources - 1 },
+		"md":   markdownEscape,
+		"url":  markdownURL,
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case models.DigestHTML, "":
+		tmpl, err := htmltemplate.New("digest").Funcs(funcs).Parse(digestHTML)
+		if err != nil {
+			return nil, err
+		}
+		if err := tmpl.Execute(&buf, content); err != nil {
+			return nil, err
+		}
+	case models.DigestMarkdown:
+		tmpl, err := texttemplate.New("digest").Funcs(funcs).Parse(digestMarkdown)
+		if err != nil {
+			return nil, err
+		}
+		if err := tmpl.Execute(&buf, content); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: format must be html or markdown", ErrInvalidDigest)
+	}
+	return buf.Bytes(), nil
+}
+
+// markdownEscape escapes the characters that would start Markdown markup.
+func markdownEscape(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`,
+		"[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`,
+	).Replace(text)
+}
+
+// markdownURL percent-encodes the characters that would end a Markdown
+// link destination early or break it up.
+func markdownURL(link string) string {
+	return strings.NewReplacer(
+		"(", "%28", ")", "%29", " ", "%20", "<", "%3C", ">", "%3E",
+	).Replace(link)
+}
+
+// SendDigest builds the digest id from items and delivers it now, whatever
+// its schedule. A digest with nothing to show is not delivered.
+func (s *NewsService) SendDigest(id string, items []models.NewsItem) (models.Digest, error) {
+	digest, ok := s.findDigest(id)
+	if !ok {
+		return models.Digest{}, ErrDigestNotFound
+	}
+	return s.sendDigest(digest, items, time.Now())
+}
+
+func (s *NewsService) sendDigest(digest models.Digest, items []models.NewsItem, now time.Time) (models.Digest, error) {
+	s.digests.mu.Lock()
+	defer s.digests.mu.Unlock()
+
+	content := s.buildDigest(digest, items, now)
+	var err error
+	if !content.Empty() {
+		err = s.deliverDigest(content, now)
+	}
+
+	digest.LastSent, digest.LastError = now, ""
+	if err != nil {
+		digest.LastError = err.Error()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.preferences.Digests {
+		if s.preferences.Digests[i].ID == digest.ID {
+			s.preferences.Digests[i].LastSent = digest.LastSent
+			s.preferences.Digests[i].LastError = digest.LastError
+			digest = s.preferences.Digests[i]
+			if saveErr := s.savePreferences(); saveErr != nil && err == nil {
+				err = saveErr
+			}
+		}
+	}
+	return digest, err
+}
+
+// deliverDigest writes content to the digest's directory and mails it to
+// its recipients. Callers must hold s.digests.mu.
+func (s *NewsService) deliverDigest(content DigestContent, now time.Time) error {
+	digest := content.Digest
+	if digest.Directory != "" {
+		format := digest.Format
+		ext := "html"
+		if format == models.DigestMarkdown {
+			ext = "md"
+		}
+		data, err := RenderDigest(content, format)
+		if err != nil {
+			return err
+		}
+		if s.digests.dir == "" {
+			return ErrDigestDirNotConfigured
+		}
+		dir, err := digestDirectory(s.digests.dir, digest.Directory)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		name := fmt.Sprintf("digest-%s-%s.%s", digest.ID, content.To.Format("2006-01-02"), ext)
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+
+	if len(digest.To) > 0 {
+		if s.digests.mailer.Addr == "" {
+			return ErrMailNotConfigured
+		}
+		// The envelope takes bare addresses, the headers their display forms
+		from, err := mail.ParseAddress(s.digests.mailer.From)
+		if err != nil {
+			return fmt.Errorf("invalid sender %q: %v", s.digests.mailer.From, err)
+		}
+		to := make([]*mail.Address, len(digest.To))
+		recipients := make([]string, len(digest.To))
+		for i, addr := range digest.To {
+			if to[i], err = mail.ParseAddress(addr); err != nil {
+				return fmt.Errorf("invalid recipient %q: %v", addr, err)
+			}
+			recipients[i] = to[i].Address
+		}
+		msg, err := digestMessage(from, to, content, now)
+		if err != nil {
+			return err
+		}
+		var auth smtp.Auth
+		if m := s.digests.mailer; m.Username != "" {
+			host, _, _ := net.SplitHostPort(m.Addr)
+			auth = smtp.PlainAuth("", m.Username, m.Password, host)
+		}
+		if err := smtp.SendMail(s.digests.mailer.Addr, auth, from.Address, recipients, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// digestMessage builds a multipart/alternative mail carrying the digest as
+// Markdown text and as HTML.
+func digestMessage(from *mail.Address, to []*mail.Address, content DigestContent, now time.Time) ([]byte, error) {
+	text, err := RenderDigest(content, models.DigestMarkdown)
+	if err != nil {
+		return nil, err
+	}
+	html, err := RenderDigest(content, models.DigestHTML)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		data        []byte
+	}{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write(part.data); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	subject := fmt.Sprintf("%s, %s", content.Digest.Name, content.To.Format("2 January 2006"))
+	var msg bytes.Buffer
+	recipients := make([]string, len(to))
+	for i, addr := range to {
+		recipients[i] = headerAddress(addr)
+	}
+	fmt.Fprintf(&msg, "From: %s\r\n", headerAddress(from))
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// headerAddress formats addr for a mail header, quoting and encoding its
+// display name as needed. Addresses without a name are written bare.
+func headerAddress(addr *mail.Address) string {
+	if addr.Name == "" {
+		return addr.Address
+	}
+	return addr.String()
+}
+
+// StartDigests checks every interval for digests that are due and sends
+// them, until stop is called.
+func (s *NewsService) StartDigests(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.runDueDigests(time.Now())
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// runDueDigests sends every enabled digest whose scheduled time has passed
+// since it was last sent. The news is fetched once, and only if a digest is
+// due. A failed digest waits for its next scheduled time.
+func (s *NewsService) runDueDigests(now time.Time) {
+	var items []models.NewsItem
+	fetched := false
+	for _, digest := range s.Digests() {
+		if digest.Disabled || !digestDue(digest, now) {
+			continue
+		}
+		if !fetched {
+			items, fetched = s.FetchNews(), true
+		}
+		if _, err := s.sendDigest(digest, items, now); err != nil {
+			log.Printf("Digest %s failed: %v", digest.ID, err)
+		}
+	}
+}
+
+// digestDue reports whether the digest's latest scheduled time at or
+// before now came after it was created and last sent.
+func digestDue(digest models.Digest, now time.Time) bool {
+	scheduled := lastScheduled(digest, now)
+	return !scheduled.Before(digest.Created) && digest.LastSent.Before(scheduled)
+}
+
+// lastScheduled returns the digest's latest scheduled time at or before now.
+func lastScheduled(digest models.Digest, now time.Time) time.Time {
+	at, err := time.Parse("15:04", digest.Time)
+	if err != nil {
+		at, _ = time.Parse("15:04", defaultDigestTime)
+	}
+	local := now.In(digestLocation(digest))
+	scheduled := time.Date(local.Year(), local.Month(), local.Day(), at.Hour(), at.Minute(), 0, 0, local.Location())
+	if scheduled.After(local) {
+		scheduled = scheduled.AddDate(0, 0, -1)
+	}
+	if digest.Schedule == models.DigestWeekly {
+		weekday, ok := digestWeekdays[strings.ToLower(digest.Weekday)]
+		if !ok {
+			weekday = digestWeekdays[defaultDigestWeekday]
+		}
+		for scheduled.Weekday() != weekday {
+			scheduled = scheduled.AddDate(0, 0, -1)
+		}
+	}
+	return scheduled
+}
+
+func digestPeriod(digest models.Digest) time.Duration {
+	if digest.Schedule == models.DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+func digestLocation(digest models.Digest) *time.Location {
+	if digest.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(digest.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// validateDigest checks a digest and fills in its defaults.
+func validateDigest(digest *models.Digest) error {
+	switch digest.Schedule {
+	case models.DigestDaily, models.DigestWeekly:
+	default:
+		return fmt.Errorf("%w: schedule must be daily or weekly", ErrInvalidDigest)
+	}
+
+	if digest.Time == "" {
+		digest.Time = defaultDigestTime
+	}
+	if _, err := time.Parse("15:04", digest.Time); err != nil {
+		return fmt.Errorf("%w: time must be a time such as 07:00", ErrInvalidDigest)
+	}
+
+	if digest.Schedule == models.DigestWeekly {
+		if digest.Weekday == "" {
+			digest.Weekday = defaultDigestWeekday
+		}
+		digest.Weekday = strings.ToLower(digest.Weekday)
+		if _, ok := digestWeekdays[digest.Weekday]; !ok {
+			return fmt.Errorf("%w: unknown weekday %q", ErrInvalidDigest, digest.Weekday)
+		}
+	} else {
+		digest.Weekday = ""
+	}
+
+	if digest.Timezone != "" {
+		if _, err := time.LoadLocation(digest.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidDigest, digest.Timezone)
+		}
+	}
+
+	switch digest.Format {
+	case "":
+		digest.Format = models.DigestHTML
+	case models.DigestHTML, models.DigestMarkdown:
+	default:
+		return fmt.Errorf("%w: format must be html or markdown", ErrInvalidDigest)
+	}
+
+	if len(digest.To) == 0 && digest.Directory == "" {
+		return fmt.Errorf("%w: give recipients or a directory", ErrInvalidDigest)
+	}
+	if digest.Directory != "" {
+		dir, err := digestDirectory(".", digest.Directory)
+		if err != nil {
+			return err
+		}
+		digest.Directory = dir
+	}
+	for _, to := range digest.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("%w: invalid recipient %q", ErrInvalidDigest, to)
+		}
+	}
+
+	if digest.MaxStories < 0 {
+		return fmt.Errorf("%w: maxStories must not be negative", ErrInvalidDigest)
+	}
+
+	if strings.TrimSpace(digest.Name) == "" {
+		digest.Name = "Daily digest"
+		if digest.Schedule == models.DigestWeekly {
+			digest.Name = "Weekly digest"
+		}
+	}
+	return nil
+}
+
+// digestDirectory returns dir joined to base, rejecting absolute paths and
+// paths that leave base once cleaned.
+func digestDirectory(base, dir string) (string, error) {
+	if filepath.IsAbs(dir) {
+		return "", fmt.Errorf("%w: directory %q must be relative to the digest directory", ErrInvalidDigest, dir)
+	}
+	path := filepath.Join(base, dir)
+	rel, err := filepath.Rel(base, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: directory %q is outside the digest directory", ErrInvalidDigest, dir)
+	}
+	return path, nil
+}
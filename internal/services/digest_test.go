@@ -0,0 +1,374 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+func TestDigestSchedule(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Skipf("No time zone data: %v", err)
+	}
+	// Wednesday 6 March 2024, 08:30 in Oslo
+	now := time.Date(2024, 3, 6, 8, 30, 0, 0, oslo)
+	created := now.AddDate(0, -1, 0)
+
+	tests := []struct {
+		name   string
+		digest models.Digest
+		want   time.Time
+		due    bool
+	}{
+		{
+			"daily after its time",
+			models.Digest{Schedule: models.DigestDaily, Time: "07:00", Timezone: "Europe/Oslo", Created: created},
+			time.Date(2024, 3, 6, 7, 0, 0, 0, oslo), true,
+		},
+		{
+			"daily before its time",
+			models.Digest{Schedule: models.DigestDaily, Time: "09:00", Timezone: "Europe/Oslo", Created: created},
+			time.Date(2024, 3, 5, 9, 0, 0, 0, oslo), true,
+		},
+		{
+			"daily already sent",
+			models.Digest{Schedule: models.DigestDaily, Time: "07:00", Timezone: "Europe/Oslo", Created: created, LastSent: now.Add(-time.Hour)},
+			time.Date(2024, 3, 6, 7, 0, 0, 0, oslo), false,
+		},
+		{
+			"daily created after its time",
+			models.Digest{Schedule: models.DigestDaily, Time: "07:00", Timezone: "Europe/Oslo", Created: now.Add(-time.Minute)},
+			time.Date(2024, 3, 6, 7, 0, 0, 0, oslo), false,
+		},
+		{
+			"weekly",
+			models.Digest{Schedule: models.DigestWeekly, Time: "18:00", Weekday: "monday", Timezone: "Europe/Oslo", Created: created},
+			time.Date(2024, 3, 4, 18, 0, 0, 0, oslo), true,
+		},
+		{
+			"weekly in another zone",
+			models.Digest{Schedule: models.DigestWeekly, Time: "20:00", Weekday: "tuesday", Timezone: "America/New_York", Created: created},
+			time.Date(2024, 3, 6, 2, 0, 0, 0, oslo), true,
+		},
+	}
+	for _, tt := range tests {
+		if got := lastScheduled(tt.digest, now); !got.Equal(tt.want) {
+			t.Errorf("%s: lastScheduled() = %v, want %v", tt.name, got, tt.want)
+		}
+		if got := digestDue(tt.digest, now); got != tt.due {
+			t.Errorf("%s: digestDue() = %v, want %v", tt.name, got, tt.due)
+		}
+	}
+}
+
+func TestValidateDigest(t *testing.T) {
+	invalid := []models.Digest{
+		{Schedule: "hourly", Directory: "out"},
+		{Schedule: models.DigestDaily, Time: "7am", Directory: "out"},
+		{Schedule: models.DigestWeekly, Weekday: "someday", Directory: "out"},
+		{Schedule: models.DigestDaily, Timezone: "Mars/Olympus", Directory: "out"},
+		{Schedule: models.DigestDaily, Format: "pdf", Directory: "out"},
+		{Schedule: models.DigestDaily},
+		{Schedule: models.DigestDaily, To: []string{"not an address"}},
+		{Schedule: models.DigestDaily, Directory: "/tmp/out"},
+		{Schedule: models.DigestDaily, Directory: "../out"},
+		{Schedule: models.DigestDaily, Directory: "daily/../../out"},
+	}
+	for _, digest := range invalid {
+		if err := validateDigest(&digest); !errors.Is(err, ErrInvalidDigest) {
+			t.Errorf("validateDigest(%+v) error = %v, want ErrInvalidDigest", digest, err)
+		}
+	}
+
+	digest := models.Digest{Schedule: models.DigestWeekly, Weekday: "Friday", To: []string{"Reader <reader@example.com>"}}
+	if err := validateDigest(&digest); err != nil {
+		t.Fatalf("validateDigest() error = %v", err)
+	}
+	if digest.Time != "07:00" || digest.Weekday != "friday" || digest.Format != models.DigestHTML || digest.Name != "Weekly digest" {
+		t.Errorf("validateDigest() = %+v, want the defaults filled in", digest)
+	}
+}
+
+// newDigestService returns a service whose preferences let every item
+// through.
+func newDigestService(t *testing.T) *NewsService {
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+	prefs := *service.GetPreferences()
+	prefs.Categories, prefs.ContentTypes = nil, nil
+	if err := service.UpdatePreferences(prefs); err != nil {
+		t.Fatalf("Failed to update preferences: %v", err)
+	}
+	return service
+}
+
+func digestItems(now time.Time) []models.NewsItem {
+	europe := []models.Tag{{ID: "europe", Name: "Europe"}}
+	return []models.NewsItem{
+		{ID: "a", Title: "Storting passes the new state budget after long debate", Link: "https://example.com/a", Source: "NRK", Published: now.Add(-2 * time.Hour)},
+		{ID: "b", Title: "Storting passes new state budget after a long debate", Link: "https://example.com/b", Source: "VG", Published: now.Add(-3 * time.Hour)},
+		{ID: "c", Title: "EU ministers meet in Brussels", Link: "https://example.com/c", Source: "NRK", Published: now.Add(-4 * time.Hour), Tags: europe},
+		{ID: "d", Title: "Alpine <script> pass *reopens* after storms", Link: "https://example.com/d", Source: "VG", Published: now.Add(-5 * time.Hour), Tags: europe},
+		{ID: "e", Title: "Old story from last month", Link: "https://example.com/e", Source: "NRK", Published: now.AddDate(0, -1, 0)},
+	}
+}
+
+func TestBuildDigest(t *testing.T) {
+	service := newDigestService(t)
+	now := time.Now()
+	items := digestItems(now)
+
+	digest, err := service.CreateDigest(models.Digest{Name: "Morning", Schedule: models.DigestDaily, Directory: "morning", MaxStories: 2})
+	if err != nil {
+		t.Fatalf("Failed to create digest: %v", err)
+	}
+	if _, err := service.CreateFollow(models.Follow{Type: models.FollowTag, Target: "europe"}, items); err != nil {
+		t.Fatalf("Failed to follow tag: %v", err)
+	}
+	// Starred today, though published long ago
+	if err := service.SetItemState([]string{"e"}, models.StateStarred, true); err != nil {
+		t.Fatalf("Failed to star item: %v", err)
+	}
+
+	content, err := service.BuildDigest(digest.ID, items, now)
+	if err != nil {
+		t.Fatalf("BuildDigest() error = %v", err)
+	}
+	if len(content.Stories) != 2 || len(content.Stories[0].Coverage) != 2 || content.Stories[0].SourceCount != 2 {
+		t.Fatalf("BuildDigest() stories = %+v, want the budget story covered twice first", content.Stories)
+	}
+	if len(content.Starred) != 1 || content.Starred[0].ID != "e" {
+		t.Errorf("BuildDigest() starred = %+v, want the starred old story", content.Starred)
+	}
+	// The second story is a Europe item, so only the other one is listed
+	// under the tag
+	if len(content.Tags) != 1 || len(content.Tags[0].Items) != 1 || content.Tags[0].Name != "Europe" {
+		t.Errorf("BuildDigest() tags = %+v, want one Europe item not among the stories", content.Tags)
+	}
+
+	html, err := RenderDigest(content, models.DigestHTML)
+	if err != nil {
+		t.Fatalf("RenderDigest(html) error = %v", err)
+	}
+	if !strings.Contains(string(html), `<a href="https://example.com/a">Storting passes the new state budget after long debate</a>`) ||
+		!strings.Contains(string(html), "and 1 more source") || strings.Contains(string(html), "<script>") {
+		t.Errorf("RenderDigest(html) = %s, want the story linked and titles escaped", html)
+	}
+
+	markdown, err := RenderDigest(content, models.DigestMarkdown)
+	if err != nil {
+		t.Fatalf("RenderDigest(markdown) error = %v", err)
+	}
+	if !strings.Contains(string(markdown), "## Top stories") || !strings.Contains(string(markdown), "## New in Europe") ||
+		!strings.Contains(string(markdown), "1. [Storting passes the new state budget after long debate](https://example.com/a)") {
+		t.Errorf("RenderDigest(markdown) = %s, want the sections and a numbered story", markdown)
+	}
+	if !strings.Contains(string(markdown), `Alpine \<script\> pass \*reopens\* after storms`) {
+		t.Errorf("RenderDigest(markdown) = %s, want markup in titles escaped", markdown)
+	}
+	if got := markdownURL("https://example.com/wiki/Storm_(2024) <x>"); got != "https://example.com/wiki/Storm_%282024%29%20%3Cx%3E" {
+		t.Errorf("markdownURL() = %q, want the parentheses, space and brackets encoded", got)
+	}
+
+	if _, err := service.BuildDigest("missing", items, now); !errors.Is(err, ErrDigestNotFound) {
+		t.Errorf("BuildDigest(missing) error = %v, want ErrDigestNotFound", err)
+	}
+}
+
+// smtpStandIn accepts one mail per connection and sends what it received
+// on the returned channel, after its MAIL and RCPT commands.
+func smtpStandIn(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	mails := make(chan string, 8)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+				reply("220 localhost ESMTP")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+					case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+						reply("250 localhost")
+					case strings.HasPrefix(cmd, "DATA"):
+						reply("354 go ahead")
+						for {
+							line, err := r.ReadString('\n')
+							if err != nil {
+								return
+							}
+							if line == ".\r\n" {
+								break
+							}
+							data.WriteString(strings.TrimPrefix(line, "."))
+						}
+						mails <- data.String()
+						reply("250 queued")
+					case strings.HasPrefix(cmd, "QUIT"):
+						reply("221 bye")
+						return
+					case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+						mails <- strings.TrimSpace(line)
+						reply("250 ok")
+					default:
+						reply("250 ok")
+					}
+				}
+			}(conn)
+		}
+	}()
+	return ln.Addr().String(), mails
+}
+
+func TestSendDigest(t *testing.T) {
+	service := newDigestService(t)
+	now := time.Now()
+	items := digestItems(now)
+	base := t.TempDir()
+	service.SetDigestDir(base)
+	dir := filepath.Join(base, "morning")
+
+	digest, err := service.CreateDigest(models.Digest{
+		Name:      "Morning news",
+		Schedule:  models.DigestDaily,
+		Format:    models.DigestMarkdown,
+		To:        []string{"reader@example.com", `"Nordmann, Kari" <kari@example.com>`},
+		Directory: "morning",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create digest: %v", err)
+	}
+
+	sent, err := service.SendDigest(digest.ID, items)
+	if !errors.Is(err, ErrMailNotConfigured) || sent.LastError == "" {
+		t.Errorf("SendDigest() without SMTP = %+v, %v, want ErrMailNotConfigured recorded", sent, err)
+	}
+
+	addr, mails := smtpStandIn(t)
+	service.SetMailer(Mailer{Addr: addr, From: "News Reader <news@example.com>"})
+	sent, err = service.SendDigest(digest.ID, items)
+	if err != nil {
+		t.Fatalf("SendDigest() error = %v", err)
+	}
+	if sent.LastSent.IsZero() || sent.LastError != "" {
+		t.Errorf("SendDigest() = %+v, want it sent without error", sent)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "digest-"+digest.ID+"-*.md"))
+	if len(files) != 1 {
+		t.Fatalf("Digest files = %v, want one Markdown file", files)
+	}
+	if data, _ := os.ReadFile(files[0]); !strings.Contains(string(data), "# Morning news") {
+		t.Errorf("Digest file = %s, want the Markdown digest", data)
+	}
+
+	// The envelope has the bare addresses
+	var raw string
+	var envelope []string
+	for raw == "" {
+		select {
+		case line := <-mails:
+			if strings.HasPrefix(line, "MAIL") || strings.HasPrefix(line, "RCPT") {
+				envelope = append(envelope, line)
+			} else {
+				raw = line
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("No mail received")
+		}
+	}
+	if want := []string{"MAIL FROM:<news@example.com>", "RCPT TO:<reader@example.com>", "RCPT TO:<kari@example.com>"}; !reflect.DeepEqual(envelope, want) {
+		t.Errorf("Envelope = %q, want %q", envelope, want)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Failed to parse mail: %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if !strings.HasPrefix(subject, "Morning news, ") || msg.Header.Get("To") != `reader@example.com, "Nordmann, Kari" <kari@example.com>` {
+		t.Errorf("Mail headers = %v, want the digest subject and recipients", msg.Header)
+	}
+	if from, err := mail.ParseAddress(msg.Header.Get("From")); err != nil || from.Name != "News Reader" {
+		t.Errorf("Mail From = %q, want the sender's name", msg.Header.Get("From"))
+	}
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("Mail Content-Type = %q, want multipart/alternative", mediaType)
+	}
+	var types []string
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err != nil {
+			break
+		}
+		body, _ := io.ReadAll(part)
+		types = append(types, part.Header.Get("Content-Type"))
+		if !strings.Contains(string(body), "Storting passes") {
+			t.Errorf("Mail part %s = %s, want the top story", part.Header.Get("Content-Type"), body)
+		}
+	}
+	if len(types) != 2 || !strings.HasPrefix(types[0], "text/plain") || !strings.HasPrefix(types[1], "text/html") {
+		t.Errorf("Mail parts = %v, want text and HTML", types)
+	}
+}
+
+func TestDigestDirectory(t *testing.T) {
+	service := newDigestService(t)
+	now := time.Now()
+	items := digestItems(now)
+	root := t.TempDir()
+	base := filepath.Join(root, "digests")
+	service.SetDigestDir(base)
+
+	for _, dir := range []string{filepath.Join(root, "elsewhere"), "../elsewhere", "a/../../elsewhere"} {
+		if _, err := service.CreateDigest(models.Digest{Schedule: models.DigestDaily, Directory: dir}); !errors.Is(err, ErrInvalidDigest) {
+			t.Errorf("CreateDigest(%q) error = %v, want ErrInvalidDigest", dir, err)
+		}
+	}
+
+	// A digest saved before paths were checked is not written outside base
+	digest := models.Digest{ID: "old", Name: "Old", Schedule: models.DigestDaily, Directory: "../elsewhere"}
+	if _, err := service.sendDigest(digest, items, now); !errors.Is(err, ErrInvalidDigest) {
+		t.Errorf("sendDigest() outside the base error = %v, want ErrInvalidDigest", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "elsewhere")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing written outside the digest directory, got %v", err)
+	}
+
+	service.SetDigestDir("")
+	digest.Directory = "daily"
+	if _, err := service.sendDigest(digest, items, now); !errors.Is(err, ErrDigestDirNotConfigured) {
+		t.Errorf("sendDigest() without a digest directory error = %v, want ErrDigestDirNotConfigured", err)
+	}
+}
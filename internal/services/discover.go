@@ -0,0 +1,150 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/news-reader/internal/models"
+)
+
+var (
+	// ErrInvalidDiscoverURL is wrapped by errors describing a page URL
+	// feeds cannot be discovered for.
+	ErrInvalidDiscoverURL = errors.New("invalid page URL")
+	// ErrDiscoveryFailed is wrapped by errors fetching the page.
+	ErrDiscoveryFailed = errors.New("feed discovery failed")
+)
+
+const maxDiscoverBody = 1024 * 1024
+
+// feedMediaTypes maps the media types feeds are announced with to the source
+// type that reads them. Plain application/json is left out, as sites also
+// announce their REST APIs with it.
+var feedMediaTypes = map[string]models.ContentType{
+	"application/rss+xml":   models.TypeRSS,
+	"application/atom+xml":  models.TypeRSS,
+	"application/rdf+xml":   models.TypeRSS,
+	"application/feed+json": models.TypeJSONFeed,
+}
+
+// DiscoveredFeed is a feed a page announces, with the source type to add it
+// as.
+type DiscoveredFeed struct {
+	URL         string             `json:"url"`
+	Title       string             `json:"title,omitempty"`
+	MimeType    string             `json:"mimeType"`
+	ContentType models.ContentType `json:"contentType"`
+}
+
+// DiscoverFeeds returns the feeds the page at pageURL links to as alternate
+// versions of itself. If the URL already serves a feed, that feed is
+// returned.
+func (s *NewsService) DiscoverFeeds(pageURL string) ([]DiscoveredFeed, error) {
+	u, err := url.Parse(strings.TrimSpace(pageURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: %q must be an http or https URL", ErrInvalidDiscoverURL, pageURL)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDiscoverURL, err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; NewsReader/1.0)")
+	req.Header.Set("Accept", "text/html, application/xhtml+xml, application/rss+xml, application/atom+xml, application/feed+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscoveryFailed, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s answered %d", ErrDiscoveryFailed, u.Host, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDiscoverBody))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscoveryFailed, err)
+	}
+	final := resp.Request.URL
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if feed, ok := feedAt(final, mediaType, body); ok {
+		return []DiscoveredFeed{feed}, nil
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscoveryFailed, err)
+	}
+	feeds := []DiscoveredFeed{}
+	seen := make(map[string]bool)
+	doc.Find("link[href][type]").Each(func(_ int, link *goquery.Selection) {
+		rel, _ := link.Attr("rel")
+		if !containsString(strings.Fields(strings.ToLower(rel)), "alternate") {
+			return
+		}
+		linkType, _ := link.Attr("type")
+		linkType, _, _ = mime.ParseMediaType(linkType)
+		contentType, ok := feedMediaTypes[linkType]
+		if !ok {
+			return
+		}
+		href, _ := link.Attr("href")
+		target, err := final.Parse(strings.TrimSpace(href))
+		if err != nil || seen[target.String()] {
+			return
+		}
+		seen[target.String()] = true
+		title, _ := link.Attr("title")
+		feeds = append(feeds, DiscoveredFeed{
+			URL:         target.String(),
+			Title:       strings.TrimSpace(title),
+			MimeType:    linkType,
+			ContentType: contentType,
+		})
+	})
+	return feeds, nil
+}
+
+// feedAt recognises a response that is a feed rather than a page: one served
+// as a feed type, a JSON Feed served as JSON, or RSS or Atom served as XML.
+func feedAt(u *url.URL, mediaType string, body []byte) (DiscoveredFeed, bool) {
+	feed := DiscoveredFeed{URL: u.String(), MimeType: mediaType}
+	if contentType, ok := feedMediaTypes[mediaType]; ok {
+		feed.ContentType = contentType
+		return feed, true
+	}
+
+	switch mediaType {
+	case "application/json":
+		var doc struct {
+			Version string `json:"version"`
+			Title   string `json:"title"`
+		}
+		if json.Unmarshal(body, &doc) == nil && isJSONFeedVersion(doc.Version) {
+			feed.Title = doc.Title
+			feed.ContentType = models.TypeJSONFeed
+			return feed, true
+		}
+	case "application/xml", "text/xml":
+		head := body
+		if len(head) > 1024 {
+			head = head[:1024]
+		}
+		if bytes.Contains(head, []byte("<rss")) || bytes.Contains(head, []byte("<feed")) || bytes.Contains(head, []byte("<rdf:RDF")) {
+			feed.ContentType = models.TypeRSS
+			return feed, true
+		}
+	}
+	return feed, false
+}
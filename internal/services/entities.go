@@ -0,0 +1,750 @@
+package services
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/textanalysis"
+)
+
+// entityRules are the words that tell what a capitalized name is in one
+// language. All words are lowercase.
+type entityRules struct {
+	// titles come before a person's name: "President", "statsminister".
+	titles []string
+	// titleSuffixes end compound titles such as "finansministeren".
+	titleSuffixes []string
+	// orgWords start or end organization names: "Bank", "Party".
+	orgWords []string
+	// orgSuffixes end compound organization names such as "Arbeiderpartiet".
+	orgSuffixes []string
+	// connectors may join the words of a name: "of", "van", "de".
+	connectors []string
+	// speech verbs next to a name mark it as a person: "said", "sier".
+	speech []string
+}
+
+var norwegianEntityRules = entityRules{
+	titles: []string{"president", "presidenten", "statsminister", "statsministeren", "minister", "ministeren", "statsråd",
+		"statsråden", "ordfører", "ordføreren", "leder", "lederen", "partileder", "partilederen", "sjef", "sjefen",
+		"konsernsjef", "konsernsjefen", "direktør", "direktøren", "kong", "kongen", "dronning", "dronningen", "kronprins",
+		"kronprinsen", "professor", "forsker", "stortingsrepresentant", "generalsekretær", "sentralbanksjef"},
+	titleSuffixes: []string{"minister", "ministeren", "ministar", "ministaren", "sjef", "sjefen", "leiar", "leiaren"},
+	orgWords:      []string{"as", "asa", "bank", "banken", "kommune", "universitet", "universitetet", "parti", "partiet", "forbund", "forbundet"},
+	orgSuffixes:   []string{"departementet", "direktoratet", "partiet", "tilsynet", "banken", "forbundet", "rådet", "kommune", "selskapet", "universitetet", "sykehuset", "verket"},
+	connectors:    []string{"av", "for"},
+	speech:        []string{"sier", "sa", "seier", "skriver", "mener", "uttaler"},
+}
+
+var entityRulesByLanguage = map[string]entityRules{
+	"english": {
+		titles: []string{"president", "prime", "minister", "chancellor", "senator", "sen", "governor", "gov", "mayor", "mr",
+			"mrs", "ms", "dr", "sir", "dame", "lord", "king", "queen", "prince", "princess", "pope", "ceo", "chairman",
+			"chairwoman", "chief", "executive", "judge", "justice", "general", "gen", "secretary", "leader", "speaker",
+			"rep", "representative", "commissioner", "coach", "manager", "director", "professor", "prof", "spokesman",
+			"spokeswoman", "spokesperson", "founder", "striker", "midfielder", "goalkeeper", "defender"},
+		orgWords: []string{"inc", "corp", "corporation", "co", "ltd", "plc", "llc", "group", "bank", "party", "union",
+			"association", "council", "ministry", "university", "institute", "agency", "commission", "committee",
+			"company", "airlines", "airways", "holdings", "technologies", "systems", "foundation", "fund", "department",
+			"court", "parliament", "authority", "federation", "league", "club", "fc"},
+		connectors: []string{"of", "for", "de", "van", "von", "der", "da", "di", "du", "la", "le", "bin", "al"},
+		speech:     []string{"said", "says", "told", "added", "wrote", "warned", "argued", "claimed", "announced"},
+	},
+	"norwegian-bokmal":  norwegianEntityRules,
+	"norwegian-nynorsk": norwegianEntityRules,
+	"swedish": {
+		titles:        []string{"president", "presidenten", "statsminister", "statsministern", "minister", "ministern", "ordförande", "ordföranden", "kung", "kungen", "drottning", "vd", "chef", "chefen", "partiledare", "borgmästare"},
+		titleSuffixes: []string{"minister", "ministern", "chef", "chefen"},
+		orgWords:      []string{"ab", "bank", "banken", "kommun", "universitet", "partiet"},
+		orgSuffixes:   []string{"departementet", "partiet", "verket", "myndigheten", "banken", "förbundet", "inspektionen"},
+		connectors:    []string{"av", "för"},
+		speech:        []string{"säger", "sa", "skriver", "menar"},
+	},
+	"danish": {
+		titles:        []string{"præsident", "præsidenten", "statsminister", "statsministeren", "minister", "ministeren", "borgmester", "borgmesteren", "formand", "formanden", "konge", "kongen", "dronning", "dronningen", "direktør", "direktøren"},
+		titleSuffixes: []string{"minister", "ministeren", "chef", "chefen"},
+		orgWords:      []string{"a/s", "aps", "bank", "banken", "kommune", "universitet", "partiet"},
+		orgSuffixes:   []string{"ministeriet", "partiet", "styrelsen", "banken", "forbundet", "rådet", "tilsynet"},
+		connectors:    []string{"af", "for"},
+		speech:        []string{"siger", "sagde", "skriver", "mener"},
+	},
+	"german": {
+		titles:        []string{"präsident", "präsidentin", "bundespräsident", "kanzler", "kanzlerin", "bundeskanzler", "bundeskanzlerin", "minister", "ministerin", "ministerpräsident", "ministerpräsidentin", "bürgermeister", "bürgermeisterin", "chef", "chefin", "vorsitzende", "vorsitzender", "herr", "frau", "dr", "könig", "königin", "papst", "trainer"},
+		titleSuffixes: []string{"minister", "ministerin", "chef", "chefin"},
+		orgWords:      []string{"ag", "gmbh", "se", "kg", "bank", "partei", "verband", "universität", "institut", "ministerium", "gericht", "bundestag"},
+		orgSuffixes:   []string{"ministerium", "bank", "partei", "verband", "gericht", "amt", "werke"},
+		connectors:    []string{"von", "der", "de", "für"},
+		speech:        []string{"sagte", "sagt", "erklärte", "betonte", "schreibt"},
+	},
+	"french": {
+		titles:     []string{"président", "présidente", "ministre", "premier", "maire", "m", "mme", "dr", "roi", "reine", "pape", "directeur", "directrice", "pdg", "chef", "sénateur", "député", "entraîneur"},
+		orgWords:   []string{"sa", "sas", "banque", "parti", "université", "ministère", "conseil", "commission", "groupe", "fédération", "assemblée"},
+		connectors: []string{"de", "du", "des", "la", "le"},
+		speech:     []string{"a", "déclaré", "dit", "affirme", "estime", "explique"},
+	},
+	"spanish": {
+		titles:     []string{"presidente", "presidenta", "ministro", "ministra", "alcalde", "alcaldesa", "rey", "reina", "papa", "director", "directora", "consejero", "don", "doña", "sr", "sra", "entrenador", "senador"},
+		orgWords:   []string{"sa", "sl", "banco", "partido", "universidad", "ministerio", "consejo", "comisión", "grupo", "federación", "tribunal"},
+		connectors: []string{"de", "del", "la", "los"},
+		speech:     []string{"dijo", "afirmó", "declaró", "aseguró", "explicó"},
+	},
+	"italian": {
+		titles:     []string{"presidente", "ministro", "premier", "sindaco", "re", "regina", "papa", "direttore", "senatore", "allenatore", "onorevole"},
+		orgWords:   []string{"spa", "srl", "banca", "partito", "università", "ministero", "consiglio", "commissione", "gruppo", "federazione", "tribunale"},
+		connectors: []string{"di", "del", "della", "de"},
+		speech:     []string{"ha", "detto", "dichiarato", "spiegato", "afferma"},
+	},
+	"portuguese": {
+		titles:     []string{"presidente", "ministro", "ministra", "prefeito", "prefeita", "rei", "rainha", "papa", "diretor", "diretora", "senador", "governador", "treinador"},
+		orgWords:   []string{"sa", "ltda", "banco", "partido", "universidade", "ministério", "conselho", "comissão", "grupo", "federação", "tribunal"},
+		connectors: []string{"de", "da", "do", "dos", "das"},
+		speech:     []string{"disse", "afirmou", "declarou", "explicou"},
+	},
+	"dutch": {
+		titles:        []string{"president", "premier", "minister", "burgemeester", "koning", "koningin", "paus", "directeur", "voorzitter", "staatssecretaris", "trainer"},
+		titleSuffixes: []string{"minister"},
+		orgWords:      []string{"nv", "bv", "bank", "partij", "universiteit", "ministerie", "raad", "commissie", "groep", "bond", "rechtbank"},
+		orgSuffixes:   []string{"partij", "bank", "ministerie", "bond", "raad"},
+		connectors:    []string{"van", "de", "der", "den", "ter", "voor"},
+		speech:        []string{"zegt", "zei", "schrijft", "stelt"},
+	},
+	"finnish": {
+		titles:        []string{"presidentti", "pääministeri", "ministeri", "pormestari", "johtaja", "toimitusjohtaja", "puheenjohtaja", "kuningas", "paavi", "valmentaja"},
+		titleSuffixes: []string{"ministeri", "johtaja"},
+		orgWords:      []string{"oy", "oyj", "ab", "pankki", "puolue", "yliopisto", "ministeriö"},
+		orgSuffixes:   []string{"puolue", "pankki", "ministeriö", "virasto", "liitto", "yhtiö"},
+		speech:        []string{"sanoo", "sanoi", "kertoo", "kertoi", "toteaa"},
+	},
+}
+
+// entityWords is entityRules as sets, for one language.
+type entityWords struct {
+	titles        map[string]bool
+	titleSuffixes []string
+	orgWords      map[string]bool
+	orgSuffixes   []string
+	connectors    map[string]bool
+	speech        map[string]bool
+}
+
+func wordSet(lists ...[]string) map[string]bool {
+	set := make(map[string]bool)
+	for _, list := range lists {
+		for _, word := range list {
+			set[word] = true
+		}
+	}
+	return set
+}
+
+// entityWordsByLanguage holds every language's rules combined with the
+// English ones, since feeds in any language quote English names and titles.
+var entityWordsByLanguage = func() map[string]*entityWords {
+	english := entityRulesByLanguage["english"]
+	byLanguage := make(map[string]*entityWords, len(entityRulesByLanguage))
+	for language, rules := range entityRulesByLanguage {
+		byLanguage[language] = &entityWords{
+			titles:        wordSet(rules.titles, english.titles),
+			titleSuffixes: rules.titleSuffixes,
+			orgWords:      wordSet(rules.orgWords, english.orgWords),
+			orgSuffixes:   rules.orgSuffixes,
+			connectors:    wordSet(rules.connectors),
+			speech:        wordSet(rules.speech, english.speech),
+		}
+	}
+	return byLanguage
+}()
+
+func entityWordsFor(language string) *entityWords {
+	if words, ok := entityWordsByLanguage[language]; ok {
+		return words
+	}
+	return entityWordsByLanguage["english"]
+}
+
+func (w *entityWords) isTitle(lower string) bool {
+	if w.titles[lower] {
+		return true
+	}
+	return hasLongSuffix(lower, w.titleSuffixes)
+}
+
+func (w *entityWords) isOrg(lower string) bool {
+	return w.orgWords[lower] || hasLongSuffix(lower, w.orgSuffixes)
+}
+
+// hasLongSuffix reports whether word ends with one of suffixes and has at
+// least three letters before it, so "finansminister" has the suffix
+// "minister" and "minister" itself does not.
+func hasLongSuffix(word string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(word, suffix) && textanalysis.Length(word)-textanalysis.Length(suffix) >= 3 {
+			return true
+		}
+	}
+	return false
+}
+
+// knownEntity is an organization recognised by name wherever it appears.
+// Aliases match with the capitalisation given.
+type knownEntity struct {
+	ID      string
+	Name    string
+	Aliases []string
+}
+
+func org(id, name string, aliases ...string) knownEntity {
+	return knownEntity{ID: id, Name: name, Aliases: append([]string{name}, aliases...)}
+}
+
+var knownEntities = []knownEntity{
+	// Norwegian and Nordic companies and institutions
+	org("equinor", "Equinor", "Statoil"),
+	org("norsk-hydro", "Norsk Hydro", "Hydro"),
+	org("telenor", "Telenor"),
+	org("dnb", "DNB"),
+	org("yara", "Yara"),
+	org("orkla", "Orkla"),
+	org("aker-bp", "Aker BP"),
+	org("kongsberg", "Kongsberg Gruppen"),
+	org("norwegian-air", "Norwegian Air Shuttle", "Norwegian Air"),
+	org("sas", "SAS"),
+	org("norges-bank", "Norges Bank", "Sentralbanken"),
+	org("oljefondet", "Oljefondet", "Statens pensjonsfond utland"),
+	org("stortinget", "Stortinget", "Storting"),
+	org("nav", "NAV", "Nav"),
+	org("nrk", "NRK"),
+	org("volvo", "Volvo"),
+	org("ericsson", "Ericsson"),
+	org("nokia", "Nokia"),
+	org("ikea", "IKEA", "Ikea"),
+	org("h-m", "H&M"),
+	org("spotify", "Spotify"),
+	org("novo-nordisk", "Novo Nordisk"),
+	org("maersk", "Maersk", "Mærsk"),
+	// Political parties
+	org("arbeiderpartiet", "Arbeiderpartiet", "Ap"),
+	org("hoyre", "Høyre"),
+	org("fremskrittspartiet", "Fremskrittspartiet", "Frp", "FrP"),
+	org("senterpartiet", "Senterpartiet", "Sp"),
+	org("sv", "Sosialistisk Venstreparti", "SV"),
+	org("venstre", "Venstre"),
+	org("krf", "Kristelig Folkeparti", "KrF"),
+	org("rodt", "Rødt"),
+	org("mdg", "Miljøpartiet De Grønne", "MDG"),
+	org("democratic-party", "Democratic Party", "Democrats"),
+	org("republican-party", "Republican Party", "Republicans", "GOP"),
+	// International companies
+	org("apple", "Apple"),
+	org("google", "Google", "Alphabet"),
+	org("microsoft", "Microsoft"),
+	org("amazon", "Amazon"),
+	org("meta", "Meta", "Facebook"),
+	org("tesla", "Tesla"),
+	org("nvidia", "Nvidia", "NVIDIA"),
+	org("openai", "OpenAI"),
+	org("anthropic", "Anthropic"),
+	org("samsung", "Samsung"),
+	org("shell", "Shell"),
+	org("bp", "BP"),
+	org("exxonmobil", "ExxonMobil", "Exxon"),
+	org("boeing", "Boeing"),
+	org("general-motors", "General Motors", "GM"),
+	org("ford", "Ford Motor", "Ford"),
+	org("airbus", "Airbus"),
+	org("volkswagen", "Volkswagen", "VW"),
+	org("toyota", "Toyota"),
+	// International bodies
+	org("nato", "NATO", "Nato"),
+	org("un", "United Nations", "UN", "FN"),
+	org("who", "World Health Organization", "WHO", "Verdens helseorganisasjon"),
+	org("imf", "International Monetary Fund", "IMF", "Det internasjonale pengefondet"),
+	org("world-bank", "World Bank", "Verdensbanken"),
+	org("opec", "OPEC", "Opec"),
+	org("ecb", "European Central Bank", "ECB", "Den europeiske sentralbanken"),
+	org("federal-reserve", "Federal Reserve", "Fed"),
+	org("red-cross", "Red Cross", "Røde Kors"),
+	org("fifa", "FIFA", "Fifa"),
+	org("uefa", "UEFA", "Uefa"),
+	org("ioc", "IOC"),
+	org("hamas", "Hamas"),
+	org("hezbollah", "Hezbollah"),
+	org("kremlin", "Kremlin", "Kreml"),
+	org("white-house", "White House", "Det hvite hus"),
+	org("pentagon", "Pentagon"),
+}
+
+// knownEntityAliases maps every alias, as its words joined by spaces, to its
+// entity. knownEntityWords is the most words any alias has.
+var knownEntityAliases, knownEntityWords = func() (map[string]int, int) {
+	aliases := make(map[string]int)
+	longest := 0
+	for i, entity := range knownEntities {
+		for _, alias := range entity.Aliases {
+			words := textanalysis.Words(alias)
+			aliases[strings.Join(words, " ")] = i
+			if len(words) > longest {
+				longest = len(words)
+			}
+		}
+	}
+	return aliases, longest
+}()
+
+// tickerPattern finds cashtags such as "$EQNR" and exchange listings such as
+// "(OSE: EQNR)" or "(Nasdaq: AAPL)".
+var tickerPattern = regexp.MustCompile(`(?:^|[^\w$])\$([A-Z][A-Z0-9]{0,5}(?:\.[A-Z]{1,2})?)\b|\((?i:NYSE|Nasdaq|OSE|LSE|XETRA|TSX|Euronext|OMX|Oslo Børs)\s*:\s*([A-Z][A-Z0-9]{0,5}(?:\.[A-Z]{1,2})?)\)`)
+
+// firstNames are common given names, which make a capitalized pair a person
+// without any other clue.
+var firstNames = wordSet([]string{
+	"anders", "anne", "astrid", "bjørn", "erik", "erna", "espen", "eva", "geir", "hanne", "hans", "heidi", "helge",
+	"ida", "ine", "ingrid", "jan", "jens", "jon", "jonas", "karin", "kari", "kjell", "knut", "lars", "liv",
+	"magnus", "marit", "martin", "mette", "morten", "nina", "ola", "ole", "per", "petter", "ragnhild", "rune",
+	"sigrid", "silje", "siv", "solveig", "stein", "svein", "sylvi", "tone", "tor", "tore", "trond", "trygve",
+	"aleksander", "alexander", "andrew", "angela", "barack", "benjamin", "bernie", "bill", "boris", "charles",
+	"christine", "david", "donald", "elon", "emmanuel", "friedrich", "george", "giorgia", "hillary", "james", "jeff",
+	"jerome", "joe", "john", "justin", "kamala", "keir", "kim", "mark", "mary", "michael", "nancy", "narendra",
+	"olaf", "pedro", "recep", "rishi", "robert", "sam", "sarah", "sundar", "tim", "ursula", "viktor", "vladimir",
+	"volodymyr", "xi", "william", "elizabeth", "marine", "ulf", "magdalena", "sanna", "petteri",
+})
+
+// isCapitalized reports whether word starts with an upper case letter.
+func isCapitalized(word string) bool {
+	r, _ := utf8.DecodeRuneInString(word)
+	return unicode.IsUpper(r)
+}
+
+func isAllCaps(word string) bool {
+	return strings.ToUpper(word) == word && strings.ToLower(word) != word
+}
+
+// entitySlug normalises a name for use in an entity ID.
+func entitySlug(name string) string {
+	return strings.Join(textanalysis.Words(strings.ToLower(name)), "-")
+}
+
+// entityCounter collects an item's entities in the order they were found.
+type entityCounter struct {
+	byID  map[string]*models.Entity
+	order []string
+}
+
+func (c *entityCounter) add(entityType, id, name string, count int) {
+	if c.byID == nil {
+		c.byID = make(map[string]*models.Entity)
+	}
+	key := entityType + ":" + id
+	if e, ok := c.byID[key]; ok {
+		e.Count += count
+		return
+	}
+	c.byID[key] = &models.Entity{ID: key, Name: name, Type: entityType, Count: count}
+	c.order = append(c.order, key)
+}
+
+// entities returns the collected entities, most mentioned first.
+func (c *entityCounter) entities() []models.Entity {
+	entities := make([]models.Entity, 0, len(c.order))
+	for _, key := range c.order {
+		entities = append(entities, *c.byID[key])
+	}
+	sort.SliceStable(entities, func(i, j int) bool {
+		return entities[i].Count > entities[j].Count
+	})
+	return entities
+}
+
+// nameSegment is a run of capitalized words that may name an entity. start
+// and end index the text's tokens.
+type nameSegment struct {
+	start, end int
+	// titled is set when a title such as "President" came right before.
+	titled bool
+}
+
+// entityText is an item's text prepared for entity extraction.
+type entityText struct {
+	raw    string
+	tokens []textanalysis.Token
+	words  *entityWords
+	stop   *textanalysis.Analyzer
+	// titleEnd is where the title ends in raw, and titleCase is set for
+	// headlines that capitalise every word and so reveal nothing.
+	titleEnd  int
+	titleCase bool
+	inPlace   []bool
+}
+
+func (t *entityText) name(seg nameSegment) string {
+	return t.raw[t.tokens[seg.start].Start:t.tokens[seg.end-1].End]
+}
+
+func (t *entityText) inTitle(i int) bool {
+	return t.tokens[i].End <= t.titleEnd
+}
+
+// joined reports whether tokens i and i+1 belong to the same name: only a
+// space, hyphen, apostrophe or ampersand may separate them, and names do
+// not run from the title into the description.
+func (t *entityText) joined(i int) bool {
+	if t.inTitle(i) != t.inTitle(i+1) {
+		return false
+	}
+	gap := t.raw[t.tokens[i].End:t.tokens[i+1].Start]
+	switch strings.TrimSpace(gap) {
+	case "":
+		return gap != ""
+	case "-", "'", "’", "&":
+		return true
+	}
+	return false
+}
+
+// isTitleCaseHeadline reports whether most longer words of the title are
+// capitalized, as in "Ferry Strike Ends After Talks".
+func isTitleCaseHeadline(tokens []textanalysis.Token, titleEnd int) bool {
+	words, capitalized := 0, 0
+	for _, token := range tokens {
+		if token.End > titleEnd {
+			break
+		}
+		if textanalysis.Length(token.Text) <= 3 {
+			continue
+		}
+		words++
+		if isCapitalized(token.Text) {
+			capitalized++
+		}
+	}
+	return words >= 3 && capitalized*4 >= words*3
+}
+
+// extractEntities finds the people, organizations, places and tickers an
+// item names. Places come from the gazetteer scan. Other names are runs of
+// capitalized words, classified by the organizations the reader knows, by a
+// title before them ("Prime Minister Jonas Gahr Støre"), by organization
+// words ("Bank", "-partiet"), and by given names and verbs of speech for
+// people. A lone surname counts towards the full name mentioned in the same
+// item.
+func (s *NewsService) extractEntities(item *models.NewsItem, scanned scannedText) []models.Entity {
+	var found entityCounter
+
+	for _, place := range scanned.places {
+		found.add(models.EntityPlace, place.ID, place.Name, place.Count)
+	}
+
+	raw := itemText(item)
+	// Every ticker has a "$" or a ":", and most texts have neither
+	if strings.ContainsAny(raw, "$:") {
+		for _, match := range tickerPattern.FindAllStringSubmatch(raw, -1) {
+			symbol := match[1] + match[2]
+			found.add(models.EntityTicker, strings.ToLower(symbol), "$"+symbol, 1)
+		}
+	}
+
+	tokens := textanalysis.Tokenize(raw)
+	text := &entityText{
+		raw:       raw,
+		tokens:    tokens,
+		words:     entityWordsFor(item.Language),
+		stop:      textanalysis.For(item.Language),
+		titleEnd:  len(item.Title),
+		titleCase: isTitleCaseHeadline(tokens, len(item.Title)),
+		inPlace:   make([]bool, len(tokens)),
+	}
+	for _, span := range scanned.placeSpans {
+		for i := span[0]; i < span[1] && i < len(tokens); i++ {
+			text.inPlace[i] = true
+		}
+	}
+
+	var surnames []nameSegment
+	for _, seg := range text.segments(&found) {
+		switch text.classify(seg) {
+		case models.EntityPerson:
+			found.add(models.EntityPerson, entitySlug(text.name(seg)), text.name(seg), 1)
+		case models.EntityOrg:
+			found.add(models.EntityOrg, entitySlug(text.name(seg)), text.name(seg), 1)
+		case "surname":
+			surnames = append(surnames, seg)
+		}
+	}
+
+	// Lone surnames go to the one person in the item with that last name,
+	// or stand on their own when a title marked them as a person
+	for _, seg := range surnames {
+		surname := text.name(seg)
+		var match *models.Entity
+		for _, key := range found.order {
+			e := found.byID[key]
+			if e.Type == models.EntityPerson && e.Name != surname && strings.HasSuffix(e.Name, " "+surname) {
+				if match != nil {
+					match = nil
+					break
+				}
+				match = e
+			}
+		}
+		switch {
+		case match != nil:
+			match.Count++
+		case seg.titled:
+			found.add(models.EntityPerson, entitySlug(surname), surname, 1)
+		}
+	}
+
+	return found.entities()
+}
+
+// segments splits the text's capitalized runs at titles, stop words and
+// known organizations. Known organizations are added to found directly.
+func (t *entityText) segments(found *entityCounter) []nameSegment {
+	var segments []nameSegment
+	titled := false
+	for i := 0; i < len(t.tokens); {
+		token := t.tokens[i]
+		if !isCapitalized(token.Text) {
+			titled = t.words.isTitle(token.Lower)
+			i++
+			continue
+		}
+
+		// Known organizations win over everything else, places included:
+		// "Norges Bank" is not a mention of Norway
+		if n, entity := t.knownAt(i); n > 0 {
+			found.add(models.EntityOrg, knownEntities[entity].ID, knownEntities[entity].Name, 1)
+			titled = false
+			i += n
+			continue
+		}
+		if t.inPlace[i] {
+			titled = false
+			i++
+			continue
+		}
+		if t.words.isTitle(token.Lower) {
+			titled = true
+			i++
+			continue
+		}
+		if t.stop.IsStopWord(token.Lower) || t.words.connectors[token.Lower] {
+			titled = false
+			i++
+			continue
+		}
+
+		// Extend over capitalized words and connectors followed by one
+		end := i + 1
+		for end < len(t.tokens) && t.joined(end-1) && !t.inPlace[end] {
+			next := t.tokens[end]
+			if isCapitalized(next.Text) && !t.words.isTitle(next.Lower) && !t.stop.IsStopWord(next.Lower) {
+				if n, _ := t.knownAt(end); n > 0 {
+					break
+				}
+				end++
+				continue
+			}
+			if t.words.connectors[next.Lower] && end+1 < len(t.tokens) && t.joined(end) && isCapitalized(t.tokens[end+1].Text) {
+				end += 2
+				continue
+			}
+			break
+		}
+		// A trailing connector belongs to the next name, not this one
+		for end-1 > i && t.words.connectors[t.tokens[end-1].Lower] {
+			end--
+		}
+
+		segments = append(segments, nameSegment{start: i, end: end, titled: titled})
+		titled = false
+		i = end
+	}
+	return segments
+}
+
+// knownAt returns the number of words of the longest known organization alias
+// starting at token i, and the organization.
+func (t *entityText) knownAt(i int) (int, int) {
+	for n := knownEntityWords; n > 0; n-- {
+		if i+n > len(t.tokens) {
+			continue
+		}
+		words := make([]string, n)
+		for j := range words {
+			words[j] = t.tokens[i+j].Text
+			if j > 0 && !t.joined(i+j-1) {
+				words = nil
+				break
+			}
+		}
+		if words == nil {
+			continue
+		}
+		if entity, ok := knownEntityAliases[strings.Join(words, " ")]; ok {
+			return n, entity
+		}
+	}
+	return 0, 0
+}
+
+// classify decides what a segment names: a person, an organization, a lone
+// "surname" to resolve later, or nothing ("").
+func (t *entityText) classify(seg nameSegment) string {
+	first, last := t.tokens[seg.start], t.tokens[seg.end-1]
+	length := seg.end - seg.start
+
+	if t.words.isOrg(last.Lower) || (length > 1 && t.words.isOrg(first.Lower)) {
+		if length > 1 || hasLongSuffix(last.Lower, t.words.orgSuffixes) {
+			return models.EntityOrg
+		}
+	}
+
+	if length == 1 {
+		if seg.titled {
+			return "surname"
+		}
+		// Single words only ever count towards a full name, so a word that
+		// is capitalized for starting a sentence does no harm
+		if isAllCaps(first.Text) {
+			return ""
+		}
+		return "surname"
+	}
+
+	if length > 4 || (t.titleCase && t.inTitle(seg.start) && !seg.titled) {
+		return ""
+	}
+	for i := seg.start; i < seg.end; i++ {
+		if textanalysis.HasDigit(t.tokens[i].Text) || (isAllCaps(t.tokens[i].Text) && textanalysis.Length(t.tokens[i].Text) > 1) {
+			return ""
+		}
+	}
+	if seg.titled || firstNames[first.Lower] || t.speechNear(seg) {
+		return models.EntityPerson
+	}
+	return ""
+}
+
+// speechNear reports whether a verb of speech comes right before or after
+// the segment, as in "said John Smith" or "Ola Nordmann sier".
+func (t *entityText) speechNear(seg nameSegment) bool {
+	if seg.start > 0 && t.words.speech[t.tokens[seg.start-1].Lower] {
+		return true
+	}
+	if seg.end < len(t.tokens) && t.words.speech[t.tokens[seg.end].Lower] {
+		return true
+	}
+	// Allow one word in between, as in "Smith, who said"
+	return seg.end+1 < len(t.tokens) && t.words.speech[t.tokens[seg.end+1].Lower] && !isCapitalized(t.tokens[seg.end].Text)
+}
+
+// EntitySummary is an entity with the items that mention it.
+type EntitySummary struct {
+	models.Entity
+	// Count is the number of items naming the entity and Mentions the
+	// number of times they do.
+	Mentions int                    `json:"mentions"`
+	Sources  []string               `json:"sources"`
+	Items    []models.ClusterMember `json:"items"`
+}
+
+// EntitySummaries gathers the entities named in items, optionally only of one
+// type, ordered by the number of items naming them. A person named only by
+// surname in some items is merged into the one person with the full name.
+func (s *NewsService) EntitySummaries(items []models.NewsItem, entityType string) []EntitySummary {
+	byID := make(map[string]*EntitySummary)
+	sources := make(map[string]map[string]bool)
+	for _, item := range s.SortByRecency(items) {
+		for _, entity := range item.Entities {
+			if entityType != "" && entity.Type != entityType {
+				continue
+			}
+			summary := byID[entity.ID]
+			if summary == nil {
+				summary = &EntitySummary{Entity: models.Entity{ID: entity.ID, Name: entity.Name, Type: entity.Type}}
+				byID[entity.ID] = summary
+				sources[entity.ID] = make(map[string]bool)
+			}
+			summary.Count++
+			summary.Mentions += entity.Count
+			sources[entity.ID][item.Source] = true
+			summary.Items = append(summary.Items, models.ClusterMember{
+				ID:        item.ID,
+				Title:     item.Title,
+				Link:      item.Link,
+				Source:    item.Source,
+				Published: item.Published,
+			})
+		}
+	}
+
+	mergeSurnames(byID, sources)
+
+	summaries := make([]EntitySummary, 0, len(byID))
+	for id, summary := range byID {
+		for source := range sources[id] {
+			summary.Sources = append(summary.Sources, source)
+		}
+		sort.Strings(summary.Sources)
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Count != summaries[j].Count {
+			return summaries[i].Count > summaries[j].Count
+		}
+		if summaries[i].Mentions != summaries[j].Mentions {
+			return summaries[i].Mentions > summaries[j].Mentions
+		}
+		return summaries[i].ID < summaries[j].ID
+	})
+	return summaries
+}
+
+// mergeSurnames folds single-word people into the one multi-word person whose
+// name ends with that word.
+func mergeSurnames(byID map[string]*EntitySummary, sources map[string]map[string]bool) {
+	for id, single := range byID {
+		if single.Type != models.EntityPerson || strings.Contains(single.Name, " ") {
+			continue
+		}
+		var target string
+		for otherID, other := range byID {
+			if other.Type != models.EntityPerson || !strings.HasSuffix(other.Name, " "+single.Name) {
+				continue
+			}
+			if target != "" {
+				target = ""
+				break
+			}
+			target = otherID
+		}
+		if target == "" {
+			continue
+		}
+
+		full := byID[target]
+		seen := make(map[string]bool)
+		for _, item := range full.Items {
+			seen[item.ID] = true
+		}
+		for _, item := range single.Items {
+			if !seen[item.ID] {
+				full.Items = append(full.Items, item)
+				full.Count++
+			}
+		}
+		sort.SliceStable(full.Items, func(i, j int) bool {
+			return full.Items[i].Published.After(full.Items[j].Published)
+		})
+		full.Mentions += single.Mentions
+		for source := range sources[id] {
+			sources[target][source] = true
+		}
+		delete(byID, id)
+	}
+}
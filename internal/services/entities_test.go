@@ -0,0 +1,155 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+func TestExtractEntities(t *testing.T) {
+	service := &NewsService{preferences: models.NewDefaultPreferences()}
+
+	tests := []struct {
+		name     string
+		item     models.NewsItem
+		expected map[string]int
+	}{
+		{
+			name: "Titles, known organizations and tickers",
+			item: models.NewsItem{
+				Title:       "Prime Minister Jonas Gahr Støre meets Equinor CEO Anders Opedal",
+				Description: "Støre said the talks went well. Shares in $EQNR rose.",
+				Language:    "english",
+			},
+			expected: map[string]int{
+				"person:jonas-gahr-støre": 2,
+				"person:anders-opedal":    1,
+				"org:equinor":             1,
+				"ticker:eqnr":             1,
+			},
+		},
+		{
+			name: "Norwegian titles and organization suffixes",
+			item: models.NewsItem{
+				Title:       "Finansminister Trygve Slagsvold Vedum vil kutte skatten",
+				Description: "– Vi må gjøre noe, sier Vedum. Helsedirektoratet og Arbeiderpartiet er uenige.",
+				Language:    "norwegian-bokmal",
+			},
+			expected: map[string]int{
+				"person:trygve-slagsvold-vedum": 2,
+				"org:helsedirektoratet":         1,
+				"org:arbeiderpartiet":           1,
+			},
+		},
+		{
+			name: "Places, organization words and exchange listings",
+			item: models.NewsItem{
+				Title:       "Apple (Nasdaq: AAPL) opens office in Oslo",
+				Description: "The University of Bergen and Norges Bank welcomed the move.",
+				Language:    "english",
+			},
+			expected: map[string]int{
+				"org:apple":                1,
+				"ticker:aapl":              1,
+				"place:oslo":               1,
+				"place:bergen":             1,
+				"place:no":                 3,
+				"org:university-of-bergen": 1,
+				"org:norges-bank":          1,
+			},
+		},
+		{
+			name: "Speech verbs and given names",
+			item: models.NewsItem{
+				Title:       "Storm closes schools",
+				Description: "Schools will reopen on Monday, said Ingrid Bakken. Principal Tom Hagen agreed.",
+				Language:    "english",
+			},
+			expected: map[string]int{
+				"person:ingrid-bakken": 1,
+			},
+		},
+		{
+			name: "Title case headlines and sentence starts",
+			item: models.NewsItem{
+				Title:       "Ferry Strike Ends After Marathon Talks",
+				Description: "Breaking News from the coast. Local Officials expect delays.",
+				Language:    "english",
+			},
+			expected: map[string]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := tt.item
+			service.autoTagNews(&item, service.tagPreferences())
+			got := make(map[string]int)
+			for _, entity := range item.Entities {
+				got[entity.ID] = entity.Count
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("extractEntities() = %v, want %v", item.Entities, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEntitySummaries(t *testing.T) {
+	service := &NewsService{}
+	now := time.Now()
+	items := []models.NewsItem{
+		{ID: "1", Title: "One", Source: "NRK", Published: now.Add(-3 * time.Hour), Entities: []models.Entity{
+			{ID: "person:jonas-gahr-støre", Name: "Jonas Gahr Støre", Type: models.EntityPerson, Count: 2},
+			{ID: "org:equinor", Name: "Equinor", Type: models.EntityOrg, Count: 1},
+		}},
+		{ID: "2", Title: "Two", Source: "BBC", Published: now.Add(-2 * time.Hour), Entities: []models.Entity{
+			{ID: "person:støre", Name: "Støre", Type: models.EntityPerson, Count: 1},
+		}},
+		{ID: "3", Title: "Three", Source: "NRK", Published: now.Add(-time.Hour), Entities: []models.Entity{
+			{ID: "org:equinor", Name: "Equinor", Type: models.EntityOrg, Count: 3},
+		}},
+	}
+
+	summaries := service.EntitySummaries(items, "")
+	if len(summaries) != 2 {
+		t.Fatalf("EntitySummaries() = %+v, want Støre merged into one person", summaries)
+	}
+	equinor, støre := summaries[0], summaries[1]
+	if equinor.ID != "org:equinor" || equinor.Count != 2 || equinor.Mentions != 4 || equinor.Items[0].ID != "3" {
+		t.Errorf("EntitySummaries() first = %+v, want Equinor in 2 items with 4 mentions", equinor)
+	}
+	if støre.ID != "person:jonas-gahr-støre" || støre.Count != 2 || støre.Mentions != 3 {
+		t.Errorf("EntitySummaries() second = %+v, want Jonas Gahr Støre in 2 items with 3 mentions", støre)
+	}
+	if !reflect.DeepEqual(støre.Sources, []string{"BBC", "NRK"}) || støre.Items[0].ID != "2" {
+		t.Errorf("EntitySummaries() sources = %v, items = %+v, want both sources, newest first", støre.Sources, støre.Items)
+	}
+
+	if orgs := service.EntitySummaries(items, models.EntityOrg); len(orgs) != 1 {
+		t.Errorf("EntitySummaries(org) = %+v, want only Equinor", orgs)
+	}
+}
+
+func TestTrendingEntities(t *testing.T) {
+	service := &NewsService{preferences: models.NewDefaultPreferences()}
+	now := time.Now()
+
+	var items []models.NewsItem
+	for _, text := range []string{
+		"Prime Minister Jonas Gahr Støre visits Brussels",
+		"Prime minister Jonas Gahr Støre under pressure",
+		"Prime minister Jonas Gahr Støre defends budget",
+	} {
+		item := models.NewsItem{Title: text, Language: "english", Published: now.Add(-time.Hour)}
+		service.autoTagNews(&item, service.tagPreferences())
+		items = append(items, item)
+	}
+
+	topics := service.GetTrendingTopics(items, TrendingOptions{Now: now})
+	if len(topics) != 1 || topics[0].Topic != "Jonas Gahr Støre" || topics[0].Entity != "person:jonas-gahr-støre" {
+		t.Errorf("GetTrendingTopics() = %+v, want only the person", topics)
+	}
+}
@@ -0,0 +1,424 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+// Output feed formats.
+const (
+	FeedRSS  = "rss"
+	FeedAtom = "atom"
+	FeedJSON = "json"
+)
+
+// FeedContentTypes maps the output feed formats to their media types.
+var FeedContentTypes = map[string]string{
+	FeedRSS:  "application/rss+xml; charset=utf-8",
+	FeedAtom: "application/atom+xml; charset=utf-8",
+	FeedJSON: "application/feed+json; charset=utf-8",
+}
+
+const feedGenerator = "News Reader"
+
+// OutputFeed describes a feed the reader publishes.
+type OutputFeed struct {
+	Title       string
+	Description string
+	// SelfURL is where the feed itself is served and HomeURL the site it
+	// belongs to.
+	SelfURL string
+	HomeURL string
+}
+
+// RenderFeed renders items as RSS 2.0, Atom 1.0 or JSON Feed 1.1. Podcast
+// and video items carry their media as enclosures, and thumbnails as Media
+// RSS thumbnails or JSON Feed images.
+func RenderFeed(feed OutputFeed, items []models.NewsItem, format string) ([]byte, error) {
+	// Feeds are stamped with their newest item, so they only change when
+	// the items do
+	var updated time.Time
+	for _, item := range items {
+		if item.Published.After(updated) {
+			updated = item.Published
+		}
+	}
+
+	switch format {
+	case FeedRSS:
+		return renderRSS(feed, items, updated)
+	case FeedAtom:
+		return renderAtom(feed, items, updated)
+	case FeedJSON:
+		return renderJSONFeed(feed, items)
+	default:
+		return nil, fmt.Errorf("unknown feed format: %s", format)
+	}
+}
+
+// NewestItems returns at most limit items, newest first.
+func NewestItems(items []models.NewsItem, limit int) []models.NewsItem {
+	sorted := append([]models.NewsItem{}, items...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Published.After(sorted[j].Published)
+	})
+	if limit > 0 && len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+	return sorted
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	ITunes  string     `xml:"xmlns:itunes,attr"`
+	Media   string     `xml:"xmlns:media,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Generator     string    `xml:"generator"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string          `xml:"title"`
+	Link        string          `xml:"link"`
+	Description string          `xml:"description,omitempty"`
+	GUID        rssGUID         `xml:"guid"`
+	PubDate     string          `xml:"pubDate"`
+	Author      string          `xml:"itunes:author,omitempty"`
+	Categories  []string        `xml:"category"`
+	Enclosure   *rssEnclosure   `xml:"enclosure"`
+	Duration    string          `xml:"itunes:duration,omitempty"`
+	Thumbnail   *mediaThumbnail `xml:"media:thumbnail"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type mediaThumbnail struct {
+	URL string `xml:"url,attr"`
+}
+
+func renderRSS(feed OutputFeed, items []models.NewsItem, updated time.Time) ([]byte, error) {
+	doc := rssFeed{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		ITunes:  "http://www.itunes.com/dtds/podcast-1.0.dtd",
+		Media:   "http://search.yahoo.com/mrss/",
+		Channel: rssChannel{
+			Title:       feed.Title,
+			Link:        feed.HomeURL,
+			Description: feed.Description,
+			Self:        atomLink{Rel: "self", Href: feed.SelfURL, Type: FeedContentTypes[FeedRSS]},
+			Generator:   feedGenerator,
+			Items:       []rssItem{},
+		},
+	}
+	if !updated.IsZero() {
+		doc.Channel.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, item := range items {
+		entry := rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Description,
+			GUID:        rssGUID{Value: item.ID},
+			PubDate:     item.Published.UTC().Format(time.RFC1123Z),
+			Author:      itemAuthor(item),
+			Categories:  feedCategories(item),
+		}
+		if media := itemMedia(item); media.url != "" {
+			// RSS requires a length; 0 is the convention when it is unknown
+			entry.Enclosure = &rssEnclosure{URL: media.url, Type: media.mimeType}
+			if media.seconds > 0 {
+				entry.Duration = strconv.Itoa(media.seconds)
+			}
+		}
+		if item.Thumbnail != "" {
+			entry.Thumbnail = &mediaThumbnail{URL: item.Thumbnail}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, entry)
+	}
+	return marshalXMLFeed(doc)
+}
+
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"feed"`
+	NS        string      `xml:"xmlns,attr"`
+	Media     string      `xml:"xmlns:media,attr"`
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Subtitle  string      `xml:"subtitle,omitempty"`
+	Updated   string      `xml:"updated"`
+	Links     []atomLink  `xml:"link"`
+	Author    atomPerson  `xml:"author"`
+	Generator string      `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel    string `xml:"rel,attr,omitempty"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID         string          `xml:"id"`
+	Title      string          `xml:"title"`
+	Links      []atomLink      `xml:"link"`
+	Published  string          `xml:"published"`
+	Updated    string          `xml:"updated"`
+	Author     atomPerson      `xml:"author"`
+	Summary    string          `xml:"summary,omitempty"`
+	Categories []atomCategory  `xml:"category"`
+	Thumbnail  *mediaThumbnail `xml:"media:thumbnail"`
+}
+
+func renderAtom(feed OutputFeed, items []models.NewsItem, updated time.Time) ([]byte, error) {
+	doc := atomFeed{
+		NS:       "http://www.w3.org/2005/Atom",
+		Media:    "http://search.yahoo.com/mrss/",
+		ID:       feed.SelfURL,
+		Title:    feed.Title,
+		Subtitle: feed.Description,
+		Updated:  updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Href: feed.SelfURL, Type: FeedContentTypes[FeedAtom]},
+			{Rel: "alternate", Href: feed.HomeURL, Type: "text/html"},
+		},
+		Author:    atomPerson{Name: feedGenerator},
+		Generator: feedGenerator,
+	}
+
+	for _, item := range items {
+		published := item.Published.UTC().Format(time.RFC3339)
+		entry := atomEntry{
+			ID:        item.Link,
+			Title:     item.Title,
+			Links:     []atomLink{{Rel: "alternate", Href: item.Link}},
+			Published: published,
+			Updated:   published,
+			Author:    atomPerson{Name: itemAuthor(item)},
+			Summary:   item.Description,
+		}
+		if entry.ID == "" {
+			entry.ID = feed.SelfURL + "#" + item.ID
+		}
+		for _, category := range feedCategories(item) {
+			entry.Categories = append(entry.Categories, atomCategory{Term: category})
+		}
+		if media := itemMedia(item); media.url != "" {
+			entry.Links = append(entry.Links, atomLink{Rel: "enclosure", Href: media.url, Type: media.mimeType})
+		}
+		if item.Thumbnail != "" {
+			entry.Thumbnail = &mediaThumbnail{URL: item.Thumbnail}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return marshalXMLFeed(doc)
+}
+
+func marshalXMLFeed(doc interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url,omitempty"`
+	FeedURL     string         `json:"feed_url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url,omitempty"`
+	Title         string               `json:"title"`
+	ContentText   string               `json:"content_text"`
+	Image         string               `json:"image,omitempty"`
+	DatePublished string               `json:"date_published"`
+	Authors       []jsonFeedAuthor     `json:"authors,omitempty"`
+	Tags          []string             `json:"tags,omitempty"`
+	Attachments   []jsonFeedAttachment `json:"attachments,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedAttachment struct {
+	URL               string `json:"url"`
+	MimeType          string `json:"mime_type"`
+	DurationInSeconds int    `json:"duration_in_seconds,omitempty"`
+}
+
+func renderJSONFeed(feed OutputFeed, items []models.NewsItem) ([]byte, error) {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       feed.Title,
+		HomePageURL: feed.HomeURL,
+		FeedURL:     feed.SelfURL,
+		Description: feed.Description,
+		Items:       []jsonFeedItem{},
+	}
+	for _, item := range items {
+		entry := jsonFeedItem{
+			ID:            item.ID,
+			URL:           item.Link,
+			Title:         item.Title,
+			ContentText:   item.Description,
+			Image:         item.Thumbnail,
+			DatePublished: item.Published.UTC().Format(time.RFC3339),
+			Tags:          feedCategories(item),
+		}
+		if author := itemAuthor(item); author != "" {
+			entry.Authors = []jsonFeedAuthor{{Name: author}}
+		}
+		if media := itemMedia(item); media.url != "" {
+			entry.Attachments = []jsonFeedAttachment{{URL: media.url, MimeType: media.mimeType, DurationInSeconds: media.seconds}}
+		}
+		doc.Items = append(doc.Items, entry)
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// itemAuthor returns the item's byline, or its source when it has none.
+func itemAuthor(item models.NewsItem) string {
+	if item.Author != "" {
+		return item.Author
+	}
+	return item.Source
+}
+
+// feedCategories returns an item's categories, tag names and keywords, once
+// each.
+func feedCategories(item models.NewsItem) []string {
+	var categories []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if name != "" && !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			categories = append(categories, name)
+		}
+	}
+	for _, category := range item.AllCategories() {
+		add(category)
+	}
+	for _, tag := range item.Tags {
+		add(tag.Name)
+	}
+	for _, keyword := range item.Keywords {
+		add(keyword)
+	}
+	return categories
+}
+
+// feedMedia is an item's audio or video.
+type feedMedia struct {
+	url      string
+	mimeType string
+	seconds  int
+}
+
+// itemMedia returns the audio of podcast items, or the video of video items.
+func itemMedia(item models.NewsItem) feedMedia {
+	media := feedMedia{seconds: parseDuration(item.Duration)}
+	switch {
+	case item.AudioURL != "":
+		media.url, media.mimeType = item.AudioURL, mediaType(item.AudioURL, "audio/mpeg")
+	case item.VideoURL != "":
+		media.url, media.mimeType = item.VideoURL, mediaType(item.VideoURL, "video/mp4")
+	}
+	return media
+}
+
+// mediaTypes maps the extensions of podcast and video files to their types.
+// The system MIME tables are not used, as few of them list audio.
+var mediaTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/opus",
+	".wav":  "audio/wav",
+	".mp4":  "video/mp4",
+	".m4v":  "video/x-m4v",
+	".webm": "video/webm",
+	".mov":  "video/quicktime",
+}
+
+// mediaType guesses a media URL's type from its extension.
+func mediaType(rawURL, fallback string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fallback
+	}
+	if t, ok := mediaTypes[strings.ToLower(path.Ext(u.Path))]; ok {
+		return t
+	}
+	return fallback
+}
+
+// parseDuration reads an iTunes duration, given as seconds or as H:MM:SS or
+// MM:SS, in seconds. It returns 0 if the duration cannot be read.
+func parseDuration(duration string) int {
+	parts := strings.Split(strings.TrimSpace(duration), ":")
+	if len(parts) > 3 {
+		return 0
+	}
+	seconds := 0
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds
+}
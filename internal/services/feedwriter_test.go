@@ -0,0 +1,187 @@
+package services
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+var feedTestItems = []models.NewsItem{
+	{
+		ID:          "episode-1",
+		Title:       "Episode 1 & more",
+		Link:        "https://example.com/podcast/1",
+		Description: "The first episode.",
+		Source:      "Podcast",
+		Category:    "Technology",
+		Published:   time.Date(2024, 3, 6, 8, 0, 0, 0, time.UTC),
+		ContentType: models.TypePodcast,
+		AudioURL:    "https://example.com/media/1.m4a?token=x",
+		Duration:    "1:02:03",
+		Thumbnail:   "https://example.com/media/1.jpg",
+		Tags:        []models.Tag{{ID: "technology", Name: "Technology"}, {ID: "english", Name: "English"}},
+	},
+	{
+		ID:        "article-1",
+		Title:     "An article",
+		Link:      "https://example.com/news/1",
+		Source:    "News",
+		Category:  "General",
+		Published: time.Date(2024, 3, 6, 9, 0, 0, 0, time.UTC),
+	},
+}
+
+var testOutputFeed = OutputFeed{
+	Title:   "Test",
+	SelfURL: "https://reader.example.com/feeds/tag/technology.rss",
+	HomeURL: "https://reader.example.com/",
+}
+
+func TestRenderRSS(t *testing.T) {
+	body, err := RenderFeed(testOutputFeed, feedTestItems, FeedRSS)
+	if err != nil {
+		t.Fatalf("Failed to render feed: %v", err)
+	}
+
+	var doc struct {
+		Channel struct {
+			LastBuildDate string `xml:"lastBuildDate"`
+			Items         []struct {
+				Title      string   `xml:"title"`
+				GUID       string   `xml:"guid"`
+				Categories []string `xml:"category"`
+				Enclosure  *struct {
+					URL  string `xml:"url,attr"`
+					Type string `xml:"type,attr"`
+				} `xml:"enclosure"`
+				Duration  string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+				Thumbnail struct {
+					URL string `xml:"url,attr"`
+				} `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("Failed to parse feed: %v\n%s", err, body)
+	}
+	if doc.Channel.LastBuildDate != "Wed, 06 Mar 2024 09:00:00 +0000" {
+		t.Errorf("lastBuildDate = %q, want the newest item's date", doc.Channel.LastBuildDate)
+	}
+	if len(doc.Channel.Items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(doc.Channel.Items))
+	}
+
+	episode := doc.Channel.Items[0]
+	if episode.Title != "Episode 1 & more" || episode.GUID != "episode-1" {
+		t.Errorf("Unexpected item %+v", episode)
+	}
+	if episode.Enclosure == nil || episode.Enclosure.URL != feedTestItems[0].AudioURL || episode.Enclosure.Type != "audio/mp4" {
+		t.Errorf("Expected an audio/mp4 enclosure, got %+v", episode.Enclosure)
+	}
+	if episode.Duration != "3723" {
+		t.Errorf("itunes:duration = %q, want %q", episode.Duration, "3723")
+	}
+	if episode.Thumbnail.URL != feedTestItems[0].Thumbnail {
+		t.Errorf("media:thumbnail = %q, want %q", episode.Thumbnail.URL, feedTestItems[0].Thumbnail)
+	}
+	if len(episode.Categories) != 2 {
+		t.Errorf("Expected the category and tag merged, got %v", episode.Categories)
+	}
+	if doc.Channel.Items[1].Enclosure != nil {
+		t.Errorf("Expected no enclosure for an article, got %+v", doc.Channel.Items[1].Enclosure)
+	}
+}
+
+func TestRenderAtom(t *testing.T) {
+	body, err := RenderFeed(testOutputFeed, feedTestItems, FeedAtom)
+	if err != nil {
+		t.Fatalf("Failed to render feed: %v", err)
+	}
+
+	var doc struct {
+		Updated string `xml:"updated"`
+		Entries []struct {
+			ID    string `xml:"id"`
+			Links []struct {
+				Rel  string `xml:"rel,attr"`
+				Href string `xml:"href,attr"`
+				Type string `xml:"type,attr"`
+			} `xml:"link"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("Failed to parse feed: %v\n%s", err, body)
+	}
+	if doc.Updated != "2024-03-06T09:00:00Z" {
+		t.Errorf("updated = %q, want the newest item's date", doc.Updated)
+	}
+	if len(doc.Entries) != 2 || doc.Entries[0].ID != feedTestItems[0].Link {
+		t.Fatalf("Unexpected entries %+v", doc.Entries)
+	}
+	links := doc.Entries[0].Links
+	if len(links) != 2 || links[1].Rel != "enclosure" || links[1].Href != feedTestItems[0].AudioURL {
+		t.Errorf("Expected an enclosure link, got %+v", links)
+	}
+}
+
+func TestRenderJSONFeed(t *testing.T) {
+	body, err := RenderFeed(testOutputFeed, feedTestItems, FeedJSON)
+	if err != nil {
+		t.Fatalf("Failed to render feed: %v", err)
+	}
+
+	var doc jsonFeed
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+	if doc.Version != "https://jsonfeed.org/version/1.1" || doc.FeedURL != testOutputFeed.SelfURL {
+		t.Errorf("Unexpected feed %+v", doc)
+	}
+	if len(doc.Items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(doc.Items))
+	}
+	episode := doc.Items[0]
+	if episode.Image != feedTestItems[0].Thumbnail {
+		t.Errorf("image = %q, want %q", episode.Image, feedTestItems[0].Thumbnail)
+	}
+	want := jsonFeedAttachment{URL: feedTestItems[0].AudioURL, MimeType: "audio/mp4", DurationInSeconds: 3723}
+	if len(episode.Attachments) != 1 || episode.Attachments[0] != want {
+		t.Errorf("attachments = %+v, want %+v", episode.Attachments, want)
+	}
+
+	if _, err := RenderFeed(testOutputFeed, feedTestItems, "html"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		duration string
+		want     int
+	}{
+		{"", 0},
+		{"90", 90},
+		{"12:34", 754},
+		{"1:02:03", 3723},
+		{"1:2:3:4", 0},
+		{"an hour", 0},
+	}
+	for _, tt := range tests {
+		if got := parseDuration(tt.duration); got != tt.want {
+			t.Errorf("parseDuration(%q) = %d, want %d", tt.duration, got, tt.want)
+		}
+	}
+}
+
+func TestNewestItems(t *testing.T) {
+	newest := NewestItems(feedTestItems, 1)
+	if len(newest) != 1 || newest[0].ID != "article-1" {
+		t.Errorf("NewestItems() = %v, want the article", newest)
+	}
+	if feedTestItems[0].ID != "episode-1" {
+		t.Error("NewestItems() reordered its input")
+	}
+}
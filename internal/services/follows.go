@@ -0,0 +1,308 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+var (
+	// ErrFollowNotFound is returned when a follow ID matches no follow.
+	ErrFollowNotFound = errors.New("follow not found")
+	// ErrInvalidFollow is wrapped by errors describing a follow that cannot
+	// be created.
+	ErrInvalidFollow = errors.New("invalid follow")
+)
+
+// FollowSummary is a follow with how many of the current items it matches
+// and how many of those are new.
+type FollowSummary struct {
+	models.Follow
+	Items  int       `json:"items"`
+	New    int       `json:"new"`
+	Latest time.Time `json:"latest,omitempty"`
+}
+
+// TimelineItem is an item on a follow's timeline. New marks items published
+// since the timeline was last marked seen.
+type TimelineItem struct {
+	models.NewsItem
+	New bool `json:"new"`
+}
+
+// FollowTimeline is every current item a follow matches, oldest first.
+type FollowTimeline struct {
+	Follow models.Follow  `json:"follow"`
+	Items  []TimelineItem `json:"items"`
+	New    int            `json:"new"`
+}
+
+// Follows returns the user's follows.
+func (s *NewsService) Follows() []models.Follow {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]models.Follow{}, s.preferences.Follows...)
+}
+
+// CreateFollow starts following a cluster, entity, search or tag among items.
+// A cluster or entity must be among items; its name and, for a cluster, its
+// members are taken from there. Following the same target twice returns the
+// existing follow. The new follow counts everything current as seen.
+func (s *NewsService) CreateFollow(follow models.Follow, items []models.NewsItem) (models.Follow, error) {
+	follow.Target = strings.TrimSpace(follow.Target)
+	if follow.Target == "" {
+		return models.Follow{}, fmt.Errorf("%w: target must not be empty", ErrInvalidFollow)
+	}
+
+	switch follow.Type {
+	case models.FollowCluster:
+		cluster, ok := s.findCluster(follow.Target, nil, items)
+		if !ok {
+			return models.Follow{}, fmt.Errorf("%w: cluster %s not found", ErrInvalidFollow, follow.Target)
+		}
+		follow.Members = clusterMemberIDs(cluster)
+		if follow.Name == "" {
+			follow.Name = cluster.Representative.Title
+		}
+	case models.FollowEntity:
+		name := entityName(follow.Target, items)
+		if name == "" {
+			return models.Follow{}, fmt.Errorf("%w: entity %s not found", ErrInvalidFollow, follow.Target)
+		}
+		if follow.Name == "" {
+			follow.Name = name
+		}
+	case models.FollowSearch:
+		if follow.Name == "" {
+			follow.Name = follow.Target
+		}
+	case models.FollowTag:
+		tag, ok := s.FindTag(follow.Target)
+		if !ok {
+			return models.Follow{}, fmt.Errorf("%w: %v", ErrInvalidFollow, ErrTagNotFound)
+		}
+		if follow.Name == "" {
+			follow.Name = tag.Name
+		}
+	default:
+		return models.Follow{}, fmt.Errorf("%w: type must be cluster, entity, search or tag", ErrInvalidFollow)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.preferences.Follows {
+		if existing.Type == follow.Type && existing.Target == follow.Target {
+			return existing, nil
+		}
+	}
+
+	now := time.Now()
+	hash := sha256.Sum256([]byte(follow.Type + follow.Target + now.String()))
+	follow.ID = hex.EncodeToString(hash[:])[:8]
+	follow.CreatedAt = now
+	follow.LastSeen = now
+
+	s.preferences.Follows = append(s.preferences.Follows, follow)
+	if err := s.savePreferences(); err != nil {
+		return models.Follow{}, err
+	}
+	return follow, nil
+}
+
+// DeleteFollow stops following id.
+func (s *NewsService) DeleteFollow(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, follow := range s.preferences.Follows {
+		if follow.ID == id {
+			s.preferences.Follows = append(s.preferences.Follows[:i], s.preferences.Follows[i+1:]...)
+			return s.savePreferences()
+		}
+	}
+	return ErrFollowNotFound
+}
+
+// FollowSummaries counts the items among items that every follow matches.
+func (s *NewsService) FollowSummaries(items []models.NewsItem) []FollowSummary {
+	follows := s.Follows()
+	summaries := make([]FollowSummary, 0, len(follows))
+	for _, follow := range follows {
+		summary := FollowSummary{Follow: follow}
+		for _, item := range s.followedItems(follow, items) {
+			summary.Items++
+			if item.Published.After(follow.LastSeen) {
+				summary.New++
+			}
+			if item.Published.After(summary.Latest) {
+				summary.Latest = item.Published
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// FollowTimeline returns the items among items that follow id matches, in
+// the order they were published, marking those that are new.
+func (s *NewsService) FollowTimeline(id string, items []models.NewsItem) (FollowTimeline, error) {
+	follow, ok := s.findFollow(id)
+	if !ok {
+		return FollowTimeline{}, ErrFollowNotFound
+	}
+
+	timeline := FollowTimeline{Follow: follow, Items: []TimelineItem{}}
+	for _, item := range s.followedItems(follow, items) {
+		isNew := item.Published.After(follow.LastSeen)
+		if isNew {
+			timeline.New++
+		}
+		timeline.Items = append(timeline.Items, TimelineItem{NewsItem: item, New: isNew})
+	}
+	sort.SliceStable(timeline.Items, func(i, j int) bool {
+		a, b := timeline.Items[i], timeline.Items[j]
+		if !a.Published.Equal(b.Published) {
+			return a.Published.Before(b.Published)
+		}
+		return a.ID < b.ID
+	})
+	return timeline, nil
+}
+
+// MarkFollowSeen records that follow id's timeline was looked at now. A
+// followed cluster also remembers its current members.
+func (s *NewsService) MarkFollowSeen(id string, items []models.NewsItem) (models.Follow, error) {
+	follow, ok := s.findFollow(id)
+	if !ok {
+		return models.Follow{}, ErrFollowNotFound
+	}
+
+	var members []string
+	if follow.Type == models.FollowCluster {
+		if cluster, ok := s.findCluster(follow.Target, follow.Members, items); ok {
+			members = clusterMemberIDs(cluster)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.preferences.Follows {
+		f := &s.preferences.Follows[i]
+		if f.ID != id {
+			continue
+		}
+		f.LastSeen = time.Now()
+		if members != nil {
+			f.Members = members
+		}
+		if err := s.savePreferences(); err != nil {
+			return models.Follow{}, err
+		}
+		return *f, nil
+	}
+	return models.Follow{}, ErrFollowNotFound
+}
+
+func (s *NewsService) findFollow(id string) (models.Follow, bool) {
+	for _, follow := range s.Follows() {
+		if follow.ID == id {
+			return follow, true
+		}
+	}
+	return models.Follow{}, false
+}
+
+// followedItems returns the items follow matches, in the order of items.
+func (s *NewsService) followedItems(follow models.Follow, items []models.NewsItem) []models.NewsItem {
+	var matched []models.NewsItem
+	switch follow.Type {
+	case models.FollowCluster:
+		cluster, ok := s.findCluster(follow.Target, follow.Members, items)
+		if !ok {
+			return nil
+		}
+		ids := make(map[string]bool, len(cluster.Coverage))
+		for _, member := range cluster.Coverage {
+			ids[member.ID] = true
+		}
+		for _, item := range items {
+			if ids[item.ID] {
+				matched = append(matched, item)
+			}
+		}
+	case models.FollowEntity:
+		for _, item := range items {
+			for _, entity := range item.Entities {
+				if entity.ID == follow.Target {
+					matched = append(matched, item)
+					break
+				}
+			}
+		}
+	case models.FollowSearch:
+		matched = s.SearchNews(items, follow.Target)
+	case models.FollowTag:
+		for _, item := range items {
+			if hasTag(&item, follow.Target) {
+				matched = append(matched, item)
+			}
+		}
+	}
+	return matched
+}
+
+// findCluster clusters items and returns the cluster with the given ID or,
+// failing that, the one covering most of members.
+func (s *NewsService) findCluster(id string, members []string, items []models.NewsItem) (models.StoryCluster, bool) {
+	known := make(map[string]bool, len(members))
+	for _, member := range members {
+		known[member] = true
+	}
+
+	clustered := append([]models.NewsItem{}, items...)
+	var best models.StoryCluster
+	bestShared := 0
+	for _, cluster := range s.ClusterNews(clustered) {
+		if cluster.ID == id {
+			return cluster, true
+		}
+		shared := 0
+		for _, member := range cluster.Coverage {
+			if known[member.ID] {
+				shared++
+			}
+		}
+		if shared > bestShared {
+			best, bestShared = cluster, shared
+		}
+	}
+	return best, bestShared > 0
+}
+
+func clusterMemberIDs(cluster models.StoryCluster) []string {
+	ids := make([]string, len(cluster.Coverage))
+	for i, member := range cluster.Coverage {
+		ids[i] = member.ID
+	}
+	return ids
+}
+
+// entityName returns the name of entity id in the first item naming it.
+func entityName(id string, items []models.NewsItem) string {
+	for _, item := range items {
+		for _, entity := range item.Entities {
+			if entity.ID == id {
+				return entity.Name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+func followItems(now time.Time) []models.NewsItem {
+	return []models.NewsItem{
+		{
+			ID:        "a",
+			Title:     "Election night: Labour leads early count in Oslo",
+			Source:    "NRK",
+			Published: now.Add(-3 * time.Hour),
+			Tags:      []models.Tag{{ID: "politics"}},
+			Entities:  []models.Entity{{ID: "org:labour", Name: "Labour", Type: models.EntityOrg}},
+		},
+		{
+			ID:        "b",
+			Title:     "Labour leads early count on election night in Oslo",
+			Source:    "VG",
+			Published: now.Add(-2 * time.Hour),
+			Tags:      []models.Tag{{ID: "politics"}},
+			Entities:  []models.Entity{{ID: "org:labour", Name: "Labour", Type: models.EntityOrg}},
+		},
+		{
+			ID:        "c",
+			Title:     "Local team wins championship",
+			Source:    "NRK",
+			Published: now.Add(-time.Hour),
+			Tags:      []models.Tag{{ID: "sports"}},
+		},
+	}
+}
+
+func TestFollows(t *testing.T) {
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+	now := time.Now()
+	items := followItems(now)
+
+	entity, err := service.CreateFollow(models.Follow{Type: models.FollowEntity, Target: "org:labour"}, items)
+	if err != nil {
+		t.Fatalf("Failed to follow entity: %v", err)
+	}
+	if entity.Name != "Labour" || entity.ID == "" || entity.LastSeen.IsZero() {
+		t.Errorf("CreateFollow() = %+v, want a named follow seen now", entity)
+	}
+	again, err := service.CreateFollow(models.Follow{Type: models.FollowEntity, Target: "org:labour"}, items)
+	if err != nil || again.ID != entity.ID {
+		t.Errorf("CreateFollow() twice = %+v, %v, want the existing follow", again, err)
+	}
+
+	for _, invalid := range []models.Follow{
+		{Type: models.FollowEntity, Target: "person:nobody"},
+		{Type: models.FollowTag, Target: "no-such-tag"},
+		{Type: models.FollowCluster, Target: "no-such-cluster"},
+		{Type: "planet", Target: "mars"},
+		{Type: models.FollowSearch, Target: " "},
+	} {
+		if _, err := service.CreateFollow(invalid, items); !errors.Is(err, ErrInvalidFollow) {
+			t.Errorf("CreateFollow(%+v) error = %v, want ErrInvalidFollow", invalid, err)
+		}
+	}
+
+	clusters := service.ClusterNews(append([]models.NewsItem{}, items...))
+	cluster, err := service.CreateFollow(models.Follow{Type: models.FollowCluster, Target: clusters[0].ID}, items)
+	if err != nil {
+		t.Fatalf("Failed to follow cluster: %v", err)
+	}
+	if len(cluster.Members) != 2 {
+		t.Errorf("Cluster follow members = %v, want the two election items", cluster.Members)
+	}
+
+	// A new item joins the story, and the item the cluster was named after
+	// leaves the cache
+	later := models.NewsItem{
+		ID:        "d",
+		Title:     "Labour leads early count in Oslo on election night",
+		Source:    "Aftenposten",
+		Published: now.Add(time.Hour),
+		Entities:  []models.Entity{{ID: "org:labour", Name: "Labour", Type: models.EntityOrg}},
+	}
+	updated := append(items[1:], later)
+
+	timeline, err := service.FollowTimeline(cluster.ID, updated)
+	if err != nil {
+		t.Fatalf("Failed to get timeline: %v", err)
+	}
+	if len(timeline.Items) != 2 || timeline.Items[0].ID != "b" || timeline.Items[1].ID != "d" {
+		t.Fatalf("FollowTimeline() = %+v, want b then d", timeline.Items)
+	}
+	if timeline.Items[0].New || !timeline.Items[1].New || timeline.New != 1 {
+		t.Errorf("FollowTimeline() new = %v, %v (%d), want only d new", timeline.Items[0].New, timeline.Items[1].New, timeline.New)
+	}
+
+	summaries := service.FollowSummaries(updated)
+	if len(summaries) != 2 || summaries[0].Items != 2 || summaries[0].New != 1 || !summaries[0].Latest.Equal(later.Published) {
+		t.Errorf("FollowSummaries() = %+v, want the entity in 2 items, 1 new", summaries)
+	}
+
+	seen, err := service.MarkFollowSeen(cluster.ID, updated)
+	if err != nil {
+		t.Fatalf("Failed to mark follow seen: %v", err)
+	}
+	if len(seen.Members) != 2 || seen.Members[0] != "b" || seen.Members[1] != "d" {
+		t.Errorf("MarkFollowSeen() members = %v, want [b d]", seen.Members)
+	}
+
+	search, err := service.CreateFollow(models.Follow{Type: models.FollowSearch, Target: "championship"}, items)
+	if err != nil {
+		t.Fatalf("Failed to follow search: %v", err)
+	}
+	timeline, _ = service.FollowTimeline(search.ID, items)
+	if len(timeline.Items) != 1 || timeline.Items[0].ID != "c" {
+		t.Errorf("Search timeline = %+v, want c", timeline.Items)
+	}
+
+	tag, err := service.CreateFollow(models.Follow{Type: models.FollowTag, Target: "politics"}, items)
+	if err != nil || tag.Name != "Politics" {
+		t.Fatalf("CreateFollow(tag) = %+v, %v, want Politics", tag, err)
+	}
+	timeline, _ = service.FollowTimeline(tag.ID, items)
+	if len(timeline.Items) != 2 {
+		t.Errorf("Tag timeline = %+v, want a and b", timeline.Items)
+	}
+
+	// Follows are kept in the preferences file
+	reloaded, err := NewNewsService(service.prefsFile)
+	if err != nil {
+		t.Fatalf("Failed to reload news service: %v", err)
+	}
+	if got := len(reloaded.Follows()); got != 4 {
+		t.Errorf("Follows() after reload = %d follows, want 4", got)
+	}
+
+	if err := service.DeleteFollow(search.ID); err != nil {
+		t.Fatalf("Failed to delete follow: %v", err)
+	}
+	if err := service.DeleteFollow(search.ID); !errors.Is(err, ErrFollowNotFound) {
+		t.Errorf("DeleteFollow() twice error = %v, want ErrFollowNotFound", err)
+	}
+	if _, err := service.FollowTimeline(search.ID, items); !errors.Is(err, ErrFollowNotFound) {
+		t.Errorf("FollowTimeline() of deleted follow error = %v, want ErrFollowNotFound", err)
+	}
+}
@@ -0,0 +1,352 @@
+package services
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/textanalysis"
+)
+
+// gazetteerEntry is a country, city, union of countries or wider region that
+// region detection recognises. Aliases cover English and Norwegian names, demonyms
+// and common inflections. An alias starting with "=" only matches with the
+// exact capitalisation given, which keeps acronyms such as "US" from
+// matching "us" and names such as "Bergen" from matching German "bergen".
+type gazetteerEntry struct {
+	ID      string
+	Name    string
+	Kind    string
+	Country string
+	Region  string
+	Aliases []string
+}
+
+const (
+	placeCountry = "country"
+	placeCity    = "city"
+	placeUnion   = "union"
+	placeRegion  = "region"
+)
+
+func country(code, name, region string, aliases ...string) gazetteerEntry {
+	return gazetteerEntry{ID: code, Name: name, Kind: placeCountry, Country: code, Region: region, Aliases: aliases}
+}
+
+func region(id string, aliases ...string) gazetteerEntry {
+	return gazetteerEntry{ID: id, Name: id, Kind: placeRegion, Region: id, Aliases: aliases}
+}
+
+func city(id, name, countryCode string, aliases ...string) gazetteerEntry {
+	return gazetteerEntry{ID: id, Name: name, Kind: placeCity, Country: countryCode, Aliases: aliases}
+}
+
+var gazetteer = []gazetteerEntry{
+	// Continents and wider regions count towards a region without naming a
+	// country. They also stop "South American" from reading as "American".
+	region("europe", "Europe", "European", "Europeans", "Europa", "europeisk", "europeiske", "Scandinavia", "Scandinavian", "Nordic", "Skandinavia", "nordisk", "nordiske"),
+	region("north-america", "North America", "North American", "Nord-Amerika"),
+	region("south-america", "South America", "South American", "Latin America", "Latin American", "Sør-Amerika", "Latin-Amerika"),
+	region("asia", "Asia", "Asian", "Asians", "asiatisk", "asiatiske", "Middle East", "Middle Eastern", "Midtøsten"),
+	region("africa", "Africa", "African", "Africans", "Afrika", "afrikansk", "afrikanske"),
+	region("oceania", "Oceania", "Oseania"),
+
+	// Europe
+	country("no", "Norway", "europe", "Norway", "Norwegian", "Norwegians", "Norge", "Norges", "norsk", "norske", "nordmenn", "nordmann"),
+	country("se", "Sweden", "europe", "Sweden", "Swedish", "Swede", "Swedes", "Sverige", "Sveriges", "svensk", "svenske", "svenskene"),
+	country("dk", "Denmark", "europe", "Denmark", "Danish", "Dane", "Danes", "Danmark", "Danmarks", "dansk", "danske", "danskene"),
+	country("fi", "Finland", "europe", "Finland", "Finnish", "Finns", "Finlands", "finsk", "finske"),
+	country("is", "Iceland", "europe", "Iceland", "Icelandic", "islandsk", "islandske"),
+	country("gb", "United Kingdom", "europe", "United Kingdom", "=UK", "Britain", "Great Britain", "British", "Briton", "Britons", "Storbritannia", "Storbritannias", "britisk", "britiske", "britene", "England", "Scotland", "Scottish", "Wales", "Welsh", "Northern Ireland"),
+	country("ie", "Ireland", "europe", "Ireland", "Irish", "Irland", "irsk", "irske"),
+	country("de", "Germany", "europe", "Germany", "German", "Germans", "Tyskland", "Tysklands", "tysk", "tyske", "tyskerne"),
+	country("fr", "France", "europe", "France", "French", "Frankrike", "Frankrikes", "fransk", "franske", "franskmennene"),
+	country("es", "Spain", "europe", "Spain", "Spanish", "Spaniards", "Spania", "Spanias", "spansk", "spanske"),
+	country("pt", "Portugal", "europe", "Portugal", "Portuguese", "Portugals", "portugisisk", "portugisiske"),
+	country("it", "Italy", "europe", "Italy", "Italian", "Italians", "Italia", "Italias", "italiensk", "italienske"),
+	country("nl", "Netherlands", "europe", "Netherlands", "Dutch", "Holland", "Nederland", "nederlandsk", "nederlandske"),
+	country("be", "Belgium", "europe", "Belgium", "Belgian", "Belgia", "belgisk", "belgiske"),
+	country("ch", "Switzerland", "europe", "Switzerland", "Swiss", "Sveits", "sveitsisk", "sveitsiske"),
+	country("at", "Austria", "europe", "Austria", "Austrian", "Østerrike", "østerriksk", "østerrikske"),
+	country("pl", "Poland", "europe", "Poland", "=Polish", "=Poles", "Polen", "Polens", "polsk", "polske"),
+	country("cz", "Czech Republic", "europe", "Czech Republic", "Czechia", "Czech", "Tsjekkia", "tsjekkisk", "tsjekkiske"),
+	country("hu", "Hungary", "europe", "Hungary", "Hungarian", "Ungarn", "ungarsk", "ungarske"),
+	country("ro", "Romania", "europe", "Romania", "Romanian", "rumensk", "rumenske"),
+	country("bg", "Bulgaria", "europe", "Bulgaria", "Bulgarian", "bulgarsk", "bulgarske"),
+	country("gr", "Greece", "europe", "Greece", "Greek", "Greeks", "Hellas", "gresk", "greske"),
+	country("rs", "Serbia", "europe", "Serbia", "Serbian", "serbisk", "serbiske"),
+	country("hr", "Croatia", "europe", "Croatia", "Croatian", "Kroatia", "kroatisk", "kroatiske"),
+	country("ua", "Ukraine", "europe", "Ukraine", "Ukrainian", "Ukrainians", "Ukrainas", "ukrainsk", "ukrainske", "ukrainerne"),
+	country("by", "Belarus", "europe", "Belarus", "Belarusian", "Hviterussland", "hviterussisk"),
+	country("ru", "Russia", "europe", "Russia", "Russian", "Russians", "Russland", "Russlands", "russisk", "russiske", "russerne"),
+	country("ee", "Estonia", "europe", "Estonia", "Estonian", "Estland", "estisk"),
+	country("lv", "Latvia", "europe", "Latvia", "Latvian", "Latvias", "latvisk"),
+	country("lt", "Lithuania", "europe", "Lithuania", "Lithuanian", "Litauen", "litauisk"),
+	{ID: "eu", Name: "European Union", Kind: placeUnion, Region: "europe", Aliases: []string{"European Union", "=EU", "EUs", "Den europeiske union"}},
+
+	// North America
+	country("us", "United States", "north-america", "United States", "United States of America", "=US", "=USA", "=U.S.", "USAs", "American", "Americans", "amerikansk", "amerikanske", "amerikanerne"),
+	country("ca", "Canada", "north-america", "Canada", "Canadian", "Canadians", "Canadas", "kanadisk", "kanadiske"),
+	country("mx", "Mexico", "north-america", "Mexico", "Mexican", "Mexicans", "Mexicos", "meksikansk", "meksikanske"),
+	country("cu", "Cuba", "north-america", "Cuba", "Cuban", "kubansk"),
+	country("ht", "Haiti", "north-america", "Haiti", "Haitian"),
+	country("gl", "Greenland", "north-america", "Greenland", "Grønland", "grønlandsk"),
+
+	// South America
+	country("br", "Brazil", "south-america", "Brazil", "Brazilian", "Brasil", "Brasils", "brasiliansk", "brasilianske"),
+	country("ar", "Argentina", "south-america", "Argentina", "Argentine", "Argentinian", "argentinsk", "argentinske"),
+	country("cl", "Chile", "south-america", "Chile", "Chilean", "chilensk"),
+	country("co", "Colombia", "south-america", "Colombia", "Colombian", "colombiansk"),
+	country("ve", "Venezuela", "south-america", "Venezuela", "Venezuelan", "venezuelansk"),
+	country("pe", "Peru", "south-america", "Peru", "Peruvian", "peruansk"),
+	country("ec", "Ecuador", "south-america", "Ecuador", "Ecuadorian"),
+	country("bo", "Bolivia", "south-america", "Bolivia", "Bolivian"),
+
+	// Asia, including the Middle East
+	country("cn", "China", "asia", "China", "Chinese", "Kina", "Kinas", "kinesisk", "kinesiske"),
+	country("jp", "Japan", "asia", "Japan", "Japanese", "Japans", "japansk", "japanske"),
+	country("kr", "South Korea", "asia", "South Korea", "South Korean", "Sør-Korea", "sørkoreansk"),
+	country("kp", "North Korea", "asia", "North Korea", "North Korean", "Nord-Korea", "nordkoreansk"),
+	country("in", "India", "asia", "India", "Indian", "Indians", "Indias", "indisk", "indiske"),
+	country("pk", "Pakistan", "asia", "Pakistan", "Pakistani", "pakistansk"),
+	country("bd", "Bangladesh", "asia", "Bangladesh"),
+	country("af", "Afghanistan", "asia", "Afghanistan", "Afghan", "afghansk"),
+	country("id", "Indonesia", "asia", "Indonesia", "Indonesian", "indonesisk"),
+	country("ph", "Philippines", "asia", "Philippines", "Filipino", "Filippinene"),
+	country("vn", "Vietnam", "asia", "Vietnam", "Vietnamese", "vietnamesisk"),
+	country("th", "Thailand", "asia", "Thailand", "Thai", "thailandsk"),
+	country("my", "Malaysia", "asia", "Malaysia", "Malaysian"),
+	country("sg", "Singapore", "asia", "Singapore", "Singaporean"),
+	country("tw", "Taiwan", "asia", "Taiwan", "Taiwanese", "taiwansk"),
+	country("mm", "Myanmar", "asia", "Myanmar", "Burma", "Burmese"),
+	country("ir", "Iran", "asia", "Iran", "Iranian", "Irans", "iransk", "iranske"),
+	country("iq", "Iraq", "asia", "Iraq", "Iraqi", "Irak", "irakisk"),
+	country("sy", "Syria", "asia", "Syria", "Syrian", "Syrias", "syrisk", "syriske"),
+	country("il", "Israel", "asia", "Israel", "Israeli", "Israelis", "Israels", "israelsk", "israelske"),
+	country("ps", "Palestine", "asia", "Palestine", "Palestinian", "Palestinians", "Palestina", "palestinsk", "palestinske", "Gaza", "West Bank", "Vestbredden"),
+	country("lb", "Lebanon", "asia", "Lebanon", "Lebanese", "Libanon", "libanesisk"),
+	country("jo", "Jordan", "asia", "=Jordan", "Jordanian"),
+	country("sa", "Saudi Arabia", "asia", "Saudi Arabia", "Saudi", "Saudi-Arabia", "saudiarabisk"),
+	country("ae", "United Arab Emirates", "asia", "United Arab Emirates", "=UAE", "De forente arabiske emirater"),
+	country("qa", "Qatar", "asia", "Qatar", "Qatari"),
+	country("ye", "Yemen", "asia", "Yemen", "Yemeni", "Jemen"),
+	country("tr", "Turkey", "asia", "=Turkey", "Türkiye", "Turkish", "Tyrkia", "Tyrkias", "tyrkisk", "tyrkiske"),
+	country("kz", "Kazakhstan", "asia", "Kazakhstan", "Kasakhstan"),
+
+	// Africa
+	country("za", "South Africa", "africa", "South Africa", "South African", "Sør-Afrika", "sørafrikansk"),
+	country("ng", "Nigeria", "africa", "Nigeria", "Nigerian", "nigeriansk"),
+	country("eg", "Egypt", "africa", "Egypt", "Egyptian", "Egypts", "egyptisk", "egyptiske"),
+	country("ke", "Kenya", "africa", "Kenya", "Kenyan", "kenyansk"),
+	country("et", "Ethiopia", "africa", "Ethiopia", "Ethiopian", "Etiopia", "etiopisk"),
+	country("sd", "Sudan", "africa", "Sudan", "Sudanese", "sudansk"),
+	country("ss", "South Sudan", "africa", "South Sudan", "Sør-Sudan"),
+	country("so", "Somalia", "africa", "Somalia", "Somali", "somalisk"),
+	country("ma", "Morocco", "africa", "Morocco", "Moroccan", "Marokko", "marokkansk"),
+	country("dz", "Algeria", "africa", "Algeria", "Algerian", "algerisk"),
+	country("ly", "Libya", "africa", "Libya", "Libyan", "libysk"),
+	country("tn", "Tunisia", "africa", "Tunisia", "Tunisian"),
+	country("gh", "Ghana", "africa", "Ghana", "Ghanaian"),
+	country("cd", "DR Congo", "africa", "Democratic Republic of the Congo", "DR Congo", "DRC", "Kongo"),
+	country("ml", "Mali", "africa", "Mali", "Malian"),
+	country("ug", "Uganda", "africa", "Uganda", "Ugandan"),
+	country("tz", "Tanzania", "africa", "Tanzania", "Tanzanian"),
+	country("zw", "Zimbabwe", "africa", "Zimbabwe", "Zimbabwean"),
+	country("rw", "Rwanda", "africa", "Rwanda", "Rwandan"),
+
+	// Oceania
+	country("au", "Australia", "oceania", "Australia", "Australian", "Australians", "Australias", "australsk", "australske"),
+	country("nz", "New Zealand", "oceania", "New Zealand", "New Zealander", "New Zealands", "newzealandsk"),
+	country("pg", "Papua New Guinea", "oceania", "Papua New Guinea", "Papua Ny-Guinea"),
+	country("fj", "Fiji", "oceania", "Fiji", "Fijian"),
+
+	// Cities
+	city("oslo", "Oslo", "no", "Oslo", "Oslos"),
+	city("bergen", "Bergen", "no", "=Bergen", "Bergens"),
+	city("trondheim", "Trondheim", "no", "Trondheim", "Trondheims"),
+	city("stavanger", "Stavanger", "no", "Stavanger", "Stavangers"),
+	city("tromso", "Tromsø", "no", "Tromsø", "Tromsøs"),
+	city("kristiansand", "Kristiansand", "no", "Kristiansand"),
+	city("drammen", "Drammen", "no", "Drammen"),
+	city("alesund", "Ålesund", "no", "Ålesund"),
+	city("bodo", "Bodø", "no", "Bodø"),
+	city("stockholm", "Stockholm", "se", "Stockholm"),
+	city("gothenburg", "Gothenburg", "se", "Gothenburg", "Göteborg", "Gøteborg"),
+	city("copenhagen", "Copenhagen", "dk", "Copenhagen", "København", "Københavns"),
+	city("helsinki", "Helsinki", "fi", "Helsinki", "Helsingfors"),
+	city("reykjavik", "Reykjavik", "is", "Reykjavik", "Reykjavík"),
+	city("london", "London", "gb", "London", "Londons"),
+	city("manchester", "Manchester", "gb", "Manchester"),
+	city("edinburgh", "Edinburgh", "gb", "Edinburgh"),
+	city("dublin", "Dublin", "ie", "Dublin"),
+	city("berlin", "Berlin", "de", "Berlin", "Berlins"),
+	city("munich", "Munich", "de", "Munich", "München"),
+	city("hamburg", "Hamburg", "de", "Hamburg"),
+	city("paris", "Paris", "fr", "Paris", "Paris'"),
+	city("marseille", "Marseille", "fr", "Marseille"),
+	city("madrid", "Madrid", "es", "Madrid"),
+	city("barcelona", "Barcelona", "es", "Barcelona"),
+	city("lisbon", "Lisbon", "pt", "Lisbon", "Lisboa"),
+	city("rome", "Rome", "it", "Rome", "Roma"),
+	city("milan", "Milan", "it", "Milan", "Milano"),
+	city("amsterdam", "Amsterdam", "nl", "Amsterdam"),
+	city("the-hague", "The Hague", "nl", "The Hague", "Haag"),
+	city("brussels", "Brussels", "be", "Brussels", "Brussel"),
+	city("geneva", "Geneva", "ch", "Geneva", "Genève"),
+	city("vienna", "Vienna", "at", "Vienna", "Wien"),
+	city("warsaw", "Warsaw", "pl", "Warsaw", "Warszawa"),
+	city("prague", "Prague", "cz", "Prague", "Praha"),
+	city("budapest", "Budapest", "hu", "Budapest"),
+	city("athens", "Athens", "gr", "Athens", "Athen"),
+	city("kyiv", "Kyiv", "ua", "Kyiv", "Kiev", "Kyjiv"),
+	city("kharkiv", "Kharkiv", "ua", "Kharkiv", "Kharkov"),
+	city("odesa", "Odesa", "ua", "Odesa", "Odessa"),
+	city("moscow", "Moscow", "ru", "Moscow", "Moskva"),
+	city("st-petersburg", "St Petersburg", "ru", "St Petersburg", "Saint Petersburg", "St. Petersburg"),
+	city("washington", "Washington", "us", "Washington", "Washington DC"),
+	city("new-york", "New York", "us", "New York", "New Yorks"),
+	city("los-angeles", "Los Angeles", "us", "Los Angeles"),
+	city("chicago", "Chicago", "us", "Chicago"),
+	city("san-francisco", "San Francisco", "us", "San Francisco"),
+	city("toronto", "Toronto", "ca", "Toronto"),
+	city("ottawa", "Ottawa", "ca", "Ottawa"),
+	city("mexico-city", "Mexico City", "mx", "Mexico City"),
+	city("sao-paulo", "São Paulo", "br", "São Paulo", "Sao Paulo"),
+	city("rio-de-janeiro", "Rio de Janeiro", "br", "Rio de Janeiro"),
+	city("buenos-aires", "Buenos Aires", "ar", "Buenos Aires"),
+	city("beijing", "Beijing", "cn", "Beijing", "Peking"),
+	city("shanghai", "Shanghai", "cn", "Shanghai"),
+	city("hong-kong", "Hong Kong", "cn", "Hong Kong"),
+	city("tokyo", "Tokyo", "jp", "Tokyo"),
+	city("seoul", "Seoul", "kr", "Seoul"),
+	city("pyongyang", "Pyongyang", "kp", "Pyongyang"),
+	city("new-delhi", "New Delhi", "in", "New Delhi", "Delhi"),
+	city("mumbai", "Mumbai", "in", "Mumbai"),
+	city("tehran", "Tehran", "ir", "Tehran", "Teheran"),
+	city("baghdad", "Baghdad", "iq", "Baghdad", "Bagdad"),
+	city("damascus", "Damascus", "sy", "Damascus", "Damaskus"),
+	city("jerusalem", "Jerusalem", "il", "Jerusalem"),
+	city("tel-aviv", "Tel Aviv", "il", "Tel Aviv"),
+	city("beirut", "Beirut", "lb", "Beirut"),
+	city("riyadh", "Riyadh", "sa", "Riyadh"),
+	city("dubai", "Dubai", "ae", "Dubai"),
+	city("doha", "Doha", "qa", "Doha"),
+	city("istanbul", "Istanbul", "tr", "Istanbul"),
+	city("ankara", "Ankara", "tr", "Ankara"),
+	city("kabul", "Kabul", "af", "Kabul"),
+	city("cairo", "Cairo", "eg", "Cairo", "Kairo"),
+	city("lagos", "Lagos", "ng", "Lagos"),
+	city("nairobi", "Nairobi", "ke", "Nairobi"),
+	city("johannesburg", "Johannesburg", "za", "Johannesburg"),
+	city("cape-town", "Cape Town", "za", "Cape Town"),
+	city("addis-ababa", "Addis Ababa", "et", "Addis Ababa", "Addis Abeba"),
+	city("khartoum", "Khartoum", "sd", "Khartoum"),
+	city("sydney", "Sydney", "au", "Sydney"),
+	city("melbourne", "Melbourne", "au", "Melbourne"),
+	city("canberra", "Canberra", "au", "Canberra"),
+	city("auckland", "Auckland", "nz", "Auckland"),
+	city("wellington", "Wellington", "nz", "Wellington"),
+}
+
+// aliasRef points from a tokenised alias to its gazetteer entry.
+type aliasRef struct {
+	entry         int
+	caseSensitive bool
+	exact         string
+}
+
+var (
+	gazetteerCountries = make(map[string]int)
+	gazetteerIndex     = buildGazetteerIndex()
+)
+
+// buildGazetteerIndex fills in each city's region from its country, records
+// where each country sits in the gazetteer and maps every lowercased,
+// tokenised alias to the entries it names.
+func buildGazetteerIndex() map[string][]aliasRef {
+	regions := make(map[string]string)
+	for i, entry := range gazetteer {
+		if entry.Kind == placeCountry {
+			regions[entry.Country] = entry.Region
+			gazetteerCountries[entry.Country] = i
+		}
+	}
+
+	index := make(map[string][]aliasRef)
+	for i := range gazetteer {
+		entry := &gazetteer[i]
+		if entry.Region == "" {
+			entry.Region = regions[entry.Country]
+		}
+		seen := make(map[string]bool)
+		for _, alias := range entry.Aliases {
+			ref := aliasRef{entry: i}
+			if strings.HasPrefix(alias, "=") {
+				alias = alias[1:]
+				ref.caseSensitive = true
+				ref.exact = strings.Join(textanalysis.Words(alias), " ")
+			}
+			key := strings.ToLower(strings.Join(textanalysis.Words(alias), " "))
+			if key == "" || seen[key+ref.exact] {
+				continue
+			}
+			seen[key+ref.exact] = true
+			index[key] = append(index[key], ref)
+		}
+	}
+	return index
+}
+
+// placeMentions counts the regions and places behind a list of matched
+// gazetteer entries. Regions and places are ordered by how often they were
+// mentioned, ties broken by ID so the result is stable.
+func placeMentions(entries []int) ([]models.RegionMention, []models.PlaceMention) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	regionCounts := make(map[string]int)
+	placeCounts := make(map[int]int)
+	for _, i := range entries {
+		entry := gazetteer[i]
+		regionCounts[entry.Region]++
+		if entry.Kind != placeRegion {
+			placeCounts[i]++
+		}
+		if entry.Kind == placeCity {
+			placeCounts[gazetteerCountries[entry.Country]]++
+		}
+	}
+
+	regions := make([]models.RegionMention, 0, len(regionCounts))
+	for region, count := range regionCounts {
+		regions = append(regions, models.RegionMention{Region: region, Count: count})
+	}
+	sort.Slice(regions, func(i, j int) bool {
+		if regions[i].Count != regions[j].Count {
+			return regions[i].Count > regions[j].Count
+		}
+		return regions[i].Region < regions[j].Region
+	})
+
+	places := make([]models.PlaceMention, 0, len(placeCounts))
+	for i, count := range placeCounts {
+		entry := gazetteer[i]
+		places = append(places, models.PlaceMention{
+			ID:      entry.ID,
+			Name:    entry.Name,
+			Kind:    entry.Kind,
+			Country: entry.Country,
+			Region:  entry.Region,
+			Count:   count,
+		})
+	}
+	sort.Slice(places, func(i, j int) bool {
+		if places[i].Count != places[j].Count {
+			return places[i].Count > places[j].Count
+		}
+		return places[i].ID < places[j].ID
+	})
+
+	return regions, places
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/news-reader/internal/models"
+)
+
+// centroidsCSV holds "id,latitude,longitude" rows for the gazetteer's places.
+//
+//go:embed data/centroids.csv
+var centroidsCSV []byte
+
+var (
+	centroidsOnce sync.Once
+	centroids     map[string][2]float64
+)
+
+// loadCentroids parses the bundled centroid table into [longitude, latitude]
+// pairs keyed by place ID.
+func loadCentroids() map[string][2]float64 {
+	centroidsOnce.Do(func() {
+		table, err := parseCentroids(centroidsCSV)
+		if err != nil {
+			panic(err)
+		}
+		centroids = table
+	})
+	return centroids
+}
+
+func parseCentroids(data []byte) (map[string][2]float64, error) {
+	table := make(map[string][2]float64)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		row := strings.TrimSpace(scanner.Text())
+		if row == "" || strings.HasPrefix(row, "#") {
+			continue
+		}
+		fields := strings.Split(row, ",")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("centroids line %d: want 3 fields, got %d", line, len(fields))
+		}
+		lat, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("centroids line %d: %v", line, err)
+		}
+		lon, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("centroids line %d: %v", line, err)
+		}
+		table[fields[0]] = [2]float64{lon, lat}
+	}
+	return table, scanner.Err()
+}
+
+// GeoFeatures maps items onto the places they mention, one GeoJSON point per
+// place. Places without a known centroid are left out. Features are ordered
+// by mentions, most first, and each feature lists its items in that order too.
+func (s *NewsService) GeoFeatures(items []models.NewsItem) models.FeatureCollection {
+	table := loadCentroids()
+
+	byPlace := make(map[string]*models.Feature)
+	for _, item := range items {
+		for _, place := range item.Places {
+			coords, ok := table[place.ID]
+			if !ok {
+				continue
+			}
+			feature, ok := byPlace[place.ID]
+			if !ok {
+				feature = &models.Feature{
+					Type:     "Feature",
+					Geometry: models.PointGeometry{Type: "Point", Coordinates: coords},
+					Properties: models.PlaceProperties{
+						ID:      place.ID,
+						Name:    place.Name,
+						Kind:    place.Kind,
+						Country: place.Country,
+						Region:  place.Region,
+						Items:   []models.PlaceItem{},
+					},
+				}
+				byPlace[place.ID] = feature
+			}
+			feature.Properties.Count += place.Count
+			feature.Properties.Items = append(feature.Properties.Items, models.PlaceItem{
+				ID:    item.ID,
+				Title: item.Title,
+				Count: place.Count,
+			})
+		}
+	}
+
+	collection := models.FeatureCollection{
+		Type:     "FeatureCollection",
+		Features: make([]models.Feature, 0, len(byPlace)),
+	}
+	for _, feature := range byPlace {
+		sort.SliceStable(feature.Properties.Items, func(i, j int) bool {
+			return feature.Properties.Items[i].Count > feature.Properties.Items[j].Count
+		})
+		collection.Features = append(collection.Features, *feature)
+	}
+	sort.Slice(collection.Features, func(i, j int) bool {
+		a, b := collection.Features[i].Properties, collection.Features[j].Properties
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.ID < b.ID
+	})
+	return collection
+}
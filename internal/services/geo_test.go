@@ -0,0 +1,87 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/news-reader/internal/models"
+)
+
+func TestCentroidsCoverGazetteer(t *testing.T) {
+	table := loadCentroids()
+	for _, entry := range gazetteer {
+		if entry.Kind == placeRegion {
+			continue
+		}
+		coords, ok := table[entry.ID]
+		if !ok {
+			t.Errorf("No centroid for %s (%s)", entry.ID, entry.Name)
+			continue
+		}
+		if coords[0] < -180 || coords[0] > 180 || coords[1] < -90 || coords[1] > 90 {
+			t.Errorf("Centroid for %s out of range: %v", entry.ID, coords)
+		}
+	}
+}
+
+func TestParseCentroidsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "Missing field", data: "oslo,59.9\n"},
+		{name: "Bad latitude", data: "oslo,north,10.7\n"},
+		{name: "Bad longitude", data: "oslo,59.9,east\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseCentroids([]byte(tt.data)); err == nil {
+				t.Errorf("parseCentroids(%q) succeeded, want error", tt.data)
+			}
+		})
+	}
+}
+
+func TestGeoFeatures(t *testing.T) {
+	service := &NewsService{}
+
+	var items []models.NewsItem
+	for _, text := range []struct{ id, title string }{
+		{"a", "Oslo council meets on housing in Oslo"},
+		{"b", "Norway and Sweden sign border deal"},
+		{"c", "Weather turns cold"},
+	} {
+		item := models.NewsItem{ID: text.id, Title: text.title}
+		item.Regions, item.Places = service.detectRegions(item.Title)
+		items = append(items, item)
+	}
+
+	collection := service.GeoFeatures(items)
+	if collection.Type != "FeatureCollection" {
+		t.Errorf("GeoFeatures() type = %v, want FeatureCollection", collection.Type)
+	}
+
+	features := make(map[string]models.Feature)
+	for _, feature := range collection.Features {
+		features[feature.Properties.ID] = feature
+	}
+	if len(features) != 3 {
+		t.Fatalf("GeoFeatures() = %d features, want 3 (no, oslo, se)", len(features))
+	}
+
+	norway := features["no"]
+	if norway.Properties.Count != 3 || len(norway.Properties.Items) != 2 {
+		t.Errorf("Norway feature = %+v, want 3 mentions in 2 items", norway.Properties)
+	}
+	if norway.Properties.Items[0].ID != "a" {
+		t.Errorf("Norway items = %+v, want the item mentioning it most first", norway.Properties.Items)
+	}
+	if collection.Features[0].Properties.ID != "no" {
+		t.Errorf("First feature = %s, want no", collection.Features[0].Properties.ID)
+	}
+
+	oslo := features["oslo"]
+	if oslo.Geometry.Type != "Point" || oslo.Geometry.Coordinates != [2]float64{10.75, 59.91} {
+		t.Errorf("Oslo geometry = %+v, want point at [10.75, 59.91]", oslo.Geometry)
+	}
+}
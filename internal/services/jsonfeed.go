@@ -0,0 +1,200 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+// jsonFeedDocument is a JSON Feed 1.0 or 1.1 document as read from a source.
+// Version 1.0 has a single author where 1.1 has authors.
+type jsonFeedDocument struct {
+	Version  string           `json:"version"`
+	Title    string           `json:"title"`
+	Language string           `json:"language"`
+	Author   *jsonFeedAuthor  `json:"author"`
+	Authors  []jsonFeedAuthor `json:"authors"`
+	Items    []jsonFeedEntry  `json:"items"`
+}
+
+type jsonFeedEntry struct {
+	// ID may be any JSON value; readers are to coerce it to a string.
+	ID            json.RawMessage     `json:"id"`
+	URL           string              `json:"url"`
+	ExternalURL   string              `json:"external_url"`
+	Title         string              `json:"title"`
+	ContentHTML   string              `json:"content_html"`
+	ContentText   string              `json:"content_text"`
+	Summary       string              `json:"summary"`
+	Image         string              `json:"image"`
+	BannerImage   string              `json:"banner_image"`
+	DatePublished string              `json:"date_published"`
+	DateModified  string              `json:"date_modified"`
+	Author        *jsonFeedAuthor     `json:"author"`
+	Authors       []jsonFeedAuthor    `json:"authors"`
+	Tags          []string            `json:"tags"`
+	Language      string              `json:"language"`
+	Attachments   []jsonFeedEnclosure `json:"attachments"`
+}
+
+type jsonFeedEnclosure struct {
+	URL               string  `json:"url"`
+	MimeType          string  `json:"mime_type"`
+	DurationInSeconds float64 `json:"duration_in_seconds"`
+}
+
+func (s *NewsService) fetchJSONFeed(src models.NewsSource) ([]models.NewsItem, error) {
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+
+	req, err := http.NewRequest("GET", src.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request for %s: %v", src.Name, err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; NewsReader/1.0)")
+	req.Header.Set("Accept", "application/feed+json, application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching JSON Feed from %s: %v", src.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("received status code %d from %s", resp.StatusCode, src.Name)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body from %s: %v", src.Name, err)
+	}
+
+	items, err := parseJSONFeed(body, src)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing JSON Feed from %s: %v", src.Name, err)
+	}
+	if len(items) == 0 {
+		log.Printf("Warning: No items found in JSON Feed from %s", src.Name)
+	}
+	return items, nil
+}
+
+// parseJSONFeed reads the items of a JSON Feed. Audio and video attachments
+// become the item's AudioURL and VideoURL, and the feed's tags its keywords.
+func parseJSONFeed(body []byte, src models.NewsSource) ([]models.NewsItem, error) {
+	var feed jsonFeedDocument
+	if err := json.Unmarshal(body, &feed); err != nil {
+		return nil, err
+	}
+	if !isJSONFeedVersion(feed.Version) {
+		return nil, fmt.Errorf("not a JSON Feed: version %q", feed.Version)
+	}
+
+	feedLanguage := languageFromCode(feed.Language)
+	feedAuthor := jsonFeedAuthors(feed.Authors, feed.Author)
+
+	var items []models.NewsItem
+	for _, entry := range feed.Items {
+		link := entry.URL
+		if link == "" {
+			link = entry.ExternalURL
+		}
+		if link == "" {
+			// Feeds often use the permalink as ID
+			var id string
+			if json.Unmarshal(entry.ID, &id) == nil && strings.HasPrefix(id, "http") {
+				link = id
+			}
+		}
+
+		// Microblog items may have no title
+		title := entry.Title
+		if title == "" {
+			title = truncateRunes(firstNonEmpty(entry.Summary, entry.ContentText), 100)
+		}
+		if title == "" && link == "" {
+			continue
+		}
+
+		published, err := time.Parse(time.RFC3339, firstNonEmpty(entry.DatePublished, entry.DateModified))
+		if err != nil {
+			published = time.Now()
+		}
+
+		item := models.NewsItem{
+			Title:       title,
+			Link:        link,
+			Description: firstNonEmpty(entry.Summary, entry.ContentText, entry.ContentHTML),
+			Published:   published,
+			Source:      src.Name,
+			Author:      jsonFeedAuthors(entry.Authors, entry.Author),
+			Category:    src.Category,
+			Keywords:    entry.Tags,
+			ContentType: src.ContentType,
+			Thumbnail:   firstNonEmpty(entry.Image, entry.BannerImage),
+			Language:    languageFromCode(entry.Language),
+		}
+		if item.Author == "" {
+			item.Author = feedAuthor
+		}
+		if item.Language == "" {
+			item.Language = feedLanguage
+		}
+
+		for _, attachment := range entry.Attachments {
+			mimeType := strings.ToLower(attachment.MimeType)
+			switch {
+			case strings.HasPrefix(mimeType, "audio/") && item.AudioURL == "":
+				item.AudioURL = attachment.URL
+			case strings.HasPrefix(mimeType, "video/") && item.VideoURL == "":
+				item.VideoURL = attachment.URL
+			default:
+				continue
+			}
+			if item.Duration == "" && attachment.DurationInSeconds > 0 {
+				item.Duration = strconv.Itoa(int(attachment.DurationInSeconds))
+			}
+		}
+
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// isJSONFeedVersion reports whether version names JSON Feed 1.x.
+func isJSONFeedVersion(version string) bool {
+	version = strings.TrimPrefix(strings.TrimPrefix(version, "https://"), "http://")
+	return strings.HasPrefix(version, "jsonfeed.org/version/1")
+}
+
+// jsonFeedAuthors joins the names of the 1.1 authors, or returns the name of
+// the 1.0 author.
+func jsonFeedAuthors(authors []jsonFeedAuthor, author *jsonFeedAuthor) string {
+	var names []string
+	for _, a := range authors {
+		if name := strings.TrimSpace(a.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 && author != nil {
+		return strings.TrimSpace(author.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+const testJSONFeed = `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "Blog",
+  "language": "en",
+  "authors": [{"name": "Blog Team"}],
+  "items": [
+    {
+      "id": "https://example.com/posts/1",
+      "url": "https://example.com/posts/1?utm_source=feed",
+      "title": "Election results are in",
+      "content_html": "<p>The government changes.</p>",
+      "summary": "The government changes.",
+      "image": "https://example.com/posts/1.jpg",
+      "date_published": "2024-03-06T08:00:00+01:00",
+      "authors": [{"name": "Kari Nordmann"}, {"name": "Ola Nordmann"}],
+      "tags": ["politics", "norway"]
+    },
+    {
+      "id": 2,
+      "content_text": "Episode two of the show",
+      "date_modified": "2024-03-06T09:00:00Z",
+      "attachments": [
+        {"url": "https://example.com/2.pdf", "mime_type": "application/pdf"},
+        {"url": "https://example.com/2.mp3", "mime_type": "audio/mpeg", "duration_in_seconds": 1800.5},
+        {"url": "https://example.com/2.mp4", "mime_type": "video/mp4"}
+      ]
+    },
+    {"id": "3"}
+  ]
+}`
+
+func TestParseJSONFeed(t *testing.T) {
+	src := models.NewsSource{Name: "Blog", Category: "Technology", ContentType: models.TypeJSONFeed}
+	items, err := parseJSONFeed([]byte(testJSONFeed), src)
+	if err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items, the one with neither title nor link skipped, got %d", len(items))
+	}
+
+	post := items[0]
+	if post.Title != "Election results are in" || post.Description != "The government changes." {
+		t.Errorf("Unexpected post %+v", post)
+	}
+	if !post.Published.Equal(time.Date(2024, 3, 6, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("Published = %v, want 07:00 UTC", post.Published)
+	}
+	if post.Author != "Kari Nordmann, Ola Nordmann" {
+		t.Errorf("Author = %q, want both authors", post.Author)
+	}
+	if post.Thumbnail != "https://example.com/posts/1.jpg" || len(post.Keywords) != 2 || post.Language != "english" {
+		t.Errorf("Unexpected post %+v", post)
+	}
+	if post.Source != "Blog" || post.Category != "Technology" || post.ContentType != models.TypeJSONFeed {
+		t.Errorf("Expected the source's name, category and type, got %+v", post)
+	}
+
+	episode := items[1]
+	if episode.Title != "Episode two of the show" || episode.Link != "" {
+		t.Errorf("Expected the text as title of an untitled item, got %+v", episode)
+	}
+	if episode.AudioURL != "https://example.com/2.mp3" || episode.VideoURL != "https://example.com/2.mp4" || episode.Duration != "1800" {
+		t.Errorf("Expected the audio and video attachments, got %+v", episode)
+	}
+	if episode.Author != "Blog Team" {
+		t.Errorf("Author = %q, want the feed's author", episode.Author)
+	}
+
+	for _, body := range []string{`{"version": "1", "items": []}`, `<rss/>`} {
+		if _, err := parseJSONFeed([]byte(body), src); err == nil {
+			t.Errorf("Expected an error parsing %s", body)
+		}
+	}
+}
+
+func TestFetchJSONFeed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/feed+json")
+		w.Write([]byte(testJSONFeed))
+	}))
+	defer server.Close()
+
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+	items, err := service.fetchNewsFromSource(models.NewsSource{Name: "Blog", URL: server.URL, ContentType: models.TypeJSONFeed, Enabled: true})
+	if err != nil {
+		t.Fatalf("Failed to fetch feed: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(items))
+	}
+	if items[0].ID == "" || items[0].Link != "https://example.com/posts/1" {
+		t.Errorf("Expected an ID and the canonical link, got %+v", items[0])
+	}
+	if !hasTag(&items[0], "politics") {
+		t.Errorf("Expected the item auto-tagged, got %v", items[0].Tags)
+	}
+}
+
+func TestDiscoverFeeds(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head>
+<link rel="alternate" type="application/rss+xml" title="RSS" href="/feed.xml">
+<link rel="Alternate" type="application/feed+json; charset=utf-8" title="JSON" href="https://cdn.example.com/feed.json">
+<link rel="alternate" type="application/json" href="/wp-json/wp/v2/posts/1">
+<link rel="stylesheet" type="text/css" href="/style.css">
+<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+</head></html>`))
+	})
+	mux.HandleFunc("/feed.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testJSONFeed))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	service := &NewsService{}
+	feeds, err := service.DiscoverFeeds(server.URL + "/blog/")
+	if err != nil {
+		t.Fatalf("Failed to discover feeds: %v", err)
+	}
+	want := []DiscoveredFeed{
+		{URL: server.URL + "/feed.xml", Title: "RSS", MimeType: "application/rss+xml", ContentType: models.TypeRSS},
+		{URL: "https://cdn.example.com/feed.json", Title: "JSON", MimeType: "application/feed+json", ContentType: models.TypeJSONFeed},
+	}
+	if len(feeds) != len(want) {
+		t.Fatalf("DiscoverFeeds() = %+v, want %+v", feeds, want)
+	}
+	for i := range want {
+		if feeds[i] != want[i] {
+			t.Errorf("DiscoverFeeds()[%d] = %+v, want %+v", i, feeds[i], want[i])
+		}
+	}
+
+	feeds, err = service.DiscoverFeeds(server.URL + "/feed.json")
+	if err != nil || len(feeds) != 1 || feeds[0].ContentType != models.TypeJSONFeed || feeds[0].Title != "Blog" {
+		t.Errorf("Expected the JSON Feed itself, got %+v, %v", feeds, err)
+	}
+
+	if _, err := service.DiscoverFeeds("ftp://example.com/"); err == nil {
+		t.Error("Expected an error for a non-HTTP URL")
+	}
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/news-reader/internal/matcher"
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/textanalysis"
+)
+
+// keywordIndex finds every gazetteer alias and tag rule keyword in a text in
+// a single pass of one Aho-Corasick automaton. Patterns are normalised with
+// matchText, so the spaces around them make every match a whole-word match.
+type keywordIndex struct {
+	matcher  *matcher.Matcher
+	patterns []string
+	// places lists, per pattern, the gazetteer entries the alias names.
+	places [][]aliasRef
+	// keyword marks the patterns that tag rules look for.
+	keyword []bool
+}
+
+// scannedText is the result of running a keywordIndex over a text.
+type scannedText struct {
+	text    tagText
+	regions []models.RegionMention
+	places  []models.PlaceMention
+	// placeSpans are the word ranges, as [first, end) indexes into
+	// textanalysis.Words of the text, that named places.
+	placeSpans [][2]int
+}
+
+func newKeywordIndex(tags []compiledTag) *keywordIndex {
+	index := &keywordIndex{}
+	byPattern := make(map[string]int)
+	add := func(pattern string) int {
+		if i, ok := byPattern[pattern]; ok {
+			return i
+		}
+		byPattern[pattern] = len(index.patterns)
+		index.patterns = append(index.patterns, pattern)
+		index.places = append(index.places, nil)
+		index.keyword = append(index.keyword, false)
+		return len(index.patterns) - 1
+	}
+
+	// Sort the aliases so pattern numbering does not depend on map order
+	aliases := make([]string, 0, len(gazetteerIndex))
+	for alias := range gazetteerIndex {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		i := add(" " + alias + " ")
+		index.places[i] = gazetteerIndex[alias]
+	}
+
+	for _, tag := range tags {
+		for _, rule := range tag.rules {
+			for _, keyword := range rule.keywords {
+				index.keyword[add(keyword)] = true
+			}
+		}
+	}
+
+	index.matcher = matcher.New(index.patterns)
+	return index
+}
+
+// scan finds the places and keywords in raw. Of overlapping place aliases
+// the longest one starting at the earliest word wins, so "South Africa" is
+// not also read as "Africa", and aliases that must match case only count
+// when they do.
+func (idx *keywordIndex) scan(raw string) scannedText {
+	tokens := textanalysis.Words(raw)
+	lower := make([]string, len(tokens))
+	for i, token := range tokens {
+		lower[i] = strings.ToLower(token)
+	}
+	words := " " + strings.Join(lower, " ") + " "
+
+	// tokenAt maps the byte offset where a word starts to its index
+	tokenAt := make([]int, len(words))
+	offset := 1
+	for i, word := range lower {
+		tokenAt[offset] = i
+		offset += len(word) + 1
+	}
+
+	result := scannedText{text: tagText{raw: raw, words: words, hits: make(map[string]bool)}}
+	type candidate struct {
+		pattern int
+		length  int
+	}
+	candidates := make(map[int][]candidate)
+	for _, match := range idx.matcher.FindAll(words) {
+		pattern := idx.patterns[match.Pattern]
+		if idx.keyword[match.Pattern] {
+			result.text.hits[pattern] = true
+		}
+		if idx.places[match.Pattern] != nil {
+			start := tokenAt[match.Start+1]
+			candidates[start] = append(candidates[start], candidate{
+				pattern: match.Pattern,
+				length:  strings.Count(pattern, " ") - 1,
+			})
+		}
+	}
+
+	var entries []int
+	for i := 0; i < len(tokens); {
+		options := candidates[i]
+		sort.Slice(options, func(a, b int) bool {
+			return options[a].length > options[b].length
+		})
+
+		matched := 0
+		for _, option := range options {
+			for _, ref := range idx.places[option.pattern] {
+				if ref.caseSensitive && strings.Join(tokens[i:i+option.length], " ") != ref.exact {
+					continue
+				}
+				entries = append(entries, ref.entry)
+				matched = option.length
+				result.placeSpans = append(result.placeSpans, [2]int{i, i + matched})
+				break
+			}
+			if matched > 0 {
+				break
+			}
+		}
+		if matched == 0 {
+			matched = 1
+		}
+		i += matched
+	}
+
+	result.regions, result.places = placeMentions(entries)
+	return result
+}
+
+// detectRegions finds the places mentioned in text; see keywordIndex.scan.
+func (s *NewsService) detectRegions(text string) ([]models.RegionMention, []models.PlaceMention) {
+	_, index := s.tagMatchers()
+	scanned := index.scan(text)
+	return scanned.regions, scanned.places
+}
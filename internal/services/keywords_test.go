@@ -0,0 +1,178 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/textanalysis"
+)
+
+// naiveTag is the tagging as it was before the keyword index: gazetteer
+// lookups per word window and strings.Contains per keyword, with linear
+// searches of DefaultTags. It is kept as the reference for the benchmarks.
+func naiveTag(s *NewsService, item *models.NewsItem) {
+	text := itemText(item)
+	tokens := textanalysis.Words(text)
+	lower := make([]string, len(tokens))
+	for i, token := range tokens {
+		lower[i] = strings.ToLower(token)
+	}
+
+	var entries []int
+	for i := 0; i < len(tokens); {
+		matched := 0
+		for n := 4; n > 0 && matched == 0; n-- {
+			if i+n > len(tokens) {
+				continue
+			}
+			for _, ref := range gazetteerIndex[strings.Join(lower[i:i+n], " ")] {
+				if ref.caseSensitive && strings.Join(tokens[i:i+n], " ") != ref.exact {
+					continue
+				}
+				entries = append(entries, ref.entry)
+				matched = n
+				break
+			}
+		}
+		if matched == 0 {
+			matched = 1
+		}
+		i += matched
+	}
+	item.Regions, item.Places = placeMentions(entries)
+
+	item.Tags = []models.Tag{}
+	for _, region := range item.Regions {
+		for _, tag := range models.DefaultTags {
+			if tag.ID == region.Region && tag.Category == "region" {
+				item.Tags = append(item.Tags, tag)
+				break
+			}
+		}
+	}
+	for _, tag := range models.DefaultTags {
+		if tag.ID == item.Language && tag.Category == "language" {
+			item.Tags = append(item.Tags, tag)
+			break
+		}
+	}
+
+	words := matchText(text)
+	for _, tag := range append(s.systemTags(), s.preferences.Tags...) {
+		compiled, err := compileTag(tag)
+		if err != nil || len(compiled.rules) == 0 {
+			continue
+		}
+		if compiled.matches(item, tagText{raw: text, words: words}) {
+			item.Tags = append(item.Tags, compiled.tag)
+		}
+	}
+}
+
+var keywordTestTexts = []string{
+	"Oslo and Bergen host talks as Norway and the US discuss Norwegian oil",
+	"South African president meets EU leaders in Brussels over trade",
+	"New AI model released as researchers study climate data",
+	"Spain said again the museum would reopen; Pacifica residents vote",
+	"Tyskland og Frankrike enige om ny klimaavtale i Paris",
+	"Championship game tonight: players from Tromsø and Ålesund",
+	"Nothing to see here",
+}
+
+func newKeywordTestService() *NewsService {
+	service := &NewsService{preferences: models.NewDefaultPreferences()}
+	service.preferences.Tags = []models.Tag{
+		{ID: "ai", Name: "AI", Category: "user"},
+		{ID: "oil", Name: "Oil", Category: "user", Rules: []models.TagRule{
+			{Keywords: []string{"petroleum", "norwegian oil"}},
+		}},
+	}
+	return service
+}
+
+func TestKeywordIndexMatchesNaive(t *testing.T) {
+	service := newKeywordTestService()
+
+	for _, text := range keywordTestTexts {
+		t.Run(text, func(t *testing.T) {
+			indexed := models.NewsItem{Title: text, Language: "english"}
+			naive := indexed
+			service.autoTagNews(&indexed, service.tagPreferences())
+			naiveTag(service, &naive)
+
+			if !reflect.DeepEqual(indexed.Regions, naive.Regions) || !reflect.DeepEqual(indexed.Places, naive.Places) {
+				t.Errorf("regions = %v %v, want %v %v", indexed.Regions, indexed.Places, naive.Regions, naive.Places)
+			}
+			if !reflect.DeepEqual(indexed.Tags, naive.Tags) {
+				t.Errorf("tags = %v, want %v", indexed.Tags, naive.Tags)
+			}
+		})
+	}
+}
+
+func TestKeywordIndexRebuild(t *testing.T) {
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+
+	_, before := service.tagMatchers()
+	if _, again := service.tagMatchers(); again != before {
+		t.Error("Expected the keyword index to be reused while preferences are unchanged")
+	}
+
+	if _, err := service.CreateTag(models.Tag{Name: "Fjord"}); err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	if _, after := service.tagMatchers(); after == before {
+		t.Error("Expected the keyword index to be rebuilt after a tag was added")
+	}
+
+	item := models.NewsItem{Title: "Cruise ships banned from the fjord"}
+	service.autoTagNews(&item, service.tagPreferences())
+	found := false
+	for _, tag := range item.Tags {
+		found = found || tag.Name == "Fjord"
+	}
+	if !found {
+		t.Errorf("Expected the new tag to match, got %v", item.Tags)
+	}
+}
+
+func benchmarkItems() []models.NewsItem {
+	items := make([]models.NewsItem, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		text := keywordTestTexts[i%len(keywordTestTexts)]
+		items = append(items, models.NewsItem{
+			Title:       fmt.Sprintf("%s (%d)", text, i),
+			Description: strings.Repeat(text+". ", 3),
+			Language:    "english",
+		})
+	}
+	return items
+}
+
+func BenchmarkAutoTagIndexed(b *testing.B) {
+	service := newKeywordTestService()
+	items := benchmarkItems()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range items {
+			service.autoTagNews(&items[j], service.tagPreferences())
+		}
+	}
+}
+
+func BenchmarkAutoTagNaive(b *testing.B) {
+	service := newKeywordTestService()
+	items := benchmarkItems()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range items {
+			naiveTag(service, &items[j])
+		}
+	}
+}
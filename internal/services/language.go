@@ -0,0 +1,174 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// The language identifier is a naive Bayes classifier over character 1- to
+// 3-grams of each word, trained on languageSamples.
+const (
+	maxNGram = 3
+	// Additive smoothing for n-grams a language never produced in training.
+	ngramSmoothing = 0.5
+	// The confidence score grows with the number of letters seen, up to
+	// this many, so short headlines do not claim certainty they have not
+	// earned. Every confidenceLetterScale letters sharpen it by one unit of
+	// average log-likelihood.
+	maxConfidenceLetters  = 60
+	confidenceLetterScale = 4
+	// Texts with fewer letters than this are not classified at all.
+	minLanguageLetters = 3
+)
+
+type languageModel struct {
+	logProb map[string]float64
+	unseen  float64
+}
+
+var (
+	languageModelsOnce sync.Once
+	languageModels     map[string]languageModel
+	languageIDs        []string
+)
+
+func loadLanguageModels() {
+	languageModelsOnce.Do(func() {
+		counts := make(map[string]map[string]int)
+		vocabulary := make(map[string]bool)
+		for lang, sample := range languageSamples {
+			counts[lang] = make(map[string]int)
+			for _, gram := range charNGrams(sample) {
+				counts[lang][gram]++
+				vocabulary[gram] = true
+			}
+		}
+
+		languageModels = make(map[string]languageModel)
+		for lang, grams := range counts {
+			total := 0
+			for _, n := range grams {
+				total += n
+			}
+			denominator := float64(total) + ngramSmoothing*float64(len(vocabulary))
+
+			model := languageModel{
+				logProb: make(map[string]float64, len(grams)),
+				unseen:  math.Log(ngramSmoothing / denominator),
+			}
+			for gram, n := range grams {
+				model.logProb[gram] = math.Log((float64(n) + ngramSmoothing) / denominator)
+			}
+			languageModels[lang] = model
+			languageIDs = append(languageIDs, lang)
+		}
+		sort.Strings(languageIDs)
+	})
+}
+
+// detectLanguage identifies the language of text and returns its tag ID with
+// a confidence between 0 and 1. Text too short to judge yields "" and 0.
+func (s *NewsService) detectLanguage(text string) (string, float64) {
+	loadLanguageModels()
+
+	grams := charNGrams(text)
+	letters := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	if letters < minLanguageLetters || len(grams) == 0 {
+		return "", 0
+	}
+
+	scores := make([]float64, len(languageIDs))
+	for i, lang := range languageIDs {
+		model := languageModels[lang]
+		for _, gram := range grams {
+			if p, ok := model.logProb[gram]; ok {
+				scores[i] += p
+			} else {
+				scores[i] += model.unseen
+			}
+		}
+	}
+
+	// Turn the average log-likelihood into a posterior, sharpening it with
+	// the amount of evidence available
+	evidence := math.Min(float64(letters), maxConfidenceLetters) / confidenceLetterScale
+	best := 0
+	maxScore := math.Inf(-1)
+	for i := range scores {
+		scores[i] = scores[i] / float64(len(grams)) * evidence
+		if scores[i] > maxScore {
+			maxScore = scores[i]
+			best = i
+		}
+	}
+	sum := 0.0
+	for _, score := range scores {
+		sum += math.Exp(score - maxScore)
+	}
+
+	return languageIDs[best], 1 / sum
+}
+
+// languageFromCode maps a declared language such as "nb-NO" or "en-us" to a
+// language tag ID, or "" when the language is not one we tag. Tag IDs are
+// accepted as well.
+func languageFromCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" {
+		return ""
+	}
+	if _, ok := languageSamples[code]; ok {
+		return code
+	}
+	if lang, ok := languageCodes[code]; ok {
+		return lang
+	}
+	if i := strings.IndexAny(code, "-_"); i > 0 {
+		return languageCodes[code[:i]]
+	}
+	return ""
+}
+
+// itemLanguage returns the language declared for a feed item through Dublin
+// Core, falling back to the feed's language.
+func itemLanguage(item *gofeed.Item, feedLanguage string) string {
+	if item.DublinCoreExt != nil {
+		for _, code := range item.DublinCoreExt.Language {
+			if lang := languageFromCode(code); lang != "" {
+				return lang
+			}
+		}
+	}
+	return feedLanguage
+}
+
+// charNGrams returns the character 1- to 3-grams of every word in text. Words
+// are lowercased and padded with spaces so word starts and ends count.
+func charNGrams(text string) []string {
+	var grams []string
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, word := range words {
+		runes := []rune(" " + word + " ")
+		for n := 1; n <= maxNGram; n++ {
+			for i := 0; i+n <= len(runes); i++ {
+				gram := string(runes[i : i+n])
+				if gram != " " {
+					grams = append(grams, gram)
+				}
+			}
+		}
+	}
+	return grams
+}
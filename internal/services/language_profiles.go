@@ -0,0 +1,193 @@
+package services
+
+// Sample text used to train the character n-gram language identifier. The
+// samples are written in the register of news headlines and ledes, since
+// that is what the identifier sees. Keep each sample at a few hundred words
+// so no language gets a much richer profile than the others.
+var languageSamples = map[string]string{
+	"english": `The government announced on Monday that it will raise taxes on large companies
+to pay for new hospitals and schools. The prime minister said the decision was difficult but
+necessary after a year of rising prices. Opposition leaders warned that the plan could hurt
+jobs and growth. Police are investigating a fire that destroyed several homes in the north of
+the city overnight. No one was injured, according to the fire service. Scientists have found
+evidence that the climate is warming faster than expected, and they urged world leaders to act
+before it is too late. The football club confirmed that its manager had left after three
+seasons. Shares fell sharply on Wall Street as investors worried about interest rates. What
+happens next is not clear, but the minister says the talks will continue this week. They have
+also been asked why the report was not published earlier and who was responsible for the delay.
+The company reported higher profits than analysts had expected, which would help its workers.`,
+
+	"spanish": `El gobierno anunció el lunes que subirá los impuestos a las grandes empresas para
+pagar nuevos hospitales y escuelas. El presidente dijo que la decisión fue difícil pero necesaria
+después de un año de precios altos. Los líderes de la oposición advirtieron que el plan podría
+afectar al empleo y al crecimiento. La policía investiga un incendio que destruyó varias viviendas
+en el norte de la ciudad durante la noche. Nadie resultó herido, según los bomberos. Los
+científicos han encontrado pruebas de que el clima se calienta más rápido de lo esperado y piden
+a los líderes mundiales que actúen. El club de fútbol confirmó que su entrenador se marcha
+después de tres temporadas. Las acciones cayeron con fuerza porque los inversores están
+preocupados por los tipos de interés. Qué pasará ahora no está claro, pero el ministro asegura
+que las conversaciones continuarán esta semana. También se les preguntó por qué el informe no se
+publicó antes y quién fue el responsable del retraso. La empresa obtuvo más beneficios.`,
+
+	"french": `Le gouvernement a annoncé lundi qu'il allait augmenter les impôts des grandes
+entreprises pour financer de nouveaux hôpitaux et des écoles. Le Premier ministre a déclaré que
+la décision était difficile mais nécessaire après une année de hausse des prix. Les dirigeants
+de l'opposition ont averti que le projet pourrait nuire à l'emploi et à la croissance. La police
+enquête sur un incendie qui a détruit plusieurs maisons dans le nord de la ville pendant la nuit.
+Personne n'a été blessé, selon les pompiers. Des scientifiques ont trouvé des preuves que le
+climat se réchauffe plus vite que prévu et demandent aux dirigeants du monde d'agir. Le club de
+football a confirmé le départ de son entraîneur après trois saisons. Les actions ont fortement
+chuté car les investisseurs s'inquiètent des taux d'intérêt. La suite n'est pas claire, mais le
+ministre affirme que les discussions se poursuivront cette semaine. On leur a aussi demandé
+pourquoi le rapport n'avait pas été publié plus tôt et qui était responsable du retard.`,
+
+	"german": `Die Regierung hat am Montag angekündigt, die Steuern für große Unternehmen zu
+erhöhen, um neue Krankenhäuser und Schulen zu bezahlen. Der Bundeskanzler sagte, die
+Entscheidung sei schwierig, aber nach einem Jahr mit steigenden Preisen notwendig. Die Opposition
+warnte, dass der Plan Arbeitsplätze und Wachstum gefährden könnte. Die Polizei ermittelt wegen
+eines Brandes, der in der Nacht mehrere Häuser im Norden der Stadt zerstört hat. Nach Angaben der
+Feuerwehr wurde niemand verletzt. Wissenschaftler haben Beweise dafür gefunden, dass sich das
+Klima schneller erwärmt als erwartet, und fordern die Staats- und Regierungschefs zum Handeln auf.
+Der Fußballverein bestätigte, dass sein Trainer nach drei Spielzeiten geht. Die Aktien fielen
+deutlich, weil sich die Anleger über die Zinsen sorgen. Wie es weitergeht, ist unklar, aber der
+Minister sagt, dass die Gespräche in dieser Woche fortgesetzt werden. Sie wurden auch gefragt,
+warum der Bericht nicht früher veröffentlicht wurde und wer für die Verzögerung verantwortlich ist.`,
+
+	"norwegian-bokmal": `Regjeringen kunngjorde mandag at den vil øke skatten for store selskaper for
+å betale for nye sykehus og skoler. Statsministeren sa at beslutningen var vanskelig, men
+nødvendig etter et år med høye priser. Opposisjonen advarer om at planen kan gå ut over
+arbeidsplasser og vekst. Politiet etterforsker en brann som ødela flere boliger nord i byen i
+natt. Ingen ble skadet, ifølge brannvesenet. Forskere har funnet bevis for at klimaet blir
+varmere raskere enn ventet, og ber verdens ledere om å handle nå. Fotballklubben bekrefter at
+treneren slutter etter tre sesonger. Aksjene falt kraftig fordi investorene er bekymret for
+renten. Hva som skjer nå er ikke klart, men ministeren sier at samtalene fortsetter denne uken.
+De ble også spurt om hvorfor rapporten ikke ble publisert tidligere, og hvem som hadde ansvaret
+for forsinkelsen. Jeg tror ikke at det blir noe bedre, sier han til NRK. Det er mye som gjenstår,
+og vi må bare vente og se hva som skjer etter valget. Kommunen har også fått kritikk fra
+innbyggerne, som mener at de ikke har blitt hørt. Nå skal saken behandles i Stortinget.
+En mann er funnet død i en leilighet i Oslo, og politiet har startet etterforskning. Det ble
+full stans på flyplassen etter snøfallet, og mange reisende måtte vente i flere timer. Slik blir
+været i helgen: meteorologen venter kulde og vind langs kysten. Hun vant sin første medalje
+etter et dramatisk løp, og landslaget jubler. Dette er første gang at prisen går til en norsk
+forfatter. Nå kan du søke om støtte til strøm, men fristen er kort. Han ble pågrepet i går kveld.`,
+
+	"norwegian-nynorsk": `Regjeringa kunngjorde måndag at ho vil auke skatten for store selskap for
+å betale for nye sjukehus og skular. Statsministeren sa at avgjerda var vanskeleg, men naudsynt
+etter eit år med høge prisar. Opposisjonen åtvarar om at planen kan gå ut over arbeidsplassar og
+vekst. Politiet etterforskar ein brann som øydela fleire bustader nord i byen i natt. Ingen vart
+skadde, ifølgje brannvesenet. Forskarar har funne prov for at klimaet vert varmare raskare enn
+venta, og ber leiarane i verda om å handle no. Fotballklubben stadfestar at treneren sluttar
+etter tre sesongar. Aksjane fall kraftig fordi investorane er uroa for renta. Kva som skjer no
+er ikkje klart, men ministeren seier at samtalane held fram denne veka. Dei vart òg spurde om
+kvifor rapporten ikkje vart publisert tidlegare, og kven som hadde ansvaret for forseinkinga.
+Eg trur ikkje at det blir noko betre, seier han til NRK. Det er mykje som står att, og vi må
+berre vente og sjå kva som skjer etter valet. Kommunen har også fått kritikk frå innbyggjarane,
+som meiner at dei ikkje har vorte høyrde. No skal saka handsamast i Stortinget.
+Ein mann er funnen død i ei leilegheit i Bergen, og politiet har starta etterforsking. Det vart
+full stans på flyplassen etter snøfallet, og mange reisande måtte vente i fleire timar. Slik
+blir vêret i helga: meteorologen ventar kulde og vind langs kysten. Ho vann den første medaljen
+sin etter eit dramatisk løp, og landslaget jublar. Dette er første gongen at prisen går til ein
+norsk forfattar. No kan du søkje om stønad til straum, men fristen er kort. Han vart pågripen i går.`,
+
+	"danish": `Regeringen meddelte mandag, at den vil hæve skatten for store virksomheder for at
+betale for nye hospitaler og skoler. Statsministeren sagde, at beslutningen var svær, men
+nødvendig efter et år med høje priser. Oppositionen advarer om, at planen kan gå ud over
+arbejdspladser og vækst. Politiet efterforsker en brand, som ødelagde flere boliger nord for
+byen i nat. Ingen kom til skade, oplyser brandvæsenet. Forskere har fundet beviser for, at
+klimaet bliver varmere hurtigere end ventet, og opfordrer verdens ledere til at handle nu.
+Fodboldklubben bekræfter, at træneren stopper efter tre sæsoner. Aktierne faldt kraftigt, fordi
+investorerne er bekymrede for renten. Hvad der sker nu, er ikke klart, men ministeren siger, at
+forhandlingerne fortsætter i denne uge. De blev også spurgt, hvorfor rapporten ikke blev
+offentliggjort tidligere, og hvem der havde ansvaret for forsinkelsen. Jeg tror ikke, at det
+bliver meget bedre, siger han til DR. Der er noget, der mangler, og vi må bare vente og se, hvad
+der sker efter valget. Kommunen har også fået kritik af borgerne, som mener, at de ikke er
+blevet hørt. Nu skal sagen behandles i Folketinget.
+En mand er fundet død i en lejlighed i København, og politiet har indledt en efterforskning. Der
+var fuldt stop i lufthavnen efter snefaldet, og mange rejsende måtte vente i flere timer. Sådan
+bliver vejret i weekenden: meteorologen venter kulde og blæst langs kysten. Hun vandt sin første
+medalje efter et dramatisk løb, og landsholdet jubler. Det er første gang, at prisen går til en
+dansk forfatter. Nu kan du søge om støtte til el, men fristen er kort. Han blev anholdt i går aftes.`,
+
+	"swedish": `Regeringen meddelade på måndagen att den kommer att höja skatten för stora företag
+för att betala för nya sjukhus och skolor. Statsministern sade att beslutet var svårt men
+nödvändigt efter ett år med stigande priser. Oppositionen varnar för att planen kan slå mot jobb
+och tillväxt. Polisen utreder en brand som förstörde flera bostäder i norra delen av staden
+under natten. Ingen skadades, enligt räddningstjänsten. Forskare har hittat bevis för att
+klimatet blir varmare snabbare än väntat och uppmanar världens ledare att agera nu.
+Fotbollsklubben bekräftar att tränaren slutar efter tre säsonger. Aktierna föll kraftigt
+eftersom investerarna är oroliga för räntan. Vad som händer nu är inte klart, men ministern
+säger att samtalen fortsätter den här veckan. De fick också frågan varför rapporten inte
+publicerades tidigare och vem som hade ansvaret för förseningen. Jag tror inte att det blir
+mycket bättre, säger han till SVT. Det finns mycket kvar att göra, och vi får bara vänta och se
+vad som händer efter valet. Kommunen har också fått kritik av invånarna, som menar att de inte
+har blivit hörda. Nu ska frågan behandlas i riksdagen.`,
+
+	"finnish": `Hallitus ilmoitti maanantaina, että se aikoo nostaa suurten yritysten veroja
+rahoittaakseen uusia sairaaloita ja kouluja. Pääministeri sanoi, että päätös oli vaikea mutta
+välttämätön vuoden kestäneen hintojen nousun jälkeen. Oppositio varoittaa, että suunnitelma
+voi heikentää työllisyyttä ja kasvua. Poliisi tutkii tulipaloa, joka tuhosi useita asuntoja
+kaupungin pohjoisosassa yöllä. Kukaan ei loukkaantunut pelastuslaitoksen mukaan. Tutkijat ovat
+löytäneet todisteita siitä, että ilmasto lämpenee odotettua nopeammin, ja he kehottavat
+maailman johtajia toimimaan nyt. Jalkapalloseura vahvistaa, että valmentaja lähtee kolmen
+kauden jälkeen. Osakkeet laskivat voimakkaasti, koska sijoittajat ovat huolissaan koroista.
+Mitä seuraavaksi tapahtuu, ei ole selvää, mutta ministeri sanoo, että neuvottelut jatkuvat
+tällä viikolla. Heiltä kysyttiin myös, miksi raporttia ei julkaistu aikaisemmin ja kuka oli
+vastuussa viivästyksestä. Kunta on myös saanut kritiikkiä asukkailta, joiden mielestä heitä
+ei ole kuultu. Nyt asiaa käsitellään eduskunnassa.`,
+
+	"dutch": `De regering heeft maandag aangekondigd dat zij de belastingen voor grote bedrijven
+verhoogt om nieuwe ziekenhuizen en scholen te betalen. De premier zei dat het besluit moeilijk
+maar noodzakelijk was na een jaar van stijgende prijzen. De oppositie waarschuwt dat het plan
+banen en groei kan schaden. De politie onderzoekt een brand die vannacht meerdere woningen in het
+noorden van de stad heeft verwoest. Volgens de brandweer raakte niemand gewond. Wetenschappers
+hebben bewijs gevonden dat het klimaat sneller opwarmt dan verwacht en roepen wereldleiders op
+om nu in actie te komen. De voetbalclub bevestigt dat de trainer na drie seizoenen vertrekt. De
+aandelen daalden flink omdat beleggers zich zorgen maken over de rente. Wat er nu gebeurt is niet
+duidelijk, maar de minister zegt dat de gesprekken deze week doorgaan. Ook werd gevraagd waarom
+het rapport niet eerder werd gepubliceerd en wie verantwoordelijk was voor de vertraging. Het
+bedrijf maakte meer winst dan analisten hadden verwacht, wat goed nieuws is voor de werknemers.`,
+
+	"italian": `Il governo ha annunciato lunedì che aumenterà le tasse per le grandi aziende per
+pagare nuovi ospedali e scuole. Il presidente del Consiglio ha detto che la decisione è stata
+difficile ma necessaria dopo un anno di prezzi in aumento. I leader dell'opposizione avvertono
+che il piano potrebbe danneggiare l'occupazione e la crescita. La polizia indaga su un incendio
+che nella notte ha distrutto diverse abitazioni nel nord della città. Nessuno è rimasto ferito,
+secondo i vigili del fuoco. Gli scienziati hanno trovato prove che il clima si sta riscaldando
+più velocemente del previsto e chiedono ai leader mondiali di agire subito. La squadra di calcio
+ha confermato che l'allenatore lascerà dopo tre stagioni. Le azioni sono scese molto perché gli
+investitori sono preoccupati per i tassi di interesse. Cosa succederà adesso non è chiaro, ma il
+ministro dice che i colloqui continueranno questa settimana. È stato anche chiesto perché il
+rapporto non sia stato pubblicato prima e chi fosse responsabile del ritardo.`,
+
+	"portuguese": `O governo anunciou na segunda-feira que vai aumentar os impostos das grandes
+empresas para pagar novos hospitais e escolas. O primeiro-ministro disse que a decisão foi
+difícil, mas necessária depois de um ano de preços em alta. Os líderes da oposição alertaram que
+o plano pode prejudicar o emprego e o crescimento. A polícia investiga um incêndio que destruiu
+várias casas no norte da cidade durante a noite. Ninguém ficou ferido, segundo os bombeiros. Os
+cientistas encontraram provas de que o clima está aquecendo mais rápido do que o esperado e pedem
+aos líderes mundiais que ajam já. O clube de futebol confirmou que o treinador vai sair depois de
+três temporadas. As ações caíram muito porque os investidores estão preocupados com os juros. O
+que acontece agora não está claro, mas o ministro diz que as conversas continuam nesta semana.
+Também foi perguntado por que o relatório não foi publicado antes e quem era o responsável pelo
+atraso. A empresa teve lucros maiores do que os analistas esperavam, o que é bom para os
+trabalhadores.`,
+}
+
+// Language codes declared by feeds, mapped to the language tag IDs.
+var languageCodes = map[string]string{
+	"en":  "english",
+	"es":  "spanish",
+	"fr":  "french",
+	"de":  "german",
+	"no":  "norwegian-bokmal",
+	"nb":  "norwegian-bokmal",
+	"nob": "norwegian-bokmal",
+	"nn":  "norwegian-nynorsk",
+	"nno": "norwegian-nynorsk",
+	"sv":  "swedish",
+	"da":  "danish",
+	"fi":  "finnish",
+	"nl":  "dutch",
+	"it":  "italian",
+	"pt":  "portuguese",
+}
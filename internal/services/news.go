@@ -10,7 +10,6 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -39,23 +38,57 @@ type NewsService struct {
 	prefsFile   string
 	mu          sync.RWMutex
 	newsCache   map[string][]models.NewsItem
-}
-
-type TrendingTopic struct {
-	Topic     string `json:"topic"`
-	Frequency int    `json:"frequency"`
+	urls        *urlResolver
+
+	tagsMu     sync.Mutex
+	tagRules   []compiledTag
+	tagIndex   *keywordIndex
+	classifier *tagClassifier
+	trends     *trendHistory
+	trendIndex *trendingIndex
+	breaking   *breakingDetector
+	health     *sourceHealthTracker
+	stream     *streamHub
+	alerts     *alertDispatcher
+	push       *pushStore
+	digests    *digestRunner
 }
 
 func NewNewsService(prefsFile string) (*NewsService, error) {
 	service := &NewsService{
-		prefsFile: prefsFile,
-		newsCache: make(map[string][]models.NewsItem),
+		prefsFile:  prefsFile,
+		newsCache:  make(map[string][]models.NewsItem),
+		urls:       newURLResolver(),
+		trendIndex: newTrendingIndex(),
+		breaking:   newBreakingDetector(),
+		health:     newSourceHealthTracker(),
+		stream:     newStreamHub(),
+		alerts:     newAlertDispatcher(),
+		digests:    &digestRunner{},
 	}
 
 	if err := service.loadPreferences(); err != nil {
 		return nil, err
 	}
 
+	classifier, err := newTagClassifier(trainingFile(prefsFile))
+	if err != nil {
+		return nil, err
+	}
+	service.classifier = classifier
+
+	trends, err := newTrendHistory(trendHistoryFile(prefsFile))
+	if err != nil {
+		return nil, err
+	}
+	service.trends = trends
+
+	push, err := newPushStore(pushFile(prefsFile))
+	if err != nil {
+		return nil, err
+	}
+	service.push = push
+
 	return service, nil
 }
 
@@ -86,16 +119,63 @@ func (s *NewsService) GetPreferences() *models.UserPreferences {
 	return s.preferences
 }
 
+// ClientPreferences returns a copy of the preferences to send to clients,
+// with the alert rules' secrets left out.
+func (s *NewsService) ClientPreferences() models.UserPreferences {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	prefs := *s.preferences
+	prefs.Alerts = redactAlertRules(prefs.Alerts)
+	return prefs
+}
+
 func (s *NewsService) UpdatePreferences(prefs models.UserPreferences) error {
+	for _, src := range prefs.Sources {
+		if err := validateSource(src); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Clients that only edit sources or interests do not send item state,
+	// follows, alerts or digests
+	if prefs.ItemStates == nil {
+		prefs.ItemStates = s.preferences.ItemStates
+	}
+	if prefs.Follows == nil {
+		prefs.Follows = s.preferences.Follows
+	}
+	if prefs.Alerts == nil {
+		prefs.Alerts = s.preferences.Alerts
+	} else {
+		for i := range prefs.Alerts {
+			for _, stored := range s.preferences.Alerts {
+				if stored.ID == prefs.Alerts[i].ID {
+					keepAlertSecret(&prefs.Alerts[i], stored)
+				}
+			}
+		}
+	}
+	if prefs.Digests == nil {
+		prefs.Digests = s.preferences.Digests
+	}
+
 	s.preferences = &prefs
+	s.invalidateTagRules()
 	return s.savePreferences()
 }
 
 func (s *NewsService) GetTags() ([]models.Tag, []models.Tag) {
-	return models.DefaultTags, s.preferences.Tags
+	return s.systemTags(), s.preferences.Tags
 }
 
 func (s *NewsService) CreateTag(tag models.Tag) (models.Tag, error) {
+	if _, err := compileTag(tag); err != nil {
+		return models.Tag{}, err
+	}
+
 	// Generate a unique ID for the tag
 	hash := sha256.New()
 	hash.Write([]byte(tag.Name + time.Now().String()))
@@ -103,6 +183,7 @@ func (s *NewsService) CreateTag(tag models.Tag) (models.Tag, error) {
 	tag.Category = "user"
 
 	s.preferences.Tags = append(s.preferences.Tags, tag)
+	s.invalidateTagRules()
 	if err := s.savePreferences(); err != nil {
 		return models.Tag{}, err
 	}
@@ -128,129 +209,68 @@ func (s *NewsService) UpdateNewsTags(newsID string, tags []models.Tag) error {
 	}
 	s.preferences.NewsTags = append(existingTags, newTags...)
 
+	if err := s.recordManualTags(newsID, tags); err != nil {
+		return err
+	}
 	return s.savePreferences()
 }
 
+// generateNewsID hashes the item's title, source and the link as published,
+// cleaned of tracking parameters. The canonical link is left out, as it can
+// change once a slow redirect resolves.
 func (s *NewsService) generateNewsID(item models.NewsItem) string {
+	link := item.Link
+	if item.OriginalLink != "" {
+		link = cleanURL(item.OriginalLink)
+	}
 	hash := sha256.New()
-	hash.Write([]byte(item.Title + item.Link + item.Source))
+	hash.Write([]byte(item.Title + link + item.Source))
 	return hex.EncodeToString(hash.Sum(nil))
 }
 
-func (s *NewsService) detectRegion(text string) string {
-	regions := map[string][]string{
-		"north-america": {"USA", "Canada", "Mexico", "United States", "American"},
-		"south-america": {"Brazil", "Argentina", "Chile", "Colombia", "Venezuela"},
-		"europe":        {"EU", "European Union", "UK", "Britain", "Germany", "France", "Italy", "Spain"},
-		"asia":          {"China", "Japan", "India", "Korea", "Asian"},
-		"africa":        {"Africa", "Nigeria", "Egypt", "South Africa", "Kenya"},
-		"oceania":       {"Australia", "New Zealand", "Pacific"},
-	}
-
-	text = strings.ToLower(text)
-	for region, keywords := range regions {
-		for _, keyword := range keywords {
-			if strings.Contains(text, strings.ToLower(keyword)) {
-				return region
-			}
-		}
-	}
-	return ""
-}
-
-func (s *NewsService) detectLanguage(text string) string {
-	languages := map[string][]string{
-		"english": {"the", "and", "in", "of", "to"},
-		"spanish": {"el", "la", "en", "de", "por"},
-		"french":  {"le", "la", "les", "en", "de"},
-		"german":  {"der", "die", "das", "und", "in"},
-	}
+func (s *NewsService) autoTagNews(item *models.NewsItem, prefs tagPreferences) {
+	// One pass over the text finds places and tag keywords
+	combinedText := itemText(item)
+	tags, index := s.tagMatchers()
+	scanned := index.scan(combinedText)
 
-	wordCounts := make(map[string]int)
-	words := strings.Fields(strings.ToLower(text))
-
-	for _, word := range words {
-		for lang, markers := range languages {
-			for _, marker := range markers {
-				if word == marker {
-					wordCounts[lang]++
-				}
-			}
-		}
+	// Auto-detect region and language
+	item.Regions, item.Places = scanned.regions, scanned.places
+	item.Region = ""
+	if len(item.Regions) > 0 {
+		item.Region = item.Regions[0].Region
 	}
 
-	maxCount := 0
-	detectedLang := "english" // default
-	for lang, count := range wordCounts {
-		if count > maxCount {
-			maxCount = count
-			detectedLang = lang
-		}
+	// Prefer the language declared by the feed or source over guessing
+	if item.Language != "" {
+		item.LanguageConfidence = 1
+	} else {
+		item.Language, item.LanguageConfidence = s.detectLanguage(combinedText)
 	}
 
-	return detectedLang
-}
-
-func (s *NewsService) autoTagNews(item *models.NewsItem) {
-	// Auto-detect region and language
-	combinedText := item.Title + " " + item.Description
-	item.Region = s.detectRegion(combinedText)
-	item.Language = s.detectLanguage(combinedText)
+	// Find the people, organizations, places and tickers named
+	item.Entities = s.extractEntities(item, scanned)
 
 	// Initialize tags slice
 	item.Tags = []models.Tag{}
 
-	// Add region tag if detected
-	if item.Region != "" {
-		for _, tag := range models.DefaultTags {
-			if tag.ID == item.Region && tag.Category == "region" {
-				item.Tags = append(item.Tags, tag)
-				break
-			}
+	// Add a tag for every region mentioned, the most mentioned first
+	for _, region := range item.Regions {
+		if tag, ok := defaultTagsByID[region.Region]; ok && tag.Category == "region" {
+			item.Tags = append(item.Tags, tag)
 		}
 	}
 
 	// Add language tag
-	for _, tag := range models.DefaultTags {
-		if tag.ID == item.Language && tag.Category == "language" {
-			item.Tags = append(item.Tags, tag)
-			break
-		}
+	if tag, ok := defaultTagsByID[item.Language]; ok && tag.Category == "language" {
+		item.Tags = append(item.Tags, tag)
 	}
 
-	// Add topic tags based on content analysis
-	topicKeywords := map[string][]string{
-		"politics":      {"politics", "government", "election", "president", "minister"},
-		"economy":       {"economy", "market", "stock", "trade", "financial"},
-		"technology":    {"technology", "software", "digital", "cyber", "AI"},
-		"science":       {"science", "research", "study", "discovery"},
-		"health":        {"health", "medical", "disease", "treatment", "covid"},
-		"sports":        {"sports", "game", "tournament", "championship", "player"},
-		"entertainment": {"entertainment", "movie", "music", "celebrity", "art"},
-		"environment":   {"environment", "climate", "pollution", "sustainable"},
-	}
-
-	text := strings.ToLower(combinedText)
-	for topic, keywords := range topicKeywords {
-		for _, keyword := range keywords {
-			if strings.Contains(text, keyword) {
-				for _, tag := range models.DefaultTags {
-					if tag.ID == topic && tag.Category == "topic" {
-						item.Tags = append(item.Tags, tag)
-						break
-					}
-				}
-				break
-			}
-		}
-	}
+	// Add topic and user tags whose rules match
+	applyCompiledTags(item, tags, scanned.text)
 
-	// Add user-defined tags if they match any criteria
-	for _, userTag := range s.preferences.Tags {
-		if strings.Contains(strings.ToLower(combinedText), strings.ToLower(userTag.Name)) {
-			item.Tags = append(item.Tags, userTag)
-		}
-	}
+	// Add manual tags and tags learned from them
+	s.applyLearnedTags(item, prefs)
 }
 
 func (s *NewsService) fetchRSSFeed(src models.NewsSource) ([]models.NewsItem, error) {
@@ -284,6 +304,8 @@ func (s *NewsService) fetchRSSFeed(src models.NewsSource) ([]models.NewsItem, er
 		return nil, fmt.Errorf("received nil feed from %s", src.Name)
 	}
 
+	feedLanguage := languageFromCode(feed.Language)
+
 	var items []models.NewsItem
 	for _, item := range feed.Items {
 		if item == nil {
@@ -308,6 +330,7 @@ func (s *NewsService) fetchRSSFeed(src models.NewsSource) ([]models.NewsItem, er
 			Source:      src.Name,
 			Category:    src.Category,
 			ContentType: src.ContentType,
+			Language:    itemLanguage(item, feedLanguage),
 		}
 
 		// Try to extract image from content if available
@@ -398,6 +421,8 @@ func (s *NewsService) fetchPodcastFeed(src models.NewsSource) ([]models.NewsItem
 		return nil, fmt.Errorf("received nil feed from %s", src.Name)
 	}
 
+	feedLanguage := languageFromCode(feed.Language)
+
 	var items []models.NewsItem
 	for _, item := range feed.Items {
 		if item == nil {
@@ -422,6 +447,7 @@ func (s *NewsService) fetchPodcastFeed(src models.NewsSource) ([]models.NewsItem
 			Source:      src.Name,
 			Category:    src.Category,
 			ContentType: src.ContentType,
+			Language:    itemLanguage(item, feedLanguage),
 		}
 
 		// Extract audio URL from enclosures if available
@@ -524,6 +550,10 @@ func (s *NewsService) fetchRawNewsFromSource(src models.NewsSource) ([]models.Ne
 		return s.fetchPodcastFeed(src)
 	case models.TypeAPI:
 		return s.fetchAPIContent(src)
+	case models.TypeJSONFeed:
+		return s.fetchJSONFeed(src)
+	case models.TypeScrape:
+		return s.fetchScrapedPage(src)
 	default:
 		return nil, fmt.Errorf("unsupported content type: %s", src.ContentType)
 	}
@@ -535,44 +565,158 @@ func (s *NewsService) fetchNewsFromSource(src models.NewsSource) ([]models.NewsI
 		return nil, err
 	}
 
+	sourceLanguage := languageFromCode(src.Language)
+
+	s.canonicalizeLinks(items)
+
 	// Process each item to add IDs and tags
+	prefs := s.tagPreferences()
+	var cached map[string]models.NewsItem
 	for i := range items {
+		if sourceLanguage != "" {
+			items[i].Language = sourceLanguage
+		}
 		items[i].ID = s.generateNewsID(items[i])
-		s.autoTagNews(&items[i])
+
+		// Undated items keep the time they were first fetched
+		if items[i].Published.IsZero() {
+			if cached == nil {
+				s.mu.RLock()
+				cached = s.cachedItemsByID()
+				s.mu.RUnlock()
+			}
+			items[i].Published = time.Now()
+			if seen, ok := cached[items[i].ID]; ok && !seen.Published.IsZero() {
+				items[i].Published = seen.Published
+			}
+		}
+		s.autoTagNews(&items[i], prefs)
 	}
 
 	return items, nil
 }
 
+// FetchNews refreshes the cache from every enabled source. Source entries
+// sharing a feed URL are fetched once, and their items carry the names and
+// categories of all of those entries, the first entry's name as Source.
 func (s *NewsService) FetchNews() []models.NewsItem {
 	var wg sync.WaitGroup
 
-	// Fetch news from each source
-	for _, source := range s.preferences.Sources {
-		if !source.Enabled {
-			continue
-		}
+	groups := groupSourcesByFeed(s.preferences.Sources)
 
+	// Fetch news from each feed
+	for _, group := range groups {
 		wg.Add(1)
-		go func(src models.NewsSource) {
+		go func(group feedGroup) {
 			defer wg.Done()
 
-			items, err := s.fetchNewsFromSource(src)
+			items, err := s.fetchNewsFromSource(group.primary)
+			s.recordSourceHealth(group, len(items), err)
 			if err != nil {
 				log.Printf("Fetch error: %v", err)
 				return
 			}
 
+			if len(group.names) > 1 {
+				for i := range items {
+					items[i].SourceNames = group.names
+				}
+			}
+			if len(group.categories) > 1 {
+				for i := range items {
+					items[i].Categories = group.categories
+					// Rules constrained to the other categories apply too
+					s.applyTagRules(&items[i])
+				}
+			}
+
 			s.mu.Lock()
-			s.newsCache[src.Name] = items
+			previous, fetched := s.newsCache[group.key]
+			s.newsCache[group.key] = items
 			s.mu.Unlock()
-		}(source)
+
+			// A feed's first fetch only sets the baseline alerts compare to
+			if fetched {
+				s.runAlerts(newItems(previous, items))
+			}
+		}(group)
 	}
 
 	wg.Wait()
 
+	// Drop items from feeds that were disabled or removed
+	keys := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		keys[group.key] = true
+	}
+	if s.health != nil {
+		s.health.forget(keys)
+	}
+	s.mu.Lock()
+	for key := range s.newsCache {
+		if !keys[key] {
+			delete(s.newsCache, key)
+		}
+	}
+	s.mu.Unlock()
+
 	// Return all news items
-	return s.GetAllNews()
+	allNews := s.GetAllNews()
+	s.syncTrendingIndex(allNews)
+	breaking := s.updateBreaking(allNews)
+	s.publishUpdates(allNews)
+	for _, event := range breaking {
+		s.stream.publish(StreamBreaking, event)
+		go s.pushBreaking(event)
+	}
+	return allNews
+}
+
+// feedGroup is a set of enabled source entries pointing at the same feed.
+type feedGroup struct {
+	key        string
+	primary    models.NewsSource
+	names      []string
+	categories []string
+}
+
+// groupSourcesByFeed groups the enabled sources by normalised feed URL. The
+// first entry of each group is the one that gets fetched.
+func groupSourcesByFeed(sources []models.NewsSource) []feedGroup {
+	var groups []feedGroup
+	index := make(map[string]int)
+
+	for _, source := range sources {
+		if !source.Enabled {
+			continue
+		}
+
+		key := canonicalURLKey(source.URL)
+		if key == "" {
+			key = source.URL
+		}
+
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(groups)
+			groups = append(groups, feedGroup{
+				key:        key,
+				primary:    source,
+				names:      []string{source.Name},
+				categories: []string{source.Category},
+			})
+			continue
+		}
+
+		if !containsString(groups[i].names, source.Name) {
+			groups[i].names = append(groups[i].names, source.Name)
+		}
+
+		if !containsString(groups[i].categories, source.Category) {
+			groups[i].categories = append(groups[i].categories, source.Category)
+		}
+	}
+	return groups
 }
 
 func (s *NewsService) GetAllNews() []models.NewsItem {
@@ -611,7 +755,7 @@ func (s *NewsService) FilterNews(items []models.NewsItem) []models.NewsItem {
 		if len(s.preferences.Categories) > 0 {
 			found := false
 			for _, cat := range s.preferences.Categories {
-				if item.Category == cat {
+				if item.HasCategory(cat) {
 					found = true
 					break
 				}
@@ -641,119 +785,3 @@ func (s *NewsService) FilterNews(items []models.NewsItem) []models.NewsItem {
 
 	return filtered
 }
-
-func (s *NewsService) GetTrendingTopics(items []models.NewsItem) []TrendingTopic {
-	// Map to store topic frequencies
-	topicFrequency := make(map[string]int)
-
-	// Common English stop words
-	stopWords := map[string]bool{
-		"a": true, "about": true, "above": true, "after": true, "again": true, "against": true, "all": true,
-		"am": true, "an": true, "and": true, "any": true, "are": true, "aren't": true, "as": true, "at": true,
-		"be": true, "because": true, "been": true, "before": true, "being": true, "below": true, "between": true,
-		"both": true, "but": true, "by": true, "can't": true, "cannot": true, "could": true, "couldn't": true,
-		"did": true, "didn't": true, "do": true, "does": true, "doesn't": true, "doing": true, "don't": true,
-		"down": true, "during": true, "each": true, "few": true, "for": true, "from": true, "further": true,
-		"had": true, "hadn't": true, "has": true, "hasn't": true, "have": true, "haven't": true, "having": true,
-		"he": true, "he'd": true, "he'll": true, "he's": true, "her": true, "here": true, "here's": true,
-		"hers": true, "herself": true, "him": true, "himself": true, "his": true, "how": true, "how's": true,
-		"i": true, "i'd": true, "i'll": true, "i'm": true, "i've": true, "if": true, "in": true, "into": true,
-		"is": true, "isn't": true, "it": true, "it's": true, "its": true, "itself": true, "let's": true,
-		"me": true, "more": true, "most": true, "mustn't": true, "my": true, "myself": true, "no": true,
-		"nor": true, "not": true, "of": true, "off": true, "on": true, "once": true, "only": true, "or": true,
-		"other": true, "ought": true, "our": true, "ours": true, "ourselves": true, "out": true, "over": true,
-		"own": true, "same": true, "shan't": true, "she": true, "she'd": true, "she'll": true, "she's": true,
-		"should": true, "shouldn't": true, "so": true, "some": true, "such": true, "than": true, "that": true,
-		"that's": true, "the": true, "their": true, "theirs": true, "them": true, "themselves": true,
-		"then": true, "there": true, "there's": true, "these": true, "they": true, "they'd": true,
-		"they'll": true, "they're": true, "they've": true, "this": true, "those": true, "through": true,
-		"to": true, "too": true, "under": true, "until": true, "up": true, "very": true, "was": true,
-		"wasn't": true, "we": true, "we'd": true, "we'll": true, "we're": true, "we've": true, "were": true,
-		"weren't": true, "what": true, "what's": true, "when": true, "when's": true, "where": true,
-		"where's": true, "which": true, "while": true, "who": true, "who's": true, "whom": true, "why": true,
-		"why's": true, "with": true, "won't": true, "would": true, "wouldn't": true, "you": true, "you'd": true,
-		"you'll": true, "you're": true, "you've": true, "your": true, "yours": true, "yourself": true,
-		"yourselves": true, "said": true, "says": true, "say": true, "also": true, "like": true, "new": true,
-		"one": true, "two": true, "time": true, "year": true, "years": true, "day": true, "days": true,
-		"week": true, "weeks": true, "month": true, "months": true, "today": true, "tomorrow": true,
-		"yesterday": true, "now": true, "later": true, "early": true, "earlier": true, "late": true,
-		"latest": true, "recent": true, "recently": true,
-	}
-
-	// Function to extract potential topics from text
-	extractTopics := func(text string) []string {
-		// Convert to lowercase and split into words
-		text = strings.ToLower(text)
-		words := strings.Fields(text)
-
-		// Store potential topics (both single words and bigrams)
-		var topics []string
-		
-		// Extract single words (nouns and important terms)
-		for _, word := range words {
-			// Clean the word (remove punctuation)
-			word = strings.Trim(word, ".,!?\"'();:[]{}\\|/")
-			
-			// Skip if word is too short, is a stop word, or contains numbers
-			if len(word) < 4 || stopWords[word] || strings.ContainsAny(word, "0123456789") {
-				continue
-			}
-			
-			topics = append(topics, word)
-		}
-
-		// Extract bigrams (pairs of consecutive words)
-		for i := 0; i < len(words)-1; i++ {
-			word1 := strings.Trim(words[i], ".,!?\"'();:[]{}\\|/")
-			word2 := strings.Trim(words[i+1], ".,!?\"'();:[]{}\\|/")
-
-			// Skip if either word is a stop word or too short
-			if stopWords[word1] || stopWords[word2] || len(word1) < 4 || len(word2) < 4 {
-				continue
-			}
-
-			// Combine words into a bigram
-			bigram := word1 + " " + word2
-			topics = append(topics, bigram)
-		}
-
-		return topics
-	}
-
-	// Process each news item
-	for _, item := range items {
-		// Extract topics from title (weighted more heavily)
-		titleTopics := extractTopics(item.Title)
-		for _, topic := range titleTopics {
-			topicFrequency[topic] += 2 // Weight title topics more
-		}
-
-		// Extract topics from description
-		descTopics := extractTopics(item.Description)
-		for _, topic := range descTopics {
-			topicFrequency[topic]++
-		}
-	}
-
-	// Convert map to slice for sorting
-	var topics []TrendingTopic
-	for topic, freq := range topicFrequency {
-		if freq > 1 { // Only include topics that appear more than once
-			topics = append(topics, TrendingTopic{
-				Topic:     topic,
-				Frequency: freq,
-			})
-		}
-	}
-
-	// Sort by frequency (highest first)
-	sort.Slice(topics, func(i, j int) bool {
-		return topics[i].Frequency > topics[j].Frequency
-	})
-
-	// Return top 10 topics
-	if len(topics) > 10 {
-		return topics[:10]
-	}
-	return topics
-}
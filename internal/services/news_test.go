@@ -2,7 +2,11 @@ package services
 
 import (
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -108,35 +112,39 @@ func TestNewsService(t *testing.T) {
 
 func TestGetTrendingTopics(t *testing.T) {
 	service := &NewsService{}
+	now := time.Now()
 
 	// Create test news items
 	items := []models.NewsItem{
 		{
 			Title:       "Technology advances in artificial intelligence",
 			Description: "New developments in artificial intelligence and machine learning",
+			Published:   now.Add(-time.Hour),
 		},
 		{
 			Title:       "AI transforms healthcare industry",
 			Description: "Artificial intelligence making breakthroughs in healthcare",
+			Published:   now.Add(-2 * time.Hour),
 		},
 		{
 			Title:       "Weather report for today",
 			Description: "Sunny weather expected throughout the week",
+			Published:   now.Add(-3 * time.Hour),
 		},
 	}
 
 	// Get trending topics
-	topics := service.GetTrendingTopics(items)
+	topics := service.GetTrendingTopics(items, TrendingOptions{Now: now})
 
 	// Verify results
 	if len(topics) == 0 {
 		t.Error("Expected non-empty trending topics")
 	}
 
-	// "artificial" and "intelligence" should be among top topics
+	// "artificial intelligence" should be among top topics
 	foundAI := false
 	for _, topic := range topics {
-		if topic.Topic == "artificial" || topic.Topic == "intelligence" {
+		if topic.Topic == "artificial intelligence" {
 			if topic.Frequency < 2 {
 				t.Errorf("Expected frequency of '%s' to be at least 2, got %d", topic.Topic, topic.Frequency)
 			}
@@ -145,7 +153,7 @@ func TestGetTrendingTopics(t *testing.T) {
 	}
 
 	if !foundAI {
-		t.Error("Expected 'artificial' or 'intelligence' to be among trending topics")
+		t.Error("Expected 'artificial intelligence' to be among trending topics")
 	}
 }
 
@@ -167,23 +175,104 @@ func TestLanguageDetection(t *testing.T) {
 			text:     "El perro corre por el parque",
 			expected: "spanish",
 		},
+		{
+			name:     "French text",
+			text:     "Le président a annoncé de nouvelles mesures pour les entreprises",
+			expected: "french",
+		},
+		{
+			name:     "German text",
+			text:     "Die Bundesregierung will die Steuern für Unternehmen senken",
+			expected: "german",
+		},
+		{
+			name:     "Norwegian Bokmål text",
+			text:     "Regjeringen vil ikke øke skatten, sier statsministeren etter møtet",
+			expected: "norwegian-bokmal",
+		},
+		{
+			name:     "Norwegian Nynorsk text",
+			text:     "Regjeringa vil ikkje auke skatten, seier statsministeren etter møtet",
+			expected: "norwegian-nynorsk",
+		},
+		{
+			name:     "Swedish text",
+			text:     "Regeringen vill inte höja skatten, säger statsministern efter mötet",
+			expected: "swedish",
+		},
+		{
+			name:     "Danish text",
+			text:     "Regeringen vil ikke hæve skatten, siger statsministeren efter mødet",
+			expected: "danish",
+		},
+		{
+			name:     "Finnish text",
+			text:     "Hallitus ei aio nostaa veroja, sanoo pääministeri kokouksen jälkeen",
+			expected: "finnish",
+		},
+		{
+			name:     "Dutch text",
+			text:     "De regering wil de belastingen niet verhogen, zegt de premier na het overleg",
+			expected: "dutch",
+		},
+		{
+			name:     "Italian text",
+			text:     "Il governo non vuole aumentare le tasse, dice il presidente dopo la riunione",
+			expected: "italian",
+		},
+		{
+			name:     "Portuguese text",
+			text:     "O governo não quer aumentar os impostos, diz o ministro depois da reunião",
+			expected: "portuguese",
+		},
 		{
 			name:     "Empty text",
 			text:     "",
-			expected: "english", // default
+			expected: "",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := service.detectLanguage(tt.text)
+			result, confidence := service.detectLanguage(tt.text)
 			if result != tt.expected {
 				t.Errorf("detectLanguage() = %v, want %v", result, tt.expected)
 			}
+			if tt.expected != "" && (confidence <= 0 || confidence > 1) {
+				t.Errorf("detectLanguage() confidence = %v, want (0, 1]", confidence)
+			}
 		})
 	}
 }
 
+func TestDeclaredLanguage(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{"nb-NO", "norwegian-bokmal"},
+		{"no", "norwegian-bokmal"},
+		{"nn", "norwegian-nynorsk"},
+		{"en-us", "english"},
+		{"pt_BR", "portuguese"},
+		{"danish", "danish"},
+		{"xx", ""},
+	}
+
+	for _, tt := range tests {
+		if got := languageFromCode(tt.code); got != tt.expected {
+			t.Errorf("languageFromCode(%q) = %v, want %v", tt.code, got, tt.expected)
+		}
+	}
+
+	service := &NewsService{preferences: models.NewDefaultPreferences()}
+	item := models.NewsItem{Title: "The government said it will act", Language: "norwegian-bokmal"}
+	service.autoTagNews(&item, service.tagPreferences())
+	if item.Language != "norwegian-bokmal" || item.LanguageConfidence != 1 {
+		t.Errorf("Expected declared language to win, got %s (%v)", item.Language, item.LanguageConfidence)
+	}
+}
+
 func TestRegionDetection(t *testing.T) {
 	service := &NewsService{}
 
@@ -207,14 +296,127 @@ func TestRegionDetection(t *testing.T) {
 			text:     "General news without region",
 			expected: "",
 		},
+		{
+			name:     "EU inside a word",
+			text:     "The museum reopens after renovation",
+			expected: "",
+		},
+		{
+			name:     "Pacific inside a word",
+			text:     "Pacifica residents vote on the budget",
+			expected: "",
+		},
+		{
+			name:     "UK inside a word",
+			text:     "Ukraine signs grain deal",
+			expected: "europe",
+		},
+		{
+			name:     "Lowercase acronym",
+			text:     "Tell us what you think",
+			expected: "",
+		},
+		{
+			name:     "Norwegian country name",
+			text:     "Tyskland og Frankrike enige om ny avtale",
+			expected: "europe",
+		},
+		{
+			name:     "City",
+			text:     "Protests in Tokyo over tax plans",
+			expected: "asia",
+		},
+		{
+			name:     "Longest alias wins",
+			text:     "South American leaders meet",
+			expected: "south-america",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := service.detectRegion(tt.text)
+			regions, _ := service.detectRegions(tt.text)
+			result := ""
+			if len(regions) > 0 {
+				result = regions[0].Region
+			}
 			if result != tt.expected {
-				t.Errorf("detectRegion() = %v, want %v", result, tt.expected)
+				t.Errorf("detectRegions() = %v, want %v", regions, tt.expected)
 			}
 		})
 	}
 }
+
+func TestRegionCounts(t *testing.T) {
+	service := &NewsService{}
+
+	regions, places := service.detectRegions("Oslo and Bergen host talks as Norway and the US discuss Norwegian oil with China")
+
+	wantRegions := []models.RegionMention{
+		{Region: "europe", Count: 4},
+		{Region: "asia", Count: 1},
+		{Region: "north-america", Count: 1},
+	}
+	if !reflect.DeepEqual(regions, wantRegions) {
+		t.Errorf("detectRegions() regions = %v, want %v", regions, wantRegions)
+	}
+
+	counts := make(map[string]int)
+	for _, place := range places {
+		counts[place.ID] = place.Count
+	}
+	wantPlaces := map[string]int{"no": 4, "oslo": 1, "bergen": 1, "us": 1, "cn": 1}
+	if !reflect.DeepEqual(counts, wantPlaces) {
+		t.Errorf("detectRegions() places = %v, want %v", counts, wantPlaces)
+	}
+	if places[0].ID != "no" || places[0].Kind != "country" {
+		t.Errorf("detectRegions() first place = %+v, want Norway", places[0])
+	}
+}
+
+func TestFetchNewsSharedFeed(t *testing.T) {
+	var requests int32
+	feedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>Kultur</title>
+<item><title>Konsert i Bergen</title><link>https://example.com/konsert</link></item>
+</channel></rss>`))
+	}))
+	defer feedServer.Close()
+
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+	service.preferences.Sources = []models.NewsSource{
+		{Name: "NRK Culture", URL: feedServer.URL + "/kultur/siste.rss", Category: "Culture", ContentType: models.TypeRSS, Enabled: true},
+		{Name: "NRK Entertainment", URL: feedServer.URL + "/kultur/siste.rss", Category: "Entertainment", ContentType: models.TypeRSS, Enabled: true},
+		{Name: "NRK Disabled", URL: feedServer.URL + "/other.rss", Category: "General", ContentType: models.TypeRSS, Enabled: false},
+	}
+
+	items := service.FetchNews()
+	if requests != 1 {
+		t.Errorf("Expected the shared feed to be fetched once, got %d requests", requests)
+	}
+	if len(items) != 1 {
+		t.Fatalf("Expected a single item, got %d", len(items))
+	}
+
+	item := items[0]
+	if item.Source != "NRK Culture" {
+		t.Errorf("Expected first entry as source, got %s", item.Source)
+	}
+	if len(item.SourceNames) != 2 || item.SourceNames[1] != "NRK Entertainment" {
+		t.Errorf("Expected the names of both entries, got %v", item.SourceNames)
+	}
+	if !item.HasCategory("Culture") || !item.HasCategory("Entertainment") {
+		t.Errorf("Expected item in both categories, got %v", item.Categories)
+	}
+
+	service.preferences.ContentTypes = nil
+	service.preferences.Categories = []string{"Entertainment"}
+	if filtered := service.FilterNews(items); len(filtered) != 1 {
+		t.Error("Expected item to match its secondary category")
+	}
+}
@@ -0,0 +1,386 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+var (
+	// ErrPushSubscriptionNotFound is returned when an ID matches no push
+	// subscription.
+	ErrPushSubscriptionNotFound = errors.New("push subscription not found")
+	// ErrInvalidPushSubscription is wrapped by errors describing a push
+	// subscription that cannot be used.
+	ErrInvalidPushSubscription = errors.New("invalid push subscription")
+)
+
+// Push urgencies, telling push services how soon to wake the device.
+const (
+	PushUrgencyNormal = "normal"
+	PushUrgencyHigh   = "high"
+)
+
+const (
+	// defaultPushSubject is the VAPID contact used until one is configured.
+	defaultPushSubject = "mailto:news-reader@localhost"
+	// pushTTL is how long push services keep a message for an offline
+	// browser.
+	pushTTL = 24 * time.Hour
+	// maxPushItems is how many items a notification lists.
+	maxPushItems = 5
+)
+
+// PushMessage is the JSON payload browsers receive. The service worker shows
+// Title and Body and opens URL when the notification is clicked; Tag lets
+// later notifications for the same rule or event replace earlier ones.
+type PushMessage struct {
+	Title string     `json:"title"`
+	Body  string     `json:"body"`
+	URL   string     `json:"url,omitempty"`
+	Tag   string     `json:"tag,omitempty"`
+	Items []PushItem `json:"items,omitempty"`
+}
+
+// PushItem is an item listed in a push message.
+type PushItem struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Link  string `json:"link"`
+}
+
+// PushResult is how sending a message to one subscription went.
+type PushResult struct {
+	Subscription string `json:"subscription"`
+	StatusCode   int    `json:"statusCode,omitempty"`
+	Error        string `json:"error,omitempty"`
+	// Expired is set when the push service no longer knows the
+	// subscription, which is then removed.
+	Expired bool `json:"expired,omitempty"`
+}
+
+// pushStore holds the VAPID keys and the push subscriptions. They are kept
+// beside the preferences, rather than in them, since both hold secrets.
+type pushStore struct {
+	mu            sync.Mutex
+	file          string
+	client        *http.Client
+	Subject       string                    `json:"subject"`
+	Keys          vapidKeys                 `json:"keys"`
+	Subscriptions []models.PushSubscription `json:"subscriptions"`
+}
+
+// pushFile returns where the push keys and subscriptions of a preferences
+// file are stored.
+func pushFile(prefsFile string) string {
+	return strings.TrimSuffix(prefsFile, filepath.Ext(prefsFile)) + ".push.json"
+}
+
+// newPushStore loads the push store, generating and saving VAPID keys the
+// first time.
+func newPushStore(file string) (*pushStore, error) {
+	p := &pushStore{
+		file:    file,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		Subject: defaultPushSubject,
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, p); err != nil {
+			return nil, err
+		}
+	}
+	if p.Keys.PrivateKey == "" {
+		if p.Keys, err = generateVAPIDKeys(); err != nil {
+			return nil, err
+		}
+		if err := p.save(); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// save writes the store to disk, readable only by its owner. Callers must
+// hold p.mu.
+func (p *pushStore) save() error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.file, data, 0600)
+}
+
+// SetPushSubject sets the contact, a mailto: or https: URL, that push
+// services are given in VAPID tokens.
+func (s *NewsService) SetPushSubject(subject string) error {
+	if !strings.HasPrefix(subject, "mailto:") && !strings.HasPrefix(subject, "https://") {
+		return fmt.Errorf("push subject must be a mailto: or https: URL")
+	}
+	s.push.mu.Lock()
+	defer s.push.mu.Unlock()
+	if s.push.Subject == subject {
+		return nil
+	}
+	s.push.Subject = subject
+	return s.push.save()
+}
+
+// VAPIDPublicKey returns the key browsers subscribe with, base64url encoded.
+func (s *NewsService) VAPIDPublicKey() string {
+	s.push.mu.Lock()
+	defer s.push.mu.Unlock()
+	return s.push.Keys.PublicKey
+}
+
+// PushSubscriptions returns the push subscriptions.
+func (s *NewsService) PushSubscriptions() []models.PushSubscription {
+	s.push.mu.Lock()
+	defer s.push.mu.Unlock()
+	return append([]models.PushSubscription{}, s.push.Subscriptions...)
+}
+
+// AddPushSubscription saves a browser's subscription. A browser subscribing
+// again with the same endpoint updates its subscription.
+func (s *NewsService) AddPushSubscription(sub models.PushSubscription) (models.PushSubscription, error) {
+	if err := s.validatePushSubscription(sub); err != nil {
+		return models.PushSubscription{}, err
+	}
+
+	s.push.mu.Lock()
+	defer s.push.mu.Unlock()
+	for i, existing := range s.push.Subscriptions {
+		if existing.Endpoint == sub.Endpoint {
+			sub.ID, sub.Created = existing.ID, existing.Created
+			s.push.Subscriptions[i] = sub
+			return sub, s.push.save()
+		}
+	}
+
+	hash := sha256.Sum256([]byte(sub.Endpoint + time.Now().String()))
+	sub.ID = hex.EncodeToString(hash[:])[:8]
+	sub.Created = time.Now()
+	s.push.Subscriptions = append(s.push.Subscriptions, sub)
+	return sub, s.push.save()
+}
+
+// DeletePushSubscription removes the push subscription id.
+func (s *NewsService) DeletePushSubscription(id string) error {
+	s.push.mu.Lock()
+	defer s.push.mu.Unlock()
+	for i, sub := range s.push.Subscriptions {
+		if sub.ID == id {
+			s.push.Subscriptions = append(s.push.Subscriptions[:i], s.push.Subscriptions[i+1:]...)
+			return s.push.save()
+		}
+	}
+	return ErrPushSubscriptionNotFound
+}
+
+// TestPushSubscription sends the subscription id a test notification.
+func (s *NewsService) TestPushSubscription(id string) (PushResult, error) {
+	for _, sub := range s.PushSubscriptions() {
+		if sub.ID == id {
+			msg := PushMessage{
+				Title: "News Reader",
+				Body:  "Notifications are working.",
+				Tag:   "test",
+			}
+			return s.sendPush(sub, msg, PushUrgencyNormal), nil
+		}
+	}
+	return PushResult{}, ErrPushSubscriptionNotFound
+}
+
+func (s *NewsService) validatePushSubscription(sub models.PushSubscription) error {
+	u, err := url.Parse(sub.Endpoint)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("%w: endpoint must be an https URL", ErrInvalidPushSubscription)
+	}
+	public, err := decodePushKey(sub.Keys.P256dh)
+	if err != nil || len(public) != 65 || public[0] != 4 {
+		return fmt.Errorf("%w: p256dh must be an uncompressed P-256 public key", ErrInvalidPushSubscription)
+	}
+	auth, err := decodePushKey(sub.Keys.Auth)
+	if err != nil || len(auth) != 16 {
+		return fmt.Errorf("%w: auth must be a 16 byte secret", ErrInvalidPushSubscription)
+	}
+	for _, id := range sub.Rules {
+		if _, ok := s.alertRule(id); !ok {
+			return fmt.Errorf("%w: no alert rule %q", ErrInvalidPushSubscription, id)
+		}
+	}
+	return nil
+}
+
+// pushAlert notifies the subscriptions following rule of its matches.
+func (s *NewsService) pushAlert(rule models.AlertRule, items []models.NewsItem) {
+	if s.push == nil || len(items) == 0 {
+		return
+	}
+	msg := PushMessage{
+		Title: rule.Name,
+		Body:  items[0].Title,
+		URL:   items[0].Link,
+		Tag:   "alert-" + rule.ID,
+	}
+	if len(items) > 1 {
+		msg.Body = fmt.Sprintf("%s and %d more", items[0].Title, len(items)-1)
+	}
+	for i, item := range items {
+		if i == maxPushItems {
+			break
+		}
+		msg.Items = append(msg.Items, PushItem{ID: item.ID, Title: item.Title, Link: item.Link})
+	}
+
+	for _, sub := range s.PushSubscriptions() {
+		if followsRule(sub, rule.ID) {
+			s.sendPush(sub, msg, PushUrgencyNormal)
+		}
+	}
+}
+
+// pushBreaking notifies the subscriptions following breaking news of an
+// event that was raised or escalated.
+func (s *NewsService) pushBreaking(event BreakingEvent) {
+	if s.push == nil {
+		return
+	}
+	msg := PushMessage{
+		Title: "Breaking: " + event.Title,
+		Body:  fmt.Sprintf("Reported by %d sources", len(event.Sources)),
+		Tag:   "breaking-" + event.ID,
+	}
+	for i, item := range event.Items {
+		if i == maxPushItems {
+			break
+		}
+		if i == 0 {
+			msg.URL = item.Link
+		}
+		msg.Items = append(msg.Items, PushItem{ID: item.ID, Title: item.Title, Link: item.Link})
+	}
+
+	for _, sub := range s.PushSubscriptions() {
+		if sub.Breaking {
+			s.sendPush(sub, msg, PushUrgencyHigh)
+		}
+	}
+}
+
+func followsRule(sub models.PushSubscription, ruleID string) bool {
+	if len(sub.Rules) == 0 {
+		return true
+	}
+	for _, id := range sub.Rules {
+		if id == ruleID {
+			return true
+		}
+	}
+	return false
+}
+
+// sendPush encrypts msg for sub and posts it to the push service. Items are
+// dropped from the message until it fits a push payload. Subscriptions the
+// push service reports gone are removed, and the outcome is recorded on the
+// others.
+func (s *NewsService) sendPush(sub models.PushSubscription, msg PushMessage, urgency string) PushResult {
+	result := PushResult{Subscription: sub.ID}
+	status, err := s.postPush(sub, msg, urgency)
+	result.StatusCode = status
+	if err == nil && (status < 200 || status >= 300) {
+		err = fmt.Errorf("push service answered %d", status)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		log.Printf("Push error: %v", err)
+	}
+	result.Expired = status == http.StatusNotFound || status == http.StatusGone
+
+	s.push.mu.Lock()
+	defer s.push.mu.Unlock()
+	for i := range s.push.Subscriptions {
+		if s.push.Subscriptions[i].ID != sub.ID {
+			continue
+		}
+		if result.Expired {
+			s.push.Subscriptions = append(s.push.Subscriptions[:i], s.push.Subscriptions[i+1:]...)
+		} else {
+			s.push.Subscriptions[i].LastSent = time.Now()
+			s.push.Subscriptions[i].LastError = result.Error
+		}
+		if err := s.push.save(); err != nil {
+			log.Printf("Failed to save push subscriptions: %v", err)
+		}
+		break
+	}
+	return result
+}
+
+func (s *NewsService) postPush(sub models.PushSubscription, msg PushMessage, urgency string) (int, error) {
+	uaPublic, err := decodePushKey(sub.Keys.P256dh)
+	if err != nil {
+		return 0, err
+	}
+	authSecret, err := decodePushKey(sub.Keys.Auth)
+	if err != nil {
+		return 0, err
+	}
+
+	payload, err := json.Marshal(msg)
+	for err == nil && len(payload) > pushMaxPayload && len(msg.Items) > 0 {
+		msg.Items = msg.Items[:len(msg.Items)-1]
+		payload, err = json.Marshal(msg)
+	}
+	if err != nil {
+		return 0, err
+	}
+	body, err := encryptPushPayload(payload, uaPublic, authSecret)
+	if err != nil {
+		return 0, err
+	}
+
+	s.push.mu.Lock()
+	keys, subject := s.push.Keys, s.push.Subject
+	s.push.mu.Unlock()
+	authorization, err := keys.authorization(sub.Endpoint, subject, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(pushTTL.Seconds())))
+	req.Header.Set("Urgency", urgency)
+
+	resp, err := s.push.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,371 @@
+package services
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+func decode64(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("Failed to decode %q: %v", s, err)
+	}
+	return data
+}
+
+// The example in RFC 8291, appendix A
+func TestEncryptPushPayload(t *testing.T) {
+	asPrivate, err := ecdh.P256().NewPrivateKey(decode64(t, "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"))
+	if err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+	body, err := encryptPushPayloadWith(
+		[]byte("When I grow up, I want to be a watermelon"),
+		decode64(t, "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"),
+		decode64(t, "BTBZMqHH6r4Tts7J_aSIgg"),
+		asPrivate,
+		decode64(t, "DGv6ra1nlYgDCS1FRnbzlw"),
+	)
+	if err != nil {
+		t.Fatalf("encryptPushPayloadWith() error = %v", err)
+	}
+	want := "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+	if got := base64.RawURLEncoding.EncodeToString(body); got != want {
+		t.Errorf("encryptPushPayloadWith() = %s, want %s", got, want)
+	}
+
+	if _, err := encryptPushPayloadWith(make([]byte, pushMaxPayload+1), decode64(t, "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"), make([]byte, 16), asPrivate, make([]byte, 16)); err == nil {
+		t.Errorf("encryptPushPayloadWith() of an oversized payload succeeded")
+	}
+}
+
+// pushClient is a browser's side of a subscription.
+type pushClient struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+}
+
+func newPushClient(t *testing.T) *pushClient {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	return &pushClient{key: key, auth: auth}
+}
+
+func (c *pushClient) subscription(endpoint string) models.PushSubscription {
+	return models.PushSubscription{
+		Endpoint: endpoint,
+		Keys: models.PushKeys{
+			P256dh: base64.RawURLEncoding.EncodeToString(c.key.PublicKey().Bytes()),
+			Auth:   base64.RawURLEncoding.EncodeToString(c.auth),
+		},
+	}
+}
+
+// decrypt reverses RFC 8291 encryption as a browser does.
+func (c *pushClient) decrypt(body []byte) ([]byte, error) {
+	if len(body) < 21 {
+		return nil, errors.New("short body")
+	}
+	salt, rs, idlen := body[:16], binary.BigEndian.Uint32(body[16:20]), int(body[20])
+	asPublic, ciphertext := body[21:21+idlen], body[21+idlen:]
+	if rs != pushRecordSize || len(ciphertext) > int(rs) {
+		return nil, errors.New("bad record size")
+	}
+
+	asKey, err := ecdh.P256().NewPublicKey(asPublic)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := c.key.ECDH(asKey)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo := append(append([]byte("WebPush: info\x00"), c.key.PublicKey().Bytes()...), asPublic...)
+	ikm := hkdfExpand(hkdfExtract(c.auth, secret), keyInfo, 32)
+	prk := hkdfExtract(salt, ikm)
+	block, err := aes.NewCipher(hkdfExpand(prk, []byte("Content-Encoding: aes128gcm\x00"), 16))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	record, err := gcm.Open(nil, hkdfExpand(prk, []byte("Content-Encoding: nonce\x00"), 12), ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+	record = bytes.TrimRight(record, "\x00")
+	if len(record) == 0 || record[len(record)-1] != 0x02 {
+		return nil, errors.New("missing last record delimiter")
+	}
+	return record[:len(record)-1], nil
+}
+
+// verifyVAPID checks a VAPID Authorization header for the audience and
+// returns the public key it was signed with.
+func verifyVAPID(t *testing.T, header, audience string) string {
+	t.Helper()
+	var token, key string
+	for _, part := range strings.Split(strings.TrimPrefix(header, "vapid "), ",") {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, "t=") {
+			token = part[2:]
+		} else if strings.HasPrefix(part, "k=") {
+			key = part[2:]
+		}
+	}
+	parts := strings.Split(token, ".")
+	if !strings.HasPrefix(header, "vapid ") || len(parts) != 3 {
+		t.Fatalf("Authorization = %q, want a vapid token and key", header)
+	}
+
+	public := decode64(t, key)
+	pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(public[1:33]), Y: new(big.Int).SetBytes(public[33:])}
+	signature := decode64(t, parts[2])
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if len(signature) != 64 || !ecdsa.Verify(pub, hash[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		t.Errorf("VAPID token signature does not verify")
+	}
+
+	var claims struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}
+	if err := json.Unmarshal(decode64(t, parts[1]), &claims); err != nil {
+		t.Fatalf("Failed to decode claims: %v", err)
+	}
+	if claims.Aud != audience || claims.Sub == "" || time.Until(time.Unix(claims.Exp, 0)) > 24*time.Hour {
+		t.Errorf("VAPID claims = %+v, want aud %s, a subject and an expiry within 24h", claims, audience)
+	}
+	return key
+}
+
+func TestPushSubscriptions(t *testing.T) {
+	prefs := t.TempDir() + "/prefs.json"
+	service, err := NewNewsService(prefs)
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+	key := service.VAPIDPublicKey()
+	if len(decode64(t, key)) != 65 {
+		t.Errorf("VAPIDPublicKey() = %q, want an uncompressed P-256 key", key)
+	}
+
+	client := newPushClient(t)
+	valid := client.subscription("https://push.example.com/send/abc")
+	invalid := []models.PushSubscription{
+		client.subscription("push.example.com"),
+		{Endpoint: valid.Endpoint, Keys: models.PushKeys{P256dh: "not base64!", Auth: valid.Keys.Auth}},
+		{Endpoint: valid.Endpoint, Keys: models.PushKeys{P256dh: valid.Keys.P256dh, Auth: "AAAA"}},
+		{Endpoint: valid.Endpoint, Keys: valid.Keys, Rules: []string{"missing"}},
+	}
+	for _, sub := range invalid {
+		if _, err := service.AddPushSubscription(sub); !errors.Is(err, ErrInvalidPushSubscription) {
+			t.Errorf("AddPushSubscription(%+v) error = %v, want ErrInvalidPushSubscription", sub, err)
+		}
+	}
+
+	sub, err := service.AddPushSubscription(valid)
+	if err != nil {
+		t.Fatalf("Failed to add push subscription: %v", err)
+	}
+	// Subscribing again from the same browser updates the subscription
+	valid.Breaking = true
+	if again, err := service.AddPushSubscription(valid); err != nil || again.ID != sub.ID || !again.Breaking {
+		t.Errorf("AddPushSubscription() again = %+v, %v, want %s updated", again, err, sub.ID)
+	}
+
+	// Keys and subscriptions survive a restart
+	restarted, err := NewNewsService(prefs)
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+	if restarted.VAPIDPublicKey() != key || len(restarted.PushSubscriptions()) != 1 {
+		t.Errorf("After a restart the key is %q with %d subscriptions, want %q with 1", restarted.VAPIDPublicKey(), len(restarted.PushSubscriptions()), key)
+	}
+
+	if err := service.DeletePushSubscription(sub.ID); err != nil {
+		t.Errorf("DeletePushSubscription() error = %v", err)
+	}
+	if err := service.DeletePushSubscription(sub.ID); !errors.Is(err, ErrPushSubscriptionNotFound) {
+		t.Errorf("DeletePushSubscription() twice error = %v, want ErrPushSubscriptionNotFound", err)
+	}
+}
+
+func TestSendPush(t *testing.T) {
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+
+	type received struct {
+		path    string
+		urgency string
+		msg     PushMessage
+	}
+	messages := make(chan received, 10)
+	clients := map[string]*pushClient{}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		if key := verifyVAPID(t, r.Header.Get("Authorization"), server.URL); key != service.VAPIDPublicKey() {
+			t.Errorf("VAPID key = %q, want %q", key, service.VAPIDPublicKey())
+		}
+		if r.Header.Get("Content-Encoding") != "aes128gcm" || r.Header.Get("TTL") == "" {
+			t.Errorf("Headers = %v, want aes128gcm content and a TTL", r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		plaintext, err := clients[r.URL.Path].decrypt(body)
+		if err != nil {
+			t.Errorf("Failed to decrypt push payload: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var msg PushMessage
+		if err := json.Unmarshal(plaintext, &msg); err != nil {
+			t.Errorf("Failed to decode push message: %v", err)
+		}
+		messages <- received{r.URL.Path, r.Header.Get("Urgency"), msg}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	storm, err := service.CreateAlertRule(models.AlertRule{Name: "Storms", Expression: "title contains storm"})
+	if err != nil {
+		t.Fatalf("Failed to create alert rule: %v", err)
+	}
+	budget, err := service.CreateAlertRule(models.AlertRule{Name: "Budget", Expression: "title contains budget"})
+	if err != nil {
+		t.Fatalf("Failed to create alert rule: %v", err)
+	}
+
+	subscribe := func(path string, rules []string, breaking bool) models.PushSubscription {
+		clients[path] = newPushClient(t)
+		sub := clients[path].subscription(server.URL + path)
+		sub.Rules, sub.Breaking = rules, breaking
+		sub, err := service.AddPushSubscription(sub)
+		if err != nil {
+			t.Fatalf("Failed to add push subscription: %v", err)
+		}
+		return sub
+	}
+	subscribe("/storms", []string{storm.ID}, false)
+	subscribe("/budget", []string{budget.ID}, true)
+	gone := subscribe("/gone", nil, false)
+
+	items := []models.NewsItem{
+		{ID: "a", Title: "Storm closes roads", Link: "https://example.com/a"},
+		{ID: "b", Title: "Storm cuts power", Link: "https://example.com/b"},
+	}
+	service.pushAlert(storm, items)
+	if got := <-messages; got.path != "/storms" || got.msg.Title != "Storms" || got.msg.Body != "Storm closes roads and 1 more" || len(got.msg.Items) != 2 || got.urgency != PushUrgencyNormal {
+		t.Errorf("Pushed %+v, want the storm alert to /storms", got)
+	}
+	if len(messages) != 0 {
+		t.Errorf("Pushed %d more messages, want only the storm rule's subscriber", len(messages))
+	}
+
+	// The push service no longer knows /gone, so it is removed
+	for _, sub := range service.PushSubscriptions() {
+		if sub.ID == gone.ID {
+			t.Errorf("PushSubscriptions() still has the expired subscription")
+		}
+	}
+
+	service.pushBreaking(BreakingEvent{
+		ID:      "e1",
+		Title:   "Earthquake hits Bergen",
+		Sources: []string{"NRK", "VG", "BT", "E24", "DN"},
+		Items:   []models.ClusterMember{{ID: "c", Title: "Earthquake hits Bergen", Link: "https://example.com/c"}},
+	})
+	if got := <-messages; got.path != "/budget" || got.urgency != PushUrgencyHigh || got.msg.URL != "https://example.com/c" || got.msg.Tag != "breaking-e1" {
+		t.Errorf("Pushed %+v, want the breaking event to /budget", got)
+	}
+}
+
+func TestRunAlertsPush(t *testing.T) {
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+
+	client := newPushClient(t)
+	messages := make(chan PushMessage, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		plaintext, err := client.decrypt(body)
+		if err != nil {
+			t.Errorf("Failed to decrypt push payload: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var msg PushMessage
+		if err := json.Unmarshal(plaintext, &msg); err != nil {
+			t.Errorf("Failed to decode push message: %v", err)
+		}
+		messages <- msg
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	// A rule without a webhook only notifies push subscribers
+	rule, err := service.CreateAlertRule(models.AlertRule{Name: "Storms", Expression: "title contains storm"})
+	if err != nil {
+		t.Fatalf("Failed to create alert rule: %v", err)
+	}
+	sub := client.subscription(server.URL + "/sub")
+	sub.Rules = []string{rule.ID}
+	if _, err := service.AddPushSubscription(sub); err != nil {
+		t.Fatalf("Failed to add push subscription: %v", err)
+	}
+
+	service.runAlerts([]models.NewsItem{{ID: "a", Title: "Storm closes roads", Link: "https://example.com/a"}})
+
+	select {
+	case msg := <-messages:
+		if msg.Title != "Storms" || msg.Body != "Storm closes roads" || msg.URL != "https://example.com/a" {
+			t.Errorf("Pushed %+v, want the storm alert", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No push delivered")
+	}
+	// Wait for the outcome to be saved before the test's directory goes
+	for deadline := time.Now().Add(5 * time.Second); service.PushSubscriptions()[0].LastSent.IsZero(); {
+		if time.Now().After(deadline) {
+			t.Fatal("Push outcome not recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if deliveries := service.Deliveries(rule.ID); len(deliveries) != 0 {
+		t.Errorf("Deliveries() = %+v, want no webhook deliveries for a push-only rule", deliveries)
+	}
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+// Default ranking settings, used for every zero field and unset weight of
+// models.RankingSettings.
+var defaultRanking = models.RankingSettings{
+	HalfLifeHours:    12,
+	RecencyWeight:    rankingWeight(1),
+	InterestWeight:   rankingWeight(0.5),
+	TagWeight:        rankingWeight(0.5),
+	SourceWeight:     rankingWeight(1),
+	EngagementWeight: rankingWeight(0.3),
+	DiversityCap:     3,
+	DiversityWindow:  20,
+}
+
+func rankingWeight(w float64) *float64 {
+	return &w
+}
+
+// Engagement points per stored item state.
+const (
+	engagementRead    = 1.0
+	engagementStarred = 3.0
+	engagementHidden  = -2.0
+)
+
+// rankingSettings returns the user's ranking settings with defaults filled
+// in, so every weight is set.
+func (s *NewsService) rankingSettings() models.RankingSettings {
+	r := s.preferences.Ranking
+	if r.HalfLifeHours <= 0 {
+		r.HalfLifeHours = defaultRanking.HalfLifeHours
+	}
+	if r.RecencyWeight == nil {
+		r.RecencyWeight = defaultRanking.RecencyWeight
+	}
+	if r.InterestWeight == nil {
+		r.InterestWeight = defaultRanking.InterestWeight
+	}
+	if r.TagWeight == nil {
+		r.TagWeight = defaultRanking.TagWeight
+	}
+	if r.SourceWeight == nil {
+		r.SourceWeight = defaultRanking.SourceWeight
+	}
+	if r.EngagementWeight == nil {
+		r.EngagementWeight = defaultRanking.EngagementWeight
+	}
+	if r.DiversityCap <= 0 {
+		r.DiversityCap = defaultRanking.DiversityCap
+	}
+	if r.DiversityWindow <= 0 {
+		r.DiversityWindow = defaultRanking.DiversityWindow
+	}
+	return r
+}
+
+// RankNews orders items by their personalised score, highest first, and then
+// applies the per-source diversity cap. When debug is set every item carries
+// its score breakdown.
+func (s *NewsService) RankNews(items []models.NewsItem, debug bool) []models.NewsItem {
+	s.mu.RLock()
+	settings := s.rankingSettings()
+	interests := s.interestWeights(settings)
+	bySource, byCategory := s.engagementAffinity()
+	s.mu.RUnlock()
+
+	now := time.Now()
+	for i := range items {
+		score := scoreItem(items[i], now, settings, interests, bySource, byCategory)
+		items[i].Score = &score
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Score.Total != items[j].Score.Total {
+			return items[i].Score.Total > items[j].Score.Total
+		}
+		return items[i].Published.After(items[j].Published)
+	})
+
+	items = diversify(items, settings.DiversityCap, settings.DiversityWindow)
+
+	if !debug {
+		for i := range items {
+			items[i].Score = nil
+		}
+	}
+	return items
+}
+
+// SortByRecency orders items newest first.
+func (s *NewsService) SortByRecency(items []models.NewsItem) []models.NewsItem {
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Published.After(items[j].Published)
+	})
+	return items
+}
+
+func scoreItem(item models.NewsItem, now time.Time, settings models.RankingSettings,
+	interests map[string]float64, bySource, byCategory map[string]float64) models.ScoreBreakdown {
+	var score models.ScoreBreakdown
+
+	// Exponential decay: an item loses half its recency score every half-life
+	ageHours := now.Sub(item.Published).Hours()
+	if ageHours < 0 {
+		ageHours = 0
+	}
+	score.Recency = *settings.RecencyWeight * math.Exp(-math.Ln2*ageHours/settings.HalfLifeHours)
+
+	text := strings.ToLower(item.Title + " " + item.Description)
+	for term, weight := range interests {
+		if strings.Contains(text, term) {
+			score.Interest += weight
+		}
+	}
+	score.Interest *= *settings.InterestWeight
+
+	for _, tag := range item.Tags {
+		score.Tags += settings.TagWeights[tag.ID]
+	}
+	score.Tags *= *settings.TagWeight
+
+	score.Source = *settings.SourceWeight * settings.SourcePriority[item.Source]
+
+	score.Engagement = *settings.EngagementWeight * (bySource[item.Source] + byCategory[item.Category]) / 2
+
+	score.Total = score.Recency + score.Interest + score.Tags + score.Source + score.Engagement
+	return score
+}
+
+// interestWeights merges the plain interests list with the weighted interests.
+// Callers must hold s.mu.
+func (s *NewsService) interestWeights(settings models.RankingSettings) map[string]float64 {
+	weights := make(map[string]float64)
+	for _, interest := range s.preferences.Interests {
+		if interest = strings.ToLower(strings.TrimSpace(interest)); interest != "" {
+			weights[interest] = 1
+		}
+	}
+	for term, weight := range settings.InterestWeights {
+		if term = strings.ToLower(strings.TrimSpace(term)); term != "" {
+			weights[term] = weight
+		}
+	}
+	return weights
+}
+
+// engagementAffinity turns the stored read, starred and hidden state into a
+// per-source and per-category affinity in [-1, 1]. Callers must hold s.mu.
+func (s *NewsService) engagementAffinity() (map[string]float64, map[string]float64) {
+	bySource := make(map[string]float64)
+	byCategory := make(map[string]float64)
+	for _, state := range s.preferences.ItemStates {
+		points := 0.0
+		if state.Read {
+			points += engagementRead
+		}
+		if state.Starred {
+			points += engagementStarred
+		}
+		if state.Hidden {
+			points += engagementHidden
+		}
+		if state.Source != "" {
+			bySource[state.Source] += points
+		}
+		if state.Category != "" {
+			byCategory[state.Category] += points
+		}
+	}
+	normalizeAffinity(bySource)
+	normalizeAffinity(byCategory)
+	return bySource, byCategory
+}
+
+// normalizeAffinity scales the values so the largest magnitude becomes 1.
+func normalizeAffinity(values map[string]float64) {
+	largest := 0.0
+	for _, v := range values {
+		largest = math.Max(largest, math.Abs(v))
+	}
+	if largest == 0 {
+		return
+	}
+	for k, v := range values {
+		values[k] = v / largest
+	}
+}
+
+// diversify lets each source place at most limit items among the first
+// window positions. Items pushed out keep their relative order and follow
+// directly after the window.
+func diversify(items []models.NewsItem, limit, window int) []models.NewsItem {
+	if len(items) <= limit {
+		return items
+	}
+
+	result := make([]models.NewsItem, 0, len(items))
+	var deferred []models.NewsItem
+	perSource := make(map[string]int)
+
+	for i, item := range items {
+		if len(result) >= window {
+			result = append(result, deferred...)
+			return append(result, items[i:]...)
+		}
+		if perSource[item.Source] >= limit {
+			deferred = append(deferred, item)
+			continue
+		}
+		perSource[item.Source]++
+		result = append(result, item)
+	}
+	return append(result, deferred...)
+}
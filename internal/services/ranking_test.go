@@ -0,0 +1,111 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+func TestRankNews(t *testing.T) {
+	service := &NewsService{preferences: models.NewDefaultPreferences()}
+	service.preferences.Ranking = models.RankingSettings{
+		InterestWeights: map[string]float64{"crypto": -4},
+		TagWeights:      map[string]float64{"science": 2},
+		SourcePriority:  map[string]float64{"NRK": 0.5},
+	}
+
+	now := time.Now()
+	items := []models.NewsItem{
+		{ID: "old", Title: "Old news", Source: "BBC", Published: now.Add(-48 * time.Hour)},
+		{ID: "fresh", Title: "Fresh news", Source: "BBC", Published: now},
+		{ID: "crypto", Title: "Crypto prices soar", Source: "BBC", Published: now},
+		{ID: "science", Title: "Research result", Source: "BBC", Published: now.Add(-24 * time.Hour),
+			Tags: []models.Tag{{ID: "science"}}},
+		{ID: "nrk", Title: "Nyheter", Source: "NRK", Published: now.Add(-time.Hour)},
+	}
+
+	ranked := service.RankNews(items, true)
+
+	order := make([]string, len(ranked))
+	for i, item := range ranked {
+		order[i] = item.ID
+		if item.Score == nil {
+			t.Fatalf("Expected score breakdown on %s in debug mode", item.ID)
+		}
+	}
+	expected := []string{"nrk", "science", "fresh", "old", "crypto"}
+	if fmt.Sprint(order) != fmt.Sprint(expected) {
+		t.Errorf("RankNews() order = %v, want %v", order, expected)
+	}
+
+	if ranked[4].Score.Interest >= 0 {
+		t.Errorf("Expected negative interest score for crypto item, got %v", ranked[4].Score.Interest)
+	}
+
+	for _, item := range service.RankNews(items, false) {
+		if item.Score != nil {
+			t.Fatal("Expected no score breakdown outside debug mode")
+		}
+	}
+}
+
+func TestRankNewsEngagement(t *testing.T) {
+	service := &NewsService{preferences: models.NewDefaultPreferences()}
+	service.preferences.ItemStates["x"] = models.ItemState{Starred: true, Source: "Liked", Category: "Science", UpdatedAt: time.Now()}
+	service.preferences.ItemStates["y"] = models.ItemState{Hidden: true, Source: "Disliked", Category: "Sports", UpdatedAt: time.Now()}
+
+	now := time.Now()
+	ranked := service.RankNews([]models.NewsItem{
+		{ID: "1", Source: "Disliked", Category: "Sports", Published: now},
+		{ID: "2", Source: "Liked", Category: "Science", Published: now},
+	}, true)
+
+	if ranked[0].ID != "2" {
+		t.Errorf("Expected item from engaged source first, got %s", ranked[0].ID)
+	}
+	if ranked[1].Score.Engagement >= 0 {
+		t.Errorf("Expected negative engagement for hidden source, got %v", ranked[1].Score.Engagement)
+	}
+}
+
+func TestRankNewsZeroWeight(t *testing.T) {
+	service := &NewsService{preferences: models.NewDefaultPreferences()}
+	// A weight of 0 turns recency off rather than falling back to the default
+	if err := json.Unmarshal([]byte(`{"recencyWeight": 0, "sourcePriority": {"NRK": 1}}`), &service.preferences.Ranking); err != nil {
+		t.Fatalf("Failed to parse settings: %v", err)
+	}
+
+	now := time.Now()
+	ranked := service.RankNews([]models.NewsItem{
+		{ID: "fresh", Source: "BBC", Published: now},
+		{ID: "old", Source: "NRK", Published: now.Add(-72 * time.Hour)},
+	}, true)
+	if ranked[0].ID != "old" || ranked[1].Score.Recency != 0 {
+		t.Errorf("RankNews() = %s first with recency %v, want recency off", ranked[0].ID, ranked[1].Score.Recency)
+	}
+	if ranked[0].Score.Source != *defaultRanking.SourceWeight {
+		t.Errorf("Source score = %v, want the default weight for an unset one", ranked[0].Score.Source)
+	}
+}
+
+func TestDiversify(t *testing.T) {
+	var items []models.NewsItem
+	for i := 0; i < 6; i++ {
+		items = append(items, models.NewsItem{ID: fmt.Sprintf("flood-%d", i), Source: "Flood"})
+	}
+	items = append(items, models.NewsItem{ID: "other-0", Source: "Other"}, models.NewsItem{ID: "other-1", Source: "Other"})
+
+	result := diversify(items, 2, 4)
+
+	order := make([]string, len(result))
+	for i, item := range result {
+		order[i] = item.ID
+	}
+	expected := []string{"flood-0", "flood-1", "other-0", "other-1", "flood-2", "flood-3", "flood-4", "flood-5"}
+	if fmt.Sprint(order) != fmt.Sprint(expected) {
+		t.Errorf("diversify() = %v, want %v", order, expected)
+	}
+}
@@ -0,0 +1,383 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/news-reader/internal/models"
+)
+
+// ErrInvalidSource is wrapped by errors describing a source that cannot be
+// fetched as configured.
+var ErrInvalidSource = errors.New("invalid source")
+
+const (
+	// maxScrapePages bounds how many pages a scrape source may follow.
+	maxScrapePages = 10
+	maxScrapeBody  = 2 * 1024 * 1024
+	// maxPreviewItems is how many items a source test returns.
+	maxPreviewItems = 20
+)
+
+// scrapeField is a compiled selector and the attribute to read from the
+// element it matches. A nil selector stands for the item container itself.
+type scrapeField struct {
+	selector  cascadia.Selector
+	attribute string
+}
+
+// scraper is a compiled ScrapeConfig.
+type scraper struct {
+	item                                        cascadia.Selector
+	title, link, description, date, image, next *scrapeField
+	layout                                      string
+	location                                    *time.Location
+	pages                                       int
+}
+
+func compileScraper(config *models.ScrapeConfig) (*scraper, error) {
+	if config == nil {
+		return nil, fmt.Errorf("%w: scrape sources need selectors", ErrInvalidSource)
+	}
+	if strings.TrimSpace(config.Item) == "" || strings.TrimSpace(config.Title) == "" {
+		return nil, fmt.Errorf("%w: the item and title selectors are required", ErrInvalidSource)
+	}
+	if config.MaxPages < 0 || config.MaxPages > maxScrapePages {
+		return nil, fmt.Errorf("%w: maxPages must be from 0 to %d, 0 reading one page", ErrInvalidSource, maxScrapePages)
+	}
+
+	item, err := cascadia.Compile(config.Item)
+	if err != nil {
+		return nil, fmt.Errorf("%w: item selector %q: %v", ErrInvalidSource, config.Item, err)
+	}
+	s := &scraper{item: item, layout: config.DateFormat, location: time.UTC, pages: config.MaxPages}
+	if s.pages == 0 {
+		s.pages = 1
+	}
+	if config.Timezone != "" {
+		if s.location, err = time.LoadLocation(config.Timezone); err != nil {
+			return nil, fmt.Errorf("%w: unknown time zone %q", ErrInvalidSource, config.Timezone)
+		}
+	}
+
+	for _, field := range []struct {
+		name  string
+		value string
+		dest  **scrapeField
+	}{
+		{"title", config.Title, &s.title},
+		{"link", config.Link, &s.link},
+		{"description", config.Description, &s.description},
+		{"date", config.Date, &s.date},
+		{"image", config.Image, &s.image},
+		{"nextPage", config.NextPage, &s.next},
+	} {
+		if strings.TrimSpace(field.value) == "" {
+			continue
+		}
+		if *field.dest, err = compileScrapeField(field.value); err != nil {
+			return nil, fmt.Errorf("%w: %s selector %q: %v", ErrInvalidSource, field.name, field.value, err)
+		}
+	}
+	return s, nil
+}
+
+// compileScrapeField compiles a selector that may end in @attr. A selector
+// that is only @attr reads the attribute of the item container.
+func compileScrapeField(value string) (*scrapeField, error) {
+	field := &scrapeField{}
+	if i := strings.LastIndex(value, "@"); i >= 0 && isAttributeName(strings.TrimSpace(value[i+1:])) {
+		field.attribute = strings.TrimSpace(value[i+1:])
+		value = value[:i]
+	}
+	if strings.TrimSpace(value) == "" {
+		return field, nil
+	}
+	selector, err := cascadia.Compile(value)
+	if err != nil {
+		return nil, err
+	}
+	field.selector = selector
+	return field, nil
+}
+
+// isAttributeName reports whether name can be an HTML attribute name, so an
+// @ inside a selector such as a[href*="@"] is not taken for a suffix.
+func isAttributeName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == ':') {
+			return false
+		}
+	}
+	return true
+}
+
+// find returns the element the field matches within item.
+func (f *scrapeField) find(item *goquery.Selection) *goquery.Selection {
+	if f.selector == nil {
+		return item
+	}
+	return item.FindMatcher(f.selector).First()
+}
+
+// attr returns the field's own attribute, or else the first of names the
+// element has.
+func (f *scrapeField) attr(item *goquery.Selection, names ...string) string {
+	if f.attribute != "" {
+		names = []string{f.attribute}
+	}
+	element := f.find(item)
+	for _, name := range names {
+		if value, ok := element.Attr(name); ok && strings.TrimSpace(value) != "" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// text returns the field's attribute if it names one, or else the element's
+// text with its whitespace collapsed.
+func (f *scrapeField) text(item *goquery.Selection) string {
+	if f.attribute != "" {
+		return f.attr(item)
+	}
+	return strings.Join(strings.Fields(f.find(item).Text()), " ")
+}
+
+// fetchScrapedPage reads the items of a page without a feed, following its
+// next page links as deep as the source allows.
+func (s *NewsService) fetchScrapedPage(src models.NewsSource) ([]models.NewsItem, error) {
+	scraper, err := compileScraper(src.Scrape)
+	if err != nil {
+		return nil, fmt.Errorf("error in scrape source %s: %v", src.Name, err)
+	}
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+
+	var items []models.NewsItem
+	seen := make(map[string]bool)
+	visited := make(map[string]bool)
+	pageURL := src.URL
+	for page := 1; page <= scraper.pages && pageURL != "" && !visited[pageURL]; page++ {
+		visited[pageURL] = true
+		doc, base, err := fetchScrapeDocument(client, pageURL)
+		if err != nil {
+			if page == 1 {
+				return nil, fmt.Errorf("error fetching page from %s: %v", src.Name, err)
+			}
+			log.Printf("Warning: Stopped scraping %s at page %d: %v", src.Name, page, err)
+			break
+		}
+
+		for _, item := range scraper.items(doc, base, src) {
+			key := item.Link
+			if key == "" {
+				key = item.Title
+			}
+			if !seen[key] {
+				seen[key] = true
+				items = append(items, item)
+			}
+		}
+		pageURL = ""
+		if scraper.next != nil {
+			pageURL = resolveScrapedURL(base, scraper.next.attr(doc.Selection, "href"))
+		}
+	}
+
+	if len(items) == 0 {
+		log.Printf("Warning: No items found on page from %s", src.Name)
+	}
+	return items, nil
+}
+
+// fetchScrapeDocument fetches and parses a page, returning it with the URL
+// its relative links resolve against: the final URL after redirects, or the
+// page's <base href>.
+func fetchScrapeDocument(client *http.Client, pageURL string) (*goquery.Document, *url.URL, error) {
+	req, err := http.NewRequest("GET", pageURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; NewsReader/1.0)")
+	req.Header.Set("Accept", "text/html, application/xhtml+xml")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("received status code %d", resp.StatusCode)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(io.LimitReader(resp.Body, maxScrapeBody))
+	if err != nil {
+		return nil, nil, err
+	}
+	base := resp.Request.URL
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if u, err := base.Parse(strings.TrimSpace(href)); err == nil {
+			base = u
+		}
+	}
+	return doc, base, nil
+}
+
+// items reads every item container on a page. Containers without a title
+// are skipped, and items without a date are left undated for
+// fetchNewsFromSource to give the time they were first seen.
+func (s *scraper) items(doc *goquery.Document, base *url.URL, src models.NewsSource) []models.NewsItem {
+	var items []models.NewsItem
+	doc.FindMatcher(s.item).Each(func(_ int, container *goquery.Selection) {
+		title := s.title.text(container)
+		if title == "" {
+			return
+		}
+
+		item := models.NewsItem{
+			Title:       title,
+			Link:        resolveScrapedURL(base, s.itemLink(container)),
+			Source:      src.Name,
+			Category:    src.Category,
+			ContentType: src.ContentType,
+		}
+		if s.description != nil {
+			item.Description = s.description.text(container)
+		}
+		if s.image != nil {
+			item.Thumbnail = resolveScrapedURL(base, s.image.attr(container, "data-src", "src"))
+		}
+		if s.date != nil {
+			value := s.date.attr(container, "datetime")
+			if value == "" {
+				value = s.date.text(container)
+			}
+			if published, ok := s.parseDate(value); ok {
+				item.Published = published
+			}
+		}
+		items = append(items, item)
+	})
+	return items
+}
+
+// itemLink returns the link selector's href, or by default the link around
+// or in the title, or else the container's own or first link.
+func (s *scraper) itemLink(container *goquery.Selection) string {
+	if s.link != nil {
+		return s.link.attr(container, "href")
+	}
+	title := s.title.find(container)
+	for _, candidate := range []*goquery.Selection{
+		title.Closest("a[href]"),
+		title.Find("a[href]"),
+		container.Closest("a[href]"),
+		container.Find("a[href]"),
+	} {
+		if href, ok := candidate.First().Attr("href"); ok && strings.TrimSpace(href) != "" {
+			return strings.TrimSpace(href)
+		}
+	}
+	return ""
+}
+
+// parseDate reads a date as RFC 3339, as a datetime attribute has it, or in
+// the source's date format and time zone.
+func (s *scraper) parseDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if s.layout != "" {
+		if t, err := time.ParseInLocation(s.layout, value, s.location); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// resolveScrapedURL resolves ref against base, dropping links that are not
+// http or https, such as javascript: and mailto: links.
+func resolveScrapedURL(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
+
+// validateSource checks what can be checked about a source without
+// fetching it.
+func validateSource(src models.NewsSource) error {
+	if src.ContentType == models.TypeScrape {
+		if _, err := compileScraper(src.Scrape); err != nil {
+			return fmt.Errorf("source %s: %w", src.Name, err)
+		}
+	}
+	return nil
+}
+
+// SourceTest is the outcome of fetching a source once without saving it.
+type SourceTest struct {
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	Count      int    `json:"count"`
+	DurationMs int64  `json:"durationMs"`
+	// Items holds the first items as they would be listed, in preview mode.
+	Items []models.NewsItem `json:"items,omitempty"`
+}
+
+// TestSource fetches src the way FetchNews would, without saving it or
+// touching the cache or source health. In preview mode the first items are
+// returned as well, so selectors can be checked before the source is added.
+func (s *NewsService) TestSource(src models.NewsSource, preview bool) (SourceTest, error) {
+	u, err := url.Parse(src.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return SourceTest{}, fmt.Errorf("%w: url must be an http or https URL", ErrInvalidSource)
+	}
+	if err := validateSource(src); err != nil {
+		return SourceTest{}, err
+	}
+	if src.Name == "" {
+		src.Name = u.Host
+	}
+
+	start := time.Now()
+	items, err := s.fetchNewsFromSource(src)
+	result := SourceTest{
+		OK:         err == nil,
+		Count:      len(items),
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	if preview {
+		if len(items) > maxPreviewItems {
+			items = items[:maxPreviewItems]
+		}
+		result.Items = items
+	}
+	return result, nil
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+const scrapePage1 = `<html><head><base href="/nyheter/"></head><body>
+<article class="story">
+  <h2><a href="valg-2024">Stortingsvalget nærmer seg</a></h2>
+  <p class="lead">Partiene   legger fram
+    programmene.</p>
+  <span class="date">06.03.2024 08:30</span>
+  <img data-src="/bilder/valg.jpg" src="data:image/gif;base64,R0lGOD">
+</article>
+<article class="story">
+  <h2>Kommunen bygger ny skole</h2>
+  <a class="more" href="https://example.com/skole">Les mer</a>
+  <time datetime="2024-03-05T12:00:00Z">i går</time>
+</article>
+<article class="story"><p class="lead">No title here</p></article>
+<a class="next" href="?side=2">Neste</a>
+</body></html>`
+
+const scrapePage2 = `<html><body>
+<article class="story">
+  <h2><a href="/nyheter/valg-2024">Stortingsvalget nærmer seg</a></h2>
+</article>
+<article class="story">
+  <h2><a href="javascript:void(0)">Været i helgen</a></h2>
+</article>
+<a class="next" href="/nyheter/?side=3">Neste</a>
+</body></html>`
+
+func newScrapeServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		switch r.URL.Query().Get("side") {
+		case "":
+			w.Write([]byte(scrapePage1))
+		case "2":
+			w.Write([]byte(scrapePage2))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchScrapedPage(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Skipf("No time zone data: %v", err)
+	}
+	server := newScrapeServer(t)
+
+	src := models.NewsSource{
+		Name:        "Lokalavisa",
+		URL:         server.URL + "/nyheter/",
+		Category:    "Local",
+		ContentType: models.TypeScrape,
+		Scrape: &models.ScrapeConfig{
+			Item:        "article.story",
+			Title:       "h2",
+			Description: "p.lead",
+			Date:        ".date, time",
+			DateFormat:  "02.01.2006 15:04",
+			Timezone:    "Europe/Oslo",
+			Image:       "img",
+			NextPage:    "a.next",
+			MaxPages:    5,
+		},
+	}
+	service := &NewsService{}
+	items, err := service.fetchScrapedPage(src)
+	if err != nil {
+		t.Fatalf("Failed to scrape page: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("Expected 3 items over two pages, got %d: %+v", len(items), items)
+	}
+
+	first := items[0]
+	if first.Title != "Stortingsvalget nærmer seg" || first.Link != server.URL+"/nyheter/valg-2024" {
+		t.Errorf("Expected the title and its link resolved against <base>, got %q %q", first.Title, first.Link)
+	}
+	if first.Description != "Partiene legger fram programmene." {
+		t.Errorf("Description = %q, want the whitespace collapsed", first.Description)
+	}
+	if want := time.Date(2024, 3, 6, 8, 30, 0, 0, oslo); !first.Published.Equal(want) {
+		t.Errorf("Published = %v, want %v", first.Published, want)
+	}
+	if first.Thumbnail != server.URL+"/bilder/valg.jpg" {
+		t.Errorf("Thumbnail = %q, want the lazy-loaded image", first.Thumbnail)
+	}
+	if first.Source != "Lokalavisa" || first.Category != "Local" || first.ContentType != models.TypeScrape {
+		t.Errorf("Expected the source's name, category and type, got %+v", first)
+	}
+
+	second := items[1]
+	if second.Link != "https://example.com/skole" {
+		t.Errorf("Link = %q, want the container's first link", second.Link)
+	}
+	if want := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC); !second.Published.Equal(want) {
+		t.Errorf("Published = %v, want the datetime attribute %v", second.Published, want)
+	}
+
+	if third := items[2]; third.Title != "Været i helgen" || third.Link != "" {
+		t.Errorf("Expected the javascript: link dropped, got %+v", third)
+	}
+
+	// Without pagination only the first page is read
+	src.Scrape.MaxPages = 0
+	if items, err := service.fetchScrapedPage(src); err != nil || len(items) != 2 {
+		t.Errorf("Expected 2 items from one page, got %d, %v", len(items), err)
+	}
+}
+
+func TestScrapedFirstSeen(t *testing.T) {
+	server := newScrapeServer(t)
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+	service.preferences.Sources = []models.NewsSource{{
+		Name:        "Lokalavisa",
+		URL:         server.URL + "/nyheter/",
+		ContentType: models.TypeScrape,
+		Enabled:     true,
+		Scrape:      &models.ScrapeConfig{Item: "article.story", Title: "h2"},
+	}}
+
+	// Items without a date keep the time they were first fetched
+	first := service.FetchNews()
+	if len(first) != 2 || first[0].Published.IsZero() {
+		t.Fatalf("Expected 2 items given a time, got %+v", first)
+	}
+	time.Sleep(10 * time.Millisecond)
+	byID := make(map[string]time.Time)
+	for _, item := range service.FetchNews() {
+		byID[item.ID] = item.Published
+	}
+	for _, item := range first {
+		if !byID[item.ID].Equal(item.Published) {
+			t.Errorf("Published = %v after a refetch, want %v", byID[item.ID], item.Published)
+		}
+	}
+}
+
+func TestCompileScraper(t *testing.T) {
+	field, err := compileScrapeField(`a[href*="@"]@data-url`)
+	if err != nil || field.attribute != "data-url" || field.selector == nil {
+		t.Errorf("compileScrapeField() = %+v, %v, want the data-url attribute", field, err)
+	}
+	field, err = compileScrapeField(`a[href*="@"]`)
+	if err != nil || field.attribute != "" {
+		t.Errorf("compileScrapeField() = %+v, %v, want no attribute", field, err)
+	}
+	field, err = compileScrapeField("@href")
+	if err != nil || field.selector != nil || field.attribute != "href" {
+		t.Errorf("compileScrapeField() = %+v, %v, want the container's href", field, err)
+	}
+
+	tests := []struct {
+		name   string
+		config *models.ScrapeConfig
+	}{
+		{"no config", nil},
+		{"no item", &models.ScrapeConfig{Title: "h2"}},
+		{"no title", &models.ScrapeConfig{Item: "article"}},
+		{"bad item", &models.ScrapeConfig{Item: "article[", Title: "h2"}},
+		{"bad link", &models.ScrapeConfig{Item: "article", Title: "h2", Link: "a[href"}},
+		{"bad time zone", &models.ScrapeConfig{Item: "article", Title: "h2", Timezone: "Mars/Olympus"}},
+		{"too many pages", &models.ScrapeConfig{Item: "article", Title: "h2", MaxPages: maxScrapePages + 1}},
+	}
+	for _, tt := range tests {
+		if _, err := compileScraper(tt.config); !errors.Is(err, ErrInvalidSource) {
+			t.Errorf("%s: compileScraper() error = %v, want ErrInvalidSource", tt.name, err)
+		}
+	}
+}
+
+func TestTestSource(t *testing.T) {
+	server := newScrapeServer(t)
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+
+	src := models.NewsSource{
+		URL:         server.URL + "/nyheter/",
+		ContentType: models.TypeScrape,
+		Scrape:      &models.ScrapeConfig{Item: "article.story", Title: "h2"},
+	}
+	result, err := service.TestSource(src, true)
+	if err != nil {
+		t.Fatalf("Failed to test source: %v", err)
+	}
+	if !result.OK || result.Count != 2 || len(result.Items) != 2 || result.Items[0].ID == "" {
+		t.Errorf("Expected 2 previewed items with IDs, got %+v", result)
+	}
+	if result, _ := service.TestSource(src, false); len(result.Items) != 0 {
+		t.Errorf("Expected no items outside preview mode, got %d", len(result.Items))
+	}
+
+	src.URL = server.URL + "/nyheter/?side=9"
+	if result, err := service.TestSource(src, true); err != nil || result.OK || result.Error == "" {
+		t.Errorf("Expected a failed test, got %+v, %v", result, err)
+	}
+
+	src.Scrape = nil
+	if _, err := service.TestSource(src, true); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("TestSource() error = %v, want ErrInvalidSource", err)
+	}
+	prefs := *service.GetPreferences()
+	prefs.Sources = append(prefs.Sources, src)
+	if err := service.UpdatePreferences(prefs); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("UpdatePreferences() error = %v, want ErrInvalidSource", err)
+	}
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/textanalysis"
+)
+
+// SearchNews returns the items whose title or description contains every word
+// of query. Both sides are analysed in the item's language, so "valget"
+// finds "valg" and stop words in the query are ignored unless the query has
+// nothing else.
+func (s *NewsService) SearchNews(items []models.NewsItem, query string) []models.NewsItem {
+	if len(textanalysis.Words(query)) == 0 {
+		return items
+	}
+
+	matched := []models.NewsItem{}
+	for _, item := range items {
+		analyzer := textanalysis.For(item.Language)
+		terms := searchTerms(analyzer, query)
+
+		found := make(map[string]bool)
+		for _, token := range analyzer.Analyze(itemText(&item)) {
+			found[token.Stem] = true
+		}
+
+		all := true
+		for _, term := range terms {
+			if !found[term] {
+				all = false
+				break
+			}
+		}
+		if all {
+			matched = append(matched, item)
+		}
+	}
+	return matched
+}
+
+// searchTerms returns the stems query must match in text of the analyzer's
+// language.
+func searchTerms(analyzer *textanalysis.Analyzer, query string) []string {
+	if terms := analyzer.Terms(query); len(terms) > 0 {
+		return terms
+	}
+	var terms []string
+	for _, token := range analyzer.Analyze(query) {
+		terms = append(terms, token.Stem)
+	}
+	return terms
+}
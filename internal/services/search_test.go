@@ -0,0 +1,48 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/news-reader/internal/models"
+)
+
+func TestSearchNews(t *testing.T) {
+	service := &NewsService{}
+	items := []models.NewsItem{
+		{ID: "no", Title: "Regjeringen taper valget", Language: "norwegian-bokmal"},
+		{ID: "en", Title: "Elections in Norway", Description: "The government lost", Language: "english"},
+		{ID: "de", Title: "Die Wahlen in Bayern", Language: "german"},
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"valg", []string{"no"}},
+		{"regjering valgene", []string{"no"}},
+		{"election", []string{"en"}},
+		{"the elections", []string{"en"}},
+		{"Norway's election", []string{"en"}},
+		{"wahl", []string{"de"}},
+		{"the", []string{"en"}},
+		{"valg bayern", nil},
+		{"", []string{"no", "en", "de"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var ids []string
+			for _, item := range service.SearchNews(items, tt.query) {
+				ids = append(ids, item.ID)
+			}
+			if len(ids) != len(tt.expected) {
+				t.Fatalf("SearchNews(%q) = %v, want %v", tt.query, ids, tt.expected)
+			}
+			for i := range ids {
+				if ids[i] != tt.expected[i] {
+					t.Errorf("SearchNews(%q) = %v, want %v", tt.query, ids, tt.expected)
+				}
+			}
+		})
+	}
+}
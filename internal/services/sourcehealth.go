@@ -0,0 +1,110 @@
+package services
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+// SourceHealth is the outcome of the latest fetches of one feed.
+type SourceHealth struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Healthy bool   `json:"healthy"`
+	// LastError is the error of the latest fetch, if it failed.
+	LastError   string    `json:"lastError,omitempty"`
+	LastAttempt time.Time `json:"lastAttempt"`
+	LastSuccess time.Time `json:"lastSuccess,omitempty"`
+	// Failures counts the failed fetches since the last success.
+	Failures int `json:"failures"`
+	Items    int `json:"items"`
+}
+
+// sourceHealthTracker keeps the health of every feed, keyed like the news
+// cache.
+type sourceHealthTracker struct {
+	mu      sync.Mutex
+	sources map[string]*SourceHealth
+}
+
+func newSourceHealthTracker() *sourceHealthTracker {
+	return &sourceHealthTracker{sources: make(map[string]*SourceHealth)}
+}
+
+// record stores the outcome of fetching src and reports whether the feed
+// turned healthy or unhealthy. A feed seen for the first time only counts as
+// changed when its fetch failed.
+func (t *sourceHealthTracker) record(key string, src models.NewsSource, items int, err error, now time.Time) (SourceHealth, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	health, known := t.sources[key]
+	if !known {
+		health = &SourceHealth{Healthy: true}
+		t.sources[key] = health
+	}
+	wasHealthy := health.Healthy
+
+	health.Name = src.Name
+	health.URL = src.URL
+	health.LastAttempt = now
+	if err != nil {
+		health.Healthy = false
+		health.LastError = err.Error()
+		health.Failures++
+	} else {
+		health.Healthy = true
+		health.LastError = ""
+		health.LastSuccess = now
+		health.Failures = 0
+		health.Items = items
+	}
+	return *health, health.Healthy != wasHealthy
+}
+
+// recordSourceHealth stores the outcome of fetching a feed and announces it
+// on the stream when the feed turned healthy or unhealthy.
+func (s *NewsService) recordSourceHealth(group feedGroup, items int, err error) {
+	if s.health == nil {
+		return
+	}
+	health, changed := s.health.record(group.key, group.primary, items, err, time.Now())
+	if changed {
+		s.stream.publish(StreamSource, health)
+	}
+}
+
+// forget drops the feeds whose keys are not in keys.
+func (t *sourceHealthTracker) forget(keys map[string]bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key := range t.sources {
+		if !keys[key] {
+			delete(t.sources, key)
+		}
+	}
+}
+
+// SourceHealth returns the health of every fetched feed, unhealthy ones
+// first.
+func (s *NewsService) SourceHealth() []SourceHealth {
+	health := []SourceHealth{}
+	if s.health == nil {
+		return health
+	}
+	s.health.mu.Lock()
+	for _, h := range s.health.sources {
+		health = append(health, *h)
+	}
+	s.health.mu.Unlock()
+
+	sort.Slice(health, func(i, j int) bool {
+		if health[i].Healthy != health[j].Healthy {
+			return !health[i].Healthy
+		}
+		return health[i].Name < health[j].Name
+	})
+	return health
+}
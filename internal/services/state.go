@@ -0,0 +1,203 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+// Item states that only record "read" are dropped after this long so the
+// preferences file does not grow without bound. Starred and hidden items are
+// kept until the user clears them.
+const readStateRetention = 90 * 24 * time.Hour
+
+// MarkReadOptions selects the cached items affected by MarkAllRead. Empty
+// fields match everything.
+type MarkReadOptions struct {
+	Source   string    `json:"source"`
+	Category string    `json:"category"`
+	Before   time.Time `json:"before"`
+}
+
+// UnreadCounts summarises unread items per source and per category.
+type UnreadCounts struct {
+	Total      int            `json:"total"`
+	BySource   map[string]int `json:"bySource"`
+	ByCategory map[string]int `json:"byCategory"`
+}
+
+// SetItemState sets one state flag on every item in ids.
+func (s *NewsService) SetItemState(ids []string, field models.StateField, value bool) error {
+	switch field {
+	case models.StateRead, models.StateStarred, models.StateHidden:
+	default:
+		return fmt.Errorf("unknown item state: %s", field)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cached := s.cachedItemsByID()
+	now := time.Now()
+	for _, id := range ids {
+		state := s.preferences.ItemStates[id]
+		if item, ok := cached[id]; ok {
+			state.Source = item.Source
+			state.Category = item.Category
+		}
+
+		switch field {
+		case models.StateRead:
+			state.Read = value
+		case models.StateStarred:
+			state.Starred = value
+		case models.StateHidden:
+			state.Hidden = value
+		}
+		s.putItemState(id, state, now)
+	}
+
+	s.pruneItemStates(now)
+	return s.savePreferences()
+}
+
+// MarkAllRead marks every cached item matching opts as read and returns the
+// number of items that changed.
+func (s *NewsService) MarkAllRead(opts MarkReadOptions) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	marked := 0
+	for _, items := range s.newsCache {
+		for _, item := range items {
+			if opts.Source != "" && !item.HasSource(opts.Source) {
+				continue
+			}
+			if opts.Category != "" && !item.HasCategory(opts.Category) {
+				continue
+			}
+			if !opts.Before.IsZero() && !item.Published.Before(opts.Before) {
+				continue
+			}
+
+			state := s.preferences.ItemStates[item.ID]
+			if state.Read {
+				continue
+			}
+			state.Read = true
+			state.Source = item.Source
+			state.Category = item.Category
+			s.putItemState(item.ID, state, now)
+			marked++
+		}
+	}
+
+	if marked == 0 {
+		return 0, nil
+	}
+	s.pruneItemStates(now)
+	return marked, s.savePreferences()
+}
+
+// ApplyItemState copies the stored read, starred and hidden flags onto items.
+func (s *NewsService) ApplyItemState(items []models.NewsItem) []models.NewsItem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := range items {
+		state := s.preferences.ItemStates[items[i].ID]
+		items[i].Read = state.Read
+		items[i].Starred = state.Starred
+		items[i].Hidden = state.Hidden
+	}
+	return items
+}
+
+// FilterByState keeps the items matching state, which is one of "unread",
+// "read", "starred" or "hidden". Hidden items are dropped for every state
+// except "hidden". Items must have been passed through ApplyItemState.
+func (s *NewsService) FilterByState(items []models.NewsItem, state string) ([]models.NewsItem, error) {
+	var keep func(models.NewsItem) bool
+	switch state {
+	case "", "all":
+		keep = func(item models.NewsItem) bool { return !item.Hidden }
+	case "unread":
+		keep = func(item models.NewsItem) bool { return !item.Hidden && !item.Read }
+	case "read":
+		keep = func(item models.NewsItem) bool { return !item.Hidden && item.Read }
+	case "starred":
+		keep = func(item models.NewsItem) bool { return !item.Hidden && item.Starred }
+	case "hidden":
+		keep = func(item models.NewsItem) bool { return item.Hidden }
+	default:
+		return nil, fmt.Errorf("unknown state filter: %s", state)
+	}
+
+	filtered := []models.NewsItem{}
+	for _, item := range items {
+		if keep(item) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered, nil
+}
+
+// CountUnread counts the unread, non-hidden items per source and category.
+// Items must have been passed through ApplyItemState.
+func (s *NewsService) CountUnread(items []models.NewsItem) UnreadCounts {
+	counts := UnreadCounts{
+		BySource:   make(map[string]int),
+		ByCategory: make(map[string]int),
+	}
+	for _, item := range items {
+		if item.Read || item.Hidden {
+			continue
+		}
+		counts.Total++
+		for _, source := range item.AllSources() {
+			counts.BySource[source]++
+		}
+		for _, category := range item.AllCategories() {
+			counts.ByCategory[category]++
+		}
+	}
+	return counts
+}
+
+// putItemState stores state for id, dropping it when no flag is left set.
+// Callers must hold s.mu.
+func (s *NewsService) putItemState(id string, state models.ItemState, now time.Time) {
+	if s.preferences.ItemStates == nil {
+		s.preferences.ItemStates = make(map[string]models.ItemState)
+	}
+
+	if !state.Read && !state.Starred && !state.Hidden {
+		delete(s.preferences.ItemStates, id)
+	} else {
+		state.UpdatedAt = now
+		s.preferences.ItemStates[id] = state
+	}
+}
+
+// pruneItemStates drops read-only entries older than readStateRetention.
+// Callers must hold s.mu.
+func (s *NewsService) pruneItemStates(now time.Time) {
+	for id, state := range s.preferences.ItemStates {
+		if !state.Starred && !state.Hidden && now.Sub(state.UpdatedAt) > readStateRetention {
+			delete(s.preferences.ItemStates, id)
+		}
+	}
+}
+
+// cachedItemsByID indexes the news cache by item ID. Callers must hold s.mu.
+func (s *NewsService) cachedItemsByID() map[string]models.NewsItem {
+	byID := make(map[string]models.NewsItem)
+	for _, items := range s.newsCache {
+		for _, item := range items {
+			byID[item.ID] = item
+		}
+	}
+	return byID
+}
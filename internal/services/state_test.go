@@ -0,0 +1,117 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+func newStateTestService(t *testing.T) *NewsService {
+	t.Helper()
+
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+
+	now := time.Now()
+	service.newsCache["NRK"] = []models.NewsItem{
+		{ID: "a", Title: "Valg i Norge", Source: "NRK", Category: "General", Published: now.Add(-3 * time.Hour)},
+		{ID: "b", Title: "Fotball", Source: "NRK", SourceNames: []string{"NRK", "NRK Sport"}, Category: "Sports", Published: now.Add(-time.Hour)},
+	}
+	service.newsCache["Guardian"] = []models.NewsItem{
+		{ID: "c", Title: "Election news", Source: "Guardian", Category: "General", Published: now},
+	}
+	return service
+}
+
+func TestSetItemState(t *testing.T) {
+	service := newStateTestService(t)
+
+	if err := service.SetItemState([]string{"a", "c"}, models.StateStarred, true); err != nil {
+		t.Fatalf("SetItemState() error = %v", err)
+	}
+	if err := service.SetItemState([]string{"b"}, models.StateHidden, true); err != nil {
+		t.Fatalf("SetItemState() error = %v", err)
+	}
+	if err := service.SetItemState([]string{"a"}, "bogus", true); err == nil {
+		t.Error("Expected error for unknown state")
+	}
+
+	state := service.preferences.ItemStates["a"]
+	if !state.Starred || state.Source != "NRK" || state.Category != "General" {
+		t.Errorf("Unexpected state for item a: %+v", state)
+	}
+
+	items := service.ApplyItemState(service.GetAllNews())
+	starred, err := service.FilterByState(items, "starred")
+	if err != nil {
+		t.Fatalf("FilterByState() error = %v", err)
+	}
+	if len(starred) != 2 {
+		t.Errorf("Expected 2 starred items, got %d", len(starred))
+	}
+
+	visible, _ := service.FilterByState(items, "")
+	if len(visible) != 2 {
+		t.Errorf("Expected hidden item to be dropped, got %d items", len(visible))
+	}
+
+	// Clearing the last flag removes the entry altogether
+	if err := service.SetItemState([]string{"c"}, models.StateStarred, false); err != nil {
+		t.Fatalf("SetItemState() error = %v", err)
+	}
+	if _, ok := service.preferences.ItemStates["c"]; ok {
+		t.Error("Expected state for item c to be removed")
+	}
+}
+
+func TestMarkAllRead(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     MarkReadOptions
+		expected int
+	}{
+		{name: "By source", opts: MarkReadOptions{Source: "NRK"}, expected: 2},
+		{name: "By secondary source", opts: MarkReadOptions{Source: "NRK Sport"}, expected: 1},
+		{name: "By category", opts: MarkReadOptions{Category: "General"}, expected: 2},
+		{name: "Before timestamp", opts: MarkReadOptions{Before: time.Now().Add(-30 * time.Minute)}, expected: 2},
+		{name: "Everything", opts: MarkReadOptions{}, expected: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newStateTestService(t)
+
+			marked, err := service.MarkAllRead(tt.opts)
+			if err != nil {
+				t.Fatalf("MarkAllRead() error = %v", err)
+			}
+			if marked != tt.expected {
+				t.Errorf("MarkAllRead() = %d, want %d", marked, tt.expected)
+			}
+
+			items := service.ApplyItemState(service.GetAllNews())
+			counts := service.CountUnread(items)
+			if counts.Total != 3-tt.expected {
+				t.Errorf("Expected %d unread items, got %d", 3-tt.expected, counts.Total)
+			}
+		})
+	}
+}
+
+func TestCountUnread(t *testing.T) {
+	service := newStateTestService(t)
+	if err := service.SetItemState([]string{"a"}, models.StateRead, true); err != nil {
+		t.Fatalf("SetItemState() error = %v", err)
+	}
+
+	counts := service.CountUnread(service.ApplyItemState(service.GetAllNews()))
+	if counts.BySource["NRK"] != 1 || counts.BySource["NRK Sport"] != 1 || counts.BySource["Guardian"] != 1 {
+		t.Errorf("Unexpected per-source counts: %v", counts.BySource)
+	}
+	if counts.ByCategory["General"] != 1 || counts.ByCategory["Sports"] != 1 {
+		t.Errorf("Unexpected per-category counts: %v", counts.ByCategory)
+	}
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+// Stream event types.
+const (
+	StreamItem     = "item"
+	StreamSource   = "source"
+	StreamTrending = "trending"
+	StreamBreaking = "breaking"
+)
+
+const (
+	// streamBufferSize is how many events are kept for clients resuming
+	// with Last-Event-ID.
+	streamBufferSize = 1000
+	// subscriberBufferSize is how many events a client may fall behind by
+	// before it is dropped; it then resumes from the buffer on reconnect.
+	subscriberBufferSize = 256
+)
+
+// StreamEvent is something that happened to the news, as pushed to stream
+// clients. Data is a models.NewsItem for StreamItem, a SourceHealth for
+// StreamSource, a TrendingResult for StreamTrending and a BreakingEvent for
+// StreamBreaking.
+type StreamEvent struct {
+	ID   string
+	Type string
+	Data interface{}
+}
+
+// streamHub fans events out to subscribers and keeps the latest ones so
+// clients can resume. Event IDs are the hub's epoch and a sequence number,
+// so IDs from before a restart are recognised.
+type streamHub struct {
+	mu          sync.Mutex
+	epoch       string
+	seq         uint64
+	buffer      []StreamEvent
+	subscribers map[chan StreamEvent]bool
+
+	// known holds the IDs of the items already announced; primed is set
+	// once the items of the first fetch have been taken as known.
+	known  map[string]bool
+	primed bool
+	// trending is the ETag of the trending topics last announced.
+	trending string
+}
+
+func newStreamHub() *streamHub {
+	return &streamHub{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		subscribers: make(map[chan StreamEvent]bool),
+		known:       make(map[string]bool),
+	}
+}
+
+// publish sends an event to every subscriber, dropping those that have
+// fallen too far behind.
+func (h *streamHub) publish(eventType string, data interface{}) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	event := StreamEvent{ID: fmt.Sprintf("%s-%d", h.epoch, h.seq), Type: eventType, Data: data}
+	h.buffer = append(h.buffer, event)
+	if len(h.buffer) > streamBufferSize {
+		h.buffer = h.buffer[len(h.buffer)-streamBufferSize:]
+	}
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe registers a stream client. The events after lastEventID that
+// are still buffered are returned to be sent first; a client with an ID from
+// before a restart gets the whole buffer, a new client none of it. The
+// channel is closed when the client falls too far behind or cancel is
+// called.
+func (s *NewsService) Subscribe(lastEventID string) ([]StreamEvent, <-chan StreamEvent, func()) {
+	h := s.stream
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var backlog []StreamEvent
+	if lastEventID != "" {
+		epoch, seq, _ := strings.Cut(lastEventID, "-")
+		n, err := strconv.ParseUint(seq, 10, 64)
+		for _, event := range h.buffer {
+			_, eventSeq, _ := strings.Cut(event.ID, "-")
+			m, _ := strconv.ParseUint(eventSeq, 10, 64)
+			if epoch != h.epoch || err != nil || m > n {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+
+	ch := make(chan StreamEvent, subscriberBufferSize)
+	h.subscribers[ch] = true
+	cancel := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.subscribers[ch] {
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+	return backlog, ch, cancel
+}
+
+// publishUpdates announces the items that entered the cache since the last
+// fetch and the trending topics if they changed. The items of the first
+// fetch are only taken as known, not announced.
+func (s *NewsService) publishUpdates(items []models.NewsItem) {
+	h := s.stream
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	var added []models.NewsItem
+	current := make(map[string]bool, len(items))
+	for _, item := range items {
+		current[item.ID] = true
+		if !h.known[item.ID] && h.primed {
+			added = append(added, item)
+		}
+	}
+	h.known = current
+	h.primed = true
+	h.mu.Unlock()
+
+	// Oldest first, so the latest event is the newest item
+	sort.SliceStable(added, func(i, j int) bool {
+		return added[i].Published.Before(added[j].Published)
+	})
+	for _, item := range added {
+		h.publish(StreamItem, item)
+	}
+
+	if s.trendIndex == nil {
+		return
+	}
+	trending := s.TrendingTopics(TrendingOptions{}, "")
+	h.mu.Lock()
+	changed := trending.ETag != h.trending
+	h.trending = trending.ETag
+	h.mu.Unlock()
+	if changed {
+		h.publish(StreamTrending, trending)
+	}
+}
+
+// StreamItemMatches applies the filters of /api/news to one streamed item:
+// the preferences, the q search and the state filter. It returns the item
+// with its read, starred and hidden flags set.
+func (s *NewsService) StreamItemMatches(item models.NewsItem, query, state string) (models.NewsItem, bool) {
+	items := s.ApplyItemState(s.FilterNews([]models.NewsItem{item}))
+	if query != "" {
+		items = s.SearchNews(items, query)
+	}
+	items, err := s.FilterByState(items, state)
+	if err != nil || len(items) == 0 {
+		return models.NewsItem{}, false
+	}
+	return items[0], true
+}
+
+// StartRefresh fetches the news every interval in the background, so stream
+// clients hear of new items without anyone polling, until stop is called.
+func (s *NewsService) StartRefresh(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.FetchNews()
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
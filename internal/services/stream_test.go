@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+func TestStreamHub(t *testing.T) {
+	service := &NewsService{stream: newStreamHub()}
+	h := service.stream
+
+	h.publish(StreamSource, "first")
+	h.publish(StreamSource, "second")
+	first := h.buffer[0].ID
+
+	backlog, events, cancel := service.Subscribe(first)
+	if len(backlog) != 1 || backlog[0].Data != "second" {
+		t.Errorf("Subscribe(%q) backlog = %+v, want the second event", first, backlog)
+	}
+	if backlog, _, stop := service.Subscribe(""); len(backlog) != 0 {
+		t.Errorf("Subscribe(\"\") backlog = %+v, want none", backlog)
+	} else {
+		stop()
+	}
+	if backlog, _, stop := service.Subscribe("oldepoch-5"); len(backlog) != 2 {
+		t.Errorf("Subscribe() with an ID from before a restart = %+v, want the whole buffer", backlog)
+	} else {
+		stop()
+	}
+
+	h.publish(StreamTrending, "third")
+	if event := <-events; event.Data != "third" || event.Type != StreamTrending {
+		t.Errorf("Received %+v, want the third event", event)
+	}
+
+	// A subscriber that falls behind is dropped
+	for i := 0; i <= subscriberBufferSize; i++ {
+		h.publish(StreamSource, i)
+	}
+	received := 0
+	for range events {
+		received++
+	}
+	if received != subscriberBufferSize {
+		t.Errorf("Received %d events before being dropped, want %d", received, subscriberBufferSize)
+	}
+	cancel()
+
+	for i := 0; i < streamBufferSize; i++ {
+		h.publish(StreamSource, i)
+	}
+	if len(h.buffer) != streamBufferSize {
+		t.Errorf("Buffer holds %d events, want %d", len(h.buffer), streamBufferSize)
+	}
+}
+
+func TestPublishUpdates(t *testing.T) {
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+	now := time.Now()
+	items := []models.NewsItem{
+		{ID: "a", Title: "Storting passes new budget", Published: now.Add(-time.Hour)},
+	}
+
+	_, events, cancel := service.Subscribe("")
+	defer cancel()
+
+	// The first fetch only primes the hub
+	service.publishUpdates(items)
+	items = append(items,
+		models.NewsItem{ID: "c", Title: "Ferry strike ends", Published: now},
+		models.NewsItem{ID: "b", Title: "Storm closes roads", Published: now.Add(-time.Minute)},
+	)
+	service.publishUpdates(items)
+	service.publishUpdates(items)
+
+	var got []string
+	for len(events) > 0 {
+		event := <-events
+		if event.Type == StreamItem {
+			got = append(got, event.Data.(models.NewsItem).ID)
+		}
+	}
+	if len(got) != 2 || got[0] != "b" || got[1] != "c" {
+		t.Errorf("Announced items %v, want [b c]", got)
+	}
+}
+
+func TestSourceHealth(t *testing.T) {
+	tracker := newSourceHealthTracker()
+	src := models.NewsSource{Name: "Test", URL: "https://example.com/feed"}
+	now := time.Now()
+
+	if _, changed := tracker.record("k", src, 3, nil, now); changed {
+		t.Errorf("record() of a first success reported a change")
+	}
+	health, changed := tracker.record("k", src, 0, errors.New("timeout"), now)
+	if !changed || health.Healthy || health.Failures != 1 || health.LastError != "timeout" || health.Items != 3 {
+		t.Errorf("record() of a failure = %+v, %v, want an unhealthy change", health, changed)
+	}
+	if _, changed := tracker.record("k", src, 0, errors.New("timeout"), now); changed {
+		t.Errorf("record() of a second failure reported a change")
+	}
+	health, changed = tracker.record("k", src, 4, nil, now)
+	if !changed || !health.Healthy || health.Failures != 0 || health.Items != 4 {
+		t.Errorf("record() of a recovery = %+v, %v, want a healthy change", health, changed)
+	}
+
+	tracker.forget(map[string]bool{})
+	if len(tracker.sources) != 0 {
+		t.Errorf("forget() kept %d sources, want none", len(tracker.sources))
+	}
+}
@@ -0,0 +1,361 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/textanalysis"
+)
+
+var (
+	// ErrTagNotFound is returned when a tag ID matches no system or user tag.
+	ErrTagNotFound = errors.New("tag not found")
+	// ErrInvalidTagRule is wrapped by errors describing a rule that cannot
+	// be compiled.
+	ErrInvalidTagRule = errors.New("invalid tag rule")
+)
+
+// defaultTagsByID indexes the built-in tags.
+var defaultTagsByID = func() map[string]models.Tag {
+	byID := make(map[string]models.Tag, len(models.DefaultTags))
+	for _, tag := range models.DefaultTags {
+		byID[tag.ID] = tag
+	}
+	return byID
+}()
+
+// compiledTag is a tag with its rules ready to run against items.
+type compiledTag struct {
+	tag   models.Tag
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	// keywords are normalised with matchText and padded with spaces, so a
+	// substring match is a whole-word match.
+	keywords   []string
+	patterns   []*regexp.Regexp
+	sources    []string
+	categories []string
+	languages  map[string]bool
+}
+
+// tagText is an item's text in the two forms rules match against.
+type tagText struct {
+	raw   string
+	words string
+	// hits holds the keywords found by a keywordIndex scan. Without it,
+	// keywords are searched for one at a time.
+	hits map[string]bool
+}
+
+func newTagText(item *models.NewsItem) tagText {
+	raw := itemText(item)
+	return tagText{raw: raw, words: matchText(raw)}
+}
+
+// itemText is the text tagging and region detection look at.
+func itemText(item *models.NewsItem) string {
+	return item.Title + " " + item.Description
+}
+
+// has reports whether the text contains a keyword normalised by matchText.
+func (t tagText) has(keyword string) bool {
+	if t.hits != nil {
+		return t.hits[keyword]
+	}
+	return strings.Contains(t.words, keyword)
+}
+
+// matchText lowercases text and reduces it to its words separated by single
+// spaces, with a space at either end.
+func matchText(text string) string {
+	words := textanalysis.Words(text)
+	for i := range words {
+		words[i] = strings.ToLower(words[i])
+	}
+	return " " + strings.Join(words, " ") + " "
+}
+
+func compileRule(rule models.TagRule) (compiledRule, error) {
+	var compiled compiledRule
+	for _, keyword := range rule.Keywords {
+		if normalized := matchText(keyword); normalized != "  " {
+			compiled.keywords = append(compiled.keywords, normalized)
+		}
+	}
+	for _, pattern := range rule.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return compiledRule{}, fmt.Errorf("%w: pattern %q: %v", ErrInvalidTagRule, pattern, err)
+		}
+		compiled.patterns = append(compiled.patterns, re)
+	}
+	compiled.sources = rule.Sources
+	compiled.categories = rule.Categories
+	if len(rule.Languages) > 0 {
+		compiled.languages = make(map[string]bool)
+		for _, lang := range rule.Languages {
+			id := languageFromCode(lang)
+			if id == "" {
+				return compiledRule{}, fmt.Errorf("%w: unknown language %q", ErrInvalidTagRule, lang)
+			}
+			compiled.languages[id] = true
+		}
+	}
+	return compiled, nil
+}
+
+// compileTag compiles every rule of tag. A user tag without rules matches its
+// name as a whole word.
+func compileTag(tag models.Tag) (compiledTag, error) {
+	rules := tag.Rules
+	if len(rules) == 0 && tag.Category == "user" && strings.TrimSpace(tag.Name) != "" {
+		rules = []models.TagRule{{Keywords: []string{tag.Name}}}
+	}
+
+	compiled := compiledTag{tag: tag}
+	compiled.tag.Rules = nil
+	for _, rule := range rules {
+		cr, err := compileRule(rule)
+		if err != nil {
+			return compiledTag{}, err
+		}
+		compiled.rules = append(compiled.rules, cr)
+	}
+	return compiled, nil
+}
+
+func (t compiledTag) matches(item *models.NewsItem, text tagText) bool {
+	for _, rule := range t.rules {
+		if rule.matches(item, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r compiledRule) matches(item *models.NewsItem, text tagText) bool {
+	if len(r.sources) > 0 {
+		found := false
+		for _, source := range r.sources {
+			if item.HasSource(source) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.categories) > 0 {
+		found := false
+		for _, category := range r.categories {
+			if item.HasCategory(category) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.languages != nil && !r.languages[item.Language] {
+		return false
+	}
+
+	if len(r.keywords) == 0 && len(r.patterns) == 0 {
+		// Constraints alone select items; a rule with nothing selects none
+		return len(r.sources) > 0 || len(r.categories) > 0 || r.languages != nil
+	}
+	for _, keyword := range r.keywords {
+		if text.has(keyword) {
+			return true
+		}
+	}
+	for _, re := range r.patterns {
+		if re.MatchString(text.raw) {
+			return true
+		}
+	}
+	return false
+}
+
+// systemTags returns the built-in tags with the user's rule overrides.
+func (s *NewsService) systemTags() []models.Tag {
+	tags := make([]models.Tag, len(models.DefaultTags))
+	copy(tags, models.DefaultTags)
+	if s.preferences == nil {
+		return tags
+	}
+	for i := range tags {
+		if rules, ok := s.preferences.SystemTagRules[tags[i].ID]; ok {
+			tags[i].Rules = rules
+		}
+	}
+	return tags
+}
+
+// tagMatchers returns the system and user tags that have rules, and the
+// keyword index covering their keywords and the gazetteer. Both are built
+// once per change of preferences. Tags whose rules do not compile are
+// skipped with a log message.
+func (s *NewsService) tagMatchers() ([]compiledTag, *keywordIndex) {
+	s.tagsMu.Lock()
+	defer s.tagsMu.Unlock()
+
+	if s.tagIndex != nil {
+		return s.tagRules, s.tagIndex
+	}
+
+	tags := s.systemTags()
+	if s.preferences != nil {
+		tags = append(tags, s.preferences.Tags...)
+	}
+
+	s.tagRules = []compiledTag{}
+	for _, tag := range tags {
+		compiled, err := compileTag(tag)
+		if err != nil {
+			log.Printf("Skipping rules for tag %s: %v", tag.ID, err)
+			continue
+		}
+		if len(compiled.rules) > 0 {
+			s.tagRules = append(s.tagRules, compiled)
+		}
+	}
+	s.tagIndex = newKeywordIndex(s.tagRules)
+	return s.tagRules, s.tagIndex
+}
+
+// invalidateTagRules makes the next tagMatchers call recompile the rules and
+// the classifier retrain its tag models.
+func (s *NewsService) invalidateTagRules() {
+	s.tagsMu.Lock()
+	s.tagRules = nil
+	s.tagIndex = nil
+	s.tagsMu.Unlock()
+	s.classifier.invalidate()
+}
+
+// applyTagRules adds every tag whose rules match item and that the item does
+// not carry yet.
+func (s *NewsService) applyTagRules(item *models.NewsItem) {
+	tags, index := s.tagMatchers()
+	applyCompiledTags(item, tags, index.scan(itemText(item)).text)
+}
+
+func applyCompiledTags(item *models.NewsItem, tags []compiledTag, text tagText) {
+	for _, tag := range tags {
+		if hasTag(item, tag.tag.ID) || !tag.matches(item, text) {
+			continue
+		}
+		item.Tags = append(item.Tags, tag.tag)
+	}
+}
+
+// FindTag returns the system or user tag id.
+func (s *NewsService) FindTag(id string) (models.Tag, bool) {
+	if tag, ok := defaultTagsByID[id]; ok {
+		return tag, true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.userTag(id)
+}
+
+// NewsWithTag returns the items tagged id.
+func NewsWithTag(items []models.NewsItem, id string) []models.NewsItem {
+	tagged := []models.NewsItem{}
+	for i := range items {
+		if hasTag(&items[i], id) {
+			tagged = append(tagged, items[i])
+		}
+	}
+	return tagged
+}
+
+func hasTag(item *models.NewsItem, id string) bool {
+	for _, tag := range item.Tags {
+		if tag.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateTag changes a tag. For system tags only the rules can change, and
+// empty rules restore the built-in ones; user tags also take a new name and
+// color.
+func (s *NewsService) UpdateTag(id string, update models.Tag) (models.Tag, error) {
+	if _, err := compileTag(update); err != nil {
+		return models.Tag{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.invalidateTagRules()
+
+	for _, tag := range models.DefaultTags {
+		if tag.ID != id {
+			continue
+		}
+		if len(update.Rules) == 0 {
+			delete(s.preferences.SystemTagRules, id)
+		} else {
+			if s.preferences.SystemTagRules == nil {
+				s.preferences.SystemTagRules = make(map[string][]models.TagRule)
+			}
+			s.preferences.SystemTagRules[id] = update.Rules
+			tag.Rules = update.Rules
+		}
+		if err := s.savePreferences(); err != nil {
+			return models.Tag{}, err
+		}
+		return tag, nil
+	}
+
+	for i, tag := range s.preferences.Tags {
+		if tag.ID != id {
+			continue
+		}
+		if update.Name != "" {
+			tag.Name = update.Name
+		}
+		if update.Color != "" {
+			tag.Color = update.Color
+		}
+		tag.Rules = update.Rules
+		s.preferences.Tags[i] = tag
+		if err := s.savePreferences(); err != nil {
+			return models.Tag{}, err
+		}
+		return tag, nil
+	}
+
+	return models.Tag{}, ErrTagNotFound
+}
+
+// PreviewTag returns the cached items that tag's rules would match, without
+// saving anything.
+func (s *NewsService) PreviewTag(tag models.Tag) ([]models.NewsItem, error) {
+	if tag.Category == "" {
+		tag.Category = "user"
+	}
+	compiled, err := compileTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []models.NewsItem{}
+	for _, item := range s.SortByRecency(s.GetAllNews()) {
+		if compiled.matches(&item, newTagText(&item)) {
+			matched = append(matched, item)
+		}
+	}
+	return matched, nil
+}
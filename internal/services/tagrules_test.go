@@ -0,0 +1,191 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/news-reader/internal/models"
+)
+
+func TestTagRuleMatching(t *testing.T) {
+	item := models.NewsItem{
+		Title:       "Spain said again it would review the budget",
+		Description: "Parliament meets on Monday. Ref: BUD-2024",
+		Source:      "NRK",
+		SourceNames: []string{"NRK", "NRK Politikk"},
+		Category:    "General",
+		Categories:  []string{"General", "Politics"},
+		Language:    "english",
+	}
+
+	tests := []struct {
+		name     string
+		rule     models.TagRule
+		expected bool
+	}{
+		{name: "Keyword inside words", rule: models.TagRule{Keywords: []string{"AI"}}, expected: false},
+		{name: "Whole word", rule: models.TagRule{Keywords: []string{"budget"}}, expected: true},
+		{name: "Case-insensitive phrase", rule: models.TagRule{Keywords: []string{"PARLIAMENT MEETS"}}, expected: true},
+		{name: "Phrase across punctuation", rule: models.TagRule{Keywords: []string{"budget parliament"}}, expected: true},
+		{name: "Pattern", rule: models.TagRule{Patterns: []string{`BUD-\d+`}}, expected: true},
+		{name: "Case-sensitive pattern", rule: models.TagRule{Patterns: []string{`bud-\d+`}}, expected: false},
+		{name: "Source constraint met", rule: models.TagRule{Keywords: []string{"budget"}, Sources: []string{"nrk"}}, expected: true},
+		{name: "Source constraint not met", rule: models.TagRule{Keywords: []string{"budget"}, Sources: []string{"BBC"}}, expected: false},
+		{name: "Secondary source", rule: models.TagRule{Keywords: []string{"budget"}, Sources: []string{"nrk politikk"}}, expected: true},
+		{name: "Secondary category", rule: models.TagRule{Keywords: []string{"budget"}, Categories: []string{"Politics"}}, expected: true},
+		{name: "Category not met", rule: models.TagRule{Keywords: []string{"budget"}, Categories: []string{"Sports"}}, expected: false},
+		{name: "Language by code", rule: models.TagRule{Keywords: []string{"budget"}, Languages: []string{"en-GB"}}, expected: true},
+		{name: "Language not met", rule: models.TagRule{Keywords: []string{"budget"}, Languages: []string{"nb"}}, expected: false},
+		{name: "Constraints only", rule: models.TagRule{Sources: []string{"NRK"}}, expected: true},
+		{name: "Empty rule", rule: models.TagRule{}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := compileRule(tt.rule)
+			if err != nil {
+				t.Fatalf("Failed to compile rule: %v", err)
+			}
+			if result := rule.matches(&item, newTagText(&item)); result != tt.expected {
+				t.Errorf("matches() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCompileRuleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule models.TagRule
+	}{
+		{name: "Bad pattern", rule: models.TagRule{Patterns: []string{"(unclosed"}}},
+		{name: "Unknown language", rule: models.TagRule{Languages: []string{"klingon"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := compileRule(tt.rule); !errors.Is(err, ErrInvalidTagRule) {
+				t.Errorf("compileRule() error = %v, want ErrInvalidTagRule", err)
+			}
+		})
+	}
+}
+
+func TestAutoTagRules(t *testing.T) {
+	service := &NewsService{preferences: models.NewDefaultPreferences()}
+	service.preferences.Tags = []models.Tag{
+		{ID: "ai", Name: "AI", Category: "user"},
+		{ID: "oil", Name: "Oil", Category: "user", Rules: []models.TagRule{
+			{Keywords: []string{"petroleum", "oil price"}},
+		}},
+	}
+
+	tests := []struct {
+		name     string
+		title    string
+		expected []string
+	}{
+		{name: "Name inside words", title: "Spain said it will try again", expected: nil},
+		{name: "Name as word", title: "New AI model released", expected: []string{"technology", "ai"}},
+		{name: "User rule", title: "The oil price fell sharply", expected: []string{"oil"}},
+		{name: "Art inside words", title: "Start of the season", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := models.NewsItem{Title: tt.title, Language: "english"}
+			service.autoTagNews(&item, service.tagPreferences())
+
+			var got []string
+			for _, tag := range item.Tags {
+				if tag.Category == "topic" || tag.Category == "user" {
+					got = append(got, tag.ID)
+				}
+				if len(tag.Rules) > 0 {
+					t.Errorf("Tag %s on item carries its rules", tag.ID)
+				}
+			}
+			if len(got) != len(tt.expected) {
+				t.Fatalf("autoTagNews() tags = %v, want %v", got, tt.expected)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("autoTagNews() tags = %v, want %v", got, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateTag(t *testing.T) {
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+
+	// Override a built-in topic
+	sportsRules := []models.TagRule{{Keywords: []string{"fotball"}}}
+	tag, err := service.UpdateTag("sports", models.Tag{Name: "Ignored", Rules: sportsRules})
+	if err != nil {
+		t.Fatalf("Failed to update tag: %v", err)
+	}
+	if tag.Name != "Sports" || len(tag.Rules) != 1 {
+		t.Errorf("UpdateTag() = %+v, want Sports with the new rule", tag)
+	}
+
+	item := models.NewsItem{Title: "Fotball: Brann vant", Language: "norwegian-bokmal"}
+	service.autoTagNews(&item, service.tagPreferences())
+	if !hasTag(&item, "sports") {
+		t.Errorf("Expected overridden sports rule to match, got %v", item.Tags)
+	}
+
+	item = models.NewsItem{Title: "Championship final tonight", Language: "english"}
+	service.autoTagNews(&item, service.tagPreferences())
+	if hasTag(&item, "sports") {
+		t.Errorf("Expected built-in sports keywords to be replaced, got %v", item.Tags)
+	}
+
+	// Empty rules restore the defaults
+	if _, err := service.UpdateTag("sports", models.Tag{}); err != nil {
+		t.Fatalf("Failed to reset tag: %v", err)
+	}
+	service.autoTagNews(&item, service.tagPreferences())
+	if !hasTag(&item, "sports") {
+		t.Errorf("Expected built-in sports keywords after reset, got %v", item.Tags)
+	}
+
+	// User tags take name, color and rules
+	created, err := service.CreateTag(models.Tag{Name: "Energy"})
+	if err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	updated, err := service.UpdateTag(created.ID, models.Tag{Name: "Power", Color: "#000000", Rules: []models.TagRule{{Keywords: []string{"grid"}}}})
+	if err != nil {
+		t.Fatalf("Failed to update tag: %v", err)
+	}
+	if updated.Name != "Power" || updated.Color != "#000000" || updated.Category != "user" {
+		t.Errorf("UpdateTag() = %+v, want renamed user tag", updated)
+	}
+
+	if _, err := service.UpdateTag("missing", models.Tag{}); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("UpdateTag(missing) error = %v, want ErrTagNotFound", err)
+	}
+	if _, err := service.UpdateTag(created.ID, models.Tag{Rules: []models.TagRule{{Patterns: []string{"["}}}}); !errors.Is(err, ErrInvalidTagRule) {
+		t.Errorf("UpdateTag(bad pattern) error = %v, want ErrInvalidTagRule", err)
+	}
+
+	// Overrides survive a reload
+	if _, err := service.UpdateTag("sports", models.Tag{Rules: sportsRules}); err != nil {
+		t.Fatalf("Failed to update tag: %v", err)
+	}
+	reloaded, err := NewNewsService(service.prefsFile)
+	if err != nil {
+		t.Fatalf("Failed to reload news service: %v", err)
+	}
+	systemTags, _ := reloaded.GetTags()
+	for _, tag := range systemTags {
+		if tag.ID == "sports" && (len(tag.Rules) != 1 || tag.Rules[0].Keywords[0] != "fotball") {
+			t.Errorf("Reloaded sports tag = %+v, want the override", tag)
+		}
+	}
+}
@@ -0,0 +1,491 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/textanalysis"
+)
+
+// Trend history intervals.
+const (
+	TrendHourly = "hour"
+	TrendDaily  = "day"
+)
+
+const (
+	// TrendRetention is how long hourly counts are kept.
+	TrendRetention = 90 * 24 * time.Hour
+	// minTrendPeak is the smallest bucket that counts as a peak: two titles,
+	// or four descriptions, mentioning the topic.
+	minTrendPeak     = 4
+	defaultPeakLimit = 20
+)
+
+// TrendPoint is a topic's mentions in one hour or day. Count weighs title
+// mentions double, like trending does.
+type TrendPoint struct {
+	Time  time.Time `json:"time"`
+	Count int       `json:"count"`
+	Items int       `json:"items"`
+}
+
+// TrendSeries is a topic's mentions over a period, one point per interval.
+type TrendSeries struct {
+	Term     string       `json:"term"`
+	Topic    string       `json:"topic"`
+	Interval string       `json:"interval"`
+	Total    int          `json:"total"`
+	Points   []TrendPoint `json:"points"`
+}
+
+// TrendPeak is a topic whose busiest hour or day fell in a period.
+type TrendPeak struct {
+	Topic string    `json:"topic"`
+	Peak  time.Time `json:"peak"`
+	Count int       `json:"count"`
+	Items int       `json:"items"`
+	// Total is the topic's count over the whole period.
+	Total int `json:"total"`
+}
+
+// trendHistory keeps per-term counts of the items seen by snapshots in
+// hourly buckets, keyed by the hour the items were published.
+type trendHistory struct {
+	mu   sync.Mutex
+	file string
+	// Terms holds every term by its trending key.
+	Terms map[string]*termHistory `json:"terms"`
+	// Seen maps the IDs of counted items to their publication time, so an
+	// item is counted once however many snapshots include it.
+	Seen map[string]time.Time `json:"seen"`
+}
+
+type termHistory struct {
+	Forms map[string]int        `json:"forms"`
+	Hours map[int64]*TrendPoint `json:"hours"`
+	// Groups holds the hourly counts of the items in each category and
+	// language, keyed by trendGroup, for the baselines of trending in one
+	// category or language.
+	Groups map[string]map[int64]int `json:"groups,omitempty"`
+}
+
+// trendGroup is the key of a category's or language's counts in
+// termHistory.Groups.
+func trendGroup(kind, name string) string {
+	return kind + ":" + name
+}
+
+// trendHistoryFile returns where the trend history of a preferences file is
+// stored.
+func trendHistoryFile(prefsFile string) string {
+	return strings.TrimSuffix(prefsFile, filepath.Ext(prefsFile)) + ".trending.json"
+}
+
+func newTrendHistory(file string) (*trendHistory, error) {
+	h := &trendHistory{
+		file:  file,
+		Terms: make(map[string]*termHistory),
+		Seen:  make(map[string]time.Time),
+	}
+
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, err
+	}
+	if h.Terms == nil {
+		h.Terms = make(map[string]*termHistory)
+	}
+	if h.Seen == nil {
+		h.Seen = make(map[string]time.Time)
+	}
+	return h, nil
+}
+
+// save writes the history to disk. Callers must hold h.mu.
+func (h *trendHistory) save() error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(h.file, data, 0644)
+}
+
+// prune drops buckets and seen items older than the retention. Callers must
+// hold h.mu.
+func (h *trendHistory) prune(now time.Time) {
+	cutoff := now.Add(-TrendRetention)
+	for id, published := range h.Seen {
+		if published.Before(cutoff) {
+			delete(h.Seen, id)
+		}
+	}
+	for key, term := range h.Terms {
+		for hour := range term.Hours {
+			if time.Unix(hour, 0).Before(cutoff) {
+				delete(term.Hours, hour)
+			}
+		}
+		for group, hours := range term.Groups {
+			for hour := range hours {
+				if time.Unix(hour, 0).Before(cutoff) {
+					delete(hours, hour)
+				}
+			}
+			if len(hours) == 0 {
+				delete(term.Groups, group)
+			}
+		}
+		if len(term.Hours) == 0 {
+			delete(h.Terms, key)
+		}
+	}
+}
+
+// RecordTrendingHistory adds the terms of every item not counted before to
+// the history and saves it. Items without a publication date or older than
+// the retention are skipped.
+func (s *NewsService) RecordTrendingHistory(items []models.NewsItem, now time.Time) error {
+	h := s.trends
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	added := 0
+	for _, item := range items {
+		if item.ID == "" || item.Published.IsZero() || item.Published.Before(now.Add(-TrendRetention)) {
+			continue
+		}
+		if _, ok := h.Seen[item.ID]; ok {
+			continue
+		}
+		published := item.Published
+		if published.After(now) {
+			published = now
+		}
+		h.Seen[item.ID] = published
+		added++
+
+		hour := published.UTC().Truncate(time.Hour)
+		language := s.itemLanguage(item)
+		groups := []string{trendGroup("language", language)}
+		for _, category := range trendingCategories(item) {
+			groups = append(groups, trendGroup("category", category))
+		}
+		for key, term := range itemTrendingTerms(textanalysis.For(language), item) {
+			th := h.Terms[key]
+			if th == nil {
+				th = &termHistory{Forms: make(map[string]int), Hours: make(map[int64]*TrendPoint)}
+				h.Terms[key] = th
+			}
+			th.Forms[term.form]++
+			point := th.Hours[hour.Unix()]
+			if point == nil {
+				point = &TrendPoint{Time: hour}
+				th.Hours[hour.Unix()] = point
+			}
+			point.Count += term.weight
+			point.Items++
+
+			if th.Groups == nil {
+				th.Groups = make(map[string]map[int64]int)
+			}
+			for _, group := range groups {
+				if th.Groups[group] == nil {
+					th.Groups[group] = make(map[int64]int)
+				}
+				th.Groups[group][hour.Unix()] += term.weight
+			}
+		}
+	}
+
+	if added == 0 {
+		return nil
+	}
+	h.prune(now)
+	return h.save()
+}
+
+// recorded reports whether any item has been counted, so the history can
+// serve as a baseline.
+func (h *trendHistory) recorded() bool {
+	if h == nil {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.Seen) > 0
+}
+
+// count sums a term's counts in the hours from the one from falls in up to
+// the one to falls in, counting only items in category and language when
+// they are set. With both set the smaller of the two counts is taken, as the
+// history does not keep their combination. Callers must hold h.mu.
+func (h *trendHistory) count(key, category, language string, from, to time.Time) int {
+	th := h.Terms[key]
+	if th == nil {
+		return 0
+	}
+	start, end := from.UTC().Truncate(time.Hour), to.UTC().Truncate(time.Hour)
+
+	if category == "" && language == "" {
+		total := 0
+		for hour := start; hour.Before(end); hour = hour.Add(time.Hour) {
+			if point := th.Hours[hour.Unix()]; point != nil {
+				total += point.Count
+			}
+		}
+		return total
+	}
+
+	var groups []string
+	if category != "" {
+		groups = append(groups, trendGroup("category", category))
+	}
+	if language != "" {
+		groups = append(groups, trendGroup("language", language))
+	}
+	result := -1
+	for _, group := range groups {
+		total := 0
+		for hour := start; hour.Before(end); hour = hour.Add(time.Hour) {
+			total += th.Groups[group][hour.Unix()]
+		}
+		if result < 0 || total < result {
+			result = total
+		}
+	}
+	return result
+}
+
+// StartTrendingSnapshots fetches the news every interval and records it in
+// the trending history, until the returned function is called.
+func (s *NewsService) StartTrendingSnapshots(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := s.RecordTrendingHistory(s.FetchNews(), time.Now()); err != nil {
+				log.Printf("Failed to record trending history: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// bucketStart returns the start of the hour or UTC day t falls in.
+func bucketStart(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	if interval == TrendDaily {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return t.Truncate(time.Hour)
+}
+
+func bucketStep(interval string) time.Duration {
+	if interval == TrendDaily {
+		return 24 * time.Hour
+	}
+	return time.Hour
+}
+
+// buckets sums a term's hourly points into hours or days.
+func (t *termHistory) buckets(interval string) map[time.Time]TrendPoint {
+	buckets := make(map[time.Time]TrendPoint)
+	for _, point := range t.Hours {
+		start := bucketStart(point.Time, interval)
+		b := buckets[start]
+		b.Time = start
+		b.Count += point.Count
+		b.Items += point.Items
+		buckets[start] = b
+	}
+	return buckets
+}
+
+// termKeys returns the history keys a search term refers to. With a language
+// the term is stemmed like that language's text; otherwise keys written as
+// the term are preferred, then the term's stem in any language.
+func (h *trendHistory) termKeys(term, language string) []string {
+	words := textanalysis.Words(strings.ToLower(term))
+	if len(words) == 0 {
+		return nil
+	}
+
+	stemKey := func(a *textanalysis.Analyzer) string {
+		stems := make([]string, len(words))
+		for i, word := range words {
+			stems[i] = a.Stem(word)
+		}
+		return strings.Join(stems, " ")
+	}
+	if language != "" {
+		return []string{stemKey(textanalysis.For(language))}
+	}
+
+	form := strings.Join(words, " ")
+	var keys []string
+	for key, th := range h.Terms {
+		for written := range th.Forms {
+			// Entity forms keep their capitals
+			if strings.Join(textanalysis.Words(strings.ToLower(written)), " ") == form {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+	if len(keys) > 0 {
+		sort.Strings(keys)
+		return keys
+	}
+
+	seen := make(map[string]bool)
+	for _, lang := range textanalysis.Languages() {
+		key := stemKey(textanalysis.For(lang))
+		if !seen[key] && h.Terms[key] != nil {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// TrendingHistory returns how often term was mentioned in every hour or day
+// from from up to to. The term may be in any form of the word; language,
+// if given, is the language to stem it in.
+func (s *NewsService) TrendingHistory(term, language, interval string, from, to time.Time) TrendSeries {
+	series := TrendSeries{Term: term, Topic: strings.ToLower(term), Interval: interval, Points: []TrendPoint{}}
+
+	counts := make(map[time.Time]TrendPoint)
+	forms := make(map[string]int)
+	if h := s.trends; h != nil {
+		h.mu.Lock()
+		for _, key := range h.termKeys(term, language) {
+			th := h.Terms[key]
+			if th == nil {
+				continue
+			}
+			for form, n := range th.Forms {
+				forms[form] += n
+			}
+			for start, point := range th.buckets(interval) {
+				b := counts[start]
+				b.Count += point.Count
+				b.Items += point.Items
+				counts[start] = b
+			}
+		}
+		h.mu.Unlock()
+	}
+	if len(forms) > 0 {
+		series.Topic = mostCommonForm(forms)
+	}
+
+	step := bucketStep(interval)
+	for start := bucketStart(from, interval); start.Before(to); start = start.Add(step) {
+		point := counts[start]
+		point.Time = start
+		series.Points = append(series.Points, point)
+		series.Total += point.Count
+	}
+	return series
+}
+
+// TrendingPeaks returns the topics whose busiest hour or day fell between
+// from and to, busiest first. A single word is left out when most of its
+// mentions at the peak were in a word pair that peaked too.
+func (s *NewsService) TrendingPeaks(interval string, from, to time.Time, limit int) []TrendPeak {
+	if limit <= 0 {
+		limit = defaultPeakLimit
+	}
+	h := s.trends
+	if h == nil {
+		return []TrendPeak{}
+	}
+
+	peaks := make(map[string]TrendPeak)
+	h.mu.Lock()
+	for key, th := range h.Terms {
+		var peak TrendPoint
+		total := 0
+		for start, point := range th.buckets(interval) {
+			if !start.Before(from) && start.Before(to) {
+				total += point.Count
+			}
+			if point.Count > peak.Count || point.Count == peak.Count && start.Before(peak.Time) {
+				peak = point
+			}
+		}
+		if peak.Count < minTrendPeak || peak.Items < 2 || peak.Time.Before(bucketStart(from, interval)) || !peak.Time.Before(to) {
+			continue
+		}
+		peaks[key] = TrendPeak{
+			Topic: mostCommonForm(th.Forms),
+			Peak:  peak.Time,
+			Count: peak.Count,
+			Items: peak.Items,
+			Total: total,
+		}
+	}
+	h.mu.Unlock()
+
+	result := []TrendPeak{}
+	for key, peak := range peaks {
+		if !peakCoveredByPair(key, peak, peaks) {
+			result = append(result, peak)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		if !result[i].Peak.Equal(result[j].Peak) {
+			return result[i].Peak.Before(result[j].Peak)
+		}
+		return result[i].Topic < result[j].Topic
+	})
+
+	if len(result) > limit {
+		return result[:limit]
+	}
+	return result
+}
+
+// peakCoveredByPair reports whether a single word peaked with a word pair
+// that accounts for most of its mentions.
+func peakCoveredByPair(key string, peak TrendPeak, peaks map[string]TrendPeak) bool {
+	if strings.Contains(key, " ") {
+		return false
+	}
+	for pairKey, pair := range peaks {
+		first, second, ok := strings.Cut(pairKey, " ")
+		if ok && (first == key || second == key) && pair.Peak.Equal(peak.Peak) && 2*pair.Count >= peak.Count {
+			return true
+		}
+	}
+	return false
+}
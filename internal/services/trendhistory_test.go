@@ -0,0 +1,147 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+func TestTrendingHistory(t *testing.T) {
+	prefs := t.TempDir() + "/prefs.json"
+	service, err := NewNewsService(prefs)
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+
+	now := time.Date(2026, 3, 10, 12, 30, 0, 0, time.UTC)
+	items := []models.NewsItem{
+		{ID: "1", Title: "Storm hits coast", Language: "english", Published: now.Add(-26 * time.Hour)},
+		{ID: "2", Title: "Storms cause floods", Language: "english", Published: now.Add(-2 * time.Hour)},
+		{ID: "3", Title: "After the storm", Description: "Storm damage counted", Language: "english", Published: now.Add(-2 * time.Hour)},
+		{ID: "4", Title: "Stormen treffer kysten", Language: "norwegian-bokmal", Published: now.Add(-time.Hour)},
+		{ID: "5", Title: "Undated storm"},
+	}
+	if err := service.RecordTrendingHistory(items, now); err != nil {
+		t.Fatalf("Failed to record trending history: %v", err)
+	}
+	// Items are only counted once
+	if err := service.RecordTrendingHistory(items, now); err != nil {
+		t.Fatalf("Failed to record trending history: %v", err)
+	}
+
+	series := service.TrendingHistory("Storms", "english", TrendHourly, now.Add(-3*time.Hour), now)
+	if series.Topic != "storm" || len(series.Points) != 4 {
+		t.Fatalf("TrendingHistory() = %+v, want 4 hourly points for storm", series)
+	}
+	if p := series.Points[1]; p.Count != 4 || p.Items != 2 || !p.Time.Equal(time.Date(2026, 3, 10, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("TrendingHistory() 10:00 = %+v, want 2 items counting 4", p)
+	}
+	if series.Points[0].Count != 0 || series.Total != 6 {
+		t.Errorf("TrendingHistory() = %+v, want a total of 6 from 10:00", series)
+	}
+
+	daily := service.TrendingHistory("storm", "english", TrendDaily, now.Add(-48*time.Hour), now)
+	if len(daily.Points) != 3 || daily.Points[1].Count != 2 || daily.Points[2].Count != 6 {
+		t.Errorf("TrendingHistory() daily = %+v, want 2 then 6", daily.Points)
+	}
+
+	// Without a language the term is looked up as written
+	if norwegian := service.TrendingHistory("kysten", "", TrendDaily, now.Add(-24*time.Hour), now); norwegian.Total != 2 {
+		t.Errorf("TrendingHistory() kysten = %+v, want the Norwegian item", norwegian)
+	}
+
+	// The history survives a restart
+	reloaded, err := NewNewsService(prefs)
+	if err != nil {
+		t.Fatalf("Failed to reload news service: %v", err)
+	}
+	if got := reloaded.TrendingHistory("storm", "english", TrendDaily, now.Add(-48*time.Hour), now); got.Total != 8 {
+		t.Errorf("TrendingHistory() after reload total = %d, want 8", got.Total)
+	}
+}
+
+func TestTrendingPeaks(t *testing.T) {
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	var items []models.NewsItem
+	add := func(id, title string, daysAgo int) {
+		items = append(items, models.NewsItem{ID: id, Title: title, Language: "english", Published: now.AddDate(0, 0, -daysAgo)})
+	}
+	// The eclipse peaked last week, the election peaks today
+	add("e1", "Solar eclipse tonight", 8)
+	add("e2", "Solar eclipse photos", 8)
+	add("e3", "Solar eclipse over", 7)
+	add("v1", "Election day", 0)
+	add("v2", "Election results", 0)
+	add("v3", "Election turnout", 0)
+	add("v4", "Election night", 1)
+	if err := service.RecordTrendingHistory(items, now); err != nil {
+		t.Fatalf("Failed to record trending history: %v", err)
+	}
+
+	lastWeek := service.TrendingPeaks(TrendDaily, now.AddDate(0, 0, -10), now.AddDate(0, 0, -6), 0)
+	if len(lastWeek) != 1 || lastWeek[0].Topic != "solar eclipse" || lastWeek[0].Count != 4 || lastWeek[0].Total != 6 {
+		t.Errorf("TrendingPeaks() last week = %+v, want solar eclipse alone", lastWeek)
+	}
+
+	thisWeek := service.TrendingPeaks(TrendDaily, now.AddDate(0, 0, -6), now.AddDate(0, 0, 1), 0)
+	if len(thisWeek) != 1 || thisWeek[0].Topic != "election" || thisWeek[0].Items != 3 {
+		t.Errorf("TrendingPeaks() this week = %+v, want election", thisWeek)
+	}
+}
+
+func TestTrendingBaselineFromHistory(t *testing.T) {
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+
+	now := time.Now()
+	// Weather was in the news all week, but the cache only has today's items
+	var recorded []models.NewsItem
+	for h := 7; h < 7*24; h += 2 {
+		item := trendingItem("Weather forecast update", "Met", float64(h), now)
+		item.ID = fmt.Sprintf("w%d", h)
+		recorded = append(recorded, item)
+	}
+	if err := service.RecordTrendingHistory(recorded, now); err != nil {
+		t.Fatalf("Failed to record trending history: %v", err)
+	}
+
+	cached := []models.NewsItem{
+		trendingItem("Weather forecast update", "Met", 1, now),
+		trendingItem("Weather forecast update", "Met", 4, now),
+		trendingItem("Volcano erupts near village", "NRK", 1, now),
+		trendingItem("Volcano ash grounds flights", "BBC", 2, now),
+	}
+	for i := range cached {
+		cached[i].ID = fmt.Sprintf("c%d", i)
+	}
+	service.syncTrendingIndex(cached)
+
+	topics := service.TrendingTopics(TrendingOptions{Now: now}, "").Topics
+	if _, ok := findTopic(topics, "weather forecast"); ok {
+		t.Errorf("TrendingTopics() = %+v, want weather expected from the history", topics)
+	}
+	if len(topics) == 0 || topics[0].Topic != "volcano" {
+		t.Errorf("TrendingTopics() = %+v, want volcano first", topics)
+	}
+
+	// A category's baseline counts only that category's items
+	if topics := service.TrendingTopics(TrendingOptions{Now: now, Category: "Sports"}, "").Topics; len(topics) != 0 {
+		t.Errorf("TrendingTopics(Sports) = %+v, want nothing", topics)
+	}
+	service.trends.mu.Lock()
+	world := service.trends.count("weather forecast", "World", "english", now.Add(-7*24*time.Hour), now)
+	sports := service.trends.count("weather forecast", "Sports", "", now.Add(-7*24*time.Hour), now)
+	service.trends.mu.Unlock()
+	if world == 0 || sports != 0 {
+		t.Errorf("count() = %d in World and %d in Sports, want only World", world, sports)
+	}
+}
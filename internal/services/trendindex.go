@@ -0,0 +1,357 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+// maxTrendingResults bounds how many option sets the trending index keeps
+// results for.
+const maxTrendingResults = 64
+
+// TrendingResult is a trending computation as served to clients. Topics is
+// set for plain queries and Groups for queries split by category or
+// language.
+type TrendingResult struct {
+	Topics []TrendingTopic            `json:"topics,omitempty"`
+	Groups map[string][]TrendingTopic `json:"groups,omitempty"`
+	// Time is when the result was computed and ETag identifies its content.
+	Time time.Time `json:"time"`
+	ETag string    `json:"-"`
+}
+
+// maxTrendingCounters bounds how many option sets the trending index keeps
+// counters for.
+const maxTrendingCounters = 64
+
+// trendingIndex keeps the cached items analysed for trending, so an item is
+// tokenized once when it enters the cache rather than on every query. For
+// each window, baseline and filter asked for it keeps counters of the terms
+// in the window and baseline, which items update as they enter and leave the
+// cache and as time moves them between periods. Results are kept until the
+// items change or the minute they were computed for passes.
+type trendingIndex struct {
+	mu   sync.Mutex
+	docs map[string]*trendingDoc
+	// byTime holds the docs in order of publication.
+	byTime []*trendingDoc
+	// groups counts the docs in each category and language.
+	groups   map[string]map[string]int
+	counters map[string]*trendingCounter
+	version  uint64
+	results  map[string]*trendingEntry
+}
+
+type trendingEntry struct {
+	version uint64
+	minute  time.Time
+	result  TrendingResult
+}
+
+func newTrendingIndex() *trendingIndex {
+	return &trendingIndex{
+		docs:     make(map[string]*trendingDoc),
+		groups:   map[string]map[string]int{"category": {}, "language": {}},
+		counters: make(map[string]*trendingCounter),
+		results:  make(map[string]*trendingEntry),
+	}
+}
+
+// syncTrendingIndex analyses the items that entered the cache and forgets the
+// ones that left it.
+func (s *NewsService) syncTrendingIndex(items []models.NewsItem) {
+	idx := s.trendIndex
+	if idx == nil {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	current := make(map[string]bool, len(items))
+	changed := false
+	for _, item := range items {
+		current[item.ID] = true
+		if _, ok := idx.docs[item.ID]; ok {
+			continue
+		}
+		idx.add(s.trendingDoc(item))
+		changed = true
+	}
+	for id, doc := range idx.docs {
+		if !current[id] {
+			idx.remove(doc)
+			changed = true
+		}
+	}
+
+	if changed {
+		idx.version++
+	}
+}
+
+// add indexes doc and counts it. Callers must hold idx.mu.
+func (idx *trendingIndex) add(doc *trendingDoc) {
+	idx.docs[doc.id] = doc
+	i := sort.Search(len(idx.byTime), func(i int) bool { return idx.byTime[i].published.After(doc.published) })
+	idx.byTime = append(idx.byTime, nil)
+	copy(idx.byTime[i+1:], idx.byTime[i:])
+	idx.byTime[i] = doc
+
+	for _, category := range doc.categories {
+		idx.groups["category"][category]++
+	}
+	idx.groups["language"][doc.language]++
+	for _, counter := range idx.counters {
+		counter.apply(doc, counter.class(doc, counter.now), 1)
+	}
+}
+
+// remove forgets doc and uncounts it. Callers must hold idx.mu.
+func (idx *trendingIndex) remove(doc *trendingDoc) {
+	delete(idx.docs, doc.id)
+	for i := sort.Search(len(idx.byTime), func(i int) bool { return !idx.byTime[i].published.Before(doc.published) }); i < len(idx.byTime); i++ {
+		if idx.byTime[i] == doc {
+			idx.byTime = append(idx.byTime[:i], idx.byTime[i+1:]...)
+			break
+		}
+	}
+
+	for _, category := range doc.categories {
+		decrementGroup(idx.groups["category"], category)
+	}
+	decrementGroup(idx.groups["language"], doc.language)
+	for _, counter := range idx.counters {
+		counter.apply(doc, counter.class(doc, counter.now), -1)
+	}
+}
+
+func decrementGroup(counts map[string]int, name string) {
+	if counts[name]--; counts[name] <= 0 {
+		delete(counts, name)
+	}
+}
+
+// counter returns the counters for opts, brought forward to opts.Now.
+// Callers must hold idx.mu.
+func (idx *trendingIndex) counter(opts TrendingOptions) *trendingCounter {
+	key := fmt.Sprintf("%s|%s|%s|%s", opts.Window, opts.Baseline, opts.Category, opts.Language)
+	counter, ok := idx.counters[key]
+	if ok && !opts.Now.Before(counter.now) && opts.Now.Sub(counter.now) <= min(opts.Window, opts.Baseline) {
+		idx.advance(counter, opts.Now)
+		return counter
+	}
+
+	// Count the docs from the start of the baseline to now afresh
+	counter = newTrendingCounter(opts)
+	from := opts.Now.Add(-opts.Window - opts.Baseline)
+	for i := idx.search(from); i < len(idx.byTime) && !idx.byTime[i].published.After(opts.Now); i++ {
+		doc := idx.byTime[i]
+		counter.apply(doc, counter.class(doc, opts.Now), 1)
+	}
+	if !ok && len(idx.counters) >= maxTrendingCounters {
+		idx.counters = make(map[string]*trendingCounter)
+	}
+	idx.counters[key] = counter
+	return counter
+}
+
+// advance moves counter's docs between periods as its time goes from
+// counter.now to now. Only docs that cross the start of the window, the start
+// of the baseline or now itself are looked at, which needs now to be no
+// further ahead than the window and the baseline are long. Callers must hold
+// idx.mu.
+func (idx *trendingIndex) advance(counter *trendingCounter, now time.Time) {
+	then := counter.now
+	if now.Equal(then) {
+		return
+	}
+	for _, edge := range []time.Duration{0, counter.opts.Window, counter.opts.Window + counter.opts.Baseline} {
+		// Docs published at or after then-edge and before now-edge, or for
+		// the edge at now itself, after then and at or before now
+		lo, hi := idx.search(then.Add(-edge)), idx.search(now.Add(-edge))
+		if edge == 0 {
+			lo, hi = idx.searchAfter(then), idx.searchAfter(now)
+		}
+		for _, doc := range idx.byTime[lo:hi] {
+			if old, current := counter.class(doc, then), counter.class(doc, now); old != current {
+				counter.apply(doc, old, -1)
+				counter.apply(doc, current, 1)
+			}
+		}
+	}
+	counter.now = now
+}
+
+// search returns the index of the first doc published at or after t.
+func (idx *trendingIndex) search(t time.Time) int {
+	return sort.Search(len(idx.byTime), func(i int) bool { return !idx.byTime[i].published.Before(t) })
+}
+
+// searchAfter returns the index of the first doc published after t.
+func (idx *trendingIndex) searchAfter(t time.Time) int {
+	return sort.Search(len(idx.byTime), func(i int) bool { return idx.byTime[i].published.After(t) })
+}
+
+// Periods a doc can be in relative to a counter's time.
+const (
+	periodBefore = iota
+	periodBaseline
+	periodWindow
+	periodAfter
+)
+
+// trendingCounter counts the terms of the docs in the window and baseline
+// before its time, for one window, baseline, category and language. Terms
+// mentioned in the window are also kept ranked by their window frequency,
+// so the top topics can be read without walking every term.
+type trendingCounter struct {
+	opts  TrendingOptions
+	now   time.Time
+	terms map[string]*topicStats
+	// levels holds the distinct window frequencies in ascending order and
+	// byLevel the terms at each of them.
+	levels  []int
+	byLevel map[int]map[string]*topicStats
+}
+
+func newTrendingCounter(opts TrendingOptions) *trendingCounter {
+	return &trendingCounter{
+		opts:    opts,
+		now:     opts.Now,
+		terms:   make(map[string]*topicStats),
+		byLevel: make(map[int]map[string]*topicStats),
+	}
+}
+
+// class returns the period doc is in at now.
+func (c *trendingCounter) class(doc *trendingDoc, now time.Time) int {
+	windowStart := now.Add(-c.opts.Window)
+	switch {
+	case doc.published.After(now):
+		return periodAfter
+	case !doc.published.Before(windowStart):
+		return periodWindow
+	case !doc.published.Before(windowStart.Add(-c.opts.Baseline)):
+		return periodBaseline
+	default:
+		return periodBefore
+	}
+}
+
+// apply adds doc's terms to the counts of period, or with a sign of -1
+// takes them away. Docs outside the category or language are ignored.
+func (c *trendingCounter) apply(doc *trendingDoc, period, sign int) {
+	if period != periodWindow && period != periodBaseline {
+		return
+	}
+	if c.opts.Category != "" && !containsString(doc.categories, c.opts.Category) {
+		return
+	}
+	if c.opts.Language != "" && doc.language != c.opts.Language {
+		return
+	}
+
+	for key, term := range doc.terms {
+		st := c.terms[key]
+		if st == nil {
+			st = &topicStats{sources: make(map[string]int), forms: make(map[string]int)}
+			c.terms[key] = st
+		}
+		if period == periodBaseline {
+			st.baseline += sign * term.weight
+		} else {
+			before := st.window
+			st.window += sign * term.weight
+			st.items += sign
+			if st.sources[doc.source] += sign; st.sources[doc.source] <= 0 {
+				delete(st.sources, doc.source)
+			}
+			if st.forms[term.form] += sign; st.forms[term.form] <= 0 {
+				delete(st.forms, term.form)
+			}
+			c.relevel(key, st, before)
+		}
+		if st.window <= 0 && st.baseline <= 0 && st.items <= 0 {
+			delete(c.terms, key)
+		}
+	}
+}
+
+// relevel moves key from the level of its old window frequency to that of
+// its current one. Terms no longer in the window leave the levels.
+func (c *trendingCounter) relevel(key string, st *topicStats, before int) {
+	if before == st.window {
+		return
+	}
+	if terms := c.byLevel[before]; terms != nil {
+		if delete(terms, key); len(terms) == 0 {
+			delete(c.byLevel, before)
+			i := sort.SearchInts(c.levels, before)
+			c.levels = append(c.levels[:i], c.levels[i+1:]...)
+		}
+	}
+	if st.window <= 0 {
+		return
+	}
+	terms := c.byLevel[st.window]
+	if terms == nil {
+		terms = make(map[string]*topicStats)
+		c.byLevel[st.window] = terms
+		i := sort.SearchInts(c.levels, st.window)
+		c.levels = append(c.levels, 0)
+		copy(c.levels[i+1:], c.levels[i:])
+		c.levels[i] = st.window
+	}
+	terms[key] = st
+}
+
+// TrendingTopics returns trending over the cached items, split by category
+// or language when by is set. Results are computed at most once a minute
+// for the same options and items.
+func (s *NewsService) TrendingTopics(opts TrendingOptions, by string) TrendingResult {
+	opts = opts.withDefaults()
+	minute := opts.Now.Truncate(time.Minute)
+	opts.Now = minute
+	key := fmt.Sprintf("%s|%s|%s|%s|%s|%d|%s", opts.Window, opts.Baseline, opts.Category, opts.Language, opts.Rank, opts.Limit, by)
+
+	idx := s.trendIndex
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if entry, ok := idx.results[key]; ok && entry.version == idx.version && entry.minute.Equal(minute) {
+		return entry.result
+	}
+
+	rank := func(opts TrendingOptions) []TrendingTopic {
+		return scoreTrending(idx.counter(opts), opts, s.trends)
+	}
+	result := TrendingResult{Time: time.Now().UTC()}
+	var content interface{}
+	if by == "" {
+		result.Topics = rank(opts)
+		content = result.Topics
+	} else {
+		groups := make(map[string]bool)
+		for name := range idx.groups[by] {
+			groups[name] = true
+		}
+		result.Groups = rankGroups(groups, opts, by, rank)
+		content = result.Groups
+	}
+	data, _ := json.Marshal(content)
+	sum := sha256.Sum256(data)
+	result.ETag = `"` + hex.EncodeToString(sum[:8]) + `"`
+
+	if len(idx.results) >= maxTrendingResults {
+		idx.results = make(map[string]*trendingEntry)
+	}
+	idx.results[key] = &trendingEntry{version: idx.version, minute: minute, result: result}
+	return result
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+func TestTrendingIndex(t *testing.T) {
+	service, err := NewNewsService(t.TempDir() + "/prefs.json")
+	if err != nil {
+		t.Fatalf("Failed to create news service: %v", err)
+	}
+
+	now := time.Now()
+	items := []models.NewsItem{
+		{ID: "1", Title: "Ferry strike continues", Source: "NRK", Category: "Norway", Language: "english", Published: now.Add(-time.Hour)},
+		{ID: "2", Title: "Ferry strike ends", Source: "BBC", Category: "World", Language: "english", Published: now.Add(-2 * time.Hour)},
+		{ID: "3", Title: "Markets calm", Source: "BBC", Category: "World", Language: "english", Published: now.Add(-2 * time.Hour)},
+	}
+	service.syncTrendingIndex(items)
+
+	opts := TrendingOptions{Now: now}
+	first := service.TrendingTopics(opts, "")
+	if want := service.GetTrendingTopics(items, TrendingOptions{Now: now.Truncate(time.Minute)}); !reflect.DeepEqual(first.Topics, want) {
+		t.Errorf("TrendingTopics() = %+v, want %+v", first.Topics, want)
+	}
+	if len(first.Topics) == 0 || first.Topics[0].Topic != "ferry strike" || first.ETag == "" {
+		t.Fatalf("TrendingTopics() = %+v, want ferry strike with an ETag", first)
+	}
+
+	// Unchanged items give the memoized result
+	doc := service.trendIndex.docs["1"]
+	service.syncTrendingIndex(items)
+	if again := service.TrendingTopics(opts, ""); again.ETag != first.ETag || !again.Time.Equal(first.Time) {
+		t.Errorf("TrendingTopics() = %+v, want the cached result %+v", again, first)
+	}
+	if service.trendIndex.docs["1"] != doc {
+		t.Error("syncTrendingIndex() analysed an item that was already indexed")
+	}
+
+	// Items entering and leaving the cache change the result
+	items = append(items[1:], models.NewsItem{ID: "4", Title: "Markets calm again", Source: "NRK", Category: "World", Language: "english", Published: now.Add(-time.Hour)})
+	service.syncTrendingIndex(items)
+	if _, ok := service.trendIndex.docs["1"]; ok {
+		t.Error("syncTrendingIndex() kept an item that left the cache")
+	}
+	changed := service.TrendingTopics(opts, "")
+	if changed.ETag == first.ETag || len(changed.Topics) == 0 || changed.Topics[0].Topic != "markets calm" {
+		t.Errorf("TrendingTopics() after sync = %+v, want markets calm", changed)
+	}
+
+	grouped := service.TrendingTopics(opts, "category")
+	if _, ok := grouped.Groups["World"]; !ok || grouped.Topics != nil {
+		t.Errorf("TrendingTopics(by category) = %+v, want World", grouped)
+	}
+}
+
+func TestTrendingCounters(t *testing.T) {
+	service := &NewsService{trendIndex: newTrendingIndex()}
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	var items []models.NewsItem
+	add := func(title, source string, hoursAgo float64) {
+		item := trendingItem(title, source, hoursAgo, now)
+		item.ID = strconv.Itoa(len(items))
+		items = append(items, item)
+	}
+	add("Ferry strike continues", "NRK", 0.5)
+	add("Ferry strike ends", "BBC", 5.9)
+	add("Ferry strike talks", "NRK", 6.5)
+	add("Markets calm", "BBC", 1)
+	add("Markets calm again", "NRK", 2)
+	add("Markets rally", "BBC", 30)
+	add("Storm warning", "Met", 7*24+5.5)
+	add("Storm warning lifted", "Met", -0.2)
+	service.syncTrendingIndex(items)
+
+	idx := service.trendIndex
+	opts := TrendingOptions{Now: now}.withDefaults()
+	idx.mu.Lock()
+	counter := idx.counter(opts)
+	idx.mu.Unlock()
+
+	// Moving on in time shifts docs between periods without a recount
+	for _, step := range []time.Duration{10 * time.Minute, 30 * time.Minute, time.Hour, 4 * time.Hour} {
+		opts.Now = opts.Now.Add(step)
+		idx.mu.Lock()
+		got := idx.counter(opts)
+		want := newTrendingCounter(opts)
+		for _, doc := range idx.byTime {
+			want.apply(doc, want.class(doc, opts.Now), 1)
+		}
+		idx.mu.Unlock()
+		if got != counter {
+			t.Fatalf("counter() at %v recounted the docs", opts.Now)
+		}
+		if !reflect.DeepEqual(got.terms, want.terms) {
+			t.Errorf("counter() at %v = %v, want %v", opts.Now, got.terms, want.terms)
+		}
+		if !reflect.DeepEqual(got.levels, want.levels) || !reflect.DeepEqual(got.byLevel, want.byLevel) {
+			t.Errorf("counter() at %v has levels %v, want %v", opts.Now, got.levels, want.levels)
+		}
+		if topics, want := service.TrendingTopics(opts, "").Topics, service.GetTrendingTopics(items, opts); !reflect.DeepEqual(topics, want) {
+			t.Errorf("TrendingTopics() at %v = %+v, want %+v", opts.Now, topics, want)
+		}
+	}
+
+	// Docs leaving the cache are taken out of the counts
+	service.syncTrendingIndex(items[4:])
+	idx.mu.Lock()
+	got := idx.counter(opts)
+	idx.mu.Unlock()
+	want := newTrendingCounter(opts)
+	for _, doc := range service.trendingDocs(items[4:]) {
+		want.apply(doc, want.class(doc, opts.Now), 1)
+	}
+	if !reflect.DeepEqual(got.terms, want.terms) {
+		t.Errorf("counter() after sync = %v, want %v", got.terms, want.terms)
+	}
+	if len(idx.byTime) != len(items)-4 || idx.groups["category"]["World"] != len(items)-4 {
+		t.Errorf("Index holds %d docs and %v, want %d", len(idx.byTime), idx.groups, len(items)-4)
+	}
+}
+
+func TestTrendingTopK(t *testing.T) {
+	service := &NewsService{}
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	words := []string{"ferry", "strike", "markets", "calm", "storm", "warning", "election", "results", "budget", "talks", "floods", "rally"}
+	random := rand.New(rand.NewSource(1))
+	var items []models.NewsItem
+	for i := 0; i < 400; i++ {
+		n := 2 + random.Intn(3)
+		title := ""
+		for j := 0; j < n; j++ {
+			title += " " + words[random.Intn(len(words)-i%5)]
+		}
+		items = append(items, trendingItem(title, "S"+strconv.Itoa(i%4), random.Float64()*7*24, now))
+	}
+
+	opts := TrendingOptions{Now: now}.withDefaults()
+	counter := newTrendingCounter(opts)
+	for _, doc := range service.trendingDocs(items) {
+		counter.apply(doc, counter.class(doc, opts.Now), 1)
+	}
+
+	for _, rank := range []string{TrendingByScore, TrendingByAcceleration} {
+		// A limit above the number of terms scores every one of them
+		all := opts
+		all.Rank, all.Limit = rank, len(counter.terms)+1
+		every := scoreTrending(counter, all, nil)
+		for _, limit := range []int{1, 2, 3, 5, 10} {
+			top := all
+			top.Limit = limit
+			want := every[:min(limit, len(every))]
+			if got := scoreTrending(counter, top, nil); !reflect.DeepEqual(got, want) {
+				t.Errorf("scoreTrending(%s, %d) = %+v, want %+v", rank, limit, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkTrendingIndexed(b *testing.B) {
+	service := &NewsService{trendIndex: newTrendingIndex()}
+	items := benchmarkItems()
+	for i := range items {
+		items[i].ID = strconv.Itoa(i)
+		items[i].Published = time.Now().Add(-time.Duration(i) * time.Minute)
+	}
+	service.syncTrendingIndex(items)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		service.TrendingTopics(TrendingOptions{}, "")
+	}
+}
+
+func BenchmarkTrendingRecomputed(b *testing.B) {
+	service := &NewsService{}
+	items := benchmarkItems()
+	for i := range items {
+		items[i].Published = time.Now().Add(-time.Duration(i) * time.Minute)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		service.GetTrendingTopics(items, TrendingOptions{})
+	}
+}
@@ -0,0 +1,452 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/news-reader/internal/models"
+	"github.com/news-reader/internal/textanalysis"
+)
+
+// TrendingWindows are the windows trending can be computed over.
+var TrendingWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"6h":  6 * time.Hour,
+	"24h": 24 * time.Hour,
+}
+
+// Trending defaults, used for every zero field of TrendingOptions.
+const (
+	defaultTrendingWindow   = 6 * time.Hour
+	defaultTrendingBaseline = 7 * 24 * time.Hour
+	defaultTrendingLimit    = 10
+)
+
+// Ways of ranking trending topics.
+const (
+	TrendingByScore        = "score"
+	TrendingByAcceleration = "acceleration"
+)
+
+// TrendingOptions selects the items and the ranking trending uses.
+type TrendingOptions struct {
+	// Window is how far back from Now items count as current, and Baseline
+	// the period before the window that sets the expected frequency.
+	Window   time.Duration
+	Baseline time.Duration
+	// Category and Language restrict the items, language being a tag ID
+	// such as "norwegian-bokmal".
+	Category string
+	Language string
+	// Rank is TrendingByScore (the default) or TrendingByAcceleration.
+	Rank  string
+	Limit int
+	Now   time.Time
+}
+
+// TrendingTopic is a word or word pair mentioned more often in the window
+// than its baseline predicts.
+type TrendingTopic struct {
+	Topic string `json:"topic"`
+	// Frequency counts the items mentioning the topic in the window, those
+	// mentioning it in their title counting double.
+	Frequency int `json:"frequency"`
+	Items     int `json:"items"`
+	Sources   int `json:"sources"`
+	// Expected is the frequency the baseline predicts for the window.
+	Expected float64 `json:"expected"`
+	// Acceleration is the ratio of frequency to expected frequency, and
+	// Score how many standard deviations the frequency lies above it.
+	Acceleration float64 `json:"acceleration"`
+	Score        float64 `json:"score"`
+	// Entity is the ID of the person, organization or ticker the topic is.
+	Entity string `json:"entity,omitempty"`
+}
+
+func (o TrendingOptions) withDefaults() TrendingOptions {
+	if o.Window <= 0 {
+		o.Window = defaultTrendingWindow
+	}
+	if o.Baseline <= 0 {
+		o.Baseline = defaultTrendingBaseline
+	}
+	if o.Rank == "" {
+		o.Rank = TrendingByScore
+	}
+	if o.Limit <= 0 {
+		o.Limit = defaultTrendingLimit
+	}
+	if o.Now.IsZero() {
+		o.Now = time.Now()
+	}
+	return o
+}
+
+// topicStats accumulates one term's mentions. Sources and forms count the
+// window's items by source and by written form.
+type topicStats struct {
+	window   int
+	baseline int
+	items    int
+	sources  map[string]int
+	forms    map[string]int
+}
+
+// trendingDoc is an item reduced to what trending counts.
+type trendingDoc struct {
+	id         string
+	published  time.Time
+	source     string
+	language   string
+	categories []string
+	terms      map[string]itemTerm
+}
+
+// trendingDoc analyses an item for trending.
+func (s *NewsService) trendingDoc(item models.NewsItem) *trendingDoc {
+	language := s.itemLanguage(item)
+	return &trendingDoc{
+		id:         item.ID,
+		published:  item.Published,
+		source:     item.Source,
+		language:   language,
+		categories: trendingCategories(item),
+		terms:      itemTrendingTerms(textanalysis.For(language), item),
+	}
+}
+
+// trendingCategories returns every category trending counts an item in.
+func trendingCategories(item models.NewsItem) []string {
+	categories := item.AllCategories()
+	if !containsString(categories, item.Category) {
+		categories = append([]string{item.Category}, categories...)
+	}
+	return categories
+}
+
+func (s *NewsService) trendingDocs(items []models.NewsItem) []*trendingDoc {
+	docs := make([]*trendingDoc, len(items))
+	for i, item := range items {
+		docs[i] = s.trendingDoc(item)
+	}
+	return docs
+}
+
+// GetTrendingTopics ranks the words and word pairs of items published in the
+// window by how far their frequency exceeds what the baseline period
+// predicts, so words that are always common do not trend. Words are analysed
+// in each item's language: stop words are skipped and inflected forms share
+// a count, shown in their most common written form. A topic needs at least
+// two items in the window, and a single word is left out when most of its
+// mentions are in a trending pair.
+func (s *NewsService) GetTrendingTopics(items []models.NewsItem, opts TrendingOptions) []TrendingTopic {
+	return rankTrending(s.trendingDocs(items), opts, nil)
+}
+
+// GetTrendingTopicsBy computes trending separately for every category or
+// language among items, keyed by its name.
+func (s *NewsService) GetTrendingTopicsBy(items []models.NewsItem, opts TrendingOptions, by string) map[string][]TrendingTopic {
+	return rankTrendingBy(s.trendingDocs(items), opts, by, nil)
+}
+
+// rankTrending ranks the terms of docs.
+func rankTrending(docs []*trendingDoc, opts TrendingOptions, history *trendHistory) []TrendingTopic {
+	opts = opts.withDefaults()
+	counter := newTrendingCounter(opts)
+	for _, doc := range docs {
+		counter.apply(doc, counter.class(doc, opts.Now), 1)
+	}
+	return scoreTrending(counter, opts, history)
+}
+
+// scoreTrending ranks the counted terms. The baseline is taken from history
+// when it has recorded anything, since the cached items only reach as far
+// back as the feeds do, and otherwise from the counts.
+//
+// Terms are scored from the most frequent down. A term's score cannot exceed
+// its window frequency, nor its acceleration that plus one, so scoring stops
+// once the top topics rank above anything the less frequent terms could
+// reach and no pair yet to be scored could be frequent enough to cover them.
+func scoreTrending(counter *trendingCounter, opts TrendingOptions, history *trendHistory) []TrendingTopic {
+	windowStart := opts.Now.Add(-opts.Window)
+	baselineStart := windowStart.Add(-opts.Baseline)
+	if history.recorded() {
+		history.mu.Lock()
+		defer history.mu.Unlock()
+	} else {
+		history = nil
+	}
+
+	rank := func(t TrendingTopic) float64 {
+		if opts.Rank == TrendingByAcceleration {
+			return t.Acceleration
+		}
+		return t.Score
+	}
+	bound := func(frequency int) float64 {
+		if opts.Rank == TrendingByAcceleration {
+			return float64(frequency + 1)
+		}
+		return float64(frequency)
+	}
+
+	scale := opts.Window.Hours() / opts.Baseline.Hours()
+	topics := make(map[string]TrendingTopic)
+	// pairs holds the highest frequency of a trending pair for each word
+	pairs := make(map[string]int)
+	covered := func(key string) bool {
+		return 2*pairs[key] >= topics[key].Frequency
+	}
+
+	var ranked []string
+	for i := len(counter.levels) - 1; i >= 0; i-- {
+		for key, st := range counter.byLevel[counter.levels[i]] {
+			if st.items < 2 {
+				continue
+			}
+			baseline := st.baseline
+			if history != nil {
+				baseline = history.count(key, opts.Category, opts.Language, baselineStart, windowStart)
+			}
+			expected := float64(baseline) * scale
+			score := (float64(st.window) - expected) / math.Sqrt(expected+1)
+			if score <= 0 {
+				continue
+			}
+			topic := TrendingTopic{
+				Topic:        mostCommonForm(st.forms),
+				Frequency:    st.window,
+				Items:        st.items,
+				Sources:      len(st.sources),
+				Expected:     round2(expected),
+				Acceleration: round2((float64(st.window) + 1) / (expected + 1)),
+				Score:        round2(score),
+			}
+			if strings.HasPrefix(key, "@") {
+				topic.Entity = key[1:]
+			}
+			topics[key] = topic
+			if first, second, ok := strings.Cut(key, " "); ok {
+				pairs[first] = max(pairs[first], st.window)
+				pairs[second] = max(pairs[second], st.window)
+			}
+		}
+		if len(topics) < opts.Limit {
+			continue
+		}
+
+		ranked = ranked[:0]
+		for key := range topics {
+			if !covered(key) {
+				ranked = append(ranked, key)
+			}
+		}
+		sortTopics(ranked, topics, rank)
+		if len(ranked) > opts.Limit {
+			ranked = ranked[:opts.Limit]
+		}
+
+		// Terms not scored yet are no more frequent than next
+		next := 0
+		if i > 0 {
+			next = counter.levels[i-1]
+		}
+		done := len(ranked) == opts.Limit
+		for _, key := range ranked {
+			topic := topics[key]
+			if rank(topic) <= bound(next) || (!strings.Contains(key, " ") && 2*next >= topic.Frequency) {
+				done = false
+				break
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	ranked = ranked[:0]
+	for key := range topics {
+		if !covered(key) {
+			ranked = append(ranked, key)
+		}
+	}
+	sortTopics(ranked, topics, rank)
+	result := []TrendingTopic{}
+	for _, key := range ranked {
+		if len(result) == opts.Limit {
+			break
+		}
+		result = append(result, topics[key])
+	}
+	return result
+}
+
+// sortTopics orders keys by the rank of their topics, then by frequency and
+// then alphabetically.
+func sortTopics(keys []string, topics map[string]TrendingTopic, rank func(TrendingTopic) float64) {
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := topics[keys[i]], topics[keys[j]]
+		if rank(a) != rank(b) {
+			return rank(a) > rank(b)
+		}
+		if a.Frequency != b.Frequency {
+			return a.Frequency > b.Frequency
+		}
+		return a.Topic < b.Topic
+	})
+}
+
+func rankTrendingBy(docs []*trendingDoc, opts TrendingOptions, by string, history *trendHistory) map[string][]TrendingTopic {
+	groups := make(map[string]bool)
+	for _, doc := range docs {
+		switch by {
+		case "category":
+			for _, category := range doc.categories {
+				groups[category] = true
+			}
+		case "language":
+			groups[doc.language] = true
+		}
+	}
+
+	return rankGroups(groups, opts, by, func(groupOpts TrendingOptions) []TrendingTopic {
+		return rankTrending(docs, groupOpts, history)
+	})
+}
+
+// rankGroups ranks trending for every category or language in groups, as
+// by says, keyed by its name.
+func rankGroups(groups map[string]bool, opts TrendingOptions, by string, rank func(TrendingOptions) []TrendingTopic) map[string][]TrendingTopic {
+	result := make(map[string][]TrendingTopic)
+	for group := range groups {
+		if group == "" {
+			continue
+		}
+		groupOpts := opts
+		if by == "category" {
+			groupOpts.Category = group
+		} else {
+			groupOpts.Language = group
+		}
+		if topics := rank(groupOpts); len(topics) > 0 {
+			result[group] = topics
+		}
+	}
+	return result
+}
+
+// itemLanguage returns the item's language, detecting it for items that were
+// not tagged with one.
+func (s *NewsService) itemLanguage(item models.NewsItem) string {
+	if item.Language != "" {
+		return item.Language
+	}
+	language, _ := s.detectLanguage(item.Title + " " + item.Description)
+	return language
+}
+
+func round2(x float64) float64 {
+	return math.Round(x*100) / 100
+}
+
+// itemTerm is a term found in an item, weighted 2 if the title has it.
+type itemTerm struct {
+	form   string
+	weight int
+}
+
+// itemTrendingTerms returns the terms of an item keyed by stem, each counted
+// once however often the item repeats it. People, organizations and tickers
+// the item names are terms of their own, keyed by entity ID. Words and pairs
+// made up only of names and titles, such as "prime minister", are left out.
+func itemTrendingTerms(analyzer *textanalysis.Analyzer, item models.NewsItem) map[string]itemTerm {
+	terms := make(map[string]itemTerm)
+	nameWords := make(map[string]bool)
+	for _, entity := range item.Entities {
+		if entity.Type == models.EntityPlace {
+			continue
+		}
+		weight := 1
+		if strings.Contains(item.Title, strings.TrimPrefix(entity.Name, "$")) {
+			weight = 2
+		}
+		terms["@"+entity.ID] = itemTerm{form: entity.Name, weight: weight}
+		for _, word := range textanalysis.Words(strings.ToLower(entity.Name)) {
+			nameWords[word] = true
+		}
+	}
+
+	words := entityWordsFor(analyzer.Language())
+	skip := func(form string) bool {
+		for _, word := range strings.Fields(form) {
+			if !nameWords[word] && !words.isTitle(word) {
+				return false
+			}
+		}
+		return true
+	}
+	add := func(text string, weight int) {
+		for _, term := range trendingTerms(analyzer, text) {
+			if _, ok := terms[term.key]; ok || skip(term.form) {
+				continue
+			}
+			terms[term.key] = itemTerm{form: term.form, weight: weight}
+		}
+	}
+	add(item.Title, 2)
+	add(item.Description, 1)
+	return terms
+}
+
+// trendingTerm is a candidate topic: key groups inflections, form is how the
+// text wrote it.
+type trendingTerm struct {
+	key  string
+	form string
+}
+
+// trendingTerms extracts single words and pairs of adjacent words that could
+// be topics. Stop words, words shorter than three letters and words with
+// digits are skipped, and pairs never span punctuation.
+func trendingTerms(analyzer *textanalysis.Analyzer, text string) []trendingTerm {
+	tokens := analyzer.Analyze(text)
+	candidate := func(token textanalysis.Token) bool {
+		return !token.Stop && textanalysis.Length(token.Lower) >= 3 && !textanalysis.HasDigit(token.Lower)
+	}
+
+	var terms []trendingTerm
+	for i, token := range tokens {
+		if !candidate(token) {
+			continue
+		}
+		terms = append(terms, trendingTerm{key: token.Stem, form: token.Lower})
+
+		if i == 0 || !candidate(tokens[i-1]) || strings.TrimSpace(text[tokens[i-1].End:token.Start]) != "" {
+			continue
+		}
+		prev := tokens[i-1]
+		terms = append(terms, trendingTerm{
+			key:  prev.Stem + " " + token.Stem,
+			form: prev.Lower + " " + token.Lower,
+		})
+	}
+	return terms
+}
+
+// mostCommonForm picks the form seen most often, preferring the shorter and
+// then the alphabetically first on ties.
+func mostCommonForm(forms map[string]int) string {
+	best := ""
+	for form, n := range forms {
+		switch {
+		case best == "",
+			n > forms[best],
+			n == forms[best] && len(form) < len(best),
+			n == forms[best] && len(form) == len(best) && form < best:
+			best = form
+		}
+	}
+	return best
+}
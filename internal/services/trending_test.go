@@ -0,0 +1,184 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+// trendingItem makes an English item published the given number of hours
+// before now.
+func trendingItem(title, source string, hoursAgo float64, now time.Time) models.NewsItem {
+	return models.NewsItem{
+		Title:     title,
+		Source:    source,
+		Language:  "english",
+		Category:  "World",
+		Published: now.Add(-time.Duration(hoursAgo * float64(time.Hour))),
+	}
+}
+
+func findTopic(topics []TrendingTopic, name string) (TrendingTopic, bool) {
+	for _, topic := range topics {
+		if topic.Topic == name {
+			return topic, true
+		}
+	}
+	return TrendingTopic{}, false
+}
+
+func TestGetTrendingTopicsNorwegian(t *testing.T) {
+	service := &NewsService{}
+	now := time.Now()
+
+	items := []models.NewsItem{
+		{Title: "Støre mener valget blir jevnt", Language: "norwegian-bokmal", Published: now.Add(-time.Hour)},
+		{Title: "Valgene etter krisen", Description: "Partiene mener noe annet etter valget", Language: "norwegian-bokmal", Published: now.Add(-time.Hour)},
+		{Title: "Etter valget: 2025 blir spennende", Language: "norwegian-bokmal", Published: now.Add(-time.Hour)},
+	}
+
+	topics := service.GetTrendingTopics(items, TrendingOptions{Now: now})
+	if len(topics) == 0 || topics[0].Topic != "valget" {
+		t.Fatalf("GetTrendingTopics() = %v, want valget first", topics)
+	}
+	if topics[0].Frequency != 6 || topics[0].Items != 3 {
+		t.Errorf("GetTrendingTopics() valget = %+v, want frequency 6 in 3 items", topics[0])
+	}
+	for _, topic := range topics {
+		switch topic.Topic {
+		case "etter", "mener", "2025", "valgene":
+			t.Errorf("GetTrendingTopics() included %q", topic.Topic)
+		}
+	}
+}
+
+func TestTrendingAgainstBaseline(t *testing.T) {
+	service := &NewsService{}
+	now := time.Now()
+
+	var items []models.NewsItem
+	// "Weather" is in the news every few hours all week
+	for h := 1.0; h < 7*24; h += 3 {
+		items = append(items, trendingItem("Weather forecast update", "Met", h, now))
+	}
+	// "Volcano" only shows up in the last hour, from three sources
+	items = append(items,
+		trendingItem("Volcano erupts near village", "NRK", 0.2, now),
+		trendingItem("Volcano ash grounds flights", "BBC", 0.5, now),
+		trendingItem("Scientists monitor volcano", "BBC", 0.8, now),
+	)
+
+	topics := service.GetTrendingTopics(items, TrendingOptions{Window: time.Hour, Now: now})
+	if len(topics) == 0 || topics[0].Topic != "volcano" {
+		t.Fatalf("GetTrendingTopics() = %+v, want volcano first", topics)
+	}
+	if topics[0].Sources != 2 || topics[0].Items != 3 || topics[0].Expected != 0 {
+		t.Errorf("GetTrendingTopics() volcano = %+v, want 3 items from 2 sources and nothing expected", topics[0])
+	}
+
+	// Over a day weather is as common as its baseline predicts
+	topics = service.GetTrendingTopics(items, TrendingOptions{Window: 24 * time.Hour, Now: now})
+	if weather, ok := findTopic(topics, "weather"); ok {
+		t.Errorf("GetTrendingTopics() included evergreen %+v", weather)
+	}
+	if _, ok := findTopic(topics, "volcano"); !ok {
+		t.Errorf("GetTrendingTopics() = %+v, want volcano", topics)
+	}
+
+	// Items older than the baseline are ignored entirely
+	old := append(items, trendingItem("Volcano history", "BBC", 30*24, now))
+	if got := service.GetTrendingTopics(old, TrendingOptions{Window: time.Hour, Now: now}); got[0].Expected != 0 {
+		t.Errorf("GetTrendingTopics() counted an item before the baseline: %+v", got[0])
+	}
+}
+
+func TestTrendingRankByAcceleration(t *testing.T) {
+	service := &NewsService{}
+	now := time.Now()
+
+	var items []models.NewsItem
+	for i := 0; i < 10; i++ {
+		items = append(items, trendingItem("Budget talks", "NRK", 0.5, now))
+	}
+	for i := 0; i < 60; i++ {
+		items = append(items, trendingItem("Budget talks", "NRK", 2+float64(i)*0.35, now))
+	}
+	items = append(items,
+		trendingItem("Comet spotted", "BBC", 0.5, now),
+		trendingItem("Comet spotted", "NRK", 0.5, now),
+	)
+
+	opts := TrendingOptions{Window: time.Hour, Baseline: 24 * time.Hour, Now: now}
+	if topics := service.GetTrendingTopics(items, opts); topics[0].Topic != "budget talks" {
+		t.Errorf("GetTrendingTopics() by score = %+v, want budget talks first", topics)
+	}
+	opts.Rank = TrendingByAcceleration
+	if topics := service.GetTrendingTopics(items, opts); topics[0].Topic != "comet spotted" {
+		t.Errorf("GetTrendingTopics() by acceleration = %+v, want comet spotted first", topics)
+	}
+}
+
+func TestTrendingSuppressesCoveredWords(t *testing.T) {
+	service := &NewsService{}
+	now := time.Now()
+
+	items := []models.NewsItem{
+		trendingItem("Climate summit opens", "NRK", 1, now),
+		trendingItem("Climate summit ends without deal", "BBC", 1, now),
+		trendingItem("Leaders leave climate summit", "BBC", 1, now),
+		trendingItem("Summit security tightened", "NRK", 1, now),
+		trendingItem("Summit traffic chaos", "NRK", 1, now),
+		trendingItem("Summit hotel prices soar", "NRK", 1, now),
+		trendingItem("Summit protesters gather", "NRK", 1, now),
+	}
+
+	topics := service.GetTrendingTopics(items, TrendingOptions{Now: now})
+	if _, ok := findTopic(topics, "climate summit"); !ok {
+		t.Fatalf("GetTrendingTopics() = %+v, want climate summit", topics)
+	}
+	if _, ok := findTopic(topics, "climate"); ok {
+		t.Errorf("GetTrendingTopics() = %+v, want climate covered by climate summit", topics)
+	}
+	if _, ok := findTopic(topics, "summit"); !ok {
+		t.Errorf("GetTrendingTopics() = %+v, want summit, which trends on its own too", topics)
+	}
+}
+
+func TestTrendingByGroup(t *testing.T) {
+	service := &NewsService{}
+	now := time.Now()
+
+	sport := []models.NewsItem{
+		trendingItem("Striker transfer confirmed", "NRK", 1, now),
+		trendingItem("Striker transfer fee revealed", "BBC", 1, now),
+	}
+	for i := range sport {
+		sport[i].Category = "Sport"
+	}
+	items := append(sport,
+		trendingItem("Election results delayed", "NRK", 1, now),
+		trendingItem("Election results contested", "BBC", 1, now),
+		models.NewsItem{Title: "Valget utsatt", Language: "norwegian-bokmal", Category: "World", Published: now.Add(-time.Hour)},
+		models.NewsItem{Title: "Valget avgjort", Language: "norwegian-bokmal", Category: "World", Published: now.Add(-time.Hour)},
+	)
+
+	byCategory := service.GetTrendingTopicsBy(items, TrendingOptions{Now: now}, "category")
+	if len(byCategory) != 2 {
+		t.Fatalf("GetTrendingTopicsBy(category) = %+v, want Sport and World", byCategory)
+	}
+	if _, ok := findTopic(byCategory["Sport"], "striker transfer"); !ok {
+		t.Errorf("GetTrendingTopicsBy(category) Sport = %+v, want striker transfer", byCategory["Sport"])
+	}
+	if _, ok := findTopic(byCategory["Sport"], "election results"); ok {
+		t.Errorf("GetTrendingTopicsBy(category) Sport = %+v, want no world news", byCategory["Sport"])
+	}
+
+	byLanguage := service.GetTrendingTopicsBy(items, TrendingOptions{Now: now}, "language")
+	if topics := byLanguage["norwegian-bokmal"]; len(topics) != 1 || topics[0].Topic != "valget" {
+		t.Errorf("GetTrendingTopicsBy(language) norwegian-bokmal = %+v, want valget", topics)
+	}
+	if _, ok := findTopic(byLanguage["english"], "valget"); ok {
+		t.Errorf("GetTrendingTopicsBy(language) english = %+v, want no Norwegian topics", byLanguage["english"])
+	}
+}
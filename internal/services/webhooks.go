@@ -0,0 +1,335 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/news-reader/internal/models"
+)
+
+// Delivery statuses.
+const (
+	DeliveryPending     = "pending"
+	DeliveryDelivered   = "delivered"
+	DeliveryFailed      = "failed"
+	DeliveryQuiet       = "quiet"
+	DeliveryRateLimited = "rate-limited"
+)
+
+const (
+	// webhookMaxAttempts is how often a delivery is tried before it fails.
+	webhookMaxAttempts = 4
+	// maxDeliveryLog is how many deliveries the log keeps.
+	maxDeliveryLog = 500
+	// maxDiscordEmbeds is the most embeds Discord accepts in one message.
+	maxDiscordEmbeds = 10
+)
+
+// webhookBackoff is the wait before each retry; the last value repeats.
+var webhookBackoff = []time.Duration{2 * time.Second, 10 * time.Second, time.Minute}
+
+// Delivery is a batch of items sent, or held back, for an alert rule.
+type Delivery struct {
+	ID       string   `json:"id"`
+	RuleID   string   `json:"ruleId"`
+	RuleName string   `json:"ruleName"`
+	URL      string   `json:"url"`
+	Items    []string `json:"items"`
+	Status   string   `json:"status"`
+	Attempts int      `json:"attempts"`
+	// StatusCode and Error describe the last attempt.
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Time       time.Time `json:"time"`
+	Completed  time.Time `json:"completed,omitempty"`
+}
+
+// alertDispatcher posts alert payloads and keeps the delivery log and the
+// recent delivery times every rule's rate limit is counted from.
+type alertDispatcher struct {
+	mu     sync.Mutex
+	client *http.Client
+	log    []*Delivery
+	sent   map[string][]time.Time
+	seq    uint64
+}
+
+func newAlertDispatcher() *alertDispatcher {
+	return &alertDispatcher{
+		client: &http.Client{Timeout: 10 * time.Second},
+		sent:   make(map[string][]time.Time),
+	}
+}
+
+// allow reports whether rule may deliver now, counting the delivery if so.
+func (d *alertDispatcher) allow(rule models.AlertRule, now time.Time) bool {
+	limit := rule.MaxPerHour
+	if limit == 0 {
+		limit = defaultMaxPerHour
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	recent := d.sent[rule.ID][:0]
+	for _, t := range d.sent[rule.ID] {
+		if now.Sub(t) < time.Hour {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= limit {
+		d.sent[rule.ID] = recent
+		return false
+	}
+	d.sent[rule.ID] = append(recent, now)
+	return true
+}
+
+// record adds a delivery to the log.
+func (d *alertDispatcher) record(rule models.AlertRule, items []models.NewsItem, status string, now time.Time) *Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.seq++
+	delivery := &Delivery{
+		ID:       fmt.Sprintf("%s-%d-%d", rule.ID, now.Unix(), d.seq),
+		RuleID:   rule.ID,
+		RuleName: rule.Name,
+		URL:      rule.URL,
+		Status:   status,
+		Time:     now,
+	}
+	for _, item := range items {
+		delivery.Items = append(delivery.Items, item.ID)
+	}
+	d.log = append(d.log, delivery)
+	if len(d.log) > maxDeliveryLog {
+		d.log = d.log[len(d.log)-maxDeliveryLog:]
+	}
+	return delivery
+}
+
+// suppress logs a batch that was held back.
+func (d *alertDispatcher) suppress(rule models.AlertRule, items []models.NewsItem, status string, now time.Time) {
+	delivery := d.record(rule, items, status, now)
+	d.mu.Lock()
+	delivery.Completed = now
+	d.mu.Unlock()
+}
+
+// deliver posts items to the rule's webhook, trying up to attempts times
+// with backoff while the request fails or the server answers 429 or 5xx.
+// Payloads are signed when the rule has a secret: X-Webhook-Signature is
+// "sha256=" and the hex HMAC-SHA256 of the X-Webhook-Timestamp value, a dot
+// and the body.
+func (d *alertDispatcher) deliver(rule models.AlertRule, items []models.NewsItem, attempts int) Delivery {
+	now := time.Now()
+	delivery := d.record(rule, items, DeliveryPending, now)
+	body, err := webhookPayload(rule, items, delivery.ID, now)
+	if err != nil {
+		return d.finish(delivery, 0, err)
+	}
+
+	var status int
+	for attempt := 1; ; attempt++ {
+		status, err = d.post(rule, delivery.ID, body)
+		d.mu.Lock()
+		delivery.Attempts = attempt
+		d.mu.Unlock()
+
+		retry := err != nil || status == http.StatusTooManyRequests || status >= 500
+		if !retry || attempt >= attempts {
+			break
+		}
+		wait := webhookBackoff[len(webhookBackoff)-1]
+		if attempt-1 < len(webhookBackoff) {
+			wait = webhookBackoff[attempt-1]
+		}
+		time.Sleep(wait)
+	}
+	if err == nil && (status < 200 || status >= 300) {
+		err = fmt.Errorf("webhook answered %d", status)
+	}
+	return d.finish(delivery, status, err)
+}
+
+func (d *alertDispatcher) post(rule models.AlertRule, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, rule.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "news-reader-webhooks")
+	req.Header.Set("X-Webhook-Delivery", deliveryID)
+	if rule.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Webhook-Timestamp", timestamp)
+		req.Header.Set("X-Webhook-Signature", "sha256="+signWebhook(rule.Secret, timestamp, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func (d *alertDispatcher) finish(delivery *Delivery, status int, err error) Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delivery.StatusCode = status
+	delivery.Completed = time.Now()
+	if err != nil {
+		delivery.Status = DeliveryFailed
+		delivery.Error = err.Error()
+	} else {
+		delivery.Status = DeliveryDelivered
+	}
+	return *delivery
+}
+
+// signWebhook returns the hex HMAC-SHA256 of timestamp, a dot and body.
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliveries returns the logged deliveries, newest first, of rule id or of
+// every rule if id is empty.
+func (s *NewsService) Deliveries(id string) []Delivery {
+	deliveries := []Delivery{}
+	if s.alerts == nil {
+		return deliveries
+	}
+	s.alerts.mu.Lock()
+	defer s.alerts.mu.Unlock()
+	for i := len(s.alerts.log) - 1; i >= 0; i-- {
+		if delivery := s.alerts.log[i]; id == "" || delivery.RuleID == id {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	return deliveries
+}
+
+// alertItem is an item as sent in generic JSON payloads.
+type alertItem struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Link        string    `json:"link"`
+	Description string    `json:"description,omitempty"`
+	Source      string    `json:"source"`
+	Published   time.Time `json:"published"`
+	Tags        []string  `json:"tags,omitempty"`
+}
+
+// webhookPayload renders items in the rule's format.
+func webhookPayload(rule models.AlertRule, items []models.NewsItem, deliveryID string, now time.Time) ([]byte, error) {
+	switch rule.Format {
+	case models.WebhookSlack:
+		var text strings.Builder
+		fmt.Fprintf(&text, "*%s*", slackEscape(rule.Name))
+		for _, item := range items {
+			fmt.Fprintf(&text, "\n• <%s|%s> (%s)", slackURL(item.Link), slackEscape(item.Title), slackEscape(item.Source))
+		}
+		return marshalPayload(map[string]string{"text": text.String()})
+
+	case models.WebhookDiscord:
+		type footer struct {
+			Text string `json:"text"`
+		}
+		type embed struct {
+			Title       string    `json:"title"`
+			URL         string    `json:"url,omitempty"`
+			Description string    `json:"description,omitempty"`
+			Timestamp   time.Time `json:"timestamp"`
+			Footer      footer    `json:"footer"`
+		}
+		embeds := []embed{}
+		for _, item := range items {
+			if len(embeds) == maxDiscordEmbeds {
+				break
+			}
+			embeds = append(embeds, embed{
+				Title:       truncateRunes(item.Title, 256),
+				URL:         item.Link,
+				Description: truncateRunes(item.Description, 300),
+				Timestamp:   item.Published,
+				Footer:      footer{Text: item.Source},
+			})
+		}
+		return marshalPayload(map[string]interface{}{
+			"content": fmt.Sprintf("**%s**: %d new", rule.Name, len(items)),
+			"embeds":  embeds,
+		})
+
+	default:
+		payload := struct {
+			Rule struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"rule"`
+			Delivery string      `json:"delivery"`
+			SentAt   time.Time   `json:"sentAt"`
+			Items    []alertItem `json:"items"`
+		}{Delivery: deliveryID, SentAt: now.UTC()}
+		payload.Rule.ID = rule.ID
+		payload.Rule.Name = rule.Name
+		for _, item := range items {
+			a := alertItem{
+				ID:          item.ID,
+				Title:       item.Title,
+				Link:        item.Link,
+				Description: item.Description,
+				Source:      item.Source,
+				Published:   item.Published,
+			}
+			for _, tag := range item.Tags {
+				a.Tags = append(a.Tags, tag.ID)
+			}
+			payload.Items = append(payload.Items, a)
+		}
+		return marshalPayload(payload)
+	}
+}
+
+// marshalPayload encodes v as JSON without escaping <, > and &, which Slack
+// link markup relies on.
+func marshalPayload(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// slackEscape escapes the characters Slack gives a meaning in message text.
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// slackURL escapes a link for the URL half of Slack's <url|text> markup,
+// where | would end the URL and > the link, and & must be an entity.
+func slackURL(link string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "%3C", ">", "%3E", "|", "%7C").Replace(link)
+}
+
+func truncateRunes(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Web Push as described by RFC 8030, with VAPID authentication (RFC 8292)
+// and aes128gcm payload encryption (RFC 8291, RFC 8188).
+
+const (
+	// pushRecordSize is the record size of encrypted payloads. Payloads are
+	// sent as a single record, so it also bounds their size.
+	pushRecordSize = 4096
+	// pushMaxPayload is the largest plaintext that fits the record with the
+	// delimiter and the AEAD tag, and keeps the body within the 4096 bytes
+	// push services must accept.
+	pushMaxPayload = pushRecordSize - 16 - 1 - 86
+	// vapidExpiry is how long a VAPID token is valid; at most 24 hours.
+	vapidExpiry = 12 * time.Hour
+)
+
+// vapidKeys is the application server's P-256 key pair, identifying it to
+// push services.
+type vapidKeys struct {
+	// PrivateKey is the base64url encoded private scalar.
+	PrivateKey string `json:"privateKey"`
+	// PublicKey is the base64url encoded uncompressed public point, which
+	// browsers take as applicationServerKey.
+	PublicKey string `json:"publicKey"`
+}
+
+func generateVAPIDKeys() (vapidKeys, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return vapidKeys{}, err
+	}
+	public, err := key.PublicKey.ECDH()
+	if err != nil {
+		return vapidKeys{}, err
+	}
+	return vapidKeys{
+		PrivateKey: base64.RawURLEncoding.EncodeToString(key.D.FillBytes(make([]byte, 32))),
+		PublicKey:  base64.RawURLEncoding.EncodeToString(public.Bytes()),
+	}, nil
+}
+
+// signingKey returns the private key as an ECDSA key.
+func (k vapidKeys) signingKey() (*ecdsa.PrivateKey, error) {
+	d, err := decodePushKey(k.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	private, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, err
+	}
+	public := private.PublicKey().Bytes()
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(public[1:33]),
+			Y:     new(big.Int).SetBytes(public[33:]),
+		},
+		D: new(big.Int).SetBytes(d),
+	}, nil
+}
+
+// authorization returns the VAPID Authorization header for a push endpoint:
+// an ES256 JWT for the endpoint's origin and the public key.
+func (k vapidKeys) authorization(endpoint, subject string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	key, err := k.signingKey()
+	if err != nil {
+		return "", err
+	}
+
+	header, _ := json.Marshal(map[string]string{"typ": "JWT", "alg": "ES256"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(vapidExpiry).Unix(),
+		"sub": subject,
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	hash := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	token := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return fmt.Sprintf("vapid t=%s, k=%s", token, k.PublicKey), nil
+}
+
+// encryptPushPayload encrypts plaintext for the subscription whose public
+// key and authentication secret are given, with a new ephemeral key and
+// salt.
+func encryptPushPayload(plaintext, uaPublic, authSecret []byte) ([]byte, error) {
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return encryptPushPayloadWith(plaintext, uaPublic, authSecret, asPrivate, salt)
+}
+
+// encryptPushPayloadWith encrypts plaintext as a single aes128gcm record
+// using the application server key asPrivate and salt.
+func encryptPushPayloadWith(plaintext, uaPublic, authSecret []byte, asPrivate *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	if len(plaintext) > pushMaxPayload {
+		return nil, fmt.Errorf("push payload of %d bytes exceeds %d", len(plaintext), pushMaxPayload)
+	}
+	uaKey, err := ecdh.P256().NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := asPrivate.ECDH(uaKey)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+
+	// The input keying material mixes the ECDH secret with the auth secret
+	keyInfo := append(append([]byte("WebPush: info\x00"), uaPublic...), asPublic...)
+	ikm := hkdfExpand(hkdfExtract(authSecret, secret), keyInfo, 32)
+
+	prk := hkdfExtract(salt, ikm)
+	cek := hkdfExpand(prk, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdfExpand(prk, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// The header is the salt, the record size, and the server's public key
+	// as key ID. The 0x02 delimiter marks the last record.
+	var body bytes.Buffer
+	body.Write(salt)
+	binary.Write(&body, binary.BigEndian, uint32(pushRecordSize))
+	body.WriteByte(byte(len(asPublic)))
+	body.Write(asPublic)
+	record := append(append([]byte{}, plaintext...), 0x02)
+	return gcm.Seal(body.Bytes(), nonce, record, nil), nil
+}
+
+// hkdfExtract is the HKDF-Extract step of RFC 5869 with SHA-256.
+func hkdfExtract(salt, ikm []byte) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(ikm)
+	return mac.Sum(nil)
+}
+
+// hkdfExpand is the HKDF-Expand step of RFC 5869 with SHA-256, for lengths
+// of up to one block.
+func hkdfExpand(prk, info []byte, length int) []byte {
+	mac := hmac.New(sha256.New, prk)
+	mac.Write(info)
+	mac.Write([]byte{1})
+	return mac.Sum(nil)[:length]
+}
+
+// decodePushKey decodes base64url, with or without padding, as browsers and
+// push libraries use both.
+func decodePushKey(key string) ([]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(key, "="))
+	if err != nil {
+		return nil, errors.New("key is not base64url encoded")
+	}
+	return data, nil
+}
@@ -0,0 +1,81 @@
+package textanalysis
+
+import "sort"
+
+// Analyzer tokenizes, marks stop words and stems for one language.
+type Analyzer struct {
+	language  string
+	stopWords map[string]bool
+	stem      func(string) string
+}
+
+var (
+	analyzers = func() map[string]*Analyzer {
+		byLanguage := make(map[string]*Analyzer, len(stopWords))
+		for language := range stopWords {
+			byLanguage[language] = &Analyzer{language: language, stopWords: stopWords[language], stem: stemmers[language]}
+		}
+		return byLanguage
+	}()
+	unknownAnalyzer = &Analyzer{stopWords: stopWords["english"]}
+)
+
+// For returns the analyzer for a language tag ID such as "english" or
+// "norwegian-bokmal". Text of unknown language gets English stop words and
+// no stemming.
+func For(language string) *Analyzer {
+	if a, ok := analyzers[language]; ok {
+		return a
+	}
+	return unknownAnalyzer
+}
+
+// Language returns the language the analyzer was made for.
+func (a *Analyzer) Language() string {
+	return a.language
+}
+
+// Analyze tokenizes text and fills in every token's stem and stop flag.
+func (a *Analyzer) Analyze(text string) []Token {
+	tokens := Tokenize(text)
+	for i := range tokens {
+		tokens[i].Stop = a.stopWords[tokens[i].Lower]
+		tokens[i].Stem = a.Stem(tokens[i].Lower)
+	}
+	return tokens
+}
+
+// Terms returns the stems of the words in text that are not stop words.
+func (a *Analyzer) Terms(text string) []string {
+	var terms []string
+	for _, token := range a.Analyze(text) {
+		if !token.Stop {
+			terms = append(terms, token.Stem)
+		}
+	}
+	return terms
+}
+
+// IsStopWord reports whether the lowercase word is a stop word.
+func (a *Analyzer) IsStopWord(word string) bool {
+	return a.stopWords[word]
+}
+
+// Stem reduces a lowercase word to its stem. Words with digits are left
+// alone.
+func (a *Analyzer) Stem(word string) string {
+	if a.stem == nil || HasDigit(word) {
+		return word
+	}
+	return a.stem(word)
+}
+
+// Languages returns the languages with their own stop words, sorted.
+func Languages() []string {
+	languages := make([]string, 0, len(analyzers))
+	for language := range analyzers {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
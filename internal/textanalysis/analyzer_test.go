@@ -0,0 +1,108 @@
+package textanalysis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{"punctuation", "Rain, rain: go away!", []string{"Rain", "rain", "go", "away"}},
+		{"non-ascii letters", "Sør-Korea og Ålesund", []string{"Sør", "Korea", "og", "Ålesund"}},
+		{"apostrophe", "Norway's «budget»", []string{"Norway", "s", "budget"}},
+		{"digits", "G20 meets in 2024", []string{"G20", "meets", "in", "2024"}},
+		{"empty", "  ...  ", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var words []string
+			for _, token := range Tokenize(tt.text) {
+				if tt.text[token.Start:token.End] != token.Text {
+					t.Errorf("Tokenize() offsets %d:%d do not cover %q", token.Start, token.End, token.Text)
+				}
+				words = append(words, token.Text)
+			}
+			if !reflect.DeepEqual(words, tt.expected) {
+				t.Errorf("Tokenize() = %v, want %v", words, tt.expected)
+			}
+			if got := Words(tt.text); len(got) != len(tt.expected) {
+				t.Errorf("Words() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStopWords(t *testing.T) {
+	tests := []struct {
+		language string
+		word     string
+		expected bool
+	}{
+		{"norwegian-bokmal", "etter", true},
+		{"norwegian-bokmal", "mener", true},
+		{"norwegian-bokmal", "regjeringen", false},
+		{"english", "the", true},
+		{"english", "election", false},
+		{"german", "und", true},
+		{"swedish", "efter", true},
+		{"", "the", true},
+	}
+
+	for _, tt := range tests {
+		if got := For(tt.language).IsStopWord(tt.word); got != tt.expected {
+			t.Errorf("For(%q).IsStopWord(%q) = %v, want %v", tt.language, tt.word, got, tt.expected)
+		}
+	}
+}
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		language string
+		words    []string
+	}{
+		{"english", []string{"election", "elections"}},
+		{"english", []string{"policy", "policies"}},
+		{"norwegian-bokmal", []string{"valg", "valget", "valgene"}},
+		{"norwegian-bokmal", []string{"regjering", "regjeringen"}},
+		{"swedish", []string{"regering", "regeringen"}},
+		{"danish", []string{"regering", "regeringen"}},
+		{"german", []string{"wahl", "wahlen"}},
+		{"dutch", []string{"verkiezing", "verkiezingen"}},
+		{"finnish", []string{"talo", "talossa", "talon", "talot"}},
+		{"spanish", []string{"elección", "elecciones"}},
+		{"french", []string{"élection", "élections"}},
+	}
+
+	for _, tt := range tests {
+		a := For(tt.language)
+		want := a.Stem(tt.words[0])
+		for _, word := range tt.words[1:] {
+			if got := a.Stem(word); got != want {
+				t.Errorf("For(%q).Stem(%q) = %q, want %q like %q", tt.language, word, got, want, tt.words[0])
+			}
+		}
+	}
+
+	if got := For("english").Stem("bus"); got != "bus" {
+		t.Errorf("Stem(%q) = %q, want the short word unchanged", "bus", got)
+	}
+	if got := For("norwegian-bokmal").Stem("e39"); got != "e39" {
+		t.Errorf("Stem(%q) = %q, want words with digits unchanged", "e39", got)
+	}
+	if got := For("unknown").Stem("elections"); got != "elections" {
+		t.Errorf("Stem(%q) = %q, want no stemming for unknown languages", "elections", got)
+	}
+}
+
+func TestTerms(t *testing.T) {
+	terms := For("norwegian-bokmal").Terms("Regjeringen mener valget ble avgjort etter debatten")
+	want := For("norwegian-bokmal").Terms("regjering valg avgjort debatt")
+	if !reflect.DeepEqual(terms, want) {
+		t.Errorf("Terms() = %v, want %v", terms, want)
+	}
+}
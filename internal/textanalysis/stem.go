@@ -0,0 +1,114 @@
+package textanalysis
+
+import "strings"
+
+// Light stemmers remove the common inflectional endings of a language and
+// nothing more, so related forms share a stem ("valget", "valg") while the
+// stem stays recognisable. They never shorten a word below minStem runes.
+const minStem = 3
+
+// suffixStemmer removes the first of its suffixes that the word ends with.
+// Suffixes are listed longest first.
+type suffixStemmer []string
+
+func (suffixes suffixStemmer) stem(word string) string {
+	runes := Length(word)
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(word, suffix) && runes-Length(suffix) >= minStem {
+			return word[:len(word)-len(suffix)]
+		}
+	}
+	return word
+}
+
+var (
+	norwegianSuffixes = suffixStemmer{
+		"hetene", "hetens", "heten", "heter", "endes", "ende", "enes", "ene", "ane", "erne",
+		"ens", "ers", "ets", "het", "ast", "en", "ar", "er", "et", "as", "es", "a", "e", "s",
+	}
+	swedishSuffixes = suffixStemmer{
+		"heterna", "hetens", "arnas", "ernas", "ornas", "andes", "heten", "heter", "arna",
+		"erna", "orna", "ande", "arne", "aste", "aren", "ades", "erns", "ade", "are", "ern",
+		"ens", "het", "ast", "ad", "en", "ar", "er", "or", "as", "es", "at", "a", "e", "s",
+	}
+	danishSuffixes = suffixStemmer{
+		"erendes", "erende", "hedens", "erede", "heden", "heder", "endes", "ernes", "erens",
+		"erets", "ered", "ende", "erne", "eren", "erer", "heds", "enes", "eres", "eret", "hed",
+		"ene", "ere", "ens", "ers", "ets", "en", "er", "es", "et", "e", "s",
+	}
+	germanSuffixes  = suffixStemmer{"ern", "em", "en", "er", "es", "e", "s"}
+	dutchSuffixes   = suffixStemmer{"heden", "ene", "en", "se", "s", "e"}
+	finnishSuffixes = suffixStemmer{
+		"issa", "issä", "ista", "istä", "ineen", "ssa", "ssä", "sta", "stä", "lla", "llä",
+		"lta", "ltä", "lle", "ksi", "iin", "ien", "den", "tten", "en", "an", "än", "na", "nä",
+		"a", "ä", "t", "n",
+	}
+)
+
+// stemEnglish removes plural and possessive endings.
+func stemEnglish(word string) string {
+	n := Length(word)
+	switch {
+	case n > 4 && strings.HasSuffix(word, "ies") && !strings.HasSuffix(word, "eies") && !strings.HasSuffix(word, "aies"):
+		return word[:len(word)-3] + "y"
+	case n > 4 && (strings.HasSuffix(word, "sses") || strings.HasSuffix(word, "xes") ||
+		strings.HasSuffix(word, "ches") || strings.HasSuffix(word, "shes") || strings.HasSuffix(word, "zes")):
+		return word[:len(word)-2]
+	case n > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") &&
+		!strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// stemRomance removes the plural ending and the final gender vowel, as in
+// Spanish, Italian and Portuguese ("elecciones", "elección").
+func stemRomance(word string) string {
+	n := Length(word)
+	switch {
+	case n > 5 && strings.HasSuffix(word, "ciones"):
+		return word[:len(word)-len("ciones")] + "ción"
+	case n > 4 && strings.HasSuffix(word, "ões"):
+		return word[:len(word)-len("ões")] + "ão"
+	case n > 4 && strings.HasSuffix(word, "es"):
+		word = word[:len(word)-2]
+	case n > 3 && strings.HasSuffix(word, "s"):
+		word = word[:len(word)-1]
+	}
+	if Length(word) > minStem {
+		if last := word[len(word)-1]; last == 'a' || last == 'e' || last == 'i' || last == 'o' {
+			word = word[:len(word)-1]
+		}
+	}
+	return word
+}
+
+// stemFrench removes plural endings and a final e.
+func stemFrench(word string) string {
+	n := Length(word)
+	switch {
+	case n > 4 && strings.HasSuffix(word, "aux"):
+		return word[:len(word)-3] + "al"
+	case n > 3 && (strings.HasSuffix(word, "s") || strings.HasSuffix(word, "x")):
+		word = word[:len(word)-1]
+	}
+	if Length(word) > minStem && strings.HasSuffix(word, "e") {
+		word = word[:len(word)-1]
+	}
+	return word
+}
+
+var stemmers = map[string]func(string) string{
+	"english":           stemEnglish,
+	"norwegian-bokmal":  norwegianSuffixes.stem,
+	"norwegian-nynorsk": norwegianSuffixes.stem,
+	"swedish":           swedishSuffixes.stem,
+	"danish":            danishSuffixes.stem,
+	"german":            germanSuffixes.stem,
+	"dutch":             dutchSuffixes.stem,
+	"finnish":           finnishSuffixes.stem,
+	"french":            stemFrench,
+	"spanish":           stemRomance,
+	"italian":           stemRomance,
+	"portuguese":        stemRomance,
+}
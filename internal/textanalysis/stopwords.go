@@ -0,0 +1,136 @@
+package textanalysis
+
+import "strings"
+
+// Stop words per language tag ID. Besides function words the lists hold
+// words that are everywhere in news copy ("said", "sier", "mener") and
+// would otherwise crowd out real topics. Fragments left by splitting
+// contractions ("don", "isn") are included for English.
+var stopWordLists = map[string]string{
+	"english": `a about above after again against all also am an and any are aren as at be because been
+		before being below between both but by can cannot could couldn d did didn do does doesn doing don
+		down during each few for from further had hadn has hasn have haven having he her here hers herself
+		him himself his how i if in into is isn it its itself just ll let like m me more most mustn my
+		myself no nor not now of off on once one only or other ought our ours ourselves out over own re s
+		same say says said shan she should shouldn so some such t than that the their theirs them
+		themselves then there these they this those through to too two under until up ve very was wasn we
+		were weren what when where which while who whom why will with won would wouldn you your yours
+		yourself yourselves new time year years day days week weeks month months today tomorrow yesterday
+		later early earlier late latest recent recently get gets got make makes made may might must us
+		many much still even back first last told according`,
+
+	"norwegian-bokmal": `og i jeg det at en et den til er som på de med han av ikke der så var meg seg men
+		ett har om vi min mitt ha hadde hun nå over da ved fra du ut sin dem oss opp man kan hans hvor
+		eller hva skal selv sjøl her alle vil bli ble blitt kunne inn når være kom noen noe ville dere
+		deres kun ja etter ned skulle denne for deg si sine sitt mot å meget hvorfor dette disse uten
+		hvordan ingen din ditt blir samme hvilken hvilke sånn inni mellom vår hver hvem vors hvis både
+		bare enn fordi før mange også slik vært båe begge siden dei deira deires deim di då eit eitt
+		elles fram frå ho hoe hennar henne hennes her hjå ikkje inkje korleis korso kva kvar kvarhelst
+		kven kvi kvifor me medan mi mine mykje no nokon noka nokor noko nokre sia sidan so somt somme
+		um upp vart varte vere verte vore vors vort sier sa mener får fikk går gikk gjør gjorde nye ny
+		nytt år året dag dagen uke uka tirsdag onsdag torsdag fredag lørdag søndag mandag blant rundt
+		helt mer mest enda altså like`,
+
+	"norwegian-nynorsk": `og i eg det at ein eit den til er som på dei med han av ikkje ikkje der så var
+		meg seg men har om vi min mitt ha hadde ho no over då ved frå du ut sin dykk oss opp man kan hans
+		kvar eller kva skal sjølv her alle vil bli blei vorte kunne inn når vere kom nokon noko ville
+		dykkar kun ja etter ned skulle denne for deg si sine sitt mot å kvifor dette desse utan korleis
+		ingen din ditt blir same kven viss både berre enn fordi før mange òg slik vore sidan dei deira
+		deim di eitt elles fram hjå inkje medan mi mine mykje noka nokre sia somme um upp vart verte
+		vore seier sa meiner får fekk går gjekk gjer gjorde nye ny nytt år året dag dagen veke veka
+		blant rundt heilt meir mest enno altså`,
+
+	"swedish": `och det att i en jag hon som han på den med var sig för så till är men ett om hade de
+		av icke mig du henne då sin nu har inte hans honom skulle hennes där min man ej vid kunde något
+		från ut när efter upp vi dem vara vad över än dig kan sina här ha mot alla under någon eller
+		allt mycket sedan ju denna själv detta åt utan varit hur ingen mitt ni bli blev oss din dessa
+		några deras blir mina samma vilken er sådan vår blivit dess inom mellan sådant varför varje
+		vilka ditt vem vilket sitta sådana vart dina vars vårt våra ert era vilkas säger sade sa anser
+		får fick går gick gör gjorde nya ny nytt år året dag dagen vecka veckan också bara enligt`,
+
+	"danish": `og i jeg det at en den til er som på de med han af for ikke der var mig sig men et har om
+		vi min havde ham hun nu over da fra du ud sin dem os op man hans hvor eller hvad skal selv her
+		alle vil blev kunne ind når være dog noget ville jo deres efter ned skulle denne end dette mit
+		også under have dig anden hende mine alt meget sit sine vor mod disse hvis din nogle hos blive
+		mange ad bliver hendes været thi jer sådan siger sagde mener får fik går gik gør gjorde nye ny
+		nyt år året dag dagen uge ugen kun bare ifølge`,
+
+	"german": `aber alle allem allen aller alles als also am an ander andere anderem anderen anderer
+		anderes anderm andern anderr anders auch auf aus bei bin bis bist da damit dann der den des dem
+		die das dass daß derselbe derselben denselben desselben demselben dieselbe dieselben dasselbe
+		dazu dein deine deinem deinen deiner deines denn derer dessen dich dir du dies diese diesem
+		diesen dieser dieses doch dort durch ein eine einem einen einer eines einig einige einigem
+		einigen einiger einiges einmal er ihn ihm es etwas euer eure eurem euren eurer eures für gegen
+		gewesen hab habe haben hat hatte hatten hier hin hinter ich mich mir ihr ihre ihrem ihren ihrer
+		ihres euch im in indem ins ist jede jedem jeden jeder jedes jene jenem jenen jener jenes jetzt
+		kann kein keine keinem keinen keiner keines können könnte machen man manche manchem manchen
+		mancher manches mein meine meinem meinen meiner meines mit muss musste nach nicht nichts noch
+		nun nur ob oder ohne sehr sein seine seinem seinen seiner seines selbst sich sie ihnen sind so
+		solche solchem solchen solcher solches soll sollte sondern sonst über um und uns unsere unserem
+		unseren unser unseres unter viel vom von vor während war waren warst was weg weil weiter welche
+		welchem welchen welcher welches wenn werde werden wie wieder will wir wird wirst wo wollen wollte
+		würde würden zu zum zur zwar zwischen sagt sagte neue neuen neuer jahr jahre heute`,
+
+	"french": `au aux avec ce ces dans de des du elle en et eux il ils je la le les leur lui ma mais me
+		même mes moi mon ne nos notre nous on ou par pas pour qu que qui sa se ses son sur ta te tes toi
+		ton tu un une vos votre vous c d j l à m n s t y été étée étées étés étant suis es est sommes
+		êtes sont serai sera serons seront serais serait étais était étions étaient fus fut furent sois
+		soit soient ai as avons avez ont aurai aura aurons auront aurais aurait avais avait avions
+		avaient eu eut eurent aie ait aient plus selon après avant cette cet comme aussi dit déclaré
+		nouveau nouvelle nouveaux année ans jour jours tout tous toute toutes`,
+
+	"spanish": `de la que el en y a los del se las por un para con no una su al lo como más pero sus le
+		ya o este sí porque esta entre cuando muy sin sobre también me hasta hay donde quien desde todo
+		nos durante todos uno les ni contra otros ese eso ante ellos e esto mí antes algunos qué unos yo
+		otro otras otra él tanto esa estos mucho quienes nada muchos cual poco ella estar estas algunas
+		algo nosotros mi mis tú te ti tu tus ellas nosotras vosotros vosotras os mío mía míos mías tuyo
+		tuya tuyos tuyas suyo suya suyos suyas nuestro nuestra nuestros nuestras vuestro vuestra
+		vuestros vuestras esos esas estoy estás está estamos estáis están es son fue fueron ha han había
+		ser será sido dijo afirmó según nuevo nueva nuevos año años día días hoy tras`,
+
+	"italian": `ad al allo ai agli all agl alla alle con col coi da dal dallo dai dagli dall dagl dalla
+		dalle di del dello dei degli dell degl della delle in nel nello nei negli nell negl nella nelle
+		su sul sullo sui sugli sull sugl sulla sulle per tra contro io tu lui lei noi voi loro mio mia
+		miei mie tuo tua tuoi tue suo sua suoi sue nostro nostra nostri nostre vostro vostra vostri
+		vostre mi ti ci vi lo la li le gli ne il un uno una ma ed se perché anche come dov dove che chi
+		cui non più quale quanto quanti quanta quante quello quelli quella quelle questo questi questa
+		queste si tutto tutti a c e i l o è ha hanno era sono stato stata essere ha detto secondo nuovo
+		nuova anno anni giorno oggi dopo`,
+
+	"portuguese": `de a o que e do da em um para é com não uma os no se na por mais as dos como mas foi
+		ao ele das tem à seu sua ou ser quando muito há nos já está eu também só pelo pela até isso ela
+		entre era depois sem mesmo aos ter seus quem nas me esse eles estão você tinha foram essa num
+		nem suas meu às minha têm numa pelos elas havia seja qual será nós tenho lhe deles essas esses
+		pelas este fosse dele tu te vocês vos lhes meus minhas teu tua teus tuas nosso nossa nossos
+		nossas dela delas esta estes estas aquele aquela aqueles aquelas isto aquilo disse segundo novo
+		nova ano anos dia dias hoje após`,
+
+	"dutch": `de en van ik te dat die in een hij het niet zijn is was op aan met als voor had er maar om
+		hem dan zou of wat mijn men dit zo door over ze zich bij ook tot je mij uit der daar haar naar
+		heb hoe heeft hebben deze u want nog zal me zij nu ge geen omdat iets worden toch al waren veel
+		meer doen toen moet ben zonder kan hun dus alles onder ja eens hier wie werd altijd doch wordt
+		wezen kunnen ons zelf tegen na reeds wil kon niets uw iemand geweest andere zegt zei volgens
+		nieuwe nieuw jaar jaren dag vandaag`,
+
+	"finnish": `olla olen olet on olemme olette ovat ole oli olisi olisit olisin olisimme olisitte
+		olisivat olit olin olimme olitte olivat ollut olleet en et ei emme ette eivät minä minun minut
+		minua minussa minusta minuun minulla minulta minulle sinä sinun sinut sinua hän hänen hänet
+		häntä hänessä hänestä häneen hänellä häneltä hänelle me meidän meidät meitä te teidän teitä he
+		heidän heidät heitä tämä tämän tätä tässä tästä tähän tällä tältä tälle tänä tuo tuon tuota se
+		sen sitä siinä siitä siihen sillä siltä sille sinä ne niiden niitä niissä niistä niihin niillä
+		joka jonka jota jossa josta johon jolla jolta jolle mikä minkä mitä kuka kenen ketä että ja jos
+		koska kuin mutta niin sekä sillä tai vaan vai vaikka kanssa mukaan noin poikki yli kun niin nyt
+		itse myös sanoi uusi uuden vuosi vuoden päivä`,
+}
+
+var stopWords = func() map[string]map[string]bool {
+	sets := make(map[string]map[string]bool, len(stopWordLists))
+	for lang, list := range stopWordLists {
+		set := make(map[string]bool)
+		for _, word := range strings.Fields(list) {
+			set[word] = true
+		}
+		sets[lang] = set
+	}
+	return sets
+}()
@@ -0,0 +1,75 @@
+// Package textanalysis turns headlines and descriptions into comparable
+// terms: it splits text into words, recognises stop words and reduces words
+// to light stems, for each language the reader detects.
+package textanalysis
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token is one word of a text.
+type Token struct {
+	// Text is the word as written and Lower its lowercase form.
+	Text  string
+	Lower string
+	// Stem is the lowercase word with its inflection removed, and Stop
+	// reports whether it is a stop word. Both are only set by an Analyzer.
+	Stem string
+	Stop bool
+	// Start and End are the byte offsets of the word in the text.
+	Start int
+	End   int
+}
+
+// isWordRune reports whether r can be part of a word. Apostrophes, hyphens
+// and other punctuation always separate words, so "Norway's" yields
+// "Norway" and "s", and "Sør-Korea" yields "Sør" and "Korea".
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// Tokenize splits text into words.
+func Tokenize(text string) []Token {
+	var tokens []Token
+	start := -1
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, newToken(text, start, i))
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, newToken(text, start, len(text)))
+	}
+	return tokens
+}
+
+func newToken(text string, start, end int) Token {
+	word := text[start:end]
+	return Token{Text: word, Lower: strings.ToLower(word), Start: start, End: end}
+}
+
+// Words returns the words of text as written.
+func Words(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !isWordRune(r)
+	})
+}
+
+// HasDigit reports whether word contains a digit.
+func HasDigit(word string) bool {
+	return strings.IndexFunc(word, unicode.IsDigit) >= 0
+}
+
+// Length returns the number of characters in word.
+func Length(word string) int {
+	return utf8.RuneCountInString(word)
+}